@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+/*
+APIServer exposes a small HTTP API for operators and support tooling.
+
+Responsibilities:
+1. Serve machine-readable views of runtime state as JSON
+2. Apply a few runtime adjustments (e.g., log level) without a restart
+3. Graceful start/stop alongside the other components
+
+Endpoints:
+- GET  /failing:  file pairs whose most recent verification attempt failed
+- GET  /metrics:  runtime statistics in Prometheus text format (unless api.disableMetrics)
+- GET  /stats:    the same statistics with derived rates and duration percentiles as JSON
+- GET  /progress: files being hashed right now, with aggregate progress
+- GET  /readyz:   200 when files can be delivered, 503 while a destination is read-only or Redis is unreachable
+- GET  /manifests: files not listed in their folder's manifest, and listed files that never arrived
+- GET  /loglevel: current logging level
+- POST /loglevel?level=DEBUG: change the logging level immediately
+- GET  /destinations: current verified/DLQ/quarantine/empty sidecar folders
+- POST /destinations: switch folders (JSON body, omitted fields are kept)
+- GET  /pool:     worker count, job queue length/capacity and each worker's current file
+- PATCH /pool:    resize the worker pool (JSON body {"workers": N})
+- GET  /maintenance: maintenance windows, whether verification is paused by one, and the next window
+- GET  /events:   verification and stats records as they happen, over a websocket or as NDJSON (events.go)
+
+Does NOT:
+- Track file pairs (that's file_tracker.go)
+*/
+
+// APIServer serves the HTTP admin API
+type APIServer struct {
+	server       *http.Server
+	fileTracker  *FileTracker
+	statsTracker *StatsTracker
+	progress     *ProgressRegistry
+	hashLimiter  *HashLimiter
+	destinations *Destinations
+	manifests    *ManifestRegistry    // nil = manifests disabled
+	redis        *RedisHashProvider   // nil = Redis is not the hash source
+	events       *EventHub            // nil = /events disabled
+	maintenance  *MaintenanceSchedule // nil = no maintenance windows
+	workerPool   *WorkerPoolManager
+	logLevel     *LogLevel
+}
+
+// NewAPIServer creates a new API server listening on the given address
+func NewAPIServer(listenAddress string, fileTracker *FileTracker, statsTracker *StatsTracker, progress *ProgressRegistry, hashLimiter *HashLimiter, destinations *Destinations, manifests *ManifestRegistry, redis *RedisHashProvider, events *EventHub, maintenance *MaintenanceSchedule, metrics bool, workerPool *WorkerPoolManager, logLevel *LogLevel) *APIServer {
+	s := &APIServer{
+		fileTracker:  fileTracker,
+		statsTracker: statsTracker,
+		progress:     progress,
+		hashLimiter:  hashLimiter,
+		destinations: destinations,
+		manifests:    manifests,
+		redis:        redis,
+		events:       events,
+		maintenance:  maintenance,
+		workerPool:   workerPool,
+		logLevel:     logLevel,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/failing", s.handleFailing)
+	mux.HandleFunc("/loglevel", s.handleLogLevel)
+	if metrics {
+		mux.HandleFunc("/metrics", s.handleMetrics)
+	}
+	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("/progress", s.handleProgress)
+	mux.HandleFunc("/destinations", s.handleDestinations)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/manifests", s.handleManifests)
+	mux.HandleFunc("/pool", s.handlePool)
+	mux.HandleFunc("/events", s.handleEvents)
+	mux.HandleFunc("/maintenance", s.handleMaintenance)
+
+	s.server = &http.Server{
+		Addr:              listenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if events != nil {
+		// Shutdown waits for streaming handlers and ignores websockets, end both
+		s.server.RegisterOnShutdown(func() { events.Close() })
+	}
+
+	return s
+}
+
+// Start begins serving requests in the background
+func (s *APIServer) Start() {
+	go func() {
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "[API] Server error: %v\n", err)
+		}
+	}()
+
+	if s.logLevel.Get() == "DEBUG" || s.logLevel.Get() == "INFO" {
+		fmt.Printf("[API] Listening on %s\n", s.server.Addr)
+	}
+}
+
+// Stop gracefully shuts down the server
+func (s *APIServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "[API] Failed to shut down cleanly: %v\n", err)
+	}
+
+	if s.logLevel.Get() == "DEBUG" || s.logLevel.Get() == "INFO" {
+		fmt.Println("[API] Stopped")
+	}
+}
+
+// handleFailing returns all file pairs currently in retry-failure state
+func (s *APIServer) handleFailing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, s.fileTracker.GetFailingFiles())
+}
+
+// handleMetrics returns runtime statistics in Prometheus text format
+func (s *APIServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w, s.statsTracker.GetStatistics(), s.progress.Snapshot(), s.hashLimiter, s.destinations.ReadOnly() != "", s.maintenance.Paused())
+}
+
+// StatsReport is the JSON form of the runtime statistics for GET /stats
+// Durations are in seconds; percentiles cover the most recent files processed
+type StatsReport struct {
+	TotalProcessed         int64     `json:"totalProcessed"`
+	SuccessCount           int64     `json:"successCount"`
+	FailureCount           int64     `json:"failureCount"`
+	PendingCount           int64     `json:"pendingCount"`
+	PendingDrift           int64     `json:"pendingDrift"`
+	BytesVerified          int64     `json:"bytesVerified"`
+	PurgedFiles            int64     `json:"purgedFiles"`
+	PurgedBytes            int64     `json:"purgedBytes"`
+	MispairedCount         int64     `json:"mispairedCount"`
+	QueueStalled           int64     `json:"queueStalled"`
+	QueueWaitMaxSeconds    float64   `json:"queueWaitMaxSeconds"`
+	SuccessRate            float64   `json:"successRate"`    // Percent
+	FailureRate            float64   `json:"failureRate"`    // Percent
+	ProcessingRate         float64   `json:"processingRate"` // Files per second
+	UptimeSeconds          float64   `json:"uptimeSeconds"`
+	TotalDurationSeconds   float64   `json:"totalDurationSeconds"`
+	AverageDurationSeconds float64   `json:"averageDurationSeconds"`
+	P50DurationSeconds     float64   `json:"p50DurationSeconds"`
+	P90DurationSeconds     float64   `json:"p90DurationSeconds"`
+	P99DurationSeconds     float64   `json:"p99DurationSeconds"`
+	StartTime              time.Time `json:"startTime"`
+	LastHeartbeat          time.Time `json:"lastHeartbeat,omitzero"`
+}
+
+// handleStats returns the runtime statistics as JSON
+func (s *APIServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats := s.statsTracker.GetStatistics()
+	percentiles := s.statsTracker.GetDurationPercentiles(50, 90, 99)
+	writeJSON(w, StatsReport{
+		TotalProcessed:         stats.TotalProcessed,
+		SuccessCount:           stats.SuccessCount,
+		FailureCount:           stats.FailureCount,
+		PendingCount:           stats.PendingCount,
+		PendingDrift:           stats.PendingDrift,
+		BytesVerified:          stats.TotalBytesVerified,
+		PurgedFiles:            stats.PurgedFiles,
+		PurgedBytes:            stats.PurgedBytes,
+		MispairedCount:         stats.MispairedCount,
+		QueueStalled:           stats.QueueStalled,
+		QueueWaitMaxSeconds:    stats.QueueWaitMax.Seconds(),
+		SuccessRate:            s.statsTracker.GetSuccessRate(),
+		FailureRate:            s.statsTracker.GetFailureRate(),
+		ProcessingRate:         s.statsTracker.GetProcessingRate(),
+		UptimeSeconds:          s.statsTracker.GetUptime().Seconds(),
+		TotalDurationSeconds:   stats.TotalDuration.Seconds(),
+		AverageDurationSeconds: s.statsTracker.GetAverageDuration().Seconds(),
+		P50DurationSeconds:     percentiles[0].Seconds(),
+		P90DurationSeconds:     percentiles[1].Seconds(),
+		P99DurationSeconds:     percentiles[2].Seconds(),
+		StartTime:              stats.StartTime,
+		LastHeartbeat:          stats.LastHeartbeat,
+	})
+}
+
+// handleReadyz reports whether verified files can currently be delivered
+// Orchestrators use it as a readiness probe; it fails while a destination is read-only
+func (s *APIServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if folder := s.destinations.ReadOnly(); folder != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		writeJSON(w, map[string]interface{}{
+			"ready":  false,
+			"reason": fmt.Sprintf("%s is on a read-only file system", folder),
+		})
+		return
+	}
+	if s.redis != nil {
+		if err := s.redis.Unavailable(); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			writeJSON(w, map[string]interface{}{
+				"ready":  false,
+				"reason": fmt.Sprintf("Redis is unreachable: %v", err),
+			})
+			return
+		}
+	}
+
+	writeJSON(w, map[string]interface{}{"ready": true})
+}
+
+// handleManifests returns the discrepancies between manifests and the files that arrived
+func (s *APIServer) handleManifests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.manifests == nil {
+		http.Error(w, "manifests are not enabled", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, s.manifests.Report())
+}
+
+// handleMaintenance returns the maintenance schedule and whether it pauses verification now
+func (s *APIServer) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.maintenance == nil {
+		http.Error(w, "maintenance windows are not configured", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, s.maintenance.Status())
+}
+
+// handleEvents streams verification and stats records to the client
+func (s *APIServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if s.events == nil {
+		http.Error(w, "events are not enabled", http.StatusNotFound)
+		return
+	}
+
+	s.events.ServeHTTP(w, r)
+}
+
+// handleProgress returns the files currently being hashed
+func (s *APIServer) handleProgress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, s.progress.Snapshot())
+}
+
+// handleLogLevel reports or changes the shared logging level
+func (s *APIServer) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		level := r.URL.Query().Get("level")
+		if err := s.logLevel.Set(level); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Printf("[API] Log level changed to %s\n", level)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, map[string]string{"level": s.logLevel.Get()})
+}
+
+// handleDestinations reports or replaces the destination folders
+func (s *APIServer) handleDestinations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		// Start from the current folders so a body may change just one of them
+		folders := s.destinations.Get()
+		if err := json.NewDecoder(r.Body).Decode(&folders); err != nil {
+			http.Error(w, fmt.Sprintf("invalid JSON body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := s.destinations.Update(folders); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Printf("[API] Destinations changed: verified=%s dlq=%s quarantine=%s emptySidecar=%s mispaired=%s\n",
+			folders.Verified, folders.DLQ, folders.Quarantine, folders.EmptySidecar, folders.Mispaired)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, s.destinations.Get())
+}
+
+// handlePool reports the worker pool or changes its number of workers
+func (s *APIServer) handlePool(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch:
+		var body struct {
+			Workers int `json:"workers"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, fmt.Sprintf("invalid JSON body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := s.workerPool.SetWorkers(body.Workers); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Printf("[API] Worker count changed to %d\n", body.Workers)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, s.workerPool.Status())
+}
+
+// writeJSON encodes a value as the JSON response body
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "[API] Failed to encode response: %v\n", err)
+	}
+}
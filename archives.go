@@ -0,0 +1,222 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+Archive integrity checks verify self-checking archives that arrive without a
+sidecar (verification.archiveIntegrity patterns), and archives that carry
+their sidecar inside (verification.embeddedChecksum patterns): a checksum
+member (default "CHECKSUM") holding the hash of the payload member next to it.
+
+Responsibilities:
+1. Check that a zip archive is structurally valid (readable central directory)
+2. Decompress every member and compare it with the CRC-32 stored for it
+3. Report the first member that fails, by name
+4. For an embedded checksum, read the checksum member ("<hash>" or
+   "<hash>  <payload member>") and stream the payload member through the
+   hasher; without a name the payload is the archive's only other file
+
+A truncated or corrupt archive fails verification and is retried like a hash
+mismatch until the retry timeout, then moved to the DLQ.
+
+Does NOT:
+- Check anything beyond the archive's own checksums (an archive rebuilt with
+  different content but valid CRCs passes; use a sidecar for that)
+- Extract members to disk
+*/
+
+// ErrArchiveCorrupt is returned when an archive or one of its members fails its integrity check
+var ErrArchiveCorrupt = errors.New("archive integrity check failed")
+
+// ErrChecksumMemberMissing is returned when an archive lacks its embedded checksum or the payload it names
+var ErrChecksumMemberMissing = errors.New("embedded checksum member missing")
+
+// maxChecksumMember bounds how much of a checksum member is read
+const maxChecksumMember = 64 * 1024
+
+// VerifyZipArchive checks every member of a zip archive against its stored CRC-32
+// progress (may be nil) is told the compressed size of each member as it is checked
+func VerifyZipArchive(archivePath string, bufferSize int, progress ProgressFunc) error {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrArchiveCorrupt, err)
+	}
+	defer archive.Close()
+
+	buffer := make([]byte, bufferSize)
+	for _, member := range archive.File {
+		if err := verifyZipMember(member, buffer); err != nil {
+			return fmt.Errorf("%w: member %q: %v", ErrArchiveCorrupt, member.Name, err)
+		}
+		if progress != nil {
+			if err := progress(int(member.CompressedSize64)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifyZipMember decompresses a member; the zip reader checks its CRC-32 at the end
+func verifyZipMember(member *zip.File, buffer []byte) error {
+	r, err := member.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// Directories and other empty members have nothing to check
+	if _, err := io.CopyBuffer(io.Discard, r, buffer); err != nil {
+		return err
+	}
+	return nil
+}
+
+// VerifyZipEmbeddedChecksum verifies the payload member of a zip archive against the
+// checksum member shipped in the same archive, without extracting either to disk
+// progress (may be nil) is told every read of the archive file
+// Returns computed hash, expected hash (both of the payload member), and any error
+func VerifyZipEmbeddedChecksum(archivePath, checksumMember string, bufferSize int, hashEncoding, algo string, progress ProgressFunc) (string, string, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get archive info: %w", err)
+	}
+	var reader io.ReaderAt = file
+	if progress != nil {
+		reader = &progressReaderAt{r: file, progress: progress}
+	}
+	archive, err := zip.NewReader(reader, info.Size())
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrArchiveCorrupt, err)
+	}
+
+	var checksum *zip.File
+	for _, member := range archive.File {
+		if member.Name == checksumMember {
+			checksum = member
+			break
+		}
+	}
+	if checksum == nil {
+		return "", "", fmt.Errorf("%w: archive has no %q member", ErrChecksumMemberMissing, checksumMember)
+	}
+
+	expected, payloadName, err := readChecksumMember(checksum, hashEncoding, algo)
+	if err != nil {
+		return "", "", err
+	}
+	payload, err := findPayloadMember(archive, checksumMember, payloadName)
+	if err != nil {
+		return "", expected, err
+	}
+
+	r, err := payload.Open()
+	if err != nil {
+		return "", expected, fmt.Errorf("%w: member %q: %v", ErrArchiveCorrupt, payload.Name, err)
+	}
+	defer r.Close()
+
+	// The zip reader also checks the member's CRC-32 at the end
+	computed, err := hashReader(r, bufferSize, algo)
+	if err != nil {
+		return "", expected, fmt.Errorf("%w: member %q: %v", ErrArchiveCorrupt, payload.Name, err)
+	}
+
+	if !digestsEqual(computed, expected) {
+		return computed, expected, fmt.Errorf("%w: payload member %q", ErrHashMismatch, payload.Name)
+	}
+	return computed, expected, nil
+}
+
+// readChecksumMember parses a checksum member: one "<hash>" or "<hash>  <payload member>" line
+// Returns the normalized expected hash and the payload member it names (empty = not named)
+func readChecksumMember(member *zip.File, hashEncoding, algo string) (string, string, error) {
+	r, err := member.Open()
+	if err != nil {
+		return "", "", fmt.Errorf("%w: member %q: %v", ErrArchiveCorrupt, member.Name, err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, maxChecksumMember))
+	if err != nil {
+		return "", "", fmt.Errorf("%w: member %q: %v", ErrArchiveCorrupt, member.Name, err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	switch len(lines) {
+	case 0:
+		return "", "", fmt.Errorf("%w: %q is empty", ErrEmptySidecar, member.Name)
+	case 1:
+	default:
+		return "", "", fmt.Errorf("%w: %q holds %d checksums, expected one", ErrInvalidExpectedHash, member.Name, len(lines))
+	}
+
+	fields := strings.Fields(lines[0])
+	hash, err := parseDigest(fields[0], hashEncoding, algo)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %q: %v", ErrInvalidExpectedHash, member.Name, err)
+	}
+	// The name may carry sha256sum's binary-mode marker
+	return hash, strings.TrimPrefix(strings.Join(fields[1:], " "), "*"), nil
+}
+
+// findPayloadMember returns the member named by the checksum, or the only other file in the archive
+func findPayloadMember(archive *zip.Reader, checksumMember, payloadName string) (*zip.File, error) {
+	var candidates []*zip.File
+	for _, member := range archive.File {
+		if member.Name == checksumMember || member.FileInfo().IsDir() {
+			continue
+		}
+		if payloadName != "" && member.Name == payloadName {
+			return member, nil
+		}
+		candidates = append(candidates, member)
+	}
+
+	switch {
+	case payloadName != "":
+		return nil, fmt.Errorf("%w: payload %q named in %q is not in the archive", ErrChecksumMemberMissing, payloadName, checksumMember)
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("%w: archive holds no payload next to %q", ErrChecksumMemberMissing, checksumMember)
+	case len(candidates) > 1:
+		return nil, fmt.Errorf("%w: archive holds %d files next to %q, which does not name its payload", ErrChecksumMemberMissing, len(candidates), checksumMember)
+	}
+	return candidates[0], nil
+}
+
+// progressReaderAt reports every read of an archive to a ProgressFunc
+type progressReaderAt struct {
+	r        io.ReaderAt
+	progress ProgressFunc
+}
+
+// ReadAt reads from the underlying file and reports the bytes read
+func (p *progressReaderAt) ReadAt(buf []byte, offset int64) (int, error) {
+	n, err := p.r.ReadAt(buf, offset)
+	if n > 0 {
+		if stop := p.progress(n); stop != nil {
+			return n, stop
+		}
+	}
+	return n, err
+}
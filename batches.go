@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+BatchTracker groups data files into logical batches and reports each batch
+once all of its files have been processed.
+
+A file's batch is its subfolder below the source folder (groupBy: subfolder,
+files directly in the source folder belong to no batch) or the text captured
+from its name by output.batches.pattern (groupBy: pattern, the named group
+"batch" or else the first group; names that don't match belong to no batch).
+
+Responsibilities:
+1. Count verified and failed files (and their bytes) per batch as workers finish them
+2. Decide a batch is complete when none of its files is still tracked (other
+   than ones left in place on a collision) and no file of it has finished for
+   completeAfter, so files still being uploaded are not missed
+3. Log a summary for each completed batch and append it to the batch CSV
+
+Does NOT:
+- Hold files back until their batch is complete (files are delivered as verified)
+- Remember completed batches: a file arriving later starts a new batch of the same name
+*/
+
+// Batch grouping modes
+const (
+	BatchBySubfolder = "subfolder"
+	BatchByPattern   = "pattern"
+)
+
+// BatchSummary describes a completed batch
+type BatchSummary struct {
+	Batch       string
+	Verified    int
+	Failed      int
+	Skipped     int      // Left in the source because the destination already existed
+	TotalBytes  int64    // Bytes of the verified and failed files
+	FailedFiles []string // Names of the failed data files
+	FirstDone   time.Time
+	CompletedAt time.Time
+}
+
+// BatchTracker tracks batch membership and completion
+type BatchTracker struct {
+	mutex         sync.Mutex
+	groupBy       string
+	pattern       *regexp.Regexp
+	sourceFolder  string
+	completeAfter time.Duration
+	batches       map[string]*batchState
+	file          *os.File // Batch CSV (nil = log only)
+	writer        *csv.Writer
+	logLevel      *LogLevel
+}
+
+// batchState is a batch that has not completed yet
+type batchState struct {
+	summary      BatchSummary
+	lastFinished time.Time
+}
+
+// NewBatchTracker creates a batch tracker (cfg.File empty = summaries are only logged)
+func NewBatchTracker(cfg BatchConfig, sourceFolder string, logLevel *LogLevel) (*BatchTracker, error) {
+	bt := &BatchTracker{
+		groupBy:       cfg.GroupBy,
+		sourceFolder:  sourceFolder,
+		completeAfter: cfg.CompleteAfter,
+		batches:       make(map[string]*batchState),
+		logLevel:      logLevel,
+	}
+
+	// The pattern was already checked by validateConfig
+	if cfg.GroupBy == BatchByPattern {
+		bt.pattern = regexp.MustCompile(cfg.Pattern)
+	}
+
+	if cfg.File != "" {
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open batch CSV file: %w", err)
+		}
+		bt.file = file
+		bt.writer = csv.NewWriter(file)
+
+		if info, err := file.Stat(); err == nil && info.Size() == 0 {
+			bt.writer.Write([]string{"Completed_At", "Batch", "Verified", "Failed", "Skipped", "Total_Bytes", "Duration_Seconds", "Failed_Files"})
+			bt.writer.Flush()
+		}
+	}
+
+	return bt, nil
+}
+
+// batchOf returns the batch a data file belongs to ("" = none)
+func (bt *BatchTracker) batchOf(dataFilePath string) string {
+	if bt.groupBy == BatchByPattern {
+		match := bt.pattern.FindStringSubmatch(filepath.Base(dataFilePath))
+		if match == nil {
+			return ""
+		}
+		if index := bt.pattern.SubexpIndex("batch"); index > 0 {
+			return match[index]
+		}
+		return match[1]
+	}
+
+	rel, err := filepath.Rel(bt.sourceFolder, filepath.Dir(dataFilePath))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// RecordResult counts a data file that was verified or given up on
+func (bt *BatchTracker) RecordResult(filePair FilePair, success bool) {
+	batch := bt.batchOf(filePair.DataFilePath)
+	if batch == "" {
+		return
+	}
+
+	bt.mutex.Lock()
+	defer bt.mutex.Unlock()
+
+	now := time.Now()
+	state, exists := bt.batches[batch]
+	if !exists {
+		state = &batchState{summary: BatchSummary{Batch: batch, FirstDone: now}}
+		bt.batches[batch] = state
+	}
+
+	if success {
+		state.summary.Verified++
+	} else {
+		state.summary.Failed++
+		state.summary.FailedFiles = append(state.summary.FailedFiles, filePair.DataFile)
+	}
+	state.summary.TotalBytes += filePair.DataSize
+	state.lastFinished = now
+}
+
+// ReportCompleted reports and forgets every batch that has completed
+// tracked is the list of file pairs still in the tracker
+func (bt *BatchTracker) ReportCompleted(tracked []FilePair) {
+	// Count the files of each batch that are still waiting or left in place
+	pending := make(map[string]int)
+	skipped := make(map[string]int)
+	for _, pair := range tracked {
+		batch := bt.batchOf(pair.dataPath())
+		if batch == "" {
+			continue
+		}
+		if pair.Skipped {
+			skipped[batch]++
+		} else {
+			pending[batch]++
+		}
+	}
+
+	bt.mutex.Lock()
+	defer bt.mutex.Unlock()
+
+	now := time.Now()
+	for batch, state := range bt.batches {
+		if pending[batch] > 0 || now.Sub(state.lastFinished) < bt.completeAfter {
+			continue
+		}
+
+		summary := state.summary
+		summary.Skipped = skipped[batch]
+		summary.CompletedAt = now
+		delete(bt.batches, batch)
+
+		bt.report(summary)
+	}
+}
+
+// report logs a completed batch and appends it to the batch CSV
+func (bt *BatchTracker) report(summary BatchSummary) {
+	if summary.Failed > 0 {
+		if bt.logLevel.Get() == "WARN" || bt.logLevel.Get() == "DEBUG" {
+			fmt.Fprintf(os.Stderr, "[Batch] %s complete with failures: %d verified, %d failed %v, %d skipped, %d bytes\n",
+				summary.Batch, summary.Verified, summary.Failed, summary.FailedFiles, summary.Skipped, summary.TotalBytes)
+		}
+	} else if bt.logLevel.Get() == "DEBUG" || bt.logLevel.Get() == "INFO" {
+		fmt.Printf("[Batch] %s verified: %d files, %d skipped, %d bytes\n",
+			summary.Batch, summary.Verified, summary.Skipped, summary.TotalBytes)
+	}
+
+	if bt.writer == nil {
+		return
+	}
+
+	record := []string{
+		summary.CompletedAt.Format("2006-01-02 15:04:05"),
+		escapeControlChars(summary.Batch),
+		fmt.Sprintf("%d", summary.Verified),
+		fmt.Sprintf("%d", summary.Failed),
+		fmt.Sprintf("%d", summary.Skipped),
+		fmt.Sprintf("%d", summary.TotalBytes),
+		fmt.Sprintf("%.0f", summary.CompletedAt.Sub(summary.FirstDone).Seconds()),
+		escapeControlChars(strings.Join(summary.FailedFiles, ";")),
+	}
+	bt.writer.Write(record)
+	bt.writer.Flush()
+	if err := bt.writer.Error(); err != nil {
+		fmt.Fprintf(os.Stderr, "[Batch] Failed to write batch summary: %v\n", err)
+	}
+}
+
+// Close closes the batch CSV
+func (bt *BatchTracker) Close() error {
+	bt.mutex.Lock()
+	defer bt.mutex.Unlock()
+
+	if bt.file == nil {
+		return nil
+	}
+	bt.writer.Flush()
+	return bt.file.Close()
+}
@@ -7,7 +7,7 @@
 #   1. Builds the Go application with embedded version information
 #   2. Calculates SHA256 hash of the binary
 #   3. Rebuilds with the hash embedded
-#   4. Updates the release notes YAML file
+#   4. Updates the release notes YAML file and embeds its SHA256 hash
 #   5. Copies files to deployment directory
 #   6. Tests the deployment package
 #   7. Cleans up project root
@@ -160,6 +160,26 @@ print_info "  - version:  $VERSION"
 print_info "  - datetime: $BUILDTIME"
 print_info "  - build_id: $BUILD_ID"
 
+# Embed the hash of the final release notes so they cannot be edited to match
+# a different binary (build_id above is unaffected by this rebuild)
+RN_HASH=$(sha256sum "$RELEASE_NOTES_PATH" | awk '{print $1}')
+print_info "Release notes hash (SHA256): $RN_HASH"
+
+go build -ldflags "\
+    -X main.release=$RELEASE \
+    -X main.version=$VERSION \
+    -X main.buildTime=$BUILDTIME \
+    -X main.buildID=$BUILD_ID \
+    -X main.releaseNotesHash=$RN_HASH" \
+    -o "$BINARY_NAME"
+
+if [ ! -f "$APP_EXE" ]; then
+    print_error "Rebuild with release notes hash failed - binary not created"
+    exit 1
+fi
+
+print_info "Rebuilt binary with release notes hash embedded"
+
 # ============================================================================
 # STEP 5: CREATE DEPLOYMENT PACKAGE
 # ============================================================================
@@ -334,6 +354,7 @@ echo "  Release:     $RELEASE"
 echo "  Version:     $VERSION"
 echo "  Build Time:  $BUILDTIME"
 echo "  Build ID:    $BUILD_ID"
+echo "  RN Hash:     $RN_HASH"
 echo "======================================================================"
 echo ""
 echo "Project root: $PROJECT_ROOT"
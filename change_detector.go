@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+/*
+ChangeDetector decides cheaply whether a data file left in the source folder
+after verification (a delivery skipped by destination.onCollision: skip) has
+changed since, and must be verified again (verification.changeDetector).
+
+When such a pair is left in place, its fingerprint is recorded: size,
+modification time and an xxhash of a few blocks sampled evenly across the
+file (always the first and the last). On every later scan:
+
+- same size and modification time: unchanged, nothing is read
+- another size: changed, the pair is verified again
+- same size, another modification time: the blocks are sampled again and
+  the pair is verified again only if they differ; otherwise the new
+  modification time is recorded (e.g. a file merely touched or copied over
+  with identical content)
+
+Correctness caveats: the samples cover only part of a large file. A rewrite
+of the same size that changes bytes between the sampled blocks is not seen
+(the file keeps its last verdict), and neither is any rewrite that restores
+the size and modification time. Use it only where a full SHA256 on every
+touch is too expensive and such rewrites are not a concern.
+
+Does NOT:
+- Apply to pairs delivered (moved) out of the source folder
+- Replace the verification itself: a change detected here is verified with
+  a full hash against the sidecar
+*/
+
+// Change detector defaults
+const (
+	defaultChangeSamples    = 8
+	defaultChangeSampleSize = 64 << 10 // 64 KiB
+)
+
+// ChangeDetector samples data files to detect content changes without hashing them in full
+type ChangeDetector struct {
+	samples    int
+	sampleSize int64
+	logLevel   *LogLevel
+}
+
+// ContentFingerprint is the cheap change signal of a data file
+type ContentFingerprint struct {
+	Size    int64
+	ModTime time.Time
+	Sample  uint64 // xxhash of the sampled blocks
+}
+
+// NewChangeDetector creates a change detector sampling cfg.Samples blocks of cfg.SampleSize bytes
+func NewChangeDetector(cfg ChangeDetectorConfig, logLevel *LogLevel) *ChangeDetector {
+	return &ChangeDetector{samples: cfg.Samples, sampleSize: int64(cfg.SampleSize), logLevel: logLevel}
+}
+
+// Fingerprint samples a data file
+func (cd *ChangeDetector) Fingerprint(path string) (*ContentFingerprint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	sample, err := cd.sample(file, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample %s: %w", path, err)
+	}
+	return &ContentFingerprint{Size: info.Size(), ModTime: info.ModTime(), Sample: sample}, nil
+}
+
+// sample hashes the size and evenly spaced blocks of a file (all of it when small)
+func (cd *ChangeDetector) sample(file *os.File, size int64) (uint64, error) {
+	hasher := xxhash.New()
+	binary.Write(hasher, binary.BigEndian, size)
+
+	if size <= int64(cd.samples)*cd.sampleSize {
+		_, err := io.Copy(hasher, file)
+		return hasher.Sum64(), err
+	}
+
+	buffer := make([]byte, cd.sampleSize)
+	last := size - cd.sampleSize
+	for i := 0; i < cd.samples; i++ {
+		offset := last * int64(i) / int64(cd.samples-1)
+		if _, err := file.ReadAt(buffer, offset); err != nil {
+			return 0, err
+		}
+		hasher.Write(buffer)
+	}
+	return hasher.Sum64(), nil
+}
+
+// Changed reports whether a data file differs from its fingerprint, sampling it only when
+// its size is the same but its modification time is not; the second result is the
+// fingerprint to keep when it is unchanged (nil = keep the old one)
+func (cd *ChangeDetector) Changed(path string, old *ContentFingerprint, size int64, modTime time.Time) (bool, *ContentFingerprint) {
+	if size == old.Size && modTime.Equal(old.ModTime) {
+		return false, nil
+	}
+	if size != old.Size {
+		return true, nil
+	}
+
+	current, err := cd.Fingerprint(path)
+	if err != nil {
+		// Unknown is treated as changed, the verification will tell
+		if cd.logLevel.Get() == "WARN" || cd.logLevel.Get() == "DEBUG" {
+			fmt.Fprintf(os.Stderr, "[Scanner] Change detector: %v\n", err)
+		}
+		return true, nil
+	}
+	if current.Size != old.Size || current.Sample != old.Sample {
+		return true, nil
+	}
+	return false, current
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/*
+CheckConfigCommand implements the "check-config" subcommand, which validates a
+configuration file the way the service would at startup, e.g. in a CI
+pipeline before deploying it:
+
+	go-filesha-verifier check-config --config /etc/filesha/config.yaml
+	go-filesha-verifier check-config --config config.yaml --profile prod
+
+The folders named in the configuration usually only exist on the hosts it is
+deployed to, so the source folder is only checked with --check-folders.
+
+Exit codes: 0 valid, 1 invalid or unreadable, 2 usage error.
+
+Does NOT:
+- Create the destination folders
+- Start any of the long-running components, or connect to a database,
+  Redis, Kafka or syslog endpoint named in the configuration
+*/
+
+// Exit codes of the check-config subcommand
+const (
+	checkConfigExitValid   = 0
+	checkConfigExitInvalid = 1
+	checkConfigExitUsage   = 2
+)
+
+// runCheckConfigCommand runs the check-config subcommand and returns the process exit code
+func runCheckConfigCommand(args []string) int {
+	flags := flag.NewFlagSet("check-config", flag.ContinueOnError)
+	configFile := flags.String("config", "config.yaml", "Path to configuration file")
+	profile := flags.String("profile", "", "Merge the profile's overrides onto the configuration file (e.g. prod reads config.prod.yaml)")
+	checkFolders := flags.Bool("check-folders", false, "Also check that the source folder exists on this host")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s check-config [--config path] [--profile name] [--check-folders]\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return checkConfigExitUsage
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return checkConfigExitUsage
+	}
+
+	config, err := ParseConfig(*configFile, *profile)
+	if err == nil && *checkFolders {
+		if err = checkSourceFolder(config); err != nil {
+			err = fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "INVALID %s: %v\n", *configFile, err)
+		return checkConfigExitInvalid
+	}
+
+	fmt.Printf("OK %s\n", strings.Join(config.files, " + "))
+	return checkConfigExitValid
+}
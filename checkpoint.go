@@ -0,0 +1,246 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*
+Checkpointer lets the hashing of very large files survive a restart.
+
+Responsibilities:
+1. While hashing a file of at least minSize bytes, persist the hasher's
+   intermediate state and byte offset every interval bytes
+   (verification.checkpoint.folder, one JSON file per data file)
+2. Resume from the last checkpoint when the same file is hashed again, but
+   only if its size and modification time are unchanged; otherwise start over
+3. Delete a file's checkpoint once its hash is complete, and at startup
+   delete checkpoints of files that are gone or have changed
+
+Only algorithms whose hasher implements encoding.BinaryMarshaler can be
+checkpointed (all supported ones do); others are simply hashed from the start.
+
+Does NOT:
+- Decide whether a file passes (that's sha_verifier.go)
+- Checkpoint streams without a file behind them (verify subcommand stdin)
+*/
+
+// checkpointSuffix is the extension of checkpoint files
+const checkpointSuffix = ".checkpoint.json"
+
+// Checkpointer saves and restores intermediate hash states
+type Checkpointer struct {
+	folder   string
+	minSize  int64 // Smaller files are always hashed in one go
+	interval int64 // Bytes hashed between two checkpoints
+}
+
+// hashCheckpoint is the persisted state of an interrupted hash
+type hashCheckpoint struct {
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"modTime"`
+	Algorithm string    `json:"algorithm"`
+	Offset    int64     `json:"offset"`
+	State     []byte    `json:"state"` // Output of the hasher's MarshalBinary
+}
+
+// NewCheckpointer creates a checkpointer storing its files in folder
+func NewCheckpointer(folder string, minSize, interval int64) (*Checkpointer, error) {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint folder %s: %w", folder, err)
+	}
+
+	return &Checkpointer{
+		folder:   folder,
+		minSize:  minSize,
+		interval: interval,
+	}, nil
+}
+
+// checkpointPath returns where the checkpoint of a data file is stored
+func (c *Checkpointer) checkpointPath(dataFilePath string) string {
+	sum := sha256.Sum256([]byte(dataFilePath))
+	return filepath.Join(c.folder, hex.EncodeToString(sum[:16])+checkpointSuffix)
+}
+
+// load returns the checkpoint of a data file if it is still valid for info and algo
+// An outdated checkpoint is deleted
+func (c *Checkpointer) load(dataFilePath string, info os.FileInfo, algo string) *hashCheckpoint {
+	checkpointPath := c.checkpointPath(dataFilePath)
+	data, err := os.ReadFile(checkpointPath)
+	if err != nil {
+		return nil
+	}
+
+	var checkpoint hashCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil || !checkpoint.matches(dataFilePath, info, algo) {
+		os.Remove(checkpointPath)
+		return nil
+	}
+
+	return &checkpoint
+}
+
+// matches reports whether the checkpoint was taken of this exact file version
+func (cp *hashCheckpoint) matches(dataFilePath string, info os.FileInfo, algo string) bool {
+	return cp.Path == dataFilePath &&
+		cp.Size == info.Size() &&
+		cp.ModTime.Equal(info.ModTime()) &&
+		cp.Algorithm == algo &&
+		cp.Offset > 0 && cp.Offset <= cp.Size
+}
+
+// save writes a checkpoint atomically (write to a temp file, then rename)
+func (c *Checkpointer) save(checkpoint hashCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	checkpointPath := c.checkpointPath(checkpoint.Path)
+	tmpPath := checkpointPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmpPath, checkpointPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// remove deletes the checkpoint of a data file (if any)
+func (c *Checkpointer) remove(dataFilePath string) {
+	os.Remove(c.checkpointPath(dataFilePath))
+}
+
+// Prune deletes checkpoints of files that no longer exist or have changed
+// Returns the number of checkpoints deleted
+func (c *Checkpointer) Prune() int {
+	entries, err := os.ReadDir(c.folder)
+	if err != nil {
+		return 0
+	}
+
+	pruned := 0
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), checkpointSuffix) {
+			continue
+		}
+		checkpointPath := filepath.Join(c.folder, entry.Name())
+
+		var checkpoint hashCheckpoint
+		data, err := os.ReadFile(checkpointPath)
+		if err == nil {
+			err = json.Unmarshal(data, &checkpoint)
+		}
+		if err == nil {
+			info, statErr := os.Stat(checkpoint.Path)
+			if statErr == nil && checkpoint.matches(checkpoint.Path, info, checkpoint.Algorithm) {
+				continue
+			}
+		}
+
+		if err := os.Remove(checkpointPath); err == nil || errors.Is(err, fs.ErrNotExist) {
+			pruned++
+		}
+	}
+
+	return pruned
+}
+
+// hashFile hashes an open file, resuming from and saving checkpoints
+// Falls back to hashing from the start when the file is small or the
+// algorithm's state cannot be saved
+func (c *Checkpointer) hashFile(file *os.File, dataFilePath string, bufferSize int, algo string, progress ProgressFunc) (string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	newHasher, ok := hashAlgorithms[algo]
+	if !ok {
+		return "", fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+	hasher := newHasher()
+	marshaler, canSave := hasher.(encoding.BinaryMarshaler)
+	unmarshaler, canRestore := hasher.(encoding.BinaryUnmarshaler)
+	if info.Size() < c.minSize || !canSave || !canRestore {
+		return hashReader(withProgress(file, progress), bufferSize, algo)
+	}
+
+	// Resume from a checkpoint of this exact file version, if there is one
+	var offset int64
+	if checkpoint := c.load(dataFilePath, info, algo); checkpoint != nil {
+		if err := unmarshaler.UnmarshalBinary(checkpoint.State); err == nil {
+			if _, err := file.Seek(checkpoint.Offset, io.SeekStart); err == nil {
+				offset = checkpoint.Offset
+			}
+		}
+		if offset == 0 {
+			// Unusable state, start over with a fresh hasher
+			hasher.Reset()
+			file.Seek(0, io.SeekStart)
+		}
+	}
+	if offset > 0 && progress != nil {
+		if err := progress(int(offset)); err != nil {
+			return "", err
+		}
+	}
+
+	buffer := make([]byte, bufferSize)
+	lastSaved := offset
+	for {
+		bytesRead, err := file.Read(buffer)
+		if bytesRead > 0 {
+			hasher.Write(buffer[:bytesRead])
+			offset += int64(bytesRead)
+			if progress != nil {
+				if err := progress(bytesRead); err != nil {
+					return "", fmt.Errorf("failed to read file: %w", err)
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+
+		if offset-lastSaved >= c.interval {
+			state, err := marshaler.MarshalBinary()
+			if err == nil {
+				err = c.save(hashCheckpoint{
+					Path:      dataFilePath,
+					Size:      info.Size(),
+					ModTime:   info.ModTime(),
+					Algorithm: algo,
+					Offset:    offset,
+					State:     state,
+				})
+			}
+			if err != nil {
+				// Hashing goes on, only the ability to resume is lost
+				fmt.Fprintf(os.Stderr, "[Checkpoint] Failed to save checkpoint for %s: %v\n", dataFilePath, err)
+			}
+			lastSaved = offset
+		}
+	}
+
+	c.remove(dataFilePath)
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
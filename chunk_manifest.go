@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+ChunkManifests verify resumable uploads chunk by chunk
+(verification.chunkManifest), so a corrupted upload names the chunks to send
+again instead of only failing as a whole.
+
+An upload client writes "<datafile>.chunks" (configurable suffix) next to the
+data file, one chunk per line, with the hash in the pair's algorithm, hex or
+base64:
+
+	# offset  length   hash
+	0         4194304  9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+	4194304   1048576  60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752
+
+The chunks must follow each other from offset 0 and cover the data file
+exactly. Chunks are numbered from 1 in the order listed.
+
+Responsibilities:
+1. Read the chunk manifest of a data file for each attempt; with required, a
+   missing one is retried until the retry timeout (it may still be uploading)
+2. Hash every chunk in the same pass as the whole file
+3. Pass the file only if every chunk and the overall hash match; otherwise
+   fail with the chunks that differ (retried like a hash mismatch, then
+   moved with the data file to the DLQ with a reason file listing them)
+4. Treat the manifest as a companion of the data file: deleted on delivery,
+   moved with it on failure
+
+Does NOT:
+- Check archives verified by an embedded checksum member
+- Reuse a hash computed earlier (known hashes, verdict cache, hard links,
+  integrity oracle): a chunked file is always read in full
+- Undo pre-hash transforms: chunks are hashes of the file as uploaded
+*/
+
+// Chunk manifest failures
+var (
+	ErrMissingChunkManifest = errors.New("missing chunk manifest")
+	ErrInvalidChunkManifest = errors.New("invalid chunk manifest")
+	ErrChunkMismatch        = errors.New("chunk hash mismatch")
+)
+
+// chunkReportLimit bounds how many differing chunks are named in an error
+const chunkReportLimit = 20
+
+// ChunkManifests locates and reads chunk manifests
+type ChunkManifests struct {
+	suffix   string // Appended to the data file name
+	required bool   // Data files without a chunk manifest fail
+}
+
+// fileChunk is one entry of a chunk manifest
+type fileChunk struct {
+	offset int64
+	length int64
+	hash   string
+}
+
+// NewChunkManifests creates the chunk manifest mode
+func NewChunkManifests(cfg ChunkManifestConfig) *ChunkManifests {
+	return &ChunkManifests{suffix: cfg.Suffix, required: cfg.Required}
+}
+
+// ManifestPath returns the path of a data file's chunk manifest
+func (cm *ChunkManifests) ManifestPath(dataFilePath string) string {
+	return dataFilePath + cm.suffix
+}
+
+// IsManifest reports whether a file name ends with the chunk manifest suffix
+func (cm *ChunkManifests) IsManifest(filename string) bool {
+	return strings.HasSuffix(filename, cm.suffix)
+}
+
+// Load reads the chunk manifest of a data file of the given size and returns a verifier
+// hashing its chunks with algo (nil when there is no manifest and none is required)
+// Safe to call on a nil ChunkManifests (returns nil)
+func (cm *ChunkManifests) Load(dataFilePath string, size int64, algo string) (*ChunkVerifier, error) {
+	if cm == nil {
+		return nil, nil
+	}
+
+	path := cm.ManifestPath(dataFilePath)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		if cm.required {
+			return nil, fmt.Errorf("%w: %s", ErrMissingChunkManifest, path)
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chunk manifest: %w", err)
+	}
+	defer file.Close()
+
+	var chunks []fileChunk
+	var next int64
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%w: line %d: expected \"<offset> <length> <hash>\"", ErrInvalidChunkManifest, lineNumber)
+		}
+		offset, errOffset := strconv.ParseInt(fields[0], 10, 64)
+		length, errLength := strconv.ParseInt(fields[1], 10, 64)
+		if errOffset != nil || errLength != nil || length <= 0 {
+			return nil, fmt.Errorf("%w: line %d: invalid offset or length", ErrInvalidChunkManifest, lineNumber)
+		}
+		if offset != next {
+			return nil, fmt.Errorf("%w: line %d: chunk starts at %d, expected %d", ErrInvalidChunkManifest, lineNumber, offset, next)
+		}
+		if _, ok := rawDigest(fields[2]); !ok {
+			return nil, fmt.Errorf("%w: line %d: invalid hash %q", ErrInvalidChunkManifest, lineNumber, fields[2])
+		}
+		chunks = append(chunks, fileChunk{offset: offset, length: length, hash: fields[2]})
+		next = offset + length
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chunk manifest: %w", err)
+	}
+	if next != size {
+		return nil, fmt.Errorf("%w: chunks cover %d bytes, the data file has %d", ErrInvalidChunkManifest, next, size)
+	}
+
+	newHasher, ok := hashAlgorithms[algo]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+	return &ChunkVerifier{chunks: chunks, newHasher: newHasher, hasher: newHasher()}, nil
+}
+
+// ChunkVerifier hashes the chunks of a data file as the file's bytes are written to it in order
+type ChunkVerifier struct {
+	chunks    []fileChunk
+	newHasher func() hash.Hash
+	hasher    hash.Hash // Hash of the current chunk so far
+	current   int       // Index of the chunk being hashed
+	done      int64     // Bytes of the current chunk hashed
+	failed    []int     // Indexes of the chunks that differ
+	extra     int64     // Bytes written past the last chunk
+}
+
+// Write hashes the next bytes of the data file
+func (cv *ChunkVerifier) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		if cv.current == len(cv.chunks) {
+			cv.extra += int64(len(p))
+			break
+		}
+		chunk := cv.chunks[cv.current]
+		n := int(min(int64(len(p)), chunk.length-cv.done))
+		cv.hasher.Write(p[:n])
+		cv.done += int64(n)
+		p = p[n:]
+
+		if cv.done == chunk.length {
+			if !digestsEqual(hex.EncodeToString(cv.hasher.Sum(nil)), chunk.hash) {
+				cv.failed = append(cv.failed, cv.current)
+			}
+			cv.current++
+			cv.done = 0
+			cv.hasher = cv.newHasher()
+		}
+	}
+	return written, nil
+}
+
+// Verify reports the chunks that differ, once the whole file has been written
+func (cv *ChunkVerifier) Verify() error {
+	// Chunks never reached are missing from a file that shrank
+	failed := cv.failed
+	for i := cv.current; i < len(cv.chunks); i++ {
+		failed = append(failed, i)
+	}
+	if cv.extra > 0 {
+		return fmt.Errorf("%w: the data file is %d bytes longer than its chunks", ErrChunkMismatch, cv.extra)
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	var names []string
+	for _, i := range failed[:min(len(failed), chunkReportLimit)] {
+		chunk := cv.chunks[i]
+		names = append(names, fmt.Sprintf("%d (offset %d, length %d)", i+1, chunk.offset, chunk.length))
+	}
+	if len(failed) > chunkReportLimit {
+		names = append(names, fmt.Sprintf("and %d more", len(failed)-chunkReportLimit))
+	}
+	return fmt.Errorf("%w: %d of %d chunks differ: %s", ErrChunkMismatch, len(failed), len(cv.chunks), strings.Join(names, ", "))
+}
+
+// hashTee returns the writer fed the data file's bytes in the hashing pass: the shadow
+// hasher, the deny-list hasher and the chunk verifier, any of which may be nil (nil = none)
+func hashTee(shadow, denied hash.Hash, chunks *ChunkVerifier) io.Writer {
+	var writers []io.Writer
+	for _, hasher := range []hash.Hash{shadow, denied} {
+		if hasher != nil {
+			writers = append(writers, hasher)
+		}
+	}
+	if chunks != nil {
+		writers = append(writers, chunks)
+	}
+	switch len(writers) {
+	case 0:
+		return nil
+	case 1:
+		return writers[0]
+	}
+	return io.MultiWriter(writers...)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Internal hooks for the clock and collision suffixes used in result records.
+
+Setting FILESHA_DETERMINISTIC=1 in the environment (intended for integration
+tests only, not documented in config.yaml) freezes the clock and makes
+collision suffixes count up from 1, so the CSV output of a run can be
+compared byte for byte against a golden file. Decisions taken on the clock
+see the frozen time too: the retry deadline never passes, so only permanent
+failures reach the DLQ, and the janitor never finds a file old enough.
+*/
+
+// deterministicEnv enables the deterministic mode
+const deterministicEnv = "FILESHA_DETERMINISTIC"
+
+// deterministicTime is what the clock reads in deterministic mode
+var deterministicTime = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// clockNow returns the time recorded in results (time.Now unless deterministic)
+var clockNow = time.Now
+
+// uniqueSuffix returns the suffix appended to a file name on a destination collision
+var uniqueSuffix = func() string {
+	return fmt.Sprintf("%d", os.Getpid())
+}
+
+func init() {
+	if os.Getenv(deterministicEnv) == "1" {
+		setDeterministic()
+	}
+}
+
+// setDeterministic freezes the clock and switches to counting collision suffixes
+func setDeterministic() {
+	clockNow = func() time.Time { return deterministicTime }
+
+	var counter atomic.Int64
+	uniqueSuffix = func() string {
+		return fmt.Sprintf("%d", counter.Add(1))
+	}
+}
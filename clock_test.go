@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deterministicForTest switches to the deterministic mode until the test ends
+func deterministicForTest(t *testing.T) {
+	t.Helper()
+	now, suffix := clockNow, uniqueSuffix
+	t.Cleanup(func() { clockNow, uniqueSuffix = now, suffix })
+	setDeterministic()
+}
+
+// goldenVerificationCSV is verification.csv after delivering data.zip twice (the
+// second copy renamed on collision) with the destination root shown as <root>
+const goldenVerificationCSV = `Timestamp,Filename,SHA256,Size_Bytes,Size_KB,Duration_Seconds,Action,Destination_Path,Shadow_Hash,Hole_Bytes,Compressed_Bytes,Source_Path,Linked_To
+2000-01-01 00:00:00,data.zip,3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7,4,0.00,0.0000,moved,<root>/verified/data.zip,,,,data.zip,
+2000-01-01 00:00:00,data.zip,3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7,4,0.00,0.0000,moved,<root>/verified/data_1.zip,,,,data.zip,
+`
+
+func TestDeterministicCSVMatchesGolden(t *testing.T) {
+	deterministicForTest(t)
+	pool := newTestPool(t)
+	logs := t.TempDir()
+	logger, err := NewCSVLogger(filepath.Join(logs, "verification.csv"), filepath.Join(logs, "stats.csv"), time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.resultLogger = logger
+
+	for i := 0; i < 2; i++ {
+		writeTestPair(t, pool.source, "data.zip")
+		if _, outcome := pool.processJob(1, pool.job(t, "data.zip")); outcome != OutcomeVerified {
+			t.Fatalf("delivery %d: outcome %s", i+1, outcome)
+		}
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	root := filepath.Dir(pool.source)
+	got := strings.ReplaceAll(readTestFile(t, filepath.Join(logs, "verification.csv")), root, "<root>")
+	if got != goldenVerificationCSV {
+		t.Errorf("verification.csv\n got:\n%s\nwant:\n%s", got, goldenVerificationCSV)
+	}
+}
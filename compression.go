@@ -0,0 +1,169 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Compressed delivery stores verified data files gzipped in the verified folder
+(destination.compress): "data.zip" is delivered as "data.zip.gz".
+
+Responsibilities:
+1. Pick the gzip level for a data file from the first matching rule
+2. Stream the verified source through gzip into a temp file next to the
+   destination, sync it and give it its final name, then delete the source
+3. Record the original name, modification time and verified hash in the gzip
+   header (comment "<algorithm>:<hash>"), so the file documents itself
+4. With confirmDelivery, decompress the copy and compare its hash with the
+   verified hash before it gets its final name
+
+The compressed size is logged with the result; the original size and hash
+are the ones logged for every verified file.
+
+Does NOT:
+- Compress files delivered from tar streams (tar_source.go)
+- Hash the file in the same pass as the compression: the verified file is
+  read a second time while it is compressed
+*/
+
+// compressedSuffix is appended to the name of a data file delivered gzipped
+const compressedSuffix = ".gz"
+
+// defaultCompressionLevel is the gzip level of a rule that sets none
+const defaultCompressionLevel = 6
+
+// compressionFor returns the gzip level for a data file from the first matching rule (0 = deliver as is)
+func compressionFor(rules []CompressionRule, dataFile string) int {
+	for _, rule := range rules {
+		if matched, _ := filepath.Match(rule.Pattern, dataFile); matched {
+			return rule.Level
+		}
+	}
+	return 0
+}
+
+// CompressToVerified delivers a verified data file gzipped into the verified folder as filename
+// comment goes into the gzip header; with a check, the decompressed copy must hash to the
+// verified hash before it gets its final name and the source is deleted
+// Returns the new file path and the compressed size
+func CompressToVerified(sourceFilePath, verifiedFolder, filename, onCollision string, level int, comment string, check *DeliveryCheck) (string, int64, error) {
+	// Skip or fail before compressing when the name is taken already
+	if _, err := resolveDestination(verifiedFolder, filename, onCollision); err != nil {
+		return "", 0, err
+	}
+
+	// Like a copy, the final name only ever appears with the complete content
+	tempPath, err := reserveTempPath(filepath.Join(verifiedFolder, filename))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	size, err := gzipFile(sourceFilePath, tempPath, level, comment)
+	if err != nil {
+		os.Remove(tempPath)
+		return "", 0, fmt.Errorf("failed to compress file: %w", err)
+	}
+	if err := check.confirm(tempPath); err != nil {
+		os.Remove(tempPath)
+		return "", 0, err
+	}
+	destPath, err := claimDestination(verifiedFolder, filename, onCollision, func(destPath string, replace bool) error {
+		if err := placeFile(tempPath, destPath, replace); err != nil {
+			return fmt.Errorf("failed to rename compressed file to %s: %w", filepath.Base(destPath), err)
+		}
+		return nil
+	})
+	if err != nil {
+		os.Remove(tempPath)
+		return "", 0, err
+	}
+	syncDir(verifiedFolder)
+
+	if err := os.Remove(sourceFilePath); err != nil {
+		return "", 0, fmt.Errorf("failed to delete source file after compressing: %w", err)
+	}
+	return destPath, size, nil
+}
+
+// gzipFile writes a gzipped copy of source to dest and returns its size
+func gzipFile(sourcePath, destPath string, level int, comment string) (int64, error) {
+	sourceFile, err := os.Open(sourcePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer sourceFile.Close()
+
+	sourceInfo, err := sourceFile.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get source file info: %w", err)
+	}
+
+	destFile, err := os.Create(destPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer destFile.Close()
+
+	zw, err := gzip.NewWriterLevel(destFile, level)
+	if err != nil {
+		return 0, err
+	}
+	// gzip header strings are Latin-1, other names are left out
+	if name := filepath.Base(sourcePath); isLatin1(name) {
+		zw.Name = name
+	}
+	zw.ModTime = sourceInfo.ModTime()
+	zw.Comment = comment
+
+	if _, err := io.Copy(zw, sourceFile); err != nil {
+		return 0, fmt.Errorf("failed to compress file contents: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return 0, fmt.Errorf("failed to compress file contents: %w", err)
+	}
+	if err := destFile.Sync(); err != nil {
+		return 0, fmt.Errorf("failed to sync destination file: %w", err)
+	}
+	if err := os.Chmod(destPath, sourceInfo.Mode()); err != nil {
+		return 0, fmt.Errorf("failed to set destination file permissions: %w", err)
+	}
+
+	destInfo, err := destFile.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get destination file info: %w", err)
+	}
+	return destInfo.Size(), nil
+}
+
+// gzipOriginalSize returns the uncompressed size stored in a gzip file's trailer
+// The trailer holds the size modulo 2^32, compare it with uint32 of the expected size
+func gzipOriginalSize(path string) (uint32, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	trailer := make([]byte, 4)
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if info.Size() < 18 {
+		return 0, fmt.Errorf("%s is too short to be a gzip file", filepath.Base(path))
+	}
+	if _, err := file.ReadAt(trailer, info.Size()-4); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(trailer), nil
+}
+
+// isLatin1 reports whether s can be stored in a gzip header
+func isLatin1(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return r > 0xff }) < 0
+}
@@ -2,14 +2,47 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
-// LoadConfig reads and parses the configuration file
-func LoadConfig(configPath string) (*Config, error) {
+// LoadConfig reads and parses the configuration file and creates the destination folders
+// A profile (e.g. "prod") merges its overrides file onto it before validation
+func LoadConfig(configPath, profile string) (*Config, error) {
+	config, err := ParseConfig(configPath, profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := PrepareFolders(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// PrepareFolders checks that the source folder exists and creates the destination folders
+func PrepareFolders(config *Config) error {
+	if err := checkSourceFolder(config); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Create destination folders if they don't exist
+	if err := createDestinationFolders(config); err != nil {
+		return fmt.Errorf("failed to create destination folders: %w", err)
+	}
+
+	return nil
+}
+
+// ParseConfig reads, merges and validates the configuration without touching any folder
+func ParseConfig(configPath, profile string) (*Config, error) {
 	// Read config file
 	data, err := os.ReadFile(configPath)
 	if err != nil {
@@ -21,18 +54,272 @@ func LoadConfig(configPath string) (*Config, error) {
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config YAML: %w", err)
 	}
+	config.files = []string{configPath}
+
+	// The profile is decoded onto the base: mappings merge key by key,
+	// scalars and lists replace the base values
+	if profile != "" {
+		profilePath, err := ProfilePath(configPath, profile)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(profilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s profile: %w", profile, err)
+		}
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse %s profile YAML: %w", profile, err)
+		}
+		config.profile = profile
+		config.files = append(config.files, profilePath)
+	}
+
+	// Fill in defaults for optional settings
+	applyDefaults(&config)
 
 	// Validate configuration
 	if err := validateConfig(&config); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	// Create destination folders if they don't exist
-	if err := createDestinationFolders(&config); err != nil {
-		return nil, fmt.Errorf("failed to create destination folders: %w", err)
+	return &config, nil
+}
+
+// checkSourceFolder checks that the source folder exists on this host
+func checkSourceFolder(cfg *Config) error {
+	if _, err := os.Stat(cfg.Spec.Source.Folder); os.IsNotExist(err) {
+		return fmt.Errorf("source folder does not exist: %s", cfg.Spec.Source.Folder)
+	}
+	return nil
+}
+
+// ProfilePath returns the overrides file of a profile: "config.prod.yaml" for config.yaml and prod
+func ProfilePath(configPath, profile string) (string, error) {
+	if profile == "." || profile == ".." || strings.ContainsAny(profile, `/\`) {
+		return "", fmt.Errorf("invalid profile name: %q", profile)
 	}
+	ext := filepath.Ext(configPath)
+	return strings.TrimSuffix(configPath, ext) + "." + profile + ext, nil
+}
 
-	return &config, nil
+// applyDefaults sets default values for optional settings that were not configured
+func applyDefaults(cfg *Config) {
+	// Scans stat files on the walking goroutine by default
+	if cfg.Spec.Source.ScanParallelism == 0 {
+		cfg.Spec.Source.ScanParallelism = 1
+	}
+
+	// Data files are selected by fileFilters unless exclude mode is requested
+	if cfg.Spec.Verification.FilterMode == "" {
+		cfg.Spec.Verification.FilterMode = FilterModeInclude
+	}
+
+	// Digest encoding is detected from the sidecar content by default
+	if cfg.Spec.Verification.HashEncoding == "" {
+		cfg.Spec.Verification.HashEncoding = HashEncodingAuto
+	}
+
+	// Only .sha256 sidecars are looked for by default
+	if len(cfg.Spec.Verification.SidecarSuffixes) == 0 {
+		cfg.Spec.Verification.SidecarSuffixes = []string{".sha256"}
+	}
+
+	// Expected hashes come from .sha256 files by default
+	if cfg.Spec.Verification.ExpectedHashSource == "" {
+		cfg.Spec.Verification.ExpectedHashSource = HashSourceSidecar
+	}
+
+	// Every file is hashed unless an integrity oracle is configured
+	if cfg.Spec.Verification.IntegrityOracle == "" {
+		cfg.Spec.Verification.IntegrityOracle = IntegrityOracleNone
+	}
+
+	// Destination collisions default to appending a unique suffix
+	if cfg.Spec.Destination.OnCollision == "" {
+		cfg.Spec.Destination.OnCollision = CollisionRename
+	}
+
+	// Compressed deliveries use gzip's default level
+	for i := range cfg.Spec.Destination.Compress {
+		if cfg.Spec.Destination.Compress[i].Level == 0 {
+			cfg.Spec.Destination.Compress[i].Level = defaultCompressionLevel
+		}
+	}
+
+	// A file that can't enter the queue for a minute signals too few workers
+	if cfg.Spec.Concurrency.SubmitWarnAfter == 0 {
+		cfg.Spec.Concurrency.SubmitWarnAfter = 1 * time.Minute
+	}
+
+	// A full queue is retried on the next tick by default
+	if cfg.Spec.Concurrency.SubmitMode == "" {
+		cfg.Spec.Concurrency.SubmitMode = SubmitModeNonblocking
+	}
+
+	// Sidecars whose data file never arrives go to the DLQ by default
+	if cfg.Spec.Verification.DuplicateSidecars == "" {
+		cfg.Spec.Verification.DuplicateSidecars = DuplicateSidecarsRefuse
+	}
+	if cfg.Spec.Destination.OrphanSidecars == "" {
+		cfg.Spec.Destination.OrphanSidecars = OrphanSidecarsDLQ
+	}
+
+	// Checkpoint only files that take a while to hash, every 256 MiB
+	if cfg.Spec.Verification.Checkpoint.MinSizeBytes == 0 {
+		cfg.Spec.Verification.Checkpoint.MinSizeBytes = 1 << 30
+	}
+	if cfg.Spec.Verification.Checkpoint.IntervalBytes == 0 {
+		cfg.Spec.Verification.Checkpoint.IntervalBytes = 256 << 20
+	}
+	if cfg.Spec.Verification.ChangeDetector.Samples == 0 {
+		cfg.Spec.Verification.ChangeDetector.Samples = defaultChangeSamples
+	}
+	if cfg.Spec.Verification.ChangeDetector.SampleSize == 0 {
+		cfg.Spec.Verification.ChangeDetector.SampleSize = defaultChangeSampleSize
+	}
+	if cfg.Spec.Verification.BSPatchMaxBytes == 0 {
+		cfg.Spec.Verification.BSPatchMaxBytes = defaultBSPatchMaxBytes
+	}
+	if cfg.Spec.Verification.LargeRead.BufferSize == 0 {
+		cfg.Spec.Verification.LargeRead.BufferSize = defaultLargeReadBuffer
+	}
+	if cfg.Spec.Verification.ChunkManifest.Suffix == "" {
+		cfg.Spec.Verification.ChunkManifest.Suffix = ".chunks"
+	}
+
+	// Listed files get as long to arrive as a data file waits for its sidecar
+	if cfg.Spec.Verification.Manifest.MissingTimeout == 0 {
+		cfg.Spec.Verification.Manifest.MissingTimeout = cfg.Spec.Verification.RetryTimeout
+	}
+
+	// Retention janitor runs hourly by default
+	if cfg.Spec.Destination.Retention.CheckInterval == 0 {
+		cfg.Spec.Destination.Retention.CheckInterval = 1 * time.Hour
+	}
+
+	// Growing files have ".segments" sidecars of sha256 hashes, checked every 10 seconds
+	if cfg.Spec.Source.Growing.Suffix == "" {
+		cfg.Spec.Source.Growing.Suffix = ".segments"
+	}
+	if cfg.Spec.Source.Growing.Algorithm == "" {
+		cfg.Spec.Source.Growing.Algorithm = AlgorithmSHA256
+	}
+	if cfg.Spec.Source.Growing.CheckInterval == 0 {
+		cfg.Spec.Source.Growing.CheckInterval = 10 * time.Second
+	}
+
+	// Orphan sidecars are quarantined, checked every 10 minutes, by default
+	if cfg.Spec.Destination.OrphanCleanup.Action == "" {
+		cfg.Spec.Destination.OrphanCleanup.Action = OrphanCleanupQuarantine
+	}
+	if cfg.Spec.Destination.OrphanCleanup.CheckInterval == 0 {
+		cfg.Spec.Destination.OrphanCleanup.CheckInterval = 10 * time.Minute
+	}
+
+	// Sidecar signatures are "<sidecar>.sig" by default
+	if cfg.Spec.Verification.Signature.Suffix == "" {
+		cfg.Spec.Verification.Signature.Suffix = ".sig"
+	}
+
+	// Tar stream source defaults
+	if len(cfg.Spec.Source.PreScanCommand) > 0 && cfg.Spec.Source.PreScanTimeout == 0 {
+		cfg.Spec.Source.PreScanTimeout = 30 * time.Second
+	}
+	if cfg.Spec.Verification.EmbeddedChecksum.Member == "" {
+		cfg.Spec.Verification.EmbeddedChecksum.Member = "CHECKSUM"
+	}
+	if cfg.Spec.Source.TarListener.IdleTimeout == 0 {
+		cfg.Spec.Source.TarListener.IdleTimeout = 1 * time.Minute
+	}
+	if cfg.Spec.Source.TarListener.ManifestName == "" {
+		cfg.Spec.Source.TarListener.ManifestName = "MANIFEST.sha256"
+	}
+	if cfg.Spec.Source.TarListener.MaxStreams == 0 {
+		cfg.Spec.Source.TarListener.MaxStreams = 4
+	}
+	if cfg.Spec.Source.TarListener.MaxPendingMembers == 0 {
+		cfg.Spec.Source.TarListener.MaxPendingMembers = 100
+	}
+	if cfg.Spec.Source.TarListener.MaxPendingBytes == 0 {
+		cfg.Spec.Source.TarListener.MaxPendingBytes = 1 << 30
+	}
+
+	// StatsD defaults
+	if cfg.Spec.StatsD.Prefix == "" {
+		cfg.Spec.StatsD.Prefix = metricsNamespace
+	}
+
+	// Tracing defaults
+	if cfg.Spec.Tracing.ServiceName == "" {
+		cfg.Spec.Tracing.ServiceName = "go-filesha-verifier"
+	}
+
+	// Database defaults
+	if cfg.Spec.Database.Table == "" {
+		cfg.Spec.Database.Table = "file_hashes"
+	}
+	if cfg.Spec.Database.Timeout == 0 {
+		cfg.Spec.Database.Timeout = 5 * time.Second
+	}
+
+	// Redis defaults
+	if cfg.Spec.Redis.Timeout == 0 {
+		cfg.Spec.Redis.Timeout = 2 * time.Second
+	}
+	if cfg.Spec.Redis.CacheFor == 0 {
+		cfg.Spec.Redis.CacheFor = time.Minute
+	}
+
+	// Output sinks default to CSV files only
+	if len(cfg.Spec.Output.Sinks) == 0 {
+		cfg.Spec.Output.Sinks = []string{"csv"}
+	}
+	if cfg.Spec.Output.Reconcile == "" {
+		cfg.Spec.Output.Reconcile = ReconcileOff
+	}
+
+	// Source paths are recorded relative to the source folder
+	if cfg.Spec.Output.SourcePath == "" {
+		cfg.Spec.Output.SourcePath = SourcePathRelative
+	}
+
+	// Syslog sink defaults
+	if cfg.Spec.Output.Syslog.Network == "" {
+		cfg.Spec.Output.Syslog.Network = "udp"
+	}
+	if cfg.Spec.Output.Syslog.Facility == "" {
+		cfg.Spec.Output.Syslog.Facility = "local0"
+	}
+	if cfg.Spec.Output.Syslog.AppName == "" {
+		cfg.Spec.Output.Syslog.AppName = "go-filesha-verifier"
+	}
+	if cfg.Spec.Output.Syslog.BufferSize == 0 {
+		cfg.Spec.Output.Syslog.BufferSize = 1000
+	}
+
+	// Kafka sink defaults
+	if cfg.Spec.Output.Kafka.ClientID == "" {
+		cfg.Spec.Output.Kafka.ClientID = "go-filesha-verifier"
+	}
+	if cfg.Spec.Output.Kafka.BufferSize == 0 {
+		cfg.Spec.Output.Kafka.BufferSize = 1000
+	}
+	if cfg.Spec.Output.Kafka.Overflow == "" {
+		cfg.Spec.Output.Kafka.Overflow = KafkaOverflowBlock
+	}
+	if cfg.Spec.Output.Kafka.Timeout == 0 {
+		cfg.Spec.Output.Kafka.Timeout = 10 * time.Second
+	}
+
+	// A batch is complete once no file of it has finished for 30 seconds
+	if cfg.Spec.Output.Batches.CompleteAfter == 0 {
+		cfg.Spec.Output.Batches.CompleteAfter = 30 * time.Second
+	}
+
+	// Each /events client may fall this many events behind
+	if cfg.Spec.API.EventBuffer == 0 {
+		cfg.Spec.API.EventBuffer = defaultEventBuffer
+	}
 }
 
 // validateConfig ensures all required fields are present and valid
@@ -42,16 +329,66 @@ func validateConfig(cfg *Config) error {
 		return fmt.Errorf("source.folder cannot be empty")
 	}
 
-	// Check if source folder exists
-	if _, err := os.Stat(cfg.Spec.Source.Folder); os.IsNotExist(err) {
-		return fmt.Errorf("source folder does not exist: %s", cfg.Spec.Source.Folder)
-	}
-
 	// Validate scan interval
 	if cfg.Spec.Source.PeriodicScanInterval <= 0 {
 		return fmt.Errorf("source.periodicScanInterval must be positive")
 	}
 
+	// Validate scan parallelism
+	if cfg.Spec.Source.ScanParallelism < 1 || cfg.Spec.Source.ScanParallelism > 256 {
+		return fmt.Errorf("source.scanParallelism must be between 1 and 256")
+	}
+
+	// Validate exclude patterns
+	for _, pattern := range cfg.Spec.Source.ExcludePatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("source.excludePatterns contains invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	// Validate in-progress markers (an empty marker would match every file)
+	for _, suffix := range cfg.Spec.Source.InProgressSuffixes {
+		if suffix == "" {
+			return fmt.Errorf("source.inProgressSuffixes cannot contain empty entries")
+		}
+	}
+	for _, prefix := range cfg.Spec.Source.InProgressPrefixes {
+		if prefix == "" {
+			return fmt.Errorf("source.inProgressPrefixes cannot contain empty entries")
+		}
+	}
+
+	// Validate pre-scan command
+	if len(cfg.Spec.Source.PreScanCommand) > 0 {
+		if cfg.Spec.Source.PreScanCommand[0] == "" {
+			return fmt.Errorf("source.preScanCommand must start with the program to run")
+		}
+		if cfg.Spec.Source.PreScanTimeout < 0 {
+			return fmt.Errorf("source.preScanTimeout cannot be negative")
+		}
+	}
+
+	// Validate tar stream source
+	if cfg.Spec.Source.TarListener.Address != "" {
+		if _, _, err := net.SplitHostPort(cfg.Spec.Source.TarListener.Address); err != nil {
+			return fmt.Errorf("source.tarListener.address is invalid: %w", err)
+		}
+		if cfg.Spec.Source.TarListener.IdleTimeout < 0 {
+			return fmt.Errorf("source.tarListener.idleTimeout cannot be negative")
+		}
+		for _, cidr := range cfg.Spec.Source.TarListener.AllowedCIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return fmt.Errorf("source.tarListener.allowedCIDRs: %w", err)
+			}
+		}
+		if cfg.Spec.Source.TarListener.MaxStreams < 0 {
+			return fmt.Errorf("source.tarListener.maxStreams cannot be negative")
+		}
+		if cfg.Spec.Source.TarListener.MaxPendingMembers < 0 || cfg.Spec.Source.TarListener.MaxPendingBytes < 0 {
+			return fmt.Errorf("source.tarListener.maxPendingMembers and maxPendingBytes cannot be negative")
+		}
+	}
+
 	// Validate retry timeout
 	if cfg.Spec.Verification.RetryTimeout <= 0 {
 		return fmt.Errorf("verification.retryTimeout must be positive")
@@ -62,9 +399,174 @@ func validateConfig(cfg *Config) error {
 		return fmt.Errorf("verification.bufferSize must be positive")
 	}
 
-	// Validate file filters
-	if len(cfg.Spec.Verification.FileFilters) == 0 {
-		return fmt.Errorf("verification.fileFilters cannot be empty")
+	// Validate hash encoding
+	switch cfg.Spec.Verification.HashEncoding {
+	case HashEncodingAuto, HashEncodingHex, HashEncodingBase64:
+	default:
+		return fmt.Errorf("verification.hashEncoding must be one of: auto, hex, base64")
+	}
+
+	// Validate file filters (exactly one of include/exclude mode is configured)
+	switch cfg.Spec.Verification.FilterMode {
+	case FilterModeInclude:
+		if len(cfg.Spec.Verification.FileFilters) == 0 {
+			return fmt.Errorf("verification.fileFilters cannot be empty")
+		}
+		if len(cfg.Spec.Verification.ExcludeFilters) > 0 {
+			return fmt.Errorf("verification.excludeFilters requires verification.filterMode: exclude")
+		}
+	case FilterModeExclude:
+		if len(cfg.Spec.Verification.FileFilters) > 0 {
+			return fmt.Errorf("verification.fileFilters cannot be used with verification.filterMode: exclude")
+		}
+	default:
+		return fmt.Errorf("verification.filterMode must be one of: include, exclude")
+	}
+	filters := append(append([]string{}, cfg.Spec.Verification.FileFilters...), cfg.Spec.Verification.ExcludeFilters...)
+	for _, pattern := range filters {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("verification filter contains invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	// Validate expected hash source
+	switch cfg.Spec.Verification.ExpectedHashSource {
+	case HashSourceSidecar, HashSourceDatabase, HashSourceRedis:
+	default:
+		return fmt.Errorf("verification.expectedHashSource must be one of: sidecar, database, redis")
+	}
+
+	// Validate filename hash pattern
+	if pattern := cfg.Spec.Verification.FilenameHashPattern; pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("verification.filenameHashPattern is not a valid regex: %w", err)
+		}
+		if re.NumSubexp() == 0 {
+			return fmt.Errorf("verification.filenameHashPattern must contain a capture group for the hash")
+		}
+	}
+
+	// Validate pre-hash transforms
+	for i, rule := range cfg.Spec.Verification.Transforms {
+		if _, err := filepath.Match(rule.Pattern, ""); err != nil || rule.Pattern == "" {
+			return fmt.Errorf("verification.transforms[%d].pattern is not a valid pattern: %q", i, rule.Pattern)
+		}
+		if len(rule.Steps) == 0 {
+			return fmt.Errorf("verification.transforms[%d].steps cannot be empty", i)
+		}
+		for _, step := range rule.Steps {
+			if _, ok := preHashTransforms[step]; !ok {
+				return fmt.Errorf("verification.transforms[%d] has unknown step %q (supported: gunzip, bunzip2, zlib, bspatch)", i, step)
+			}
+		}
+	}
+
+	if cfg.Spec.Verification.BSPatchMaxBytes < 0 {
+		return fmt.Errorf("verification.bspatchMaxBytes cannot be negative")
+	}
+
+	// Validate checkpoint settings
+	if cfg.Spec.Verification.Checkpoint.MinSizeBytes < 0 {
+		return fmt.Errorf("verification.checkpoint.minSizeBytes cannot be negative")
+	}
+	if cfg.Spec.Verification.Checkpoint.IntervalBytes < 0 {
+		return fmt.Errorf("verification.checkpoint.intervalBytes cannot be negative")
+	}
+
+	// Validate change detector settings
+	if cfg.Spec.Verification.ChangeDetector.Samples < 2 {
+		return fmt.Errorf("verification.changeDetector.samples must be at least 2 (the first and the last block)")
+	}
+	if cfg.Spec.Verification.ChangeDetector.SampleSize < 1 {
+		return fmt.Errorf("verification.changeDetector.sampleSize must be positive")
+	}
+
+	// Validate large-read settings
+	if cfg.Spec.Verification.LargeRead.BufferSize < 0 {
+		return fmt.Errorf("verification.largeRead.bufferSize cannot be negative")
+	}
+	if cfg.Spec.Verification.LargeRead.Readahead < 0 {
+		return fmt.Errorf("verification.largeRead.readahead cannot be negative")
+	}
+
+	// Validate chunk manifests
+	if cfg.Spec.Verification.ChunkManifest.Required && !cfg.Spec.Verification.ChunkManifest.Enabled {
+		return fmt.Errorf("verification.chunkManifest.required requires verification.chunkManifest.enabled")
+	}
+
+	// Validate shadow algorithm
+	if shadow := cfg.Spec.Verification.ShadowAlgorithm; shadow != "" {
+		if _, ok := hashAlgorithms[shadow]; !ok {
+			return fmt.Errorf("verification.shadowAlgorithm %q is not a supported algorithm", shadow)
+		}
+	}
+
+	// Validate sidecar suffixes
+	seenSuffixes := make(map[string]bool)
+	for _, suffix := range cfg.Spec.Verification.SidecarSuffixes {
+		if !strings.HasPrefix(suffix, ".") {
+			return fmt.Errorf("verification.sidecarSuffixes entry %q must start with a dot", suffix)
+		}
+		if _, ok := hashAlgorithms[strings.TrimPrefix(suffix, ".")]; !ok {
+			return fmt.Errorf("verification.sidecarSuffixes entry %q does not name a supported algorithm", suffix)
+		}
+		if seenSuffixes[suffix] {
+			return fmt.Errorf("verification.sidecarSuffixes contains %q more than once", suffix)
+		}
+		seenSuffixes[suffix] = true
+	}
+
+	// Validate duplicate sidecar handling
+	switch cfg.Spec.Verification.DuplicateSidecars {
+	case DuplicateSidecarsRefuse, DuplicateSidecarsTrack:
+	default:
+		return fmt.Errorf("verification.duplicateSidecars must be one of: refuse, track")
+	}
+
+	// Validate manifest settings
+	if name := cfg.Spec.Verification.Manifest.Name; name != "" {
+		if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			return fmt.Errorf("verification.manifest.name must be a file name, not a path: %q", name)
+		}
+	}
+	if cfg.Spec.Verification.Manifest.Hierarchy && !cfg.Spec.Source.Recursive {
+		return fmt.Errorf("verification.manifest.hierarchy requires source.recursive")
+	}
+	if cfg.Spec.Verification.Manifest.MissingTimeout < 0 {
+		return fmt.Errorf("verification.manifest.missingTimeout cannot be negative")
+	}
+
+	// Validate known hashes
+	if cfg.Spec.Verification.TrustKnownHashes && cfg.Spec.Verification.KnownHashesFile == "" {
+		return fmt.Errorf("verification.trustKnownHashes requires verification.knownHashesFile")
+	}
+	if cfg.Spec.Verification.Signature.Required && cfg.Spec.Verification.Signature.PublicKeyFile == "" {
+		return fmt.Errorf("verification.signature.required requires verification.signature.publicKeyFile")
+	}
+	if cfg.Spec.Verification.VerdictCacheSize < 0 {
+		return fmt.Errorf("verification.verdictCacheSize cannot be negative")
+	}
+	if _, ok := integrityOracles[cfg.Spec.Verification.IntegrityOracle]; !ok {
+		return fmt.Errorf("verification.integrityOracle must be one of: %s", integrityOracleNames())
+	}
+
+	// Validate archive integrity patterns
+	for _, pattern := range cfg.Spec.Verification.ArchiveIntegrity {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("verification.archiveIntegrity contains invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	// Validate embedded checksum archives
+	for _, pattern := range cfg.Spec.Verification.EmbeddedChecksum.Patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("verification.embeddedChecksum.patterns contains invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	if cfg.Spec.MaxRuntime < 0 {
+		return fmt.Errorf("maxRuntime cannot be negative")
 	}
 
 	// Validate destination folders
@@ -75,6 +577,127 @@ func validateConfig(cfg *Config) error {
 		return fmt.Errorf("destination.dlqFolder cannot be empty")
 	}
 
+	// Recursive scanning must not pick up files that were already moved
+	if cfg.Spec.Source.Recursive {
+		for name, folder := range map[string]string{
+			"destination.verifiedFolder":     cfg.Spec.Destination.VerifiedFolder,
+			"destination.dlqFolder":          cfg.Spec.Destination.DlqFolder,
+			"destination.quarantineFolder":   cfg.Spec.Destination.QuarantineFolder,
+			"destination.emptySidecarFolder": cfg.Spec.Destination.EmptySidecarFolder,
+			"destination.mispairedFolder":    cfg.Spec.Destination.MispairedFolder,
+		} {
+			if folder != "" && isWithinFolder(folder, cfg.Spec.Source.Folder) {
+				return fmt.Errorf("%s must not be inside source.folder when source.recursive is enabled", name)
+			}
+		}
+	}
+
+	// Validate collision policy
+	switch cfg.Spec.Destination.OnCollision {
+	case CollisionRename, CollisionOverwrite, CollisionSkip, CollisionFail:
+	default:
+		return fmt.Errorf("destination.onCollision must be one of: rename, overwrite, skip, fail")
+	}
+
+	// Validate naming template
+	if template := cfg.Spec.Destination.NamingTemplate; template != "" {
+		if err := validateNamingTemplate(template); err != nil {
+			return fmt.Errorf("destination.namingTemplate: %w", err)
+		}
+	}
+
+	// Validate compression rules
+	for i, rule := range cfg.Spec.Destination.Compress {
+		if _, err := filepath.Match(rule.Pattern, ""); err != nil || rule.Pattern == "" {
+			return fmt.Errorf("destination.compress[%d].pattern is not a valid pattern: %q", i, rule.Pattern)
+		}
+		if rule.Level < 1 || rule.Level > 9 {
+			return fmt.Errorf("destination.compress[%d].level must be between 1 and 9", i)
+		}
+	}
+
+	// Validate latest links
+	for i, rule := range cfg.Spec.Destination.LatestLinks {
+		if _, err := filepath.Match(rule.Pattern, ""); err != nil || rule.Pattern == "" {
+			return fmt.Errorf("destination.latestLinks[%d].pattern is not a valid pattern: %q", i, rule.Pattern)
+		}
+		if rule.Name == "" || rule.Name == "." || rule.Name == ".." || filepath.Base(rule.Name) != rule.Name {
+			return fmt.Errorf("destination.latestLinks[%d].name must be a file name: %q", i, rule.Name)
+		}
+	}
+
+	if cfg.Spec.Destination.SidecarDeleteDelay < 0 {
+		return fmt.Errorf("destination.sidecarDeleteDelay cannot be negative")
+	}
+
+	// Validate the intent log: a CSV row must be on disk before its delivery is recorded done
+	if cfg.Spec.Destination.IntentLog != "" && slices.Contains(cfg.Spec.Output.Sinks, "csv") && !cfg.Spec.Output.FlushImmediately {
+		return fmt.Errorf("destination.intentLog requires output.flushImmediately with the csv sink")
+	}
+
+	// Validate orphan sidecar handling
+	switch cfg.Spec.Destination.OrphanSidecars {
+	case OrphanSidecarsDLQ, OrphanSidecarsLeave:
+	case OrphanSidecarsQuarantine:
+		if cfg.Spec.Destination.QuarantineFolder == "" {
+			return fmt.Errorf("destination.orphanSidecars: quarantine requires destination.quarantineFolder")
+		}
+	default:
+		return fmt.Errorf("destination.orphanSidecars must be one of: dlq, quarantine, leave")
+	}
+
+	// Validate retention settings
+	if cfg.Spec.Destination.Retention.MaxAge < 0 {
+		return fmt.Errorf("destination.retention.maxAge cannot be negative")
+	}
+	if cfg.Spec.Destination.Retention.MaxSizeBytes < 0 {
+		return fmt.Errorf("destination.retention.maxSizeBytes cannot be negative")
+	}
+	if cfg.Spec.Destination.Retention.CheckInterval <= 0 {
+		return fmt.Errorf("destination.retention.checkInterval must be positive")
+	}
+	dlqRetention := cfg.Spec.Destination.DLQRetention
+	if dlqRetention.MaxAge < 0 || dlqRetention.MaxSizeBytes < 0 || dlqRetention.AlertSizeBytes < 0 || dlqRetention.AlertFiles < 0 {
+		return fmt.Errorf("destination.dlqRetention settings cannot be negative")
+	}
+
+	// Validate growing files
+	if growing := cfg.Spec.Source.Growing; len(growing.Patterns) > 0 {
+		for _, pattern := range growing.Patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil || pattern == "" {
+				return fmt.Errorf("source.growing.patterns contains invalid pattern %q", pattern)
+			}
+		}
+		if growing.StateFile == "" {
+			return fmt.Errorf("source.growing.stateFile cannot be empty")
+		}
+		if _, ok := hashAlgorithms[growing.Algorithm]; !ok {
+			return fmt.Errorf("source.growing.algorithm is not supported: %s", growing.Algorithm)
+		}
+		if growing.CheckInterval <= 0 {
+			return fmt.Errorf("source.growing.checkInterval must be positive")
+		}
+	}
+
+	// Validate orphan sidecar cleanup
+	if orphanCleanup := cfg.Spec.Destination.OrphanCleanup; orphanCleanup.GracePeriod != 0 {
+		if orphanCleanup.GracePeriod < 0 {
+			return fmt.Errorf("destination.orphanCleanup.gracePeriod cannot be negative")
+		}
+		if orphanCleanup.CheckInterval <= 0 {
+			return fmt.Errorf("destination.orphanCleanup.checkInterval must be positive")
+		}
+		switch orphanCleanup.Action {
+		case OrphanCleanupDelete:
+		case OrphanCleanupQuarantine:
+			if cfg.Spec.Destination.QuarantineFolder == "" {
+				return fmt.Errorf("destination.orphanCleanup: quarantine requires destination.quarantineFolder")
+			}
+		default:
+			return fmt.Errorf("destination.orphanCleanup.action must be one of: delete, quarantine")
+		}
+	}
+
 	// Validate concurrency settings
 	if cfg.Spec.Concurrency.Workers <= 0 {
 		return fmt.Errorf("concurrency.workers must be positive")
@@ -82,28 +705,198 @@ func validateConfig(cfg *Config) error {
 	if cfg.Spec.Concurrency.QueueSize <= 0 {
 		return fmt.Errorf("concurrency.queueSize must be positive")
 	}
+	if cfg.Spec.Concurrency.HashingSlots < 0 {
+		return fmt.Errorf("concurrency.hashingSlots cannot be negative")
+	}
+	if cfg.Spec.Concurrency.SubmitWarnAfter < 0 {
+		return fmt.Errorf("concurrency.submitWarnAfter cannot be negative")
+	}
+	if cfg.Spec.Concurrency.StallTimeout < 0 {
+		return fmt.Errorf("concurrency.stallTimeout cannot be negative")
+	}
+
+	// Validate maintenance windows
+	if _, err := NewMaintenanceSchedule(cfg.Spec.Maintenance, nil); err != nil {
+		return err
+	}
+	switch cfg.Spec.Concurrency.SubmitMode {
+	case SubmitModeNonblocking, SubmitModeBlocking:
+	default:
+		return fmt.Errorf("concurrency.submitMode must be one of: nonblocking, blocking")
+	}
+
+	// Validate batch grouping
+	switch cfg.Spec.Output.Batches.GroupBy {
+	case "", BatchBySubfolder:
+	case BatchByPattern:
+		re, err := regexp.Compile(cfg.Spec.Output.Batches.Pattern)
+		if err != nil {
+			return fmt.Errorf("output.batches.pattern is not a valid regex: %w", err)
+		}
+		if re.NumSubexp() == 0 {
+			return fmt.Errorf("output.batches.pattern must contain a capture group for the batch id")
+		}
+	default:
+		return fmt.Errorf("output.batches.groupBy must be one of: subfolder, pattern")
+	}
+	if cfg.Spec.Output.Batches.CompleteAfter < 0 {
+		return fmt.Errorf("output.batches.completeAfter cannot be negative")
+	}
 
 	// Validate output settings
-	if cfg.Spec.Output.VerificationFile == "" {
-		return fmt.Errorf("output.verificationFile cannot be empty")
+	switch cfg.Spec.Output.SourcePath {
+	case SourcePathRelative, SourcePathFull:
+	default:
+		return fmt.Errorf("output.sourcePath must be one of: relative, full")
 	}
-	if cfg.Spec.Output.StatsFile == "" {
-		return fmt.Errorf("output.statsFile cannot be empty")
+	switch cfg.Spec.Output.Reconcile {
+	case ReconcileOff:
+	case ReconcileReport, ReconcileRepair:
+		if !hasSink(cfg, "csv") {
+			return fmt.Errorf("output.reconcile requires the csv sink")
+		}
+	default:
+		return fmt.Errorf("output.reconcile must be one of: off, report, repair")
 	}
-	if cfg.Spec.Output.FlushInterval <= 0 {
-		return fmt.Errorf("output.flushInterval must be positive")
+	for _, sink := range cfg.Spec.Output.Sinks {
+		switch sink {
+		case "csv":
+			if cfg.Spec.Output.VerificationFile == "" {
+				return fmt.Errorf("output.verificationFile cannot be empty")
+			}
+			if cfg.Spec.Output.StatsFile == "" {
+				return fmt.Errorf("output.statsFile cannot be empty")
+			}
+			if cfg.Spec.Output.FlushInterval <= 0 {
+				return fmt.Errorf("output.flushInterval must be positive")
+			}
+		case "syslog":
+			if cfg.Spec.Output.Syslog.Network != "udp" && cfg.Spec.Output.Syslog.Network != "tcp" {
+				return fmt.Errorf("output.syslog.network must be one of: udp, tcp")
+			}
+			if cfg.Spec.Output.Syslog.Address == "" {
+				return fmt.Errorf("output.syslog.address cannot be empty")
+			}
+			if _, ok := syslogFacilities[cfg.Spec.Output.Syslog.Facility]; !ok {
+				return fmt.Errorf("output.syslog.facility is not a known syslog facility: %s", cfg.Spec.Output.Syslog.Facility)
+			}
+			if cfg.Spec.Output.Syslog.BufferSize <= 0 {
+				return fmt.Errorf("output.syslog.bufferSize must be positive")
+			}
+		case "database":
+			// Checked with the database settings below
+		case "ledger":
+			if cfg.Spec.Output.Ledger.File == "" {
+				return fmt.Errorf("output.ledger.file cannot be empty")
+			}
+		case "kafka":
+			if len(cfg.Spec.Output.Kafka.Brokers) == 0 {
+				return fmt.Errorf("output.kafka.brokers cannot be empty")
+			}
+			if cfg.Spec.Output.Kafka.Topic == "" {
+				return fmt.Errorf("output.kafka.topic cannot be empty")
+			}
+			if cfg.Spec.Output.Kafka.BufferSize <= 0 {
+				return fmt.Errorf("output.kafka.bufferSize must be positive")
+			}
+			if cfg.Spec.Output.Kafka.Timeout <= 0 {
+				return fmt.Errorf("output.kafka.timeout must be positive")
+			}
+			switch cfg.Spec.Output.Kafka.Overflow {
+			case KafkaOverflowBlock, KafkaOverflowDrop:
+			case KafkaOverflowSpill:
+				if cfg.Spec.Output.Kafka.SpillFile == "" {
+					return fmt.Errorf("output.kafka.spillFile is required with overflow: spill")
+				}
+			default:
+				return fmt.Errorf("output.kafka.overflow must be one of: block, drop, spill")
+			}
+		default:
+			return fmt.Errorf("output.sinks contains unknown sink %q (must be csv, syslog, kafka, database or ledger)", sink)
+		}
+	}
+
+	// Validate database settings (only when the database is used)
+	if usesDatabase(cfg) {
+		if cfg.Spec.Database.DSN == "" {
+			return fmt.Errorf("database.dsn cannot be empty")
+		}
+		if !dbTableName.MatchString(cfg.Spec.Database.Table) {
+			return fmt.Errorf("database.table must be a plain table name (optionally schema.table): %s", cfg.Spec.Database.Table)
+		}
+		if cfg.Spec.Database.Timeout <= 0 {
+			return fmt.Errorf("database.timeout must be positive")
+		}
+	}
+
+	// Validate Redis settings (only when Redis is the hash source)
+	if cfg.Spec.Verification.ExpectedHashSource == HashSourceRedis {
+		if _, _, err := net.SplitHostPort(cfg.Spec.Redis.Address); err != nil {
+			return fmt.Errorf("redis.address must be host:port: %w", err)
+		}
+		if cfg.Spec.Redis.DB < 0 {
+			return fmt.Errorf("redis.db cannot be negative")
+		}
+		if cfg.Spec.Redis.Timeout <= 0 {
+			return fmt.Errorf("redis.timeout must be positive")
+		}
+		if cfg.Spec.Redis.CacheFor < 0 {
+			return fmt.Errorf("redis.cacheFor cannot be negative")
+		}
 	}
 
 	// Validate logging level
-	validLevels := map[string]bool{"DEBUG": true, "INFO": true, "WARN": true, "ERROR": true}
-	if !validLevels[cfg.Spec.Logging.Level] {
+	if !validLogLevels[cfg.Spec.Logging.Level] {
 		return fmt.Errorf("logging.level must be one of: DEBUG, INFO, WARN, ERROR")
 	}
 
+	// Validate tracing settings
+	if cfg.Spec.Tracing.Enabled && cfg.Spec.Tracing.Endpoint == "" {
+		return fmt.Errorf("tracing.endpoint cannot be empty when tracing.enabled is true")
+	}
+
+	// Validate StatsD settings
+	if cfg.Spec.StatsD.Enabled {
+		if _, _, err := net.SplitHostPort(cfg.Spec.StatsD.Address); err != nil {
+			return fmt.Errorf("statsd.address must be host:port when statsd.enabled is true: %w", err)
+		}
+	}
+
+	// Validate heartbeat interval (0 disables it)
+	if cfg.Spec.Logging.HeartbeatInterval < 0 {
+		return fmt.Errorf("logging.heartbeatInterval cannot be negative")
+	}
+
+	// Validate API settings
+	if cfg.Spec.API.Enabled && cfg.Spec.API.ListenAddress == "" {
+		return fmt.Errorf("api.listenAddress cannot be empty when api.enabled is true")
+	}
+	if cfg.Spec.API.EventBuffer < 1 {
+		return fmt.Errorf("api.eventBuffer must be at least 1")
+	}
+
 	return nil
 }
 
-// createDestinationFolders creates verified and DLQ folders if they don't exist
+// isWithinFolder reports whether path is the folder itself or located beneath it
+func isWithinFolder(path, folder string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absFolder, err := filepath.Abs(folder)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(absFolder, absPath)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// createDestinationFolders creates verified, DLQ and quarantine folders if they don't exist
 func createDestinationFolders(cfg *Config) error {
 	// Create verified folder
 	verifiedPath := cfg.Spec.Destination.VerifiedFolder
@@ -117,6 +910,27 @@ func createDestinationFolders(cfg *Config) error {
 		return fmt.Errorf("failed to create DLQ folder %s: %w", dlqPath, err)
 	}
 
+	// Create quarantine folder (optional)
+	if quarantinePath := cfg.Spec.Destination.QuarantineFolder; quarantinePath != "" {
+		if err := os.MkdirAll(quarantinePath, 0755); err != nil {
+			return fmt.Errorf("failed to create quarantine folder %s: %w", quarantinePath, err)
+		}
+	}
+
+	// Create empty sidecar folder (optional)
+	if emptySidecarPath := cfg.Spec.Destination.EmptySidecarFolder; emptySidecarPath != "" {
+		if err := os.MkdirAll(emptySidecarPath, 0755); err != nil {
+			return fmt.Errorf("failed to create empty sidecar folder %s: %w", emptySidecarPath, err)
+		}
+	}
+
+	// Create mispaired folder (optional)
+	if mispairedPath := cfg.Spec.Destination.MispairedFolder; mispairedPath != "" {
+		if err := os.MkdirAll(mispairedPath, 0755); err != nil {
+			return fmt.Errorf("failed to create mispaired folder %s: %w", mispairedPath, err)
+		}
+	}
+
 	return nil
 }
 
@@ -131,17 +945,262 @@ func GetAbsolutePath(configDir, path string) string {
 // PrintConfig displays the loaded configuration (for debugging)
 func PrintConfig(cfg *Config) {
 	fmt.Println("=== Configuration Loaded ===")
+	fmt.Printf("Config Files:    %s\n", strings.Join(cfg.files, " + "))
+	if cfg.profile != "" {
+		fmt.Printf("Profile:         %s\n", cfg.profile)
+	}
 	fmt.Printf("App Name:        %s\n", cfg.AppName)
 	fmt.Printf("Version:         %s\n", cfg.AppVersion)
 	fmt.Printf("Source Folder:   %s\n", cfg.Spec.Source.Folder)
 	fmt.Printf("Scan Interval:   %s\n", cfg.Spec.Source.PeriodicScanInterval)
+	fmt.Printf("Recursive:       %t\n", cfg.Spec.Source.Recursive)
+	if cfg.Spec.Source.ScanParallelism > 1 {
+		fmt.Printf("Scan Parallel:   %d goroutines\n", cfg.Spec.Source.ScanParallelism)
+	}
+	if !cfg.Spec.Source.MinModTime.IsZero() {
+		fmt.Printf("Min Mod Time:    %s\n", cfg.Spec.Source.MinModTime.Format(time.RFC3339))
+	}
+	if cfg.Spec.Source.StartFromNow {
+		fmt.Println("Start From Now:  true (files modified before startup are ignored)")
+	}
+	if len(cfg.Spec.Source.ExcludePatterns) > 0 {
+		fmt.Printf("Exclude:         %v\n", cfg.Spec.Source.ExcludePatterns)
+	}
+	if len(cfg.Spec.Source.PreScanCommand) > 0 {
+		fmt.Printf("Pre-Scan:        %v (timeout %s)\n", cfg.Spec.Source.PreScanCommand, cfg.Spec.Source.PreScanTimeout)
+	}
+	if cfg.Spec.Source.TarListener.Address != "" {
+		fmt.Printf("Tar Listener:    %s (idle timeout %s, up to %d streams)\n", cfg.Spec.Source.TarListener.Address, cfg.Spec.Source.TarListener.IdleTimeout, cfg.Spec.Source.TarListener.MaxStreams)
+		if len(cfg.Spec.Source.TarListener.AllowedCIDRs) > 0 {
+			fmt.Printf("Tar Senders:     %s\n", strings.Join(cfg.Spec.Source.TarListener.AllowedCIDRs, ", "))
+		}
+	}
 	fmt.Printf("Retry Timeout:   %s\n", cfg.Spec.Verification.RetryTimeout)
 	fmt.Printf("Buffer Size:     %d bytes\n", cfg.Spec.Verification.BufferSize)
-	fmt.Printf("File Filters:    %v\n", cfg.Spec.Verification.FileFilters)
+	fmt.Printf("Hash Encoding:   %s\n", cfg.Spec.Verification.HashEncoding)
+	if cfg.Spec.Verification.FilterMode == FilterModeExclude {
+		fmt.Printf("Data Files:      all except %v\n", cfg.Spec.Verification.ExcludeFilters)
+	} else {
+		fmt.Printf("File Filters:    %v\n", cfg.Spec.Verification.FileFilters)
+	}
+	if cfg.Spec.Verification.CaseInsensitive {
+		fmt.Printf("File Names:      case-insensitive\n")
+	}
+	for _, rule := range cfg.Spec.Verification.Transforms {
+		fmt.Printf("Transform:       %s -> %v\n", rule.Pattern, rule.Steps)
+	}
+	if checkpoint := cfg.Spec.Verification.Checkpoint; checkpoint.Folder != "" {
+		fmt.Printf("Checkpoints:     %s (files >= %d bytes, every %d bytes)\n",
+			checkpoint.Folder, checkpoint.MinSizeBytes, checkpoint.IntervalBytes)
+	}
+	if detector := cfg.Spec.Verification.ChangeDetector; detector.Enabled {
+		fmt.Printf("Change Detector: %d samples of %d bytes (pairs left in source re-verified when changed)\n",
+			detector.Samples, detector.SampleSize)
+	}
+	if largeRead := cfg.Spec.Verification.LargeRead; largeRead.Enabled {
+		fmt.Printf("Large Reads:     %d bytes per read, %d read ahead (up to %d bytes per worker)\n",
+			largeRead.BufferSize, largeRead.Readahead, largeRead.BufferSize*(largeRead.Readahead+1))
+	}
+	if chunkManifest := cfg.Spec.Verification.ChunkManifest; chunkManifest.Enabled {
+		fmt.Printf("Chunk Manifests: *%s (required: %t)\n", chunkManifest.Suffix, chunkManifest.Required)
+	}
+	if cfg.Spec.Verification.CheckSidecarFilename {
+		fmt.Println("Check Filename:  true (sidecars naming another file are mispaired)")
+	}
+	if cfg.Spec.Verification.ReverifyOnSidecarChange {
+		fmt.Println("Reverify:        true (verified pairs left in place are re-verified when their sidecar changes)")
+	}
+	if cfg.Spec.Verification.AnyMatch {
+		fmt.Println("Any Match:       true (sidecars may list several acceptable hashes)")
+	}
+	if cfg.Spec.Verification.ShadowAlgorithm != "" {
+		fmt.Printf("Shadow Hash:     %s (logged only)\n", cfg.Spec.Verification.ShadowAlgorithm)
+	}
+	if cfg.Spec.Verification.TrustKnownHashes {
+		fmt.Printf("Known Hashes:    %s (trusted without hashing)\n", cfg.Spec.Verification.KnownHashesFile)
+	}
+	if cfg.Spec.Verification.DenyListFile != "" {
+		fmt.Printf("Deny-List:       %s (quarantined)\n", cfg.Spec.Verification.DenyListFile)
+	}
+	if signature := cfg.Spec.Verification.Signature; signature.PublicKeyFile != "" {
+		fmt.Printf("Signatures:      %s (*%s, required: %t)\n", signature.PublicKeyFile, signature.Suffix, signature.Required)
+	}
+	if cfg.Spec.Verification.VerdictCacheSize > 0 {
+		fmt.Printf("Verdict Cache:   %d entries (verdicts of content verified in this run are reused)\n", cfg.Spec.Verification.VerdictCacheSize)
+	}
+	if cfg.Spec.Verification.IntegrityOracle != IntegrityOracleNone {
+		fmt.Printf("Integrity:       %s oracle (hashes of intact files are reused on retry)\n", cfg.Spec.Verification.IntegrityOracle)
+	}
+	if cfg.Spec.Verification.InodeDedup {
+		fmt.Println("Inode Dedup:     true (hard links hashed once)")
+	}
+	if cfg.Spec.Verification.DetectSparse {
+		fmt.Println("Detect Sparse:   true (hole bytes recorded)")
+	}
+	if cfg.Spec.Verification.DetectAlgorithm {
+		fmt.Println("Detect Algo:     true (sidecars with another algorithm's digest are verified with it)")
+	}
+	if len(cfg.Spec.Verification.SidecarSuffixes) > 1 {
+		fmt.Printf("Sidecars:        %v\n", cfg.Spec.Verification.SidecarSuffixes)
+	}
+	if cfg.Spec.Verification.DuplicateSidecars == DuplicateSidecarsTrack {
+		fmt.Println("Sidecar Copies:  tracked as sidecars of their own data file")
+	}
+	if cfg.Spec.Verification.FilenameHashPattern != "" {
+		fmt.Printf("Filename Hash:   %s\n", cfg.Spec.Verification.FilenameHashPattern)
+	}
+	if manifest := cfg.Spec.Verification.Manifest; manifest.Name != "" {
+		scope := "per folder"
+		if manifest.Hierarchy {
+			scope = "covering subfolders"
+		}
+		fmt.Printf("Manifests:       %s (%s, listed files missing after %s)\n", manifest.Name, scope, manifest.MissingTimeout)
+	}
+	if len(cfg.Spec.Verification.ArchiveIntegrity) > 0 {
+		fmt.Printf("Archive Checks:  %v (member CRCs, no sidecar)\n", cfg.Spec.Verification.ArchiveIntegrity)
+	}
+	if len(cfg.Spec.Verification.EmbeddedChecksum.Patterns) > 0 {
+		fmt.Printf("Embedded Sum:    %v (payload checked against member %s)\n", cfg.Spec.Verification.EmbeddedChecksum.Patterns, cfg.Spec.Verification.EmbeddedChecksum.Member)
+	}
 	fmt.Printf("Verified Folder: %s\n", cfg.Spec.Destination.VerifiedFolder)
 	fmt.Printf("DLQ Folder:      %s\n", cfg.Spec.Destination.DlqFolder)
+	fmt.Printf("On Collision:    %s\n", cfg.Spec.Destination.OnCollision)
+	if cfg.Spec.Destination.ResultFiles {
+		fmt.Println("Result Files:    <datafile>.result.json next to each delivered file")
+	}
+	if cfg.Spec.Destination.IntentLog != "" {
+		fmt.Printf("Intent Log:      %s\n", cfg.Spec.Destination.IntentLog)
+	}
+	if cfg.Spec.Destination.NamingTemplate != "" {
+		fmt.Printf("Naming Template: %s\n", cfg.Spec.Destination.NamingTemplate)
+	}
+	for _, rule := range cfg.Spec.Destination.Compress {
+		fmt.Printf("Compress:        %s (gzip level %d)\n", rule.Pattern, rule.Level)
+	}
+	for _, rule := range cfg.Spec.Destination.LatestLinks {
+		fmt.Printf("Latest Link:     %s -> newest %s\n", rule.Name, rule.Pattern)
+	}
+	if cfg.Spec.Destination.QuarantineFolder != "" {
+		fmt.Printf("Quarantine:      %s\n", cfg.Spec.Destination.QuarantineFolder)
+	}
+	if cfg.Spec.Destination.EmptySidecarFolder != "" {
+		fmt.Printf("Empty Sidecars:  %s\n", cfg.Spec.Destination.EmptySidecarFolder)
+	}
+	if cfg.Spec.Destination.MispairedFolder != "" {
+		fmt.Printf("Mispaired:       %s\n", cfg.Spec.Destination.MispairedFolder)
+	}
+	fmt.Printf("Orphan Sidecars: %s\n", cfg.Spec.Destination.OrphanSidecars)
+	if cfg.Spec.Destination.SidecarDeleteDelay > 0 {
+		fmt.Printf("Sidecar Delete:  after %s (delivered file checked again first)\n", cfg.Spec.Destination.SidecarDeleteDelay)
+	}
+	if cfg.Spec.Destination.ConfirmDelivery {
+		fmt.Println("Confirm:         true (delivered files are re-read and re-hashed)")
+	}
+	if cfg.Spec.Destination.PreserveSparse {
+		fmt.Println("Preserve Sparse: true (copies keep holes)")
+	}
+	if retention := cfg.Spec.Destination.Retention; retention.MaxAge > 0 || retention.MaxSizeBytes > 0 {
+		fmt.Printf("Retention:       maxAge=%s maxSize=%d bytes (every %s)\n",
+			retention.MaxAge, retention.MaxSizeBytes, retention.CheckInterval)
+	}
+	if dlqRetention := cfg.Spec.Destination.DLQRetention; dlqRetention.enabled() {
+		fmt.Printf("DLQ Retention:   maxAge=%s maxSize=%d bytes, alert above %d bytes or %d files (every %s)\n",
+			dlqRetention.MaxAge, dlqRetention.MaxSizeBytes, dlqRetention.AlertSizeBytes, dlqRetention.AlertFiles,
+			cfg.Spec.Destination.Retention.CheckInterval)
+	}
+	if growing := cfg.Spec.Source.Growing; len(growing.Patterns) > 0 {
+		fmt.Printf("Growing Files:   %v verified in place against <file>%s (%s, every %s)\n",
+			growing.Patterns, growing.Suffix, growing.Algorithm, growing.CheckInterval)
+	}
+	if orphanCleanup := cfg.Spec.Destination.OrphanCleanup; orphanCleanup.GracePeriod > 0 {
+		fmt.Printf("Orphan Cleanup:  %s sidecars without data file for %s (every %s)\n",
+			orphanCleanup.Action, orphanCleanup.GracePeriod, orphanCleanup.CheckInterval)
+	}
+	if cfg.Spec.MaxRuntime > 0 {
+		fmt.Printf("Max Runtime:     %s (then exit code %d)\n", cfg.Spec.MaxRuntime, exitMaxRuntime)
+	}
+	if cfg.Spec.ExitNonZeroOnAnyFailure {
+		fmt.Printf("Exit On Failure: code %d if any file failed\n", exitFilesFailed)
+	}
 	fmt.Printf("Workers:         %d\n", cfg.Spec.Concurrency.Workers)
 	fmt.Printf("Queue Size:      %d\n", cfg.Spec.Concurrency.QueueSize)
+	if cfg.Spec.Concurrency.SubmitMode == SubmitModeBlocking {
+		fmt.Println("Submit Mode:     blocking (waits for room in the queue)")
+	}
+	if cfg.Spec.Concurrency.StallTimeout > 0 {
+		fmt.Printf("Stall Timeout:   %s (stuck workers are replaced)\n", cfg.Spec.Concurrency.StallTimeout)
+	}
+	if slots := cfg.Spec.Concurrency.HashingSlots; slots > 0 {
+		fmt.Printf("Hashing Slots:   %d (of %d CPUs available)\n", slots, runtime.GOMAXPROCS(0))
+	}
+	if windows := cfg.Spec.Maintenance.Windows; len(windows) > 0 {
+		timezone := cfg.Spec.Maintenance.Timezone
+		if timezone == "" {
+			timezone = "local time"
+		}
+		fmt.Printf("Maintenance:     %v (%s, scanning and submission pause)\n", windows, timezone)
+	}
+	fmt.Printf("Output Sinks:    %v\n", cfg.Spec.Output.Sinks)
+	if cfg.Spec.Output.Reconcile != ReconcileOff {
+		fmt.Printf("Reconcile:       %s (verified folder against %s at startup)\n", cfg.Spec.Output.Reconcile, cfg.Spec.Output.VerificationFile)
+	}
+	if batches := cfg.Spec.Output.Batches; batches.GroupBy != "" {
+		fmt.Printf("Batches:         by %s (complete after %s)\n", batches.GroupBy, batches.CompleteAfter)
+	}
+	if cfg.Spec.Output.Ledger.File != "" {
+		fmt.Printf("Ledger:          %s\n", cfg.Spec.Output.Ledger.File)
+	}
+	if cfg.Spec.Output.FlushImmediately {
+		fmt.Println("CSV Flush:       every record")
+	}
+	if cfg.Spec.Output.SourcePath == SourcePathFull {
+		fmt.Println("Source Paths:    full")
+	}
+	if cfg.Spec.Output.CSVOptional {
+		fmt.Println("CSV Optional:    true (stderr if the CSV files can't be opened)")
+	}
 	fmt.Printf("Log Level:       %s\n", cfg.Spec.Logging.Level)
+	if cfg.Spec.Logging.HeartbeatInterval > 0 {
+		fmt.Printf("Heartbeat:       %s\n", cfg.Spec.Logging.HeartbeatInterval)
+	}
+	if cfg.Spec.Logging.ArrivalSkew {
+		fmt.Println("Arrival Skew:    logged per pair")
+	}
+	if cfg.Spec.API.Enabled {
+		fmt.Printf("API Address:     %s\n", cfg.Spec.API.ListenAddress)
+		if cfg.Spec.API.Events {
+			fmt.Printf("API Events:      /events (%d events buffered per client)\n", cfg.Spec.API.EventBuffer)
+		}
+		if cfg.Spec.API.DisableMetrics {
+			fmt.Println("API Metrics:     disabled")
+		}
+	}
+	if cfg.Spec.StatsD.Enabled {
+		fmt.Printf("StatsD:          %s (prefix %s, every %s)\n", cfg.Spec.StatsD.Address, cfg.Spec.StatsD.Prefix, statsInterval)
+	}
+	if cfg.Spec.Tracing.Enabled {
+		fmt.Printf("OTLP Endpoint:   %s\n", cfg.Spec.Tracing.Endpoint)
+	}
+	if usesDatabase(cfg) {
+		fmt.Printf("Database Table:  %s (expected hashes: %s)\n", cfg.Spec.Database.Table, cfg.Spec.Verification.ExpectedHashSource)
+	}
+	if cfg.Spec.Verification.ExpectedHashSource == HashSourceRedis {
+		fmt.Printf("Redis:           %s (keys: %s<file name>, cached up to %s)\n", cfg.Spec.Redis.Address, cfg.Spec.Redis.KeyPrefix, cfg.Spec.Redis.CacheFor)
+	}
 	fmt.Println("============================")
 }
+
+// usesDatabase reports whether the database is the hash source or a result sink
+func usesDatabase(cfg *Config) bool {
+	return cfg.Spec.Verification.ExpectedHashSource == HashSourceDatabase || hasSink(cfg, "database")
+}
+
+// hasSink reports whether a result sink is selected
+func hasSink(cfg *Config, name string) bool {
+	for _, sink := range cfg.Spec.Output.Sinks {
+		if sink == name {
+			return true
+		}
+	}
+	return false
+}
@@ -2,12 +2,24 @@ package main
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
+	"unicode"
 )
 
+// verificationHeader names the columns of the verification CSV
+var verificationHeader = []string{"Timestamp", "Filename", "SHA256", "Size_Bytes", "Size_KB", "Duration_Seconds", "Action", "Destination_Path", "Shadow_Hash", "Hole_Bytes", "Compressed_Bytes", "Source_Path", "Linked_To"}
+
+// statsHeader names the columns of the stats CSV
+var statsHeader = []string{"Timestamp", "TotalProcessed", "SuccessCount", "FailureCount", "PendingCount", "AverageDuration", "TotalBytesVerified"}
+
 // CSVLogger handles buffered CSV logging for verification results and statistics
 type CSVLogger struct {
 	verificationFile   *os.File
@@ -15,13 +27,23 @@ type CSVLogger struct {
 	verificationWriter *csv.Writer
 	statsWriter        *csv.Writer
 	flushInterval      time.Duration
+	flushImmediately   bool
 	mutex              sync.Mutex
 	stopChan           chan struct{}
 	wg                 sync.WaitGroup
 }
 
 // NewCSVLogger creates a new CSV logger and starts the periodic flush routine
-func NewCSVLogger(verificationFilePath, statsFilePath string, flushInterval time.Duration) (*CSVLogger, error) {
+// With flushImmediately every record is flushed and synced to disk as it is written
+// A file written with other columns (by an older version) is rotated away first
+func NewCSVLogger(verificationFilePath, statsFilePath string, flushInterval time.Duration, flushImmediately bool) (*CSVLogger, error) {
+	if err := rotateOnHeaderChange(verificationFilePath, verificationHeader); err != nil {
+		return nil, err
+	}
+	if err := rotateOnHeaderChange(statsFilePath, statsHeader); err != nil {
+		return nil, err
+	}
+
 	// Open verification CSV file
 	verificationFile, err := os.OpenFile(verificationFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
@@ -45,6 +67,7 @@ func NewCSVLogger(verificationFilePath, statsFilePath string, flushInterval time
 		verificationWriter: verificationWriter,
 		statsWriter:        statsWriter,
 		flushInterval:      flushInterval,
+		flushImmediately:   flushImmediately,
 		stopChan:           make(chan struct{}),
 	}
 
@@ -71,8 +94,7 @@ func (l *CSVLogger) writeHeadersIfNeeded(verificationPath, statsPath string) err
 
 	if verificationInfo.Size() == 0 {
 		// Write verification CSV header
-		header := []string{"Timestamp", "Filename", "SHA256", "Size_Bytes", "Size_KB", "Duration_Seconds"}
-		if err := l.verificationWriter.Write(header); err != nil {
+		if err := l.verificationWriter.Write(verificationHeader); err != nil {
 			return fmt.Errorf("failed to write verification header: %w", err)
 		}
 	}
@@ -85,8 +107,7 @@ func (l *CSVLogger) writeHeadersIfNeeded(verificationPath, statsPath string) err
 
 	if statsInfo.Size() == 0 {
 		// Write stats CSV header
-		header := []string{"Timestamp", "TotalProcessed", "SuccessCount", "FailureCount", "PendingCount", "AverageDuration"}
-		if err := l.statsWriter.Write(header); err != nil {
+		if err := l.statsWriter.Write(statsHeader); err != nil {
 			return fmt.Errorf("failed to write stats header: %w", err)
 		}
 	}
@@ -94,6 +115,45 @@ func (l *CSVLogger) writeHeadersIfNeeded(verificationPath, statsPath string) err
 	return nil
 }
 
+// rotateOnHeaderChange renames a CSV file whose header differs from header to
+// "<name>.<time><ext>", so new rows never land under the columns of an older version
+// A missing or empty file is left alone
+func rotateOnHeaderChange(path string, header []string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	existing, readErr := reader.Read()
+	file.Close()
+	if errors.Is(readErr, io.EOF) || (readErr == nil && slices.Equal(existing, header)) {
+		return nil
+	}
+
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	rotated := strings.TrimSuffix(name, ext) + "." + clockNow().Format("20060102-150405") + ext
+	rotatedPath := filepath.Join(dir, rotated)
+	if FileExists(rotatedPath) {
+		rotatedPath = getUniqueFilePath(dir, rotated)
+	}
+	if err := os.Rename(path, rotatedPath); err != nil {
+		return fmt.Errorf("failed to rotate %s, its columns differ from this version's: %w", path, err)
+	}
+
+	if readErr != nil {
+		fmt.Fprintf(os.Stderr, "[CSV] %s has an unreadable header (%v), moved it to %s\n", path, readErr, rotatedPath)
+	} else {
+		fmt.Fprintf(os.Stderr, "[CSV] %s was written with other columns (%d, this version writes %d), moved it to %s\n",
+			path, len(existing), len(header), rotatedPath)
+	}
+	return nil
+}
+
 // LogVerification logs a successful verification to the verification CSV
 func (l *CSVLogger) LogVerification(entry CSVLogEntry) error {
 	l.mutex.Lock()
@@ -106,12 +166,25 @@ func (l *CSVLogger) LogVerification(entry CSVLogEntry) error {
 		fmt.Sprintf("%d", entry.SizeBytes),
 		fmt.Sprintf("%.2f", entry.SizeKB),
 		fmt.Sprintf("%.4f", entry.Duration),
+		entry.Action,
+		entry.DestinationPath,
+		entry.ShadowHash,
+		formatHoleBytes(entry.HoleBytes),
+		formatCompressedBytes(entry.CompressedBytes),
+		entry.SourcePath,
+		entry.LinkedTo,
 	}
 
 	if err := l.verificationWriter.Write(record); err != nil {
 		return fmt.Errorf("failed to write verification record: %w", err)
 	}
 
+	if l.flushImmediately {
+		if err := syncWriter(l.verificationWriter, l.verificationFile); err != nil {
+			return fmt.Errorf("failed to flush verification record: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -127,12 +200,19 @@ func (l *CSVLogger) LogStats(entry StatsEntry) error {
 		fmt.Sprintf("%d", entry.FailureCount),
 		fmt.Sprintf("%d", entry.PendingCount),
 		fmt.Sprintf("%.4f", entry.AverageDuration),
+		fmt.Sprintf("%d", entry.TotalBytesVerified),
 	}
 
 	if err := l.statsWriter.Write(record); err != nil {
 		return fmt.Errorf("failed to write stats record: %w", err)
 	}
 
+	if l.flushImmediately {
+		if err := syncWriter(l.statsWriter, l.statsFile); err != nil {
+			return fmt.Errorf("failed to flush stats record: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -165,6 +245,15 @@ func (l *CSVLogger) Flush() error {
 	return nil
 }
 
+// syncWriter flushes a CSV writer and syncs its underlying file to disk
+func syncWriter(w *csv.Writer, f *os.File) error {
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
 // periodicFlush flushes CSV data at regular intervals
 func (l *CSVLogger) periodicFlush() {
 	defer l.wg.Done()
@@ -219,19 +308,72 @@ func (l *CSVLogger) Close() error {
 	return nil
 }
 
+// How the source path of a verified file is recorded (output.sourcePath)
+const (
+	SourcePathRelative = "relative" // Relative to the source folder
+	SourcePathFull     = "full"     // Full path
+)
+
 // CreateCSVLogEntry creates a CSVLogEntry from a VerificationResult
-func CreateCSVLogEntry(result VerificationResult) CSVLogEntry {
+// The source path is recorded relative to sourceFolder (empty = full path)
+func CreateCSVLogEntry(result VerificationResult, action, destinationPath, sourceFolder string) CSVLogEntry {
 	sizeKB := float64(result.Job.FilePair.DataSize) / 1024.0
 	durationSeconds := result.Duration.Seconds()
 
+	sourcePath := result.Job.FilePair.DataFilePath
+	if relative, err := filepath.Rel(sourceFolder, sourcePath); sourceFolder != "" && sourcePath != "" && err == nil {
+		sourcePath = relative
+	}
+
 	return CSVLogEntry{
-		Timestamp: result.Timestamp.Format("2006-01-02 15:04:05"),
-		Filename:  result.Job.FilePair.DataFile,
-		SHA256:    result.ComputedHash,
-		SizeBytes: result.Job.FilePair.DataSize,
-		SizeKB:    sizeKB,
-		Duration:  durationSeconds,
+		Timestamp:       result.Timestamp.Format("2006-01-02 15:04:05"),
+		Filename:        escapeControlChars(result.Job.FilePair.DataFile),
+		SHA256:          result.ComputedHash,
+		SizeBytes:       result.Job.FilePair.DataSize,
+		SizeKB:          sizeKB,
+		Duration:        durationSeconds,
+		Action:          action,
+		DestinationPath: escapeControlChars(destinationPath),
+		ShadowHash:      result.ShadowHash,
+		HoleBytes:       result.HoleBytes,
+		CompressedBytes: result.CompressedBytes,
+		SourcePath:      escapeControlChars(sourcePath),
+		LinkedTo:        escapeControlChars(result.LinkedTo),
+	}
+}
+
+// formatHoleBytes formats the hole bytes column (empty when not checked)
+func formatHoleBytes(holeBytes int64) string {
+	if holeBytes < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", holeBytes)
+}
+
+// formatCompressedBytes formats the compressed size column (empty when delivered as is)
+func formatCompressedBytes(compressedBytes int64) string {
+	if compressedBytes <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", compressedBytes)
+}
+
+// escapeControlChars replaces control characters (newlines, NUL, ...) with \xNN escapes
+// CSV quoting alone does not protect downstream parsers from these
+func escapeControlChars(s string) string {
+	if strings.IndexFunc(s, unicode.IsControl) < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			fmt.Fprintf(&b, "\\x%02x", r)
+			continue
+		}
+		b.WriteRune(r)
 	}
+	return b.String()
 }
 
 // CreateStatsEntry creates a StatsEntry from Statistics
@@ -242,11 +384,12 @@ func CreateStatsEntry(stats Statistics) StatsEntry {
 	}
 
 	return StatsEntry{
-		Timestamp:       time.Now().Format("2006-01-02 15:04:05"),
-		TotalProcessed:  stats.TotalProcessed,
-		SuccessCount:    stats.SuccessCount,
-		FailureCount:    stats.FailureCount,
-		PendingCount:    stats.PendingCount,
-		AverageDuration: avgDuration,
+		Timestamp:          clockNow().Format("2006-01-02 15:04:05"),
+		TotalProcessed:     stats.TotalProcessed,
+		SuccessCount:       stats.SuccessCount,
+		FailureCount:       stats.FailureCount,
+		PendingCount:       stats.PendingCount,
+		AverageDuration:    avgDuration,
+		TotalBytesVerified: stats.TotalBytesVerified,
 	}
 }
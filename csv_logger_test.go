@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readTestCSV returns the rows of a CSV file
+func readTestCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+// openTestCSVLogger opens and closes a CSV logger on the files in dir
+func openTestCSVLogger(t *testing.T, dir string) {
+	t.Helper()
+	logger, err := NewCSVLogger(filepath.Join(dir, "verification.csv"), filepath.Join(dir, "stats.csv"), time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCSVLoggerRotatesFileWithOldHeader(t *testing.T) {
+	dir := t.TempDir()
+	old := "Timestamp,Filename,SHA256,Size_Bytes,Size_KB,Duration_Seconds\n2020-01-01 00:00:00,data.zip,abc,4,0.00,0.1000\n"
+	writeTestFile(t, dir, "verification.csv", old)
+
+	openTestCSVLogger(t, dir)
+
+	rows := readTestCSV(t, filepath.Join(dir, "verification.csv"))
+	if len(rows) != 1 || !slices.Equal(rows[0], verificationHeader) {
+		t.Errorf("verification.csv = %v, want only the current header", rows)
+	}
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "verification.*.csv"))
+	if len(rotated) != 1 {
+		t.Fatalf("rotated files = %v, want one", rotated)
+	}
+	if got := readTestFile(t, rotated[0]); got != old {
+		t.Errorf("rotated file content = %q, want %q", got, old)
+	}
+}
+
+func TestCSVLoggerKeepsFileWithCurrentHeader(t *testing.T) {
+	dir := t.TempDir()
+	openTestCSVLogger(t, dir)
+	row := "2020-01-01 00:00:00,data.zip" + strings.Repeat(",", len(verificationHeader)-2) + "\n"
+	file, err := os.OpenFile(filepath.Join(dir, "verification.csv"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(row)
+	file.Close()
+
+	openTestCSVLogger(t, dir)
+
+	if rows := readTestCSV(t, filepath.Join(dir, "verification.csv")); len(rows) != 2 {
+		t.Errorf("verification.csv has %d rows, want the header and the existing row", len(rows))
+	}
+	if rotated, _ := filepath.Glob(filepath.Join(dir, "*.*.csv")); len(rotated) != 0 {
+		t.Errorf("rotated %v, want nothing rotated", rotated)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	_ "github.com/lib/pq" // Postgres driver for database/sql
+)
+
+/*
+Database integration for a Postgres table holding one row per expected file:
+
+	CREATE TABLE file_hashes (
+	    filename      text PRIMARY KEY,
+	    expected_hash text NOT NULL,
+	    status        text,
+	    verified_at   timestamptz,
+	    computed_hash text
+	);
+
+Responsibilities:
+1. DatabaseHashProvider: read the expected hash of a data file by its name
+   (verification.expectedHashSource: database), instead of a .sha256 file
+2. DatabaseLogger: result sink writing status, verified_at and computed_hash
+   back to the row of each verified file ("database" in output.sinks)
+3. Survive connection loss: the pool reconnects on demand, statements are
+   prepared lazily, and failures are returned as errors (the worker retries
+   a failed lookup like any other failed attempt)
+
+Does NOT:
+- Create or migrate the table
+- Insert rows for files that are not in the table
+*/
+
+// Expected hash sources
+const (
+	HashSourceSidecar  = "sidecar"  // <datafile>.sha256 next to the data file
+	HashSourceDatabase = "database" // Row in database.table keyed by file name
+	HashSourceRedis    = "redis"    // Key "<redis.keyPrefix><file name>" (redis_provider.go)
+)
+
+// dbStatusVerified is written to the status column of verified files
+const dbStatusVerified = "verified"
+
+// Result rows are written a few times before the record is reported as failed
+const (
+	dbWriteAttempts = 3
+	dbWriteBackoff  = 500 * time.Millisecond // Doubled after each failed attempt
+)
+
+// dbTableName limits database.table to plain (optionally schema-qualified) identifiers
+// The name is interpolated into SQL, so anything else is rejected by validateConfig
+var dbTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// ErrNotInDatabase is returned when the table has no row (or no expected hash) for a data file
+var ErrNotInDatabase = errors.New("file not listed in database")
+
+// ExpectedHashProvider supplies expected digests from somewhere other than .sha256 files
+type ExpectedHashProvider interface {
+	ExpectedHash(filePair FilePair) (string, error)
+	Close() error
+}
+
+// dbClient is a connection pool with lazily prepared statements
+type dbClient struct {
+	db         *sql.DB
+	timeout    time.Duration
+	mutex      sync.Mutex
+	statements map[string]*sql.Stmt
+}
+
+// openDatabase creates the connection pool (no connection is made until first use)
+func openDatabase(cfg DatabaseConfig) (*dbClient, error) {
+	db, err := sql.Open("postgres", cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return &dbClient{
+		db:         db,
+		timeout:    cfg.Timeout,
+		statements: make(map[string]*sql.Stmt),
+	}, nil
+}
+
+// statement returns the prepared statement for query, preparing it on first use
+// A failed prepare (e.g. database unreachable) is retried on the next call
+func (c *dbClient) statement(ctx context.Context, query string) (*sql.Stmt, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if stmt, exists := c.statements[query]; exists {
+		return stmt, nil
+	}
+
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	c.statements[query] = stmt
+
+	return stmt, nil
+}
+
+// Close closes the prepared statements and the connection pool
+func (c *dbClient) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, stmt := range c.statements {
+		stmt.Close()
+	}
+	c.statements = nil
+
+	return c.db.Close()
+}
+
+// DatabaseHashProvider reads expected hashes from the database table
+type DatabaseHashProvider struct {
+	client *dbClient
+	query  string
+}
+
+// NewDatabaseHashProvider creates a provider reading database.table
+func NewDatabaseHashProvider(cfg DatabaseConfig) (*DatabaseHashProvider, error) {
+	client, err := openDatabase(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DatabaseHashProvider{
+		client: client,
+		query:  fmt.Sprintf("SELECT expected_hash FROM %s WHERE filename = $1", cfg.Table),
+	}, nil
+}
+
+// ExpectedHash returns the expected hash stored for the data file's name
+func (p *DatabaseHashProvider) ExpectedHash(filePair FilePair) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.client.timeout)
+	defer cancel()
+
+	stmt, err := p.client.statement(ctx, p.query)
+	if err != nil {
+		return "", err
+	}
+
+	var expectedHash sql.NullString
+	err = stmt.QueryRowContext(ctx, filePair.DataFile).Scan(&expectedHash)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !expectedHash.Valid) {
+		return "", fmt.Errorf("%w: %s", ErrNotInDatabase, filePair.DataFile)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query expected hash: %w", err)
+	}
+
+	return expectedHash.String, nil
+}
+
+// Close closes the database connections
+func (p *DatabaseHashProvider) Close() error {
+	return p.client.Close()
+}
+
+// DatabaseLogger writes verification outcomes back to the database table
+type DatabaseLogger struct {
+	client *dbClient
+	update string
+}
+
+// NewDatabaseLogger creates a result sink updating database.table
+func NewDatabaseLogger(cfg DatabaseConfig) (*DatabaseLogger, error) {
+	client, err := openDatabase(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DatabaseLogger{
+		client: client,
+		update: fmt.Sprintf("UPDATE %s SET status = $1, verified_at = $2, computed_hash = $3 WHERE filename = $4", cfg.Table),
+	}, nil
+}
+
+// LogVerification records a verified file in its row, retrying briefly on errors
+func (l *DatabaseLogger) LogVerification(entry CSVLogEntry) error {
+	verifiedAt, err := time.ParseInLocation("2006-01-02 15:04:05", entry.Timestamp, time.Local)
+	if err != nil {
+		verifiedAt = time.Now()
+	}
+
+	backoff := dbWriteBackoff
+	for attempt := 1; ; attempt++ {
+		err = l.write(entry, verifiedAt)
+		if err == nil || errors.Is(err, ErrNotInDatabase) || attempt == dbWriteAttempts {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write result for %s: %w", entry.Filename, err)
+	}
+
+	return nil
+}
+
+// write runs the update statement once
+func (l *DatabaseLogger) write(entry CSVLogEntry, verifiedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.client.timeout)
+	defer cancel()
+
+	stmt, err := l.client.statement(ctx, l.update)
+	if err != nil {
+		return err
+	}
+
+	result, err := stmt.ExecContext(ctx, dbStatusVerified, verifiedAt, entry.SHA256, entry.Filename)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrNotInDatabase
+	}
+
+	return nil
+}
+
+// LogStats is a no-op, statistics are not stored in the database
+func (l *DatabaseLogger) LogStats(entry StatsEntry) error {
+	return nil
+}
+
+// Close closes the database connections
+func (l *DatabaseLogger) Close() error {
+	return l.client.Close()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+/*
+DenyList rejects content known to be bad, e.g. from a malware checksum feed
+(verification.denyListFile).
+
+The list file has one entry per line, a hex SHA-256 hash or hash prefix (at
+least denyListMinPrefix digits) optionally followed by a name for reference:
+
+	# hash or prefix                                                 name
+	275a021bbfb6489e54d471899f7db9d1663fc695ec2fe2a2c4538aabf651fd0f eicar.com
+	275a021bbfb6489e                                                 eicar (prefix)
+
+Responsibilities:
+1. Load the list once at startup
+2. Tell the worker and the tar listener whether the SHA-256 of a data file
+   starts with an entry, whatever algorithm verifies it (the SHA-256 is
+   computed in the same pass when it is not the verified hash); such a file
+   goes to the quarantine folder (DLQ if unset) at once with a "denied hash"
+   reason file, whether or not it matched its sidecar, and a CRITICAL alert
+   is logged
+
+Does NOT:
+- Support lists of other algorithms (MD5 feeds must be converted)
+- Hash the file of a SHA-256 hash trusted without hashing (trustKnownHashes,
+  integrity oracle, inode dedup); files verified with another algorithm are
+  always hashed while a deny-list is loaded
+- Reload the list while running
+*/
+
+// denyListMinPrefix is the shortest hash prefix accepted, shorter ones would match innocent files
+const denyListMinPrefix = 16
+
+// ErrDeniedHash is returned for a data file whose hash is on the deny-list
+var ErrDeniedHash = errors.New("denied hash")
+
+// DenyList maps denied hashes and hash prefixes (lowercase hex) to their names
+type DenyList struct {
+	entries map[string]string
+	lengths []int // Distinct entry lengths, shortest first
+}
+
+// LoadDenyList reads a deny-list file
+func LoadDenyList(path string) (*DenyList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open deny-list file: %w", err)
+	}
+	defer file.Close()
+
+	denied := &DenyList{entries: make(map[string]string)}
+	lengths := make(map[int]bool)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		hash := strings.ToLower(fields[0])
+		// A prefix may end mid-byte, pad it to decode
+		if _, err := hex.DecodeString(hash + strings.Repeat("0", len(hash)%2)); err != nil {
+			return nil, fmt.Errorf("%s line %d: invalid hex hash %q", path, lineNumber, fields[0])
+		}
+		if len(hash) < denyListMinPrefix {
+			return nil, fmt.Errorf("%s line %d: %q is shorter than %d hex digits", path, lineNumber, fields[0], denyListMinPrefix)
+		}
+		if len(hash) > sha256.Size*2 {
+			return nil, fmt.Errorf("%s line %d: %q is longer than a SHA-256 hash", path, lineNumber, fields[0])
+		}
+		denied.entries[hash] = strings.Join(fields[1:], " ")
+		lengths[len(hash)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read deny-list file: %w", err)
+	}
+
+	for length := range lengths {
+		denied.lengths = append(denied.lengths, length)
+	}
+	sort.Ints(denied.lengths)
+	return denied, nil
+}
+
+// Match returns the entry a hash starts with, with its name when it has one
+// Safe to call on a nil list (matches nothing)
+func (d *DenyList) Match(hash string) (string, bool) {
+	if d == nil || hash == "" {
+		return "", false
+	}
+
+	hash = strings.ToLower(hash)
+	for _, length := range d.lengths {
+		if length > len(hash) {
+			break
+		}
+		if name, exists := d.entries[hash[:length]]; exists {
+			if name != "" {
+				return fmt.Sprintf("%s (%s)", hash[:length], name), true
+			}
+			return hash[:length], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+/*
+Destinations holds the folders files are moved to, replaceable at runtime
+(SIGHUP config reload or POST /destinations) so downstream storage can be
+migrated without a restart.
+
+Responsibilities:
+1. Hand out consistent snapshots of all destination folders at once
+2. Validate replacement folders (created if missing, writable, not inside a
+   recursively scanned source folder) before switching to them
+3. Remember a destination whose mount turned read-only (EROFS) until it is
+   writable again; meanwhile the coordinator submits no jobs and GET /readyz
+   reports not ready
+
+A job takes its snapshot when it starts, so it finishes against the folders
+that were current at that time; only later jobs use the new ones.
+
+Does NOT:
+- Move files already delivered to the old folders
+*/
+
+// DestinationFolders is a snapshot of the folders files are moved to
+type DestinationFolders struct {
+	Verified     string `json:"verifiedFolder"`
+	DLQ          string `json:"dlqFolder"`
+	Quarantine   string `json:"quarantineFolder"`   // Empty = refused files stay in place
+	EmptySidecar string `json:"emptySidecarFolder"` // Empty = DLQ
+	Mispaired    string `json:"mispairedFolder"`    // Empty = DLQ
+}
+
+// Destinations guards the current destination folders
+type Destinations struct {
+	mutex        sync.RWMutex
+	current      DestinationFolders
+	readOnly     string // Folder found on a read-only mount ("" = all writable)
+	sourceFolder string
+	recursive    bool
+}
+
+// NewDestinations creates the holder with the configured folders
+func NewDestinations(source SourceConfig, destination DestinationConfig) *Destinations {
+	return &Destinations{
+		current:      destinationFolders(destination),
+		sourceFolder: source.Folder,
+		recursive:    source.Recursive,
+	}
+}
+
+// destinationFolders extracts the destination folders from the configuration
+func destinationFolders(destination DestinationConfig) DestinationFolders {
+	return DestinationFolders{
+		Verified:     destination.VerifiedFolder,
+		DLQ:          destination.DlqFolder,
+		Quarantine:   destination.QuarantineFolder,
+		EmptySidecar: destination.EmptySidecarFolder,
+		Mispaired:    destination.MispairedFolder,
+	}
+}
+
+// Get returns the current destination folders
+func (d *Destinations) Get() DestinationFolders {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	return d.current
+}
+
+// Update validates the new folders and switches to them
+// The current folders are kept when any of the new ones is unusable
+func (d *Destinations) Update(folders DestinationFolders) error {
+	if folders.Verified == "" {
+		return fmt.Errorf("verified folder cannot be empty")
+	}
+	if folders.DLQ == "" {
+		return fmt.Errorf("DLQ folder cannot be empty")
+	}
+
+	for _, folder := range []string{folders.Verified, folders.DLQ, folders.Quarantine, folders.EmptySidecar, folders.Mispaired} {
+		if folder == "" {
+			continue
+		}
+		// Recursive scanning must not pick up files that were already moved
+		if d.recursive && isWithinFolder(folder, d.sourceFolder) {
+			return fmt.Errorf("%s must not be inside the source folder when scanning recursively", folder)
+		}
+		if err := checkWritableFolder(folder); err != nil {
+			return err
+		}
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.current = folders
+	d.readOnly = "" // The new folders were just checked to be writable
+	return nil
+}
+
+// MarkReadOnly records that a move into folder failed because its mount is read-only
+// Returns true if no read-only folder was recorded before (i.e. this is the first failure)
+func (d *Destinations) MarkReadOnly(folder string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	first := d.readOnly == ""
+	d.readOnly = folder
+	return first
+}
+
+// ReadOnly returns the folder found on a read-only mount, or "" when all are writable
+func (d *Destinations) ReadOnly() string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	return d.readOnly
+}
+
+// RecheckReadOnly probes the read-only folder and forgets it once it is writable again
+// Returns true if the folder has recovered
+func (d *Destinations) RecheckReadOnly() bool {
+	folder := d.ReadOnly()
+	if folder == "" || checkWritableFolder(folder) != nil {
+		return false
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// Keep a different folder that failed while we were probing
+	if d.readOnly == folder {
+		d.readOnly = ""
+	}
+	return true
+}
+
+// checkWritableFolder creates a folder if needed and checks files can be created in it
+func checkWritableFolder(folder string) error {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return fmt.Errorf("failed to create folder %s: %w", folder, err)
+	}
+
+	probe, err := os.CreateTemp(folder, ".write-test-*")
+	if err != nil {
+		return fmt.Errorf("folder %s is not writable: %w", folder, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+/*
+Directives are optional per-file overrides dropped next to a data file
+as "<datafile>.meta.json", for example:
+
+	{
+	  "algorithm": "sha512",
+	  "destinationFolder": "invoices/2024"
+	}
+
+Supported keys:
+- algorithm:         hash algorithm for this file (sha256, sha512, sha1, md5,
+                     or the non-cryptographic xxhash, crc64); overrides the
+                     algorithm implied by the sidecar suffix
+- destinationFolder: subfolder of the verified folder to move the file into
+
+Unknown keys are ignored with a warning. A directives file that cannot be
+parsed fails verification of its data file only; it is retried like any
+other failure until fixed or until the file is sent to the DLQ.
+*/
+
+// directivesSuffix is appended to a data file name to find its directives file
+const directivesSuffix = ".meta.json"
+
+// knownDirectives lists the keys understood in a directives file
+var knownDirectives = map[string]bool{
+	"algorithm":         true,
+	"destinationFolder": true,
+}
+
+// FileDirectives holds the overrides parsed from a directives file
+type FileDirectives struct {
+	Algorithm         string `json:"algorithm"`
+	DestinationFolder string `json:"destinationFolder"`
+
+	Path    string    `json:"-"` // Full path of the directives file
+	Size    int64     `json:"-"` // Size observed by the scanner
+	ModTime time.Time `json:"-"` // Modification time observed by the scanner
+	Error   string    `json:"-"` // Why the file could not be applied (empty when valid)
+}
+
+// ParseDirectivesFile reads and validates a directives file
+// Returns the directives and any unknown keys; a parse or validation
+// failure is reported in FileDirectives.Error rather than as an error
+func ParseDirectivesFile(path string, size int64, modTime time.Time) (*FileDirectives, []string) {
+	directives := &FileDirectives{Path: path, Size: size, ModTime: modTime}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		directives.Error = fmt.Sprintf("failed to read directives file: %v", err)
+		return directives, nil
+	}
+
+	// Decode loosely first to find keys we don't understand
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		directives.Error = fmt.Sprintf("invalid directives JSON: %v", err)
+		return directives, nil
+	}
+	var unknown []string
+	for key := range raw {
+		if !knownDirectives[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+
+	if err := json.Unmarshal(data, directives); err != nil {
+		directives.Error = fmt.Sprintf("invalid directives JSON: %v", err)
+		return directives, unknown
+	}
+
+	// Validate overrides
+	if directives.Algorithm != "" {
+		if _, ok := hashAlgorithms[directives.Algorithm]; !ok {
+			directives.Error = fmt.Sprintf("unsupported algorithm in directives: %s", directives.Algorithm)
+		}
+	}
+	if directives.DestinationFolder != "" && !filepath.IsLocal(directives.DestinationFolder) {
+		directives.Error = fmt.Sprintf("destinationFolder must be a relative path inside the verified folder: %s",
+			directives.DestinationFolder)
+	}
+
+	return directives, unknown
+}
+
+// algorithm returns the hash algorithm to use, defaulting to sha256
+func (d *FileDirectives) algorithm() string {
+	if d == nil || d.Algorithm == "" {
+		return AlgorithmSHA256
+	}
+	return d.Algorithm
+}
+
+// destination returns the folder a verified file should be moved into
+func (d *FileDirectives) destination(verifiedFolder string) string {
+	if d == nil || d.DestinationFolder == "" {
+		return verifiedFolder
+	}
+	return filepath.Join(verifiedFolder, d.DestinationFolder)
+}
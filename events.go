@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+EventHub streams verification results and periodic statistics to clients
+connected to GET /events on the admin API (api.events).
+
+Each event is one JSON object, the same payload as the Kafka sink (field
+"type" is "verification" or "stats"), followed by a newline. Clients either
+upgrade to a websocket and get one text message per event, or make a plain
+GET and read a chunked NDJSON stream:
+
+	curl -N http://127.0.0.1:8080/events
+
+Responsibilities:
+1. Join the result fan-out like any other sink, so every record written to
+   the configured sinks is also published
+2. Give each client a bounded buffer (api.eventBuffer events) and never wait
+   for one: a client whose buffer is full is disconnected (websocket close
+   code 1008) instead of slowing down the workers
+3. Disconnect all clients when the API server stops
+
+Does NOT:
+- Replay events from before a client connected
+- Stream failed verifications: like the other sinks it receives verified
+  files and statistics only
+- Support websocket extensions (compression) or fragmented client messages
+*/
+
+// defaultEventBuffer is the number of events buffered per /events client
+const defaultEventBuffer = 256
+
+// websocketGUID is appended to the client key to compute Sec-WebSocket-Accept (RFC 6455)
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// eventWriteTimeout bounds how long a single event may take to reach a client
+const eventWriteTimeout = 10 * time.Second
+
+// Websocket opcodes and close codes used by the /events stream
+const (
+	wsOpText         = 0x1
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+	wsCloseGoingAway = 1001
+	wsClosePolicy    = 1008
+)
+
+// EventHub publishes result records to the connected /events clients
+type EventHub struct {
+	mutex      sync.Mutex
+	clients    map[*eventClient]bool
+	bufferSize int
+	closed     bool
+	logLevel   *LogLevel
+}
+
+// eventClient is one connected /events stream
+type eventClient struct {
+	remote  string
+	events  chan []byte // Closed when the client is disconnected
+	dropped bool        // Disconnected because its buffer was full
+}
+
+// NewEventHub creates a hub buffering up to bufferSize events per client
+func NewEventHub(bufferSize int, logLevel *LogLevel) *EventHub {
+	return &EventHub{
+		clients:    make(map[*eventClient]bool),
+		bufferSize: bufferSize,
+		logLevel:   logLevel,
+	}
+}
+
+// LogVerification publishes a verification record
+func (h *EventHub) LogVerification(entry CSVLogEntry) error {
+	return h.publish(newVerificationMessage(entry))
+}
+
+// LogStats publishes a statistics record
+func (h *EventHub) LogStats(entry StatsEntry) error {
+	return h.publish(newStatsMessage(entry))
+}
+
+// Close disconnects every client; later records are discarded
+func (h *EventHub) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.closed = true
+	for client := range h.clients {
+		delete(h.clients, client)
+		close(client.events)
+	}
+	return nil
+}
+
+// publish encodes an event once and queues it for every client without blocking
+func (h *EventHub) publish(event interface{}) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.clients) == 0 {
+		return nil
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	line = append(line, '\n')
+
+	for client := range h.clients {
+		select {
+		case client.events <- line:
+		default:
+			// A slow client must not hold up the workers
+			client.dropped = true
+			delete(h.clients, client)
+			close(client.events)
+			if h.logLevel.Get() == "WARN" || h.logLevel.Get() == "DEBUG" {
+				fmt.Fprintf(os.Stderr, "[Events] Disconnected %s: %d events behind\n", client.remote, h.bufferSize)
+			}
+		}
+	}
+	return nil
+}
+
+// subscribe registers a new client (nil once the hub is closed)
+func (h *EventHub) subscribe(remote string) *eventClient {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.closed {
+		return nil
+	}
+	client := &eventClient{remote: remote, events: make(chan []byte, h.bufferSize)}
+	h.clients[client] = true
+
+	if h.logLevel.Get() == "DEBUG" || h.logLevel.Get() == "INFO" {
+		fmt.Printf("[Events] %s connected (%d clients)\n", remote, len(h.clients))
+	}
+	return client
+}
+
+// unsubscribe removes a client that went away on its own
+func (h *EventHub) unsubscribe(client *eventClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.clients[client] {
+		delete(h.clients, client)
+		close(client.events)
+	}
+
+	if h.logLevel.Get() == "DEBUG" || h.logLevel.Get() == "INFO" {
+		fmt.Printf("[Events] %s disconnected (%d clients)\n", client.remote, len(h.clients))
+	}
+}
+
+// wasDropped reports whether the hub disconnected a client for being too slow
+func (h *EventHub) wasDropped(client *eventClient) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return client.dropped
+}
+
+// ServeHTTP streams events to a websocket, or as chunked NDJSON to a plain GET
+func (h *EventHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket") {
+		h.serveWebsocket(w, r)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	client := h.subscribe(r.RemoteAddr)
+	if client == nil {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer h.unsubscribe(client)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	controller := http.NewResponseController(w)
+	for {
+		select {
+		case line, ok := <-client.events:
+			if !ok {
+				return
+			}
+			controller.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			if _, err := w.Write(line); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// serveWebsocket completes the websocket handshake and sends each event as a text message
+func (h *EventHub) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "websocket version 13 with a Sec-WebSocket-Key is required", http.StatusBadRequest)
+		return
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+
+	client := h.subscribe(r.RemoteAddr)
+	if client == nil {
+		fmt.Fprint(rw, "HTTP/1.1 503 Service Unavailable\r\nConnection: close\r\n\r\n")
+		rw.Flush()
+		return
+	}
+	defer h.unsubscribe(client)
+
+	// A hijacked connection may still carry the server's header deadline
+	conn.SetDeadline(time.Time{})
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	ws := &wsConn{conn: conn}
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		ws.readUntilClose(rw.Reader)
+	}()
+
+	for {
+		select {
+		case line, ok := <-client.events:
+			if !ok {
+				if h.wasDropped(client) {
+					ws.writeClose(wsClosePolicy, "too slow, events were dropped")
+				} else {
+					ws.writeClose(wsCloseGoingAway, "server shutting down")
+				}
+				return
+			}
+			if err := ws.writeFrame(wsOpText, line); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// headerContains reports whether a comma-separated header lists token (case-insensitive)
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsConn writes server frames to a hijacked websocket connection
+type wsConn struct {
+	mutex sync.Mutex // Events and pongs are written from different goroutines
+	conn  net.Conn
+}
+
+// writeFrame sends a single unmasked, unfragmented frame
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+	_, err := (&net.Buffers{header, payload}).WriteTo(c.conn)
+	return err
+}
+
+// writeClose sends a close frame with a status code and reason
+func (c *wsConn) writeClose(code uint16, reason string) error {
+	return c.writeFrame(wsOpClose, append(binary.BigEndian.AppendUint16(nil, code), reason...))
+}
+
+// readUntilClose reads client frames, answering pings, until the client closes or the connection fails
+func (c *wsConn) readUntilClose(reader *bufio.Reader) {
+	for {
+		opcode, payload, err := readClientFrame(reader)
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsOpClose:
+			c.writeFrame(wsOpClose, payload[:min(len(payload), 2)])
+			return
+		case wsOpPing:
+			if c.writeFrame(wsOpPong, payload) != nil {
+				return
+			}
+		}
+	}
+}
+
+// maxClientFrame bounds the payload accepted from a client, which has nothing to say but pings and close
+const maxClientFrame = 4096
+
+// readClientFrame reads one masked client frame and returns its opcode and unmasked payload
+func readClientFrame(reader *bufio.Reader) (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if header[1]&0x80 == 0 {
+		return 0, nil, errors.New("unmasked client frame")
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(reader, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(reader, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > maxClientFrame {
+		return 0, nil, fmt.Errorf("client frame of %d bytes is too large", length)
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(reader, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return header[0] & 0x0f, payload, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+/*
+Features are the optional components built from the configuration at startup
+and handed to the components that use them (worker pool, scanner, tar
+listener, orphan janitor, API server, coordinator).
+
+Responsibilities:
+1. Carry each optional component to its users, nil when it is disabled
+   (every component's methods used by its callers are safe on nil or
+   checked for nil)
+
+Does NOT:
+- Build the components (main does, from the configuration)
+- Change after startup: a component keeps the Features it was created with
+*/
+
+// Features holds the optional components (nil = disabled)
+type Features struct {
+	Hasher         *FileHasher          // How data files are read for hashing (nil = plain reads)
+	IntentLog      *IntentLog           // Records deliveries so they survive a crash
+	DenyList       *DenyList            // Quarantines known-bad content
+	Signatures     *SignatureVerifier   // Checks sidecar signatures before their hash is trusted
+	ChunkManifests *ChunkManifests      // Verifies resumable uploads chunk by chunk
+	InodeDedup     *InodeDedup          // Shares one hash among the hard links of a data file
+	ChangeDetector *ChangeDetector      // Fingerprints pairs left in the source folder
+	LatestLinks    *LatestLinks         // Points fixed names at the newest verified files
+	Maintenance    *MaintenanceSchedule // Pauses scanning and submission during maintenance windows
+}
@@ -1,66 +1,245 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
 )
 
+// Collision policies applied when a file already exists at the destination
+const (
+	CollisionRename    = "rename"    // Append a unique suffix to the new file
+	CollisionOverwrite = "overwrite" // Atomically replace the existing file
+	CollisionSkip      = "skip"      // Leave the source file in place
+	CollisionFail      = "fail"      // Return ErrDestinationExists
+)
+
+// Handling of sidecars whose data file never arrives
+const (
+	OrphanSidecarsDLQ        = "dlq"        // Move the sidecar to the DLQ folder
+	OrphanSidecarsQuarantine = "quarantine" // Move the sidecar to the quarantine folder
+	OrphanSidecarsLeave      = "leave"      // Leave the sidecar in the source and stop tracking it
+)
+
+// namingPlaceholder matches a placeholder of destination.namingTemplate, e.g. "{hash}"
+var namingPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// namingFields are the placeholders a naming template may use
+var namingFields = map[string]bool{"name": true, "ext": true, "hash": true, "algorithm": true}
+
+// ErrCollisionSkipped is returned when a move was skipped by the "skip" policy
+var ErrCollisionSkipped = errors.New("destination file already exists, move skipped")
+
+// ErrDestinationExists is returned when a move was refused by the "fail" policy
+var ErrDestinationExists = errors.New("destination file already exists")
+
+// ErrUnsafeDelete is returned when SafeDeleteFile refuses to delete a file
+var ErrUnsafeDelete = errors.New("refusing to delete")
+
+// ErrDeliveryCorrupted is returned when a delivered file does not hash to its verified hash
+var ErrDeliveryCorrupted = errors.New("delivered file does not match the verified hash")
+
+// DeliveryCheck is the hash a delivered file must have to confirm the delivery
+type DeliveryCheck struct {
+	Algorithm  string
+	Hash       string // Verified hash, lowercase hex
+	BufferSize int
+	Transforms []string // Undo the delivery's encoding before hashing (e.g. gunzip for a compressed delivery)
+}
+
+// confirm re-reads a delivered file and compares its hash (a nil check always passes)
+func (c *DeliveryCheck) confirm(deliveredPath string) error {
+	if c == nil {
+		return nil
+	}
+
+	hash, err := computeFileHash(deliveredPath, c.BufferSize, c.Algorithm, c.Transforms, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to re-read delivered file: %w", err)
+	}
+	if hash != c.Hash {
+		return fmt.Errorf("%w: expected %s, delivered %s", ErrDeliveryCorrupted, c.Hash, hash)
+	}
+	return nil
+}
+
 // MoveToVerified moves a successfully verified data file to the verified folder
+// under filename (from verifiedName); with a check, the delivered file is re-read and must hash to the verified hash;
+// otherwise the delivery is undone and the source file is left in place
 // Returns the new file path or an error
-func MoveToVerified(sourceFilePath, verifiedFolder string) (string, error) {
-	// Get the filename from the source path
-	filename := filepath.Base(sourceFilePath)
+// With preserveSparse, a copy to another file system keeps the holes of a sparse file
+func MoveToVerified(sourceFilePath, verifiedFolder, filename, onCollision string, check *DeliveryCheck, preserveSparse bool) (string, error) {
+	return moveToFolder(sourceFilePath, verifiedFolder, filename, onCollision, check, preserveSparse)
+}
 
-	// Build destination path
-	destPath := filepath.Join(verifiedFolder, filename)
+// verifiedName returns the name a verified file is delivered under: its own
+// name, or the naming template with {name}, {ext}, {hash} and {algorithm}
+// filled in ("data.bin" with "{name}-{hash}{ext}" becomes "data-<hash>.bin")
+func verifiedName(template, filename, algorithm, hash string) string {
+	if template == "" {
+		return filename
+	}
+
+	ext := filepath.Ext(filename)
+	return strings.NewReplacer(
+		"{name}", strings.TrimSuffix(filename, ext),
+		"{ext}", ext,
+		"{hash}", hash,
+		"{algorithm}", algorithm,
+	).Replace(template)
+}
 
-	// Check if destination already exists
-	if _, err := os.Stat(destPath); err == nil {
-		// File exists, create unique name with timestamp
-		destPath = getUniqueFilePath(verifiedFolder, filename)
+// validateNamingTemplate checks that a naming template only uses known
+// placeholders and always yields a plain file name
+func validateNamingTemplate(template string) error {
+	for _, placeholder := range namingPlaceholder.FindAllString(template, -1) {
+		if !namingFields[strings.Trim(placeholder, "{}")] {
+			return fmt.Errorf("unknown placeholder %s (use {name}, {ext}, {hash}, {algorithm})", placeholder)
+		}
 	}
 
-	// Move file (rename if on same filesystem, otherwise copy+delete)
-	if err := moveFile(sourceFilePath, destPath); err != nil {
-		return "", fmt.Errorf("failed to move file to verified folder: %w", err)
+	literal := namingPlaceholder.ReplaceAllString(template, "")
+	if strings.ContainsAny(literal, "{}") {
+		return fmt.Errorf("unbalanced brace in %q", template)
+	}
+	if strings.ContainsAny(literal, `/\`) || strings.IndexFunc(literal, unicode.IsControl) >= 0 {
+		return fmt.Errorf("%q must produce a file name, not a path", template)
+	}
+	if !strings.Contains(template, "{name}") && !strings.Contains(template, "{hash}") {
+		return fmt.Errorf("%q must contain {name} or {hash}", template)
 	}
+	return nil
+}
 
-	return destPath, nil
+// MoveToFolder moves a file into folder, keeping its name and applying the collision policy
+// Returns the new file path or an error
+func MoveToFolder(sourceFilePath, folder, onCollision string) (string, error) {
+	return moveToFolder(sourceFilePath, folder, filepath.Base(sourceFilePath), onCollision, nil, false)
+}
+
+// moveToFolder moves a file into folder as filename, confirming the delivery when check is set
+func moveToFolder(sourceFilePath, folder, filename, onCollision string, check *DeliveryCheck, preserveSparse bool) (string, error) {
+	// Build destination path, applying the collision policy, and move the
+	// file there (rename if on same filesystem, otherwise copy+delete)
+	return claimDestination(folder, filename, onCollision, func(destPath string, replace bool) error {
+		if err := deliverFile(sourceFilePath, destPath, check, preserveSparse, replace); err != nil {
+			return fmt.Errorf("failed to move file to %s: %w", folder, err)
+		}
+		return nil
+	})
+}
+
+// MoveToQuarantine moves a file the scanner refused to track into the quarantine folder
+// Control characters in the name are replaced so the quarantined name is safe to handle
+// Returns the new file path or an error
+func MoveToQuarantine(sourceFilePath, quarantineFolder, onCollision string) (string, error) {
+	filename := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, filepath.Base(sourceFilePath))
+
+	return claimDestination(quarantineFolder, filename, onCollision, func(destPath string, replace bool) error {
+		if err := deliverFile(sourceFilePath, destPath, nil, false, replace); err != nil {
+			return fmt.Errorf("failed to move file to quarantine folder: %w", err)
+		}
+		return nil
+	})
 }
 
 // MoveToDLQ moves both data file and SHA256 file to the DLQ folder
-// Returns error if either move fails
-func MoveToDLQ(dataFilePath, sha256FilePath, dlqFolder string) error {
-	// Move data file
-	dataFilename := filepath.Base(dataFilePath)
-	dataDest := filepath.Join(dlqFolder, dataFilename)
+// Returns where the data file was moved ("" = no data file) and an error if either move fails
+func MoveToDLQ(dataFilePath, sha256FilePath, dlqFolder, onCollision string) (string, error) {
+	// A sidecar whose data file never arrived is moved alone
+	if dataFilePath == "" {
+		if _, err := MoveToFolder(sha256FilePath, dlqFolder, onCollision); err != nil {
+			return "", fmt.Errorf("failed to move SHA256 file to DLQ: %w", err)
+		}
+		return "", nil
+	}
 
-	// Check if destination already exists
-	if _, err := os.Stat(dataDest); err == nil {
-		dataDest = getUniqueFilePath(dlqFolder, dataFilename)
+	// Resolve both destinations before moving anything so that
+	// skip/fail policies never leave a pair half-moved
+	if _, err := resolveDestination(dlqFolder, filepath.Base(dataFilePath), onCollision); err != nil {
+		return "", err
+	}
+	if sha256FilePath != "" {
+		if _, err := resolveDestination(dlqFolder, filepath.Base(sha256FilePath), onCollision); err != nil {
+			return "", err
+		}
+	}
+
+	// Move data file
+	dataDest, err := claimDestination(dlqFolder, filepath.Base(dataFilePath), onCollision, movingFile(dataFilePath))
+	if err != nil {
+		return "", fmt.Errorf("failed to move data file to DLQ: %w", err)
 	}
 
-	if err := moveFile(dataFilePath, dataDest); err != nil {
-		return fmt.Errorf("failed to move data file to DLQ: %w", err)
+	// A data file verified against a hash in its name has no sidecar
+	if sha256FilePath == "" {
+		return dataDest, nil
 	}
 
 	// Move SHA256 file
-	sha256Filename := filepath.Base(sha256FilePath)
-	sha256Dest := filepath.Join(dlqFolder, sha256Filename)
+	if _, err := claimDestination(dlqFolder, filepath.Base(sha256FilePath), onCollision, movingFile(sha256FilePath)); err != nil {
+		// Data file already moved, log warning but continue
+		return dataDest, fmt.Errorf("failed to move SHA256 file to DLQ: %w", err)
+	}
+
+	return dataDest, nil
+}
 
-	// Check if destination already exists
-	if _, err := os.Stat(sha256Dest); err == nil {
-		sha256Dest = getUniqueFilePath(dlqFolder, sha256Filename)
+// SafeDeleteFile removes a file only when it is still the file the scanner recorded
+// Refuses (with ErrUnsafeDelete) paths outside rootFolder, non-regular files, and
+// files whose size or modification time no longer match the recorded values
+func SafeDeleteFile(filePath, rootFolder string, expectedSize int64, expectedModTime time.Time) error {
+	info, err := deletableFile(filePath, rootFolder)
+	if err != nil {
+		return err
 	}
 
-	if err := moveFile(sha256FilePath, sha256Dest); err != nil {
-		// Data file already moved, log warning but continue
-		return fmt.Errorf("failed to move SHA256 file to DLQ: %w", err)
+	// A different size or mtime means the path no longer refers to the file we scanned
+	if info.Size() != expectedSize || !info.ModTime().Equal(expectedModTime) {
+		return fmt.Errorf("%w %s: changed since it was scanned (size %d, expected %d)",
+			ErrUnsafeDelete, filePath, info.Size(), expectedSize)
 	}
 
-	return nil
+	return DeleteFile(filePath)
+}
+
+// DeleteWithinFolder removes a file nothing was recorded about (e.g. a signature next to a sidecar)
+// Refuses (with ErrUnsafeDelete) paths outside rootFolder and non-regular files
+func DeleteWithinFolder(filePath, rootFolder string) error {
+	if _, err := deletableFile(filePath, rootFolder); err != nil {
+		return err
+	}
+	return DeleteFile(filePath)
+}
+
+// deletableFile returns the info of a regular file within rootFolder, else an error
+func deletableFile(filePath, rootFolder string) (os.FileInfo, error) {
+	// Guard against stale or traversing paths deleting outside the source folder
+	if !isWithinFolder(filePath, rootFolder) {
+		return nil, fmt.Errorf("%w %s: not within %s", ErrUnsafeDelete, filePath, rootFolder)
+	}
+
+	info, err := os.Lstat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file %s: %w", filePath, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w %s: not a regular file", ErrUnsafeDelete, filePath)
+	}
+	return info, nil
 }
 
 // DeleteFile removes a file from the filesystem
@@ -86,19 +265,155 @@ func DeleteBothFiles(dataFilePath, sha256FilePath string) error {
 	return nil
 }
 
+// resolveDestination returns the destination path for filename in dir,
+// applying the collision policy when a file with that name already exists
+func resolveDestination(dir, filename, onCollision string) (string, error) {
+	destPath := filepath.Join(dir, filename)
+
+	// No collision, use the plain name
+	if !nameTaken(destPath) {
+		return destPath, nil
+	}
+
+	switch onCollision {
+	case CollisionOverwrite:
+		return destPath, nil
+	case CollisionSkip:
+		return "", fmt.Errorf("%w: %s", ErrCollisionSkipped, destPath)
+	case CollisionFail:
+		return "", fmt.Errorf("%w: %s", ErrDestinationExists, destPath)
+	default:
+		// File exists, create unique name
+		return getUniqueFilePath(dir, filename), nil
+	}
+}
+
+// claimDestination resolves the destination of filename in dir and has place
+// put the file there. Except under the overwrite policy, place is told not to
+// replace an existing file and fails with fs.ErrExist when another delivery
+// took the name after it was resolved: the collision policy is then applied
+// again, so two deliveries of one name never end up in the same file
+func claimDestination(dir, filename, onCollision string, place func(destPath string, replace bool) error) (string, error) {
+	for {
+		destPath, err := resolveDestination(dir, filename, onCollision)
+		if err != nil {
+			return "", err
+		}
+		err = place(destPath, onCollision == CollisionOverwrite)
+		if err == nil {
+			return destPath, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+	}
+}
+
+// movingFile returns a claimDestination place function that moves sourcePath
+func movingFile(sourcePath string) func(destPath string, replace bool) error {
+	return func(destPath string, replace bool) error {
+		return deliverFile(sourcePath, destPath, nil, false, replace)
+	}
+}
+
+// renameFile renames a file (a hook, so the cross-filesystem path can be taken on one file system)
+var renameFile = os.Rename
+
+// linkFile hard links a file (a hook, like renameFile)
+var linkFile = os.Link
+
+// placeFile gives the file at sourcePath the name destPath in a single step
+// With replace an existing destPath is replaced (a rename); without it the
+// name is claimed with a hard link, which fails with fs.ErrExist when the name
+// is taken, and sourcePath is then removed. File systems without hard links
+// fall back to a rename after checking the name is free, which is not atomic
+func placeFile(sourcePath, destPath string, replace bool) error {
+	if replace {
+		return renameFile(sourcePath, destPath)
+	}
+
+	err := linkFile(sourcePath, destPath)
+	if errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	if err != nil {
+		if nameTaken(destPath) {
+			return &os.LinkError{Op: "rename", Old: sourcePath, New: destPath, Err: fs.ErrExist}
+		}
+		return renameFile(sourcePath, destPath)
+	}
+
+	if err := os.Remove(sourcePath); err != nil {
+		// Leave things as they were, the copy path reports why
+		os.Remove(destPath)
+		return err
+	}
+	return nil
+}
+
 // moveFile moves a file from source to destination
 // Uses os.Rename for same filesystem, otherwise copies and deletes
+// The final name only ever appears with the complete content, and an
+// existing destination file is replaced atomically, in both cases
 func moveFile(sourcePath, destPath string) error {
+	return deliverFile(sourcePath, destPath, nil, false, true)
+}
+
+// deliverFile is moveFile with an optional delivery check
+// A copy is checked before it gets its final name and the source is deleted;
+// a renamed file is checked in place and renamed back if it fails, unless it
+// replaces an existing file: that one could not be restored, so the file is
+// checked before the rename instead (a rename does not change the content)
+// With preserveSparse, a copy only writes the data regions of the source
+// Without replace an existing destPath is left alone and fs.ErrExist returned
+func deliverFile(sourcePath, destPath string, check *DeliveryCheck, preserveSparse, replace bool) error {
+	checked := false
+	if check != nil && replace && FileExists(destPath) {
+		if err := check.confirm(sourcePath); err != nil {
+			return err
+		}
+		checked = true
+	}
+
 	// Try rename first (fast, atomic on same filesystem)
-	err := os.Rename(sourcePath, destPath)
+	err := placeFile(sourcePath, destPath, replace)
 	if err == nil {
+		if checked {
+			return nil
+		}
+		if err := check.confirm(destPath); err != nil {
+			if undoErr := renameFile(destPath, sourcePath); undoErr != nil {
+				return fmt.Errorf("%w (and failed to move it back: %v)", err, undoErr)
+			}
+			return err
+		}
 		return nil
 	}
+	if errors.Is(err, fs.ErrExist) {
+		return err
+	}
 
 	// Rename failed (possibly cross-filesystem), do copy+delete
-	if err := copyFile(sourcePath, destPath); err != nil {
+	// Copy next to the destination under a temp name and rename it to the final
+	// name once synced, so consumers watching the destination folder never see
+	// a partial file (nor an existing one partially overwritten)
+	tempPath, err := reserveTempPath(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if err := copyFile(sourcePath, tempPath, preserveSparse); err != nil {
+		os.Remove(tempPath)
 		return fmt.Errorf("failed to copy file: %w", err)
 	}
+	if err := check.confirm(tempPath); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := placeFile(tempPath, destPath, replace); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to rename copied file to %s: %w", filepath.Base(destPath), err)
+	}
+	syncDir(filepath.Dir(destPath))
 
 	// Delete source after successful copy
 	if err := os.Remove(sourcePath); err != nil {
@@ -109,7 +424,8 @@ func moveFile(sourcePath, destPath string) error {
 }
 
 // copyFile copies a file from source to destination
-func copyFile(sourcePath, destPath string) error {
+// With sparse, holes in the source are left unwritten so they stay holes
+func copyFile(sourcePath, destPath string, sparse bool) error {
 	// Open source file
 	sourceFile, err := os.Open(sourcePath)
 	if err != nil {
@@ -117,6 +433,12 @@ func copyFile(sourcePath, destPath string) error {
 	}
 	defer sourceFile.Close()
 
+	// Stat before copying, reading the file may update its access time
+	sourceInfo, err := sourceFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get source file info: %w", err)
+	}
+
 	// Create destination file
 	destFile, err := os.Create(destPath)
 	if err != nil {
@@ -125,7 +447,12 @@ func copyFile(sourcePath, destPath string) error {
 	defer destFile.Close()
 
 	// Copy contents
-	if _, err := io.Copy(destFile, sourceFile); err != nil {
+	if sparse {
+		err = copySparse(destFile, sourceFile, sourceInfo.Size())
+	} else {
+		_, err = io.Copy(destFile, sourceFile)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to copy file contents: %w", err)
 	}
 
@@ -135,28 +462,145 @@ func copyFile(sourcePath, destPath string) error {
 	}
 
 	// Copy file permissions
-	sourceInfo, err := os.Stat(sourcePath)
+	if err := os.Chmod(destPath, sourceInfo.Mode()); err != nil {
+		return fmt.Errorf("failed to set destination file permissions: %w", err)
+	}
+
+	// Keep the original timestamps, as a same-filesystem rename would
+	if err := os.Chtimes(destPath, fileAccessTime(sourceInfo), sourceInfo.ModTime()); err != nil {
+		return fmt.Errorf("failed to set destination file times: %w", err)
+	}
+
+	return nil
+}
+
+// fileRegion is a byte range [start, end) of a file
+type fileRegion struct {
+	start int64
+	end   int64
+}
+
+// copySparse copies only the data regions of source, then extends dest to the full size
+func copySparse(dest, source *os.File, size int64) error {
+	regions, err := dataRegions(source, size)
 	if err != nil {
-		return fmt.Errorf("failed to get source file info: %w", err)
+		return fmt.Errorf("failed to locate data regions: %w", err)
 	}
 
-	if err := os.Chmod(destPath, sourceInfo.Mode()); err != nil {
-		return fmt.Errorf("failed to set destination file permissions: %w", err)
+	for _, region := range regions {
+		if _, err := source.Seek(region.start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := dest.Seek(region.start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(dest, source, region.end-region.start); err != nil {
+			return err
+		}
+	}
+
+	// A trailing hole is not written, the size is set instead
+	return dest.Truncate(size)
+}
+
+// sparseHoleBytes returns how many bytes of a file are holes (0 = fully allocated)
+func sparseHoleBytes(filePath string, size int64) (int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	regions, err := dataRegions(file, size)
+	if err != nil {
+		return 0, err
+	}
+
+	holes := size
+	for _, region := range regions {
+		holes -= region.end - region.start
+	}
+	return holes, nil
+}
+
+// syncDir flushes a directory so a rename into it survives a crash
+// Best effort: not every file system supports syncing directories
+func syncDir(dirPath string) {
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		return
 	}
+	dir.Sync()
+	dir.Close()
+}
 
+// WriteReasonFile writes a note explaining why a data file was set aside
+// next to it in folder, as <dataFile>.reason.txt
+func WriteReasonFile(folder, dataFile, reason string) error {
+	reasonPath := filepath.Join(folder, dataFile+".reason.txt")
+	content := fmt.Sprintf("%s\n%s\n", clockNow().Format(time.RFC3339), reason)
+	if err := os.WriteFile(reasonPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", reasonPath, err)
+	}
 	return nil
 }
 
-// getUniqueFilePath generates a unique file path by appending timestamp
+// isReadOnlyFS reports whether err was caused by a read-only file system (EROFS)
+func isReadOnlyFS(err error) bool {
+	return errors.Is(err, syscall.EROFS)
+}
+
+// getUniqueFilePath generates a unique file path by appending a suffix (see clock.go)
+// The suffix is the same for every collision in a process, so a counter is added
+// until the name is free: "data_<pid>.zip", then "data_<pid>_2.zip", ...
 func getUniqueFilePath(dir, filename string) string {
 	ext := filepath.Ext(filename)
 	nameWithoutExt := filename[:len(filename)-len(ext)]
+	suffix := uniqueSuffix()
 
-	// Use nanosecond timestamp for uniqueness
-	timestamp := fmt.Sprintf("%d", os.Getpid())
-	uniqueName := fmt.Sprintf("%s_%s%s", nameWithoutExt, timestamp, ext)
+	uniquePath := filepath.Join(dir, fmt.Sprintf("%s_%s%s", nameWithoutExt, suffix, ext))
+	for n := 2; nameTaken(uniquePath); n++ {
+		uniquePath = filepath.Join(dir, fmt.Sprintf("%s_%s_%d%s", nameWithoutExt, suffix, n, ext))
+	}
 
-	return filepath.Join(dir, uniqueName)
+	return uniquePath
+}
+
+// nameTaken reports whether anything, even a dangling symlink, has the name path
+func nameTaken(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// reserveTempPath creates an empty temp file next to destPath, named
+// "<name>.<random>.tmp" so that two deliveries of one name never share it
+func reserveTempPath(destPath string) (string, error) {
+	file, err := os.CreateTemp(filepath.Dir(destPath), filepath.Base(destPath)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	return file.Name(), nil
+}
+
+// removeTempFiles removes the temp files reserveTempPath created for destPath
+func removeTempFiles(destPath string) {
+	entries, err := os.ReadDir(filepath.Dir(destPath))
+	if err != nil {
+		return
+	}
+	prefix := filepath.Base(destPath) + "."
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		// Only the random digits of os.CreateTemp between them
+		random := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".tmp")
+		if random != "" && strings.Trim(random, "0123456789") == "" {
+			os.Remove(filepath.Join(filepath.Dir(destPath), name))
+		}
+	}
 }
 
 // FileExists checks if a file exists
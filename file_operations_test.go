@@ -0,0 +1,343 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// readTestFile returns the content of a file
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestCopyKeepsTimestampsAndMode(t *testing.T) {
+	for _, sparse := range []bool{false, true} {
+		dir := t.TempDir()
+		source := writeTestFile(t, dir, "data.zip", "data")
+		modTime := time.Date(2020, time.March, 4, 5, 6, 7, 0, time.UTC)
+		if err := os.Chtimes(source, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(source, 0640); err != nil {
+			t.Fatal(err)
+		}
+
+		dest := filepath.Join(dir, "copy.zip")
+		if err := copyFile(source, dest, sparse); err != nil {
+			t.Fatalf("sparse %v: %v", sparse, err)
+		}
+
+		info, err := os.Stat(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(modTime) {
+			t.Errorf("sparse %v: modification time %s, want %s", sparse, info.ModTime(), modTime)
+		}
+		if info.Mode().Perm() != 0640 {
+			t.Errorf("sparse %v: mode %v, want 0640", sparse, info.Mode().Perm())
+		}
+		if got := readTestFile(t, dest); got != "data" {
+			t.Errorf("sparse %v: content %q", sparse, got)
+		}
+	}
+}
+
+// crossDevice makes renames and links out of sourceDir fail as across file systems until the test ends
+// Every other rename or link is passed to observe first
+func crossDevice(t *testing.T, sourceDir string, observe func(oldpath, newpath string)) {
+	t.Helper()
+	t.Cleanup(func() { renameFile, linkFile = os.Rename, os.Link })
+	hook := func(op string, do func(oldpath, newpath string) error) func(oldpath, newpath string) error {
+		return func(oldpath, newpath string) error {
+			if filepath.Dir(oldpath) == sourceDir {
+				return &os.LinkError{Op: op, Old: oldpath, New: newpath, Err: syscall.EXDEV}
+			}
+			observe(oldpath, newpath)
+			return do(oldpath, newpath)
+		}
+	}
+	renameFile, linkFile = hook("rename", os.Rename), hook("link", os.Link)
+}
+
+func TestCopiedDeliveryNeverShowsPartialFile(t *testing.T) {
+	source, dest := t.TempDir(), t.TempDir()
+	content := strings.Repeat("data", 1<<16)
+	sourcePath := writeTestFile(t, source, "data.zip", content)
+	destPath := filepath.Join(dest, "data.zip")
+
+	renamed := false
+	crossDevice(t, source, func(oldpath, newpath string) {
+		// The final name appears only by linking the complete, synced copy
+		if newpath == destPath {
+			renamed = true
+			if FileExists(destPath) {
+				t.Error("final name existed before the copy was complete")
+			}
+			if got := readTestFile(t, oldpath); got != content {
+				t.Errorf("renamed a copy of %d bytes, want %d", len(got), len(content))
+			}
+		}
+	})
+
+	if _, err := MoveToVerified(sourcePath, dest, "data.zip", CollisionFail, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	if !renamed {
+		t.Fatal("delivery did not take the copy path")
+	}
+	if FileExists(sourcePath) || readTestFile(t, destPath) != content {
+		t.Error("source kept or delivered content differs")
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dest, "*.tmp")); len(leftovers) != 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+}
+
+func TestFailedCopiedDeliveryLeavesNoFinalName(t *testing.T) {
+	source, dest := t.TempDir(), t.TempDir()
+	sourcePath := writeTestFile(t, source, "data.zip", "data")
+	crossDevice(t, source, func(oldpath, newpath string) {})
+
+	// The copy is checked under its temp name and fails
+	check := &DeliveryCheck{Algorithm: AlgorithmSHA256, Hash: strings.Repeat("0", 64), BufferSize: 4096}
+	if _, err := MoveToVerified(sourcePath, dest, "data.zip", CollisionFail, check, false); !errors.Is(err, ErrDeliveryCorrupted) {
+		t.Fatalf("err = %v, want ErrDeliveryCorrupted", err)
+	}
+	if entries, _ := os.ReadDir(dest); len(entries) != 0 {
+		t.Errorf("destination folder holds %v after a failed delivery", entries)
+	}
+	if !FileExists(sourcePath) {
+		t.Error("source deleted after a failed delivery")
+	}
+}
+
+func TestCollisionRenameNeverClobbers(t *testing.T) {
+	source := t.TempDir()
+	dest := t.TempDir()
+	writeTestFile(t, dest, "data.zip", "original")
+
+	// Every collision in a process shares the suffix, each must still get its own name
+	delivered := map[string]string{}
+	for _, content := range []string{"first", "second", "third"} {
+		path, err := MoveToFolder(writeTestFile(t, source, "data.zip", content), dest, CollisionRename)
+		if err != nil {
+			t.Fatalf("move %s: %v", content, err)
+		}
+		if previous, taken := delivered[path]; taken {
+			t.Fatalf("%s delivered to %s, already holding %s", content, path, previous)
+		}
+		delivered[path] = content
+	}
+
+	if got := readTestFile(t, filepath.Join(dest, "data.zip")); got != "original" {
+		t.Errorf("existing file content = %q, want %q", got, "original")
+	}
+	for path, content := range delivered {
+		if got := readTestFile(t, path); got != content {
+			t.Errorf("%s content = %q, want %q", path, got, content)
+		}
+	}
+	entries, _ := os.ReadDir(dest)
+	if len(entries) != 4 {
+		t.Errorf("destination holds %d files, want 4", len(entries))
+	}
+}
+
+func TestCollisionRenameConcurrent(t *testing.T) {
+	dest := t.TempDir()
+
+	// Deliveries of one name racing each other must each claim a name of their own:
+	// all of them resolve the destination before the first one places its file
+	const deliveries = 16
+	var resolved sync.WaitGroup
+	resolved.Add(deliveries)
+	var placed atomic.Int32
+	t.Cleanup(func() { renameFile, linkFile = os.Rename, os.Link })
+	barrier := func(place func(oldpath, newpath string) error) func(oldpath, newpath string) error {
+		return func(oldpath, newpath string) error {
+			if placed.Add(1) <= deliveries {
+				resolved.Done()
+				resolved.Wait()
+			}
+			return place(oldpath, newpath)
+		}
+	}
+	renameFile, linkFile = barrier(os.Rename), barrier(os.Link)
+
+	paths := make([]string, deliveries)
+	errs := make([]error, deliveries)
+	var wg sync.WaitGroup
+	for i := 0; i < deliveries; i++ {
+		sourcePath := writeTestFile(t, t.TempDir(), "data.zip", fmt.Sprintf("delivery %d", i))
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths[i], errs[i] = MoveToFolder(sourcePath, dest, CollisionRename)
+		}(i)
+	}
+	wg.Wait()
+
+	delivered := map[string]bool{}
+	for i, path := range paths {
+		if errs[i] != nil {
+			t.Fatalf("delivery %d: %v", i, errs[i])
+		}
+		if delivered[path] {
+			t.Errorf("delivery %d got %s, already taken", i, path)
+		}
+		delivered[path] = true
+		if got, want := readTestFile(t, path), fmt.Sprintf("delivery %d", i); got != want {
+			t.Errorf("%s content = %q, want %q", path, got, want)
+		}
+	}
+	if entries, _ := os.ReadDir(dest); len(entries) != deliveries {
+		t.Errorf("destination holds %d files, want %d", len(entries), deliveries)
+	}
+}
+
+func TestCollisionOverwrite(t *testing.T) {
+	source := t.TempDir()
+	dest := t.TempDir()
+	writeTestFile(t, dest, "data.zip", "old")
+
+	path, err := MoveToFolder(writeTestFile(t, source, "data.zip", "new"), dest, CollisionOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dest, "data.zip") {
+		t.Errorf("delivered to %s, want the existing name", path)
+	}
+	if got := readTestFile(t, path); got != "new" {
+		t.Errorf("content = %q, want %q", got, "new")
+	}
+	if FileExists(filepath.Join(source, "data.zip")) {
+		t.Error("source file still exists")
+	}
+}
+
+func TestCollisionSkip(t *testing.T) {
+	source := t.TempDir()
+	dest := t.TempDir()
+	writeTestFile(t, dest, "data.zip", "old")
+	sourcePath := writeTestFile(t, source, "data.zip", "new")
+
+	_, err := MoveToFolder(sourcePath, dest, CollisionSkip)
+	if !errors.Is(err, ErrCollisionSkipped) {
+		t.Fatalf("err = %v, want ErrCollisionSkipped", err)
+	}
+	if got := readTestFile(t, filepath.Join(dest, "data.zip")); got != "old" {
+		t.Errorf("existing file content = %q, want %q", got, "old")
+	}
+	if got := readTestFile(t, sourcePath); got != "new" {
+		t.Errorf("source content = %q, want %q", got, "new")
+	}
+}
+
+func TestCollisionFail(t *testing.T) {
+	source := t.TempDir()
+	dest := t.TempDir()
+	writeTestFile(t, dest, "data.zip", "old")
+	sourcePath := writeTestFile(t, source, "data.zip", "new")
+
+	_, err := MoveToVerified(sourcePath, dest, "data.zip", CollisionFail, nil, false)
+	if !errors.Is(err, ErrDestinationExists) {
+		t.Fatalf("err = %v, want ErrDestinationExists", err)
+	}
+	if !FileExists(sourcePath) {
+		t.Error("source file was moved")
+	}
+}
+
+func TestCollisionSkipLeavesDLQPairWhole(t *testing.T) {
+	source := t.TempDir()
+	dlq := t.TempDir()
+	// Only the sidecar collides, the data file must not be moved alone
+	writeTestFile(t, dlq, "data.zip.sha256", "old")
+	dataPath := writeTestFile(t, source, "data.zip", "data")
+	sidecarPath := writeTestFile(t, source, "data.zip.sha256", "hash")
+
+	_, err := MoveToDLQ(dataPath, sidecarPath, dlq, CollisionSkip)
+	if !errors.Is(err, ErrCollisionSkipped) {
+		t.Fatalf("err = %v, want ErrCollisionSkipped", err)
+	}
+	if !FileExists(dataPath) || !FileExists(sidecarPath) {
+		t.Error("pair was partly moved")
+	}
+}
+
+func TestDeleteWithinFolder(t *testing.T) {
+	root := t.TempDir()
+	outside := writeTestFile(t, t.TempDir(), "data.zip.sha256.sig", "signature")
+
+	// A path outside the folder is refused
+	if err := DeleteWithinFolder(outside, root); !errors.Is(err, ErrUnsafeDelete) {
+		t.Errorf("path outside the folder: err = %v, want ErrUnsafeDelete", err)
+	}
+
+	// A symlink is not followed or removed
+	link := filepath.Join(root, "data.zip.bsdiff")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Skipf("no symlinks: %v", err)
+	}
+	if err := DeleteWithinFolder(link, root); !errors.Is(err, ErrUnsafeDelete) {
+		t.Errorf("symlink: err = %v, want ErrUnsafeDelete", err)
+	}
+	if !FileExists(outside) || !FileExists(link) {
+		t.Error("symlink or its target removed")
+	}
+
+	path := writeTestFile(t, root, "data.zip.chunks", "chunks")
+	if err := DeleteWithinFolder(path, root); err != nil || FileExists(path) {
+		t.Errorf("regular file in the folder: err = %v, exists %v", err, FileExists(path))
+	}
+}
+
+func TestFailedCheckKeepsOverwrittenFile(t *testing.T) {
+	for _, copied := range []bool{false, true} {
+		source, dest := t.TempDir(), t.TempDir()
+		sourcePath := writeTestFile(t, source, "data.zip", "corrupt")
+		destPath := writeTestFile(t, dest, "data.zip", "previous delivery")
+		if copied {
+			crossDevice(t, source, func(oldpath, newpath string) {})
+		}
+
+		check := &DeliveryCheck{Algorithm: AlgorithmSHA256, Hash: dataSHA256, BufferSize: 4096}
+		if _, err := MoveToVerified(sourcePath, dest, "data.zip", CollisionOverwrite, check, false); !errors.Is(err, ErrDeliveryCorrupted) {
+			t.Fatalf("copied %v: err = %v, want ErrDeliveryCorrupted", copied, err)
+		}
+		if got := readTestFile(t, destPath); got != "previous delivery" {
+			t.Errorf("copied %v: destination holds %q, want the previous delivery", copied, got)
+		}
+		if !FileExists(sourcePath) {
+			t.Errorf("copied %v: source lost", copied)
+		}
+	}
+}
+
+func TestCheckedOverwriteReplacesFile(t *testing.T) {
+	source, dest := t.TempDir(), t.TempDir()
+	sourcePath := writeTestFile(t, source, "data.zip", "data")
+	destPath := writeTestFile(t, dest, "data.zip", "previous delivery")
+
+	check := &DeliveryCheck{Algorithm: AlgorithmSHA256, Hash: dataSHA256, BufferSize: 4096}
+	if _, err := MoveToVerified(sourcePath, dest, "data.zip", CollisionOverwrite, check, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, destPath); got != "data" || FileExists(sourcePath) {
+		t.Errorf("destination holds %q, source exists %v", got, FileExists(sourcePath))
+	}
+}
@@ -3,54 +3,181 @@ package main
 import (
 	"context"
 	"fmt"
+	iofs "io/fs"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 )
 
 /*
 FileScanner discovers files in the source directory and reports them to FileTracker.
 
 Responsibilities:
-1. Periodically scan the source directory (every 2s by default)
-2. Find files matching configured filters (e.g., "*.zip")
-3. Find corresponding .sha256 files
+1. Periodically scan the source directory (every 2s by default),
+   optionally descending into subfolders and statting files on several
+   goroutines (scanParallelism, for folders with a large backlog)
+2. Find files matching configured filters (e.g., "*.zip"), or in
+   exclude mode every file not matching the exclude filters,
+   skipping files and folders matching exclude patterns and
+   files still being uploaded under a temp name (e.g., "data.zip.part")
+   and files last modified before the configured cutoff
+3. Find corresponding .sha256 files and optional <datafile>.meta.json
+   directives (see directives.go); data files whose name carries the
+   expected hash (filenameHashPattern) need no .sha256 file, nor do any
+   data files when expected hashes come from the database or archives
+   checked against their own checksums (archiveIntegrity) or a checksum
+   member they carry (embeddedChecksum)
 4. Report discovered files to FileTracker for tracking
-5. Graceful start/stop with context cancellation
+5. Run the optional preScanCommand before each scan (e.g. to mount or
+   refresh the source); a scan whose command fails is skipped
+6. Graceful start/stop with context cancellation
+
+Files whose names contain control characters (newlines, NUL, ...) are never
+tracked; they are moved to the quarantine folder when one is configured.
+So are stray copies of a sidecar such as "data.zip (1).sha256" next to
+"data.zip.sha256" (duplicateSidecars: refuse): a data file is only ever
+paired with its exact "<datafile><suffix>" sidecar.
 
 Does NOT:
 - Track file pairs or state (that's file_tracker.go)
 - Verify hashes (that's sha_verifier.go)
-- Move or delete files (that's file_operations.go)
+- Move or delete verified files (that's worker_pool.go via file_operations.go)
 - Process verification jobs (that's worker_pool.go)
 */
 
+// Filter modes deciding which files are treated as data files
+const (
+	FilterModeInclude = "include" // Only files matching fileFilters
+	FilterModeExclude = "exclude" // All files except those matching excludeFilters
+)
+
+// Handling of stray sidecar copies (e.g. "data.zip (1).sha256")
+const (
+	DuplicateSidecarsRefuse = "refuse" // Refuse the copy like other unsafe files
+	DuplicateSidecarsTrack  = "track"  // Track it as the sidecar of a data file named "data.zip (1)"
+)
+
+// sidecarCopyPattern matches the names file managers and browsers give copies,
+// applied to the data file name derived from a sidecar ("data.zip (1)")
+var sidecarCopyPattern = regexp.MustCompile(`^(.+?)(?: \(\d+\)| - Copy(?: \(\d+\))?| copy(?: \d+)?)$`)
+
+// scannedEntry is a file found by the walk, waiting for a scan goroutine
+type scannedEntry struct {
+	path  string
+	entry iofs.DirEntry
+}
+
+// scanCounts counts the files found by one scan
+type scanCounts struct {
+	dataFiles atomic.Int64
+	sidecars  atomic.Int64
+}
+
 // FileScanner periodically scans the source directory for files
 type FileScanner struct {
-	sourceFolder string
-	scanInterval time.Duration
-	fileFilters  []string
-	tracker      *FileTracker
-	ctx          context.Context
-	cancel       context.CancelFunc
-	wg           sync.WaitGroup
-	logLevel     string
+	sourceFolder       string
+	scanInterval       time.Duration
+	preScanCommand     []string      // Run before each scan (empty = none)
+	preScanTimeout     time.Duration // Kill the pre-scan command after this long
+	fileFilters        []string
+	filterMode         string
+	excludeFilters     []string
+	filenameHash       *regexp.Regexp // Extracts the expected hash from data file names (nil = disabled)
+	hashSource         string         // Where expected hashes come from (sidecar, database)
+	archiveIntegrity   []string       // Archives verified by their member checksums instead of a sidecar
+	embeddedChecksum   []string       // Archives verified against a checksum member inside them
+	duplicateSidecars  string         // Handling of stray sidecar copies (refuse, track)
+	recursive          bool
+	parallelism        int // Goroutines statting and registering files during a scan (1 = the walk itself)
+	excludePatterns    []string
+	inProgressSuffixes []string
+	inProgressPrefixes []string
+	minModTime         time.Time         // Files modified before this are ignored (zero = no cutoff)
+	destinations       *Destinations     // Refused files go to its quarantine folder (empty = leave in place)
+	manifests          *ManifestRegistry // Batch manifests (nil = disabled)
+	onCollision        string
+	growing            GrowingConfig // Growing files and their segment sidecars are left alone
+	features           Features
+	warnedRefused      map[string]bool // Refused files already reported (when not quarantined)
+	refusedMutex       sync.Mutex      // Guards warnedRefused
+	firstScanDone      chan struct{}   // Closed once the initial scan has finished
+	tracker            *FileTracker
+	ctx                context.Context
+	cancel             context.CancelFunc
+	wg                 sync.WaitGroup
+	logLevel           *LogLevel
+}
+
+// FileScannerOptions configures a file scanner
+type FileScannerOptions struct {
+	SourceFolder       string
+	ScanInterval       time.Duration
+	PreScanCommand     []string      // Run before each scan (empty = none)
+	PreScanTimeout     time.Duration // Kill the pre-scan command after this long
+	FileFilters        []string
+	FilterMode         string
+	ExcludeFilters     []string
+	FilenameHash       *regexp.Regexp // Extracts the expected hash from data file names (nil = disabled)
+	HashSource         string         // Where expected hashes come from (sidecar, database)
+	ArchiveIntegrity   []string       // Archives verified by their member checksums instead of a sidecar
+	EmbeddedChecksum   []string       // Archives verified against a checksum member inside them
+	DuplicateSidecars  string         // Handling of stray sidecar copies (refuse, track)
+	Recursive          bool
+	Parallelism        int // Goroutines statting and registering files during a scan (1 = the walk itself)
+	ExcludePatterns    []string
+	InProgressSuffixes []string
+	InProgressPrefixes []string
+	MinModTime         time.Time     // Files modified before this are ignored (zero = no cutoff)
+	Destinations       *Destinations // Refused files go to its quarantine folder (empty = leave in place)
+	OnCollision        string
+	Manifests          *ManifestRegistry // Batch manifests (nil = disabled)
+	Growing            GrowingConfig     // Growing files, left to growing_files.go (no patterns = none)
+	Features           Features
+	Tracker            *FileTracker
+	LogLevel           *LogLevel
 }
 
 // NewFileScanner creates a new file scanner
-func NewFileScanner(sourceFolder string, scanInterval time.Duration, fileFilters []string, tracker *FileTracker, logLevel string) *FileScanner {
+func NewFileScanner(opts FileScannerOptions) *FileScanner {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &FileScanner{
-		sourceFolder: sourceFolder,
-		scanInterval: scanInterval,
-		fileFilters:  fileFilters,
-		tracker:      tracker,
-		ctx:          ctx,
-		cancel:       cancel,
-		logLevel:     logLevel,
+		sourceFolder:       opts.SourceFolder,
+		scanInterval:       opts.ScanInterval,
+		preScanCommand:     opts.PreScanCommand,
+		preScanTimeout:     opts.PreScanTimeout,
+		fileFilters:        opts.FileFilters,
+		filterMode:         opts.FilterMode,
+		excludeFilters:     opts.ExcludeFilters,
+		filenameHash:       opts.FilenameHash,
+		hashSource:         opts.HashSource,
+		archiveIntegrity:   opts.ArchiveIntegrity,
+		embeddedChecksum:   opts.EmbeddedChecksum,
+		duplicateSidecars:  opts.DuplicateSidecars,
+		recursive:          opts.Recursive,
+		parallelism:        opts.Parallelism,
+		excludePatterns:    opts.ExcludePatterns,
+		inProgressSuffixes: opts.InProgressSuffixes,
+		inProgressPrefixes: opts.InProgressPrefixes,
+		minModTime:         opts.MinModTime,
+		destinations:       opts.Destinations,
+		onCollision:        opts.OnCollision,
+		manifests:          opts.Manifests,
+		growing:            opts.Growing,
+		features:           opts.Features,
+		warnedRefused:      make(map[string]bool),
+		firstScanDone:      make(chan struct{}),
+		tracker:            opts.Tracker,
+		ctx:                ctx,
+		cancel:             cancel,
+		logLevel:           opts.LogLevel,
 	}
 }
 
@@ -59,7 +186,7 @@ func (fs *FileScanner) Start() {
 	fs.wg.Add(1)
 	go fs.scanLoop()
 
-	if fs.logLevel == "DEBUG" || fs.logLevel == "INFO" {
+	if fs.logLevel.Get() == "DEBUG" || fs.logLevel.Get() == "INFO" {
 		fmt.Printf("[Scanner] Started scanning %s every %s\n", fs.sourceFolder, fs.scanInterval)
 	}
 }
@@ -69,7 +196,7 @@ func (fs *FileScanner) Stop() {
 	fs.cancel()
 	fs.wg.Wait()
 
-	if fs.logLevel == "DEBUG" || fs.logLevel == "INFO" {
+	if fs.logLevel.Get() == "DEBUG" || fs.logLevel.Get() == "INFO" {
 		fmt.Println("[Scanner] Stopped")
 	}
 }
@@ -82,6 +209,7 @@ func (fs *FileScanner) scanLoop() {
 	if err := fs.scan(); err != nil {
 		fmt.Fprintf(os.Stderr, "[Scanner] Error during initial scan: %v\n", err)
 	}
+	close(fs.firstScanDone)
 
 	ticker := time.NewTicker(fs.scanInterval)
 	defer ticker.Stop()
@@ -100,83 +228,494 @@ func (fs *FileScanner) scanLoop() {
 
 // scan performs a single directory scan
 func (fs *FileScanner) scan() error {
-	if fs.logLevel == "DEBUG" {
+	if fs.features.Maintenance.Paused() {
+		if fs.logLevel.Get() == "DEBUG" {
+			fmt.Println("[Scanner] Skipping scan during maintenance window")
+		}
+		return nil
+	}
+
+	if err := fs.runPreScan(); err != nil {
+		// A command killed because the scanner is stopping is not worth a warning
+		if fs.ctx.Err() == nil && (fs.logLevel.Get() == "WARN" || fs.logLevel.Get() == "DEBUG") {
+			fmt.Fprintf(os.Stderr, "[Scanner] Skipping scan, pre-scan command failed: %v\n", err)
+		}
+		return nil
+	}
+
+	if fs.logLevel.Get() == "DEBUG" {
 		fmt.Printf("[Scanner] Scanning %s...\n", fs.sourceFolder)
 	}
 
-	// Read directory contents
-	entries, err := os.ReadDir(fs.sourceFolder)
-	if err != nil {
-		return fmt.Errorf("failed to read directory: %w", err)
+	var counts scanCounts
+
+	// Directories are read in order; with scanParallelism > 1 the files are
+	// handed to that many goroutines, which stat and register them
+	var files chan scannedEntry
+	var handlers sync.WaitGroup
+	if fs.parallelism > 1 {
+		files = make(chan scannedEntry, fs.parallelism)
+		for i := 0; i < fs.parallelism; i++ {
+			handlers.Add(1)
+			go func() {
+				defer handlers.Done()
+				for file := range files {
+					fs.scanFile(file.path, file.entry, &counts)
+				}
+			}()
+		}
 	}
 
-	dataFilesFound := 0
-	sha256FilesFound := 0
+	// Walk the source folder (top level only unless recursive)
+	err := filepath.WalkDir(fs.sourceFolder, func(fullPath string, entry iofs.DirEntry, err error) error {
+		if err != nil {
+			// The source folder itself must be readable
+			if fullPath == fs.sourceFolder {
+				return fmt.Errorf("failed to read directory: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "[Scanner] Failed to read %s: %v\n", fullPath, err)
+			return nil
+		}
 
-	// Process each entry
-	for _, entry := range entries {
-		// Skip directories
+		// Always descend into the source folder itself
+		if fullPath == fs.sourceFolder {
+			return nil
+		}
+
+		// Handle subfolders
 		if entry.IsDir() {
-			continue
+			if !fs.recursive {
+				return iofs.SkipDir
+			}
+			if fs.isExcluded(fullPath) {
+				if fs.logLevel.Get() == "DEBUG" {
+					fmt.Printf("[Scanner] Excluding folder: %s\n", fullPath)
+				}
+				return iofs.SkipDir
+			}
+			return nil
 		}
 
-		filename := entry.Name()
-		fullPath := filepath.Join(fs.sourceFolder, filename)
+		// Stat and register the file here, or on a scan goroutine
+		if files == nil {
+			fs.scanFile(fullPath, entry, &counts)
+			return nil
+		}
+		select {
+		case files <- scannedEntry{fullPath, entry}:
+		case <-fs.ctx.Done():
+			return iofs.SkipAll
+		}
+		return nil
+	})
+	if files != nil {
+		close(files)
+		handlers.Wait()
+	}
+	if err != nil {
+		return err
+	}
+
+	if fs.logLevel.Get() == "DEBUG" {
+		fmt.Printf("[Scanner] Scan complete: %d data files, %d SHA256 files\n", counts.dataFiles.Load(), counts.sidecars.Load())
+	}
 
-		// Check if it's a .sha256 file
-		if strings.HasSuffix(filename, ".sha256") {
-			// This is a SHA256 file
-			fs.tracker.AddOrUpdateSHA256File(fullPath)
-			sha256FilesFound++
+	if fs.manifests != nil {
+		fs.manifests.ReportMissing(fs.logLevel)
+	}
 
-			if fs.logLevel == "DEBUG" {
-				fmt.Printf("[Scanner] Found SHA256 file: %s\n", filename)
+	return nil
+}
+
+// runPreScan runs the configured pre-scan command, if any, and waits for it to succeed
+func (fs *FileScanner) runPreScan() error {
+	if len(fs.preScanCommand) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(fs.ctx, fs.preScanTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, fs.preScanCommand[0], fs.preScanCommand[1:]...)
+	cmd.Env = append(os.Environ(), "FILESHA_SOURCE_FOLDER="+fs.sourceFolder)
+	// A child left holding the output pipe must not keep the scan waiting
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", fs.preScanTimeout)
+	}
+	if err != nil {
+		if detail := strings.TrimSpace(string(output)); detail != "" {
+			return fmt.Errorf("%w: %s", err, lastLine(detail))
+		}
+		return err
+	}
+
+	if fs.logLevel.Get() == "DEBUG" {
+		fmt.Printf("[Scanner] Pre-scan command succeeded\n")
+	}
+	return nil
+}
+
+// lastLine returns the last line of a command's output, which usually holds its error
+func lastLine(output string) string {
+	return output[strings.LastIndexByte(output, '\n')+1:]
+}
+
+// scanFile handles one file found by the walk: skips, refuses or reports it to the tracker
+// Safe to call from several goroutines (source.scanParallelism)
+func (fs *FileScanner) scanFile(fullPath string, entry iofs.DirEntry, counts *scanCounts) {
+	filename := entry.Name()
+
+	// Skip excluded files
+	if fs.isExcluded(fullPath) {
+		return
+	}
+
+	// Never hash a file that is still being written under a temp name
+	if fs.isInProgress(filename) {
+		if fs.logLevel.Get() == "DEBUG" {
+			fmt.Printf("[Scanner] Skipping in-progress upload: %s\n", filename)
+		}
+		return
+	}
+
+	// Refuse names that could corrupt logs and downstream parsers
+	if strings.IndexFunc(filename, unicode.IsControl) >= 0 {
+		fs.refuseFile(fullPath, "control characters in file name")
+		return
+	}
+
+	// Skip files older than the cutoff (touch a file to reprocess it)
+	if !fs.minModTime.IsZero() {
+		info, err := entry.Info()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[Scanner] Failed to get file info for %s: %v\n", filename, err)
+			return
+		}
+		if info.ModTime().Before(fs.minModTime) {
+			return
+		}
+	}
+
+	// Directives files and chunk manifests are read together with their data file, signatures with their sidecar
+	if strings.HasSuffix(filename, directivesSuffix) {
+		return
+	}
+	if fs.features.Signatures != nil && fs.features.Signatures.IsSignature(filename) {
+		return
+	}
+	if fs.features.ChunkManifests != nil && fs.features.ChunkManifests.IsManifest(filename) {
+		return
+	}
+
+	// Growing files and their segment sidecars are verified in place (growing_files.go)
+	if fs.growing.Owns(filename) {
+		return
+	}
+
+	// Manifests are loaded so entries that never arrive can be reported
+	if fs.manifests != nil && fs.manifests.IsManifest(filename) {
+		fs.manifests.Track(fullPath)
+		return
+	}
+
+	// Check if it's a sidecar file (.sha256 or another configured suffix)
+	if suffix := fs.sidecarSuffix(filename); suffix != "" {
+		// A stray copy must not be mistaken for the sidecar of another file
+		if fs.duplicateSidecars == DuplicateSidecarsRefuse {
+			if original := duplicateSidecarOf(fullPath, suffix); original != "" {
+				fs.refuseFile(fullPath, "ambiguous copy of "+filepath.Base(original))
+				return
 			}
-			continue
 		}
 
-		// Check if it matches any data file filter
-		if fs.matchesFilter(filename) {
-			// This is a data file
-			info, err := entry.Info()
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "[Scanner] Failed to get file info for %s: %v\n", filename, err)
-				continue
+		// This is a SHA256 file
+		info, err := entry.Info()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[Scanner] Failed to get file info for %s: %v\n", filename, err)
+			return
+		}
+		fs.tracker.AddOrUpdateSidecar(fullPath, suffix, info.Size(), info.ModTime())
+		counts.sidecars.Add(1)
+
+		if fs.logLevel.Get() == "DEBUG" {
+			fmt.Printf("[Scanner] Found SHA256 file: %s\n", filename)
+		}
+		return
+	}
+
+	// Check if it matches any data file filter
+	if fs.matchesFilter(filename) {
+		// This is a data file
+		info, err := entry.Info()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[Scanner] Failed to get file info for %s: %v\n", filename, err)
+			return
+		}
+
+		// A file the folder's manifest does not list is not part of the batch
+		var manifestHash string
+		if fs.manifests != nil {
+			manifestPath, hash := fs.manifests.Lookup(fullPath)
+			if manifestPath != "" && hash == "" {
+				if fs.manifests.RecordExtra(fullPath, manifestPath) {
+					fs.tracker.Remove(fullPath)
+				}
+				fs.refuseFile(fullPath, "not listed in "+filepath.Base(manifestPath))
+				return
 			}
+			manifestHash = hash
+		}
+
+		fileSize := info.Size()
+		fs.tracker.AddOrUpdateDataFile(fullPath, fileSize, info.ModTime())
+		fs.checkLeftInPlace(fullPath, fileSize, info.ModTime())
+		fs.loadDirectives(fullPath)
+		counts.dataFiles.Add(1)
+
+		if fs.logLevel.Get() == "DEBUG" {
+			fmt.Printf("[Scanner] Found data file: %s (%d bytes)\n", filename, fileSize)
+		}
+
+		// The expected hash is listed in the manifest, don't wait for a .sha256 file
+		if manifestHash != "" {
+			fs.tracker.SetEmbeddedHash(fullPath, manifestHash)
+			return
+		}
+
+		// The expected hash is part of the name, don't wait for a .sha256 file
+		if hash := fs.embeddedHash(filename); hash != "" {
+			fs.tracker.SetEmbeddedHash(fullPath, hash)
+			return
+		}
 
-			fileSize := info.Size()
-			fs.tracker.AddOrUpdateDataFile(fullPath, fileSize)
-			dataFilesFound++
+		// The expected hash is looked up by the worker, don't wait for a .sha256 file
+		if fs.hashSource == HashSourceDatabase || fs.hashSource == HashSourceRedis {
+			fs.tracker.MarkExternalHash(fullPath)
+			return
+		}
+
+		// The archive carries the payload's hash, don't wait for a .sha256 file
+		if matchesAny(fs.embeddedChecksum, filename) {
+			fs.tracker.MarkEmbeddedChecksum(fullPath)
+			return
+		}
+
+		// The archive carries its own checksums, don't wait for a .sha256 file
+		if matchesAny(fs.archiveIntegrity, filename) {
+			fs.tracker.MarkArchiveCheck(fullPath)
+			return
+		}
 
-			if fs.logLevel == "DEBUG" {
-				fmt.Printf("[Scanner] Found data file: %s (%d bytes)\n", filename, fileSize)
+		// Check if a corresponding sidecar exists, preferred suffix first
+		for rank, suffix := range fs.tracker.SidecarSuffixes() {
+			sha256Path := fullPath + suffix
+			sha256Info, err := os.Stat(sha256Path)
+			if err != nil {
+				continue
 			}
 
-			// Check if corresponding .sha256 file exists
-			sha256Path := fullPath + ".sha256"
-			if _, err := os.Stat(sha256Path); err == nil {
-				// SHA256 file exists
-				fs.tracker.AddOrUpdateSHA256File(sha256Path)
-				fs.tracker.MarkBothFilesPresent(filename)
+			// Sidecar file exists
+			fs.tracker.AddOrUpdateSidecar(sha256Path, suffix, sha256Info.Size(), sha256Info.ModTime())
+			fs.tracker.MarkBothFilesPresent(fullPath)
 
-				if fs.logLevel == "DEBUG" {
-					fmt.Printf("[Scanner] Found complete pair: %s + %s.sha256\n", filename, filename)
+			if fs.logLevel.Get() == "DEBUG" {
+				if rank > 0 {
+					fmt.Printf("[Scanner] No higher-priority sidecar for %s, using %s%s\n", filename, filename, suffix)
 				}
+				fmt.Printf("[Scanner] Found complete pair: %s + %s%s\n", filename, filename, suffix)
 			}
+			break
 		}
 	}
+}
 
-	if fs.logLevel == "DEBUG" {
-		fmt.Printf("[Scanner] Scan complete: %d data files, %d SHA256 files\n", dataFilesFound, sha256FilesFound)
+// sidecarSuffix returns the configured sidecar suffix a file name ends with ("" = not a sidecar)
+// With verification.caseInsensitive, "DATA.ZIP.SHA256" ends with ".sha256"
+func (fs *FileScanner) sidecarSuffix(filename string) string {
+	for _, suffix := range fs.tracker.SidecarSuffixes() {
+		if fs.tracker.hasSuffix(filename, suffix) {
+			return suffix
+		}
 	}
+	return ""
+}
 
-	return nil
+// duplicateSidecarOf returns the exact sidecar that a sidecar is a stray copy
+// of, or "" when it is not one: its own data file does not exist, its name
+// carries a copy marker, and the sidecar of the unmarked data name exists
+func duplicateSidecarOf(sidecarPath, suffix string) string {
+	dataPath := trimSidecarSuffix(sidecarPath, suffix)
+	match := sidecarCopyPattern.FindStringSubmatch(filepath.Base(dataPath))
+	if match == nil || FileExists(dataPath) {
+		return ""
+	}
+
+	original := filepath.Join(filepath.Dir(dataPath), match[1]) + suffix
+	if !FileExists(original) {
+		return ""
+	}
+	return original
 }
 
-// matchesFilter checks if a filename matches any of the configured filters
+// isExcluded checks if a file or folder matches any exclude pattern
+// Patterns are matched against both the base name and the path relative
+// to the source folder, e.g. "tmp", ".*", "*.partial", "staging/*"
+func (fs *FileScanner) isExcluded(fullPath string) bool {
+	name := filepath.Base(fullPath)
+	relPath, err := filepath.Rel(fs.sourceFolder, fullPath)
+	if err != nil {
+		relPath = name
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	for _, pattern := range fs.excludePatterns {
+		if matched, err := filepath.Match(pattern, name); err == nil && matched {
+			return true
+		}
+		if matched, err := path.Match(pattern, relPath); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isInProgress checks if a filename carries one of the configured temp-name
+// suffixes or prefixes used by upload clients (e.g. "data.zip.part", ".data.zip")
+func (fs *FileScanner) isInProgress(filename string) bool {
+	for _, suffix := range fs.inProgressSuffixes {
+		if strings.HasSuffix(filename, suffix) {
+			return true
+		}
+	}
+	for _, prefix := range fs.inProgressPrefixes {
+		if strings.HasPrefix(filename, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLeftInPlace verifies a pair left in the source folder again when the change detector sees it changed
+func (fs *FileScanner) checkLeftInPlace(fullPath string, size int64, modTime time.Time) {
+	if fs.features.ChangeDetector == nil {
+		return
+	}
+	fingerprint := fs.tracker.LeftInPlace(fullPath)
+	if fingerprint == nil {
+		return
+	}
+
+	changed, touched := fs.features.ChangeDetector.Changed(fullPath, fingerprint, size, modTime)
+	if changed {
+		if fs.logLevel.Get() == "DEBUG" || fs.logLevel.Get() == "INFO" {
+			fmt.Printf("[Scanner] %s changed since it was verified, verifying it again\n", filepath.Base(fullPath))
+		}
+		fs.tracker.Reverify(fullPath)
+		return
+	}
+	if touched != nil {
+		if fs.logLevel.Get() == "DEBUG" {
+			fmt.Printf("[Scanner] %s was touched but its sampled content is unchanged\n", filepath.Base(fullPath))
+		}
+		fs.tracker.SetFingerprint(fullPath, touched)
+	}
+}
+
+// refuseFile quarantines a file the scanner will not track
+// Without a quarantine folder the file is left in place and reported once
+func (fs *FileScanner) refuseFile(fullPath, reason string) {
+	quarantineFolder := fs.destinations.Get().Quarantine
+	if quarantineFolder == "" {
+		fs.refusedMutex.Lock()
+		defer fs.refusedMutex.Unlock()
+
+		if !fs.warnedRefused[fullPath] {
+			fs.warnedRefused[fullPath] = true
+			fmt.Fprintf(os.Stderr, "[Scanner] REFUSED %q: %s (no quarantine folder configured, leaving in place)\n",
+				fullPath, reason)
+		}
+		return
+	}
+
+	newPath, err := MoveToQuarantine(fullPath, quarantineFolder, fs.onCollision)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[Scanner] Failed to quarantine %q: %v\n", fullPath, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "[Scanner] QUARANTINED %q: %s (moved to %s)\n", fullPath, reason, newPath)
+}
+
+// loadDirectives parses the optional <datafile>.meta.json of a data file
+// The file is only re-parsed when its size or modification time changes
+func (fs *FileScanner) loadDirectives(dataFilePath string) {
+	var current *FileDirectives
+	if pair, exists := fs.tracker.GetFilePair(dataFilePath); exists {
+		current = pair.Directives
+	}
+
+	path := dataFilePath + directivesSuffix
+	info, err := os.Stat(path)
+	if err != nil {
+		if current != nil {
+			fs.tracker.SetDirectives(dataFilePath, nil)
+		}
+		return
+	}
+	if current != nil && current.Size == info.Size() && current.ModTime.Equal(info.ModTime()) {
+		return
+	}
+
+	directives, unknown := ParseDirectivesFile(path, info.Size(), info.ModTime())
+	fs.tracker.SetDirectives(dataFilePath, directives)
+
+	if fs.logLevel.Get() == "WARN" || fs.logLevel.Get() == "DEBUG" {
+		if len(unknown) > 0 {
+			fmt.Fprintf(os.Stderr, "[Scanner] Ignoring unknown directives in %s: %v\n", filepath.Base(path), unknown)
+		}
+		if directives.Error != "" {
+			fmt.Fprintf(os.Stderr, "[Scanner] %s: %s\n", filepath.Base(path), directives.Error)
+		}
+	}
+}
+
+// matchesFilter checks if a filename should be treated as a data file
 // Supports wildcard patterns like "*.zip", "*.tar.gz"
+// In exclude mode every file not matching an exclude filter is a data file
+// With verification.caseInsensitive, "DATA.ZIP" matches "*.zip"
 func (fs *FileScanner) matchesFilter(filename string) bool {
-	for _, filter := range fs.fileFilters {
+	match := matchesAny
+	if fs.tracker.CaseInsensitive() {
+		match = matchesAnyFold
+	}
+	if fs.filterMode == FilterModeExclude {
+		return !match(fs.excludeFilters, filename)
+	}
+	return match(fs.fileFilters, filename)
+}
+
+// embeddedHash extracts the expected hash from a data file name
+// Uses the capture group named "hash" if the pattern has one, otherwise the first group
+// Returns "" when the feature is disabled or the name does not match
+func (fs *FileScanner) embeddedHash(filename string) string {
+	if fs.filenameHash == nil {
+		return ""
+	}
+
+	match := fs.filenameHash.FindStringSubmatch(filename)
+	if match == nil {
+		return ""
+	}
+	if index := fs.filenameHash.SubexpIndex("hash"); index >= 0 {
+		return match[index]
+	}
+	return match[1]
+}
+
+// matchesAny checks if a filename matches any of the given patterns
+func matchesAny(patterns []string, filename string) bool {
+	for _, filter := range patterns {
 		matched, err := filepath.Match(filter, filename)
 		if err != nil {
 			// Invalid pattern, skip
@@ -189,6 +728,20 @@ func (fs *FileScanner) matchesFilter(filename string) bool {
 	return false
 }
 
+// matchesAnyFold checks if a filename matches any of the given patterns, ignoring case
+func matchesAnyFold(patterns []string, filename string) bool {
+	lowered := make([]string, len(patterns))
+	for i, filter := range patterns {
+		lowered[i] = strings.ToLower(filter)
+	}
+	return matchesAny(lowered, strings.ToLower(filename))
+}
+
+// FirstScanDone returns a channel that is closed once the initial scan has finished
+func (fs *FileScanner) FirstScanDone() <-chan struct{} {
+	return fs.firstScanDone
+}
+
 // GetPendingCount returns the current number of tracked files
 func (fs *FileScanner) GetPendingCount() int {
 	return fs.tracker.GetPendingCount()
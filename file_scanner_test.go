@@ -0,0 +1,213 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestScanner creates a recursive scanner of source for "*.zip" data files and
+// ".sha256" sidecars, logging errors only
+func newTestScanner(source string, tracker *FileTracker) *FileScanner {
+	if tracker == nil {
+		tracker = NewFileTracker(time.Hour, 0, []string{".sha256"}, false, false)
+	}
+	return NewFileScanner(FileScannerOptions{
+		SourceFolder:      source,
+		ScanInterval:      time.Hour,
+		FileFilters:       []string{"*.zip"},
+		FilterMode:        FilterModeInclude,
+		HashSource:        HashSourceSidecar,
+		DuplicateSidecars: DuplicateSidecarsRefuse,
+		Recursive:         true,
+		Parallelism:       1,
+		Destinations:      NewDestinations(SourceConfig{Folder: source}, DestinationConfig{}),
+		OnCollision:       CollisionRename,
+		Tracker:           tracker,
+		LogLevel:          NewLogLevel("ERROR"),
+	})
+}
+
+// writeTestFile creates a file with content and returns its path
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeTestPair creates a data file and its sidecar, creating folders as needed
+func writeTestPair(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, name, "data")
+	writeTestFile(t, dir, name+".sha256", "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7")
+}
+
+// trackedNames returns the data file names of the tracked pairs, relative to source
+func trackedNames(t *testing.T, tracker *FileTracker, source string) map[string]bool {
+	t.Helper()
+	names := map[string]bool{}
+	for _, pair := range tracker.GetAllFiles() {
+		rel, err := filepath.Rel(source, pair.dataPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+		names[filepath.ToSlash(rel)] = true
+	}
+	return names
+}
+
+func TestScanExcludesFoldersAndFiles(t *testing.T) {
+	source := t.TempDir()
+	writeTestPair(t, source, "keep.zip")
+	writeTestPair(t, filepath.Join(source, "in"), "nested.zip")
+	writeTestPair(t, filepath.Join(source, "tmp"), "staged.zip")
+	writeTestPair(t, filepath.Join(source, "in", ".partial"), "hidden.zip")
+	writeTestPair(t, filepath.Join(source, "staging", "a"), "deep.zip")
+	writeTestPair(t, source, "upload.partial.zip")
+
+	scanner := newTestScanner(source, nil)
+	scanner.excludePatterns = []string{"tmp", ".*", "upload.*", "staging/*"}
+	if err := scanner.scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := trackedNames(t, scanner.tracker, source)
+	want := map[string]bool{"keep.zip": true, "in/nested.zip": true}
+	if len(got) != len(want) {
+		t.Fatalf("tracked %v, want %v", got, want)
+	}
+	for name := range want {
+		if !got[name] {
+			t.Errorf("%s not tracked, tracked %v", name, got)
+		}
+	}
+}
+
+func TestScanWithoutExcludePatternsTracksEverything(t *testing.T) {
+	source := t.TempDir()
+	writeTestPair(t, source, "keep.zip")
+	writeTestPair(t, filepath.Join(source, "tmp"), "staged.zip")
+
+	scanner := newTestScanner(source, nil)
+	if err := scanner.scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := trackedNames(t, scanner.tracker, source); !got["keep.zip"] || !got["tmp/staged.zip"] {
+		t.Errorf("tracked %v, want keep.zip and tmp/staged.zip", got)
+	}
+}
+
+func TestDuplicateSidecarOf(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPair(t, dir, "data.zip")
+	writeTestFile(t, dir, "kept.zip (1)", "data")
+
+	tests := []struct {
+		sidecar string
+		want    string
+	}{
+		{"data.zip (1).sha256", "data.zip.sha256"},
+		{"data.zip - Copy.sha256", "data.zip.sha256"},
+		{"data.zip - Copy (2).sha256", "data.zip.sha256"},
+		{"data.zip copy 3.sha256", "data.zip.sha256"},
+		{"data.zip.sha256", ""},            // No copy marker
+		{"other.zip (1).sha256", ""},       // No sidecar it could be a copy of
+		{"kept.zip (1).sha256", ""},        // Its own data file exists
+		{"data.zip (final).sha256", ""},    // Not a copy marker
+		{"data.zip (1) (2).sha256", ""},    // Copy of a copy whose sidecar is missing
+		{"data.zip.sha256 (1).sha256", ""}, // Sidecar of a file named like a sidecar copy
+	}
+	for _, test := range tests {
+		got := duplicateSidecarOf(filepath.Join(dir, test.sidecar), ".sha256")
+		if test.want != "" {
+			test.want = filepath.Join(dir, test.want)
+		}
+		if got != test.want {
+			t.Errorf("duplicateSidecarOf(%q) = %q, want %q", test.sidecar, got, test.want)
+		}
+	}
+}
+
+func TestScanHandlesDuplicateSidecars(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		quarantine bool
+		wantCopy   bool // The copy is tracked as the sidecar of "data.zip (1)"
+		wantMoved  bool // The copy is moved to the quarantine folder
+	}{
+		{"refused without quarantine", DuplicateSidecarsRefuse, false, false, false},
+		{"refused to quarantine", DuplicateSidecarsRefuse, true, false, true},
+		{"tracked", DuplicateSidecarsTrack, true, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := t.TempDir()
+			writeTestPair(t, source, "data.zip")
+			duplicate := writeTestFile(t, source, "data.zip (1).sha256", dataSHA256)
+
+			scanner := newTestScanner(source, nil)
+			scanner.duplicateSidecars = test.mode
+			quarantine := t.TempDir()
+			if test.quarantine {
+				scanner.destinations = NewDestinations(SourceConfig{Folder: source}, DestinationConfig{QuarantineFolder: quarantine})
+			}
+			if err := scanner.scan(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := trackedNames(t, scanner.tracker, source)
+			if !got["data.zip"] || got["data.zip (1)"] != test.wantCopy {
+				t.Errorf("tracked %v", got)
+			}
+			if moved := FileExists(filepath.Join(quarantine, "data.zip (1).sha256")); moved != test.wantMoved || FileExists(duplicate) == moved {
+				t.Errorf("copy moved to quarantine = %v, want %v", moved, test.wantMoved)
+			}
+		})
+	}
+}
+
+func TestCaseInsensitivePairing(t *testing.T) {
+	tests := []struct {
+		name            string
+		sidecar         string
+		caseInsensitive bool
+		wantPaired      bool
+	}{
+		{"case-insensitive", "data.zip.sha256", true, true},
+		{"case-insensitive suffix", "DATA.ZIP.SHA256", true, true},
+		{"case-sensitive", "data.zip.sha256", false, false},
+		{"case-sensitive suffix", "DATA.ZIP.SHA256", false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := t.TempDir()
+			writeTestFile(t, source, "DATA.ZIP", "data")
+			writeTestFile(t, source, test.sidecar, dataSHA256)
+
+			tracker := NewFileTracker(time.Hour, 0, []string{".sha256"}, false, test.caseInsensitive)
+			if err := newTestScanner(source, tracker).scan(); err != nil {
+				t.Fatal(err)
+			}
+
+			pair, ok := tracker.GetFilePair(tracker.keyOf(filepath.Join(source, "DATA.ZIP")))
+			if paired := ok && pair.HasBothFiles; paired != test.wantPaired {
+				t.Fatalf("paired = %v, want %v (pair %+v)", paired, test.wantPaired, pair)
+			}
+			if test.wantPaired && filepath.Base(pair.DataFilePath) != "DATA.ZIP" {
+				t.Errorf("data path %s, want the on-disk name DATA.ZIP", pair.DataFilePath)
+			}
+			if test.wantPaired && (pair.SHA256File != test.sidecar || pair.hashAlgorithm() != AlgorithmSHA256) {
+				t.Errorf("sidecar %s (%s), want %s (%s)", pair.SHA256File, pair.hashAlgorithm(), test.sidecar, AlgorithmSHA256)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileArrivalTime returns when a file last changed location or metadata (ctime)
+// A rename updates ctime but preserves mtime, so this reflects when the file was delivered
+func fileArrivalTime(info os.FileInfo) time.Time {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.ModTime()
+	}
+
+	ctime := time.Unix(stat.Ctim.Sec, stat.Ctim.Nsec)
+	if ctime.After(info.ModTime()) {
+		return ctime
+	}
+	return info.ModTime()
+}
+
+// fileAccessTime returns when a file was last read (atime)
+func fileAccessTime(info os.FileInfo) time.Time {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.ModTime()
+	}
+	return time.Unix(stat.Atim.Sec, stat.Atim.Nsec)
+}
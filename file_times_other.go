@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// fileArrivalTime falls back to the modification time where ctime is not available
+func fileArrivalTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
+
+// fileAccessTime falls back to the modification time where atime is not available
+func fileAccessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
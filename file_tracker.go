@@ -2,19 +2,26 @@ package main
 
 import (
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
 /*
 FileTracker manages the lifecycle of file pairs (data file + .sha256 file).
+Other sidecar suffixes (e.g. .md5) can be configured in priority order; a
+data file with several sidecars is verified against the preferred one.
 
 Responsibilities:
-1. Track file pairs in memory using a map keyed by data filename
+1. Track file pairs in memory using a map keyed by data file path
 2. Determine when BOTH files in a pair exist and are ready for verification
-3. Track when each file pair was first seen (for retry timeout logic)
+3. Track when each file pair was first seen (for retry timeout logic), and
+   when each half arrived (for the arrival skew metric)
 4. Identify files that have exceeded retry timeout and should move to DLQ
-5. Thread-safe operations for concurrent access
+5. Hold the sidecars of delivered files until their deferred deletion is due
+   (destination.sidecarDeleteDelay), so the scanner doesn't track them as orphans
+6. Thread-safe operations for concurrent access
 
 Does NOT:
 - Scan the file system (that's file_scanner.go)
@@ -24,40 +31,110 @@ Does NOT:
 
 // FileTracker manages file pair tracking and retry timeout logic
 type FileTracker struct {
-	mutex        sync.RWMutex
-	files        map[string]*FilePair // Key: data filename (e.g., "data.zip")
-	retryTimeout time.Duration        // How long to wait before moving to DLQ
+	mutex             sync.RWMutex
+	files             map[string]*FilePair        // Key: data file path (e.g., "/upload/data.zip")
+	retryTimeout      time.Duration               // How long to wait before moving to DLQ
+	changeSettleDelay time.Duration               // Wait before re-verifying a file that changed mid-verification
+	sidecarSuffixes   []string                    // Sidecar suffixes in priority order (e.g., ".sha256", ".md5")
+	reverifyOnChange  bool                        // Re-verify skipped pairs whose sidecar changes
+	deferred          map[string]DeferredDeletion // Key: sidecar path of a delivered pair
+	caseInsensitive   bool                        // Keys are lowercased, so "data.ZIP" pairs with "data.zip.sha256"
+
+	// Called when the second half of a pair arrives (nil = none)
+	onPaired func(pair FilePair, skew time.Duration)
+}
+
+// DeferredDeletion is a delivered pair whose sidecar is kept until the delivery is confirmed
+type DeferredDeletion struct {
+	Pair          FilePair       // The pair as verified
+	DeliveredPath string         // Where the data file was delivered
+	Transforms    []string       // Transforms applied, their inputs are deleted with the sidecar
+	Check         *DeliveryCheck // Re-hash the delivered file before deleting (nil = size check only)
+	Compressed    bool           // Delivered gzipped, the size is checked against the gzip trailer
+	Due           time.Time
 }
 
 // NewFileTracker creates a new file tracker with the specified retry timeout
-func NewFileTracker(retryTimeout time.Duration) *FileTracker {
+// A changed file is re-verified no sooner than the next scan
+func NewFileTracker(retryTimeout, changeSettleDelay time.Duration, sidecarSuffixes []string, reverifyOnChange, caseInsensitive bool) *FileTracker {
 	return &FileTracker{
-		files:        make(map[string]*FilePair),
-		retryTimeout: retryTimeout,
+		files:             make(map[string]*FilePair),
+		retryTimeout:      retryTimeout,
+		changeSettleDelay: changeSettleDelay,
+		sidecarSuffixes:   sidecarSuffixes,
+		reverifyOnChange:  reverifyOnChange,
+		deferred:          make(map[string]DeferredDeletion),
+		caseInsensitive:   caseInsensitive,
+	}
+}
+
+// CaseInsensitive reports whether file names are matched and paired ignoring case
+func (ft *FileTracker) CaseInsensitive() bool {
+	return ft.caseInsensitive
+}
+
+// keyOf returns the tracker key of a data file path (lowercased when ignoring case)
+func (ft *FileTracker) keyOf(path string) string {
+	if ft.caseInsensitive {
+		return strings.ToLower(path)
+	}
+	return path
+}
+
+// SetPairedObserver sets the function told how far apart the halves of each pair arrived
+// skew is positive when the sidecar arrived after the data file
+func (ft *FileTracker) SetPairedObserver(observer func(pair FilePair, skew time.Duration)) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	ft.onPaired = observer
+}
+
+// recordArrival notes the arrival of one half of a pair, caller holds the lock
+func (ft *FileTracker) recordArrival(pair *FilePair, seen *time.Time) {
+	if !seen.IsZero() {
+		return
+	}
+	*seen = time.Now()
+
+	if !pair.DataSeen.IsZero() && !pair.SidecarSeen.IsZero() && ft.onPaired != nil {
+		ft.onPaired(*pair, pair.SidecarSeen.Sub(pair.DataSeen))
 	}
 }
 
 // AddOrUpdateDataFile adds or updates a data file in the tracker
 // This is called when the scanner finds a data file (e.g., "data.zip")
-func (ft *FileTracker) AddOrUpdateDataFile(dataFilePath string, dataSize int64) {
+func (ft *FileTracker) AddOrUpdateDataFile(dataFilePath string, dataSize int64, modTime time.Time) {
 	ft.mutex.Lock()
 	defer ft.mutex.Unlock()
 
-	// Extract filename from path
+	// The full path is the key, so same-named files in different
+	// subfolders are tracked separately
+	key := ft.keyOf(dataFilePath)
 	dataFile := filepath.Base(dataFilePath)
 
 	// Check if we already track this file
-	if pair, exists := ft.files[dataFile]; exists {
+	if pair, exists := ft.files[key]; exists {
 		// Update existing entry
+		pair.DataFile = dataFile
 		pair.DataFilePath = dataFilePath
+		if dataSize != pair.DataSize || !modTime.Equal(pair.DataModTime) {
+			pair.LastHash = ""
+		}
 		pair.DataSize = dataSize
+		pair.DataModTime = modTime
+		ft.recordArrival(pair, &pair.DataSeen)
 	} else {
 		// Create new entry
-		ft.files[dataFile] = &FilePair{
+		now := time.Now()
+		ft.files[key] = &FilePair{
+			Key:          key,
 			DataFile:     dataFile,
 			DataFilePath: dataFilePath,
 			DataSize:     dataSize,
-			FirstSeen:    time.Now(),
+			DataModTime:  modTime,
+			FirstSeen:    now,
+			DataSeen:     now,
 			HasBothFiles: false,
 		}
 	}
@@ -65,64 +142,417 @@ func (ft *FileTracker) AddOrUpdateDataFile(dataFilePath string, dataSize int64)
 
 // AddOrUpdateSHA256File adds or updates a .sha256 file in the tracker
 // This is called when the scanner finds a .sha256 file (e.g., "data.zip.sha256")
-func (ft *FileTracker) AddOrUpdateSHA256File(sha256FilePath string) {
+func (ft *FileTracker) AddOrUpdateSHA256File(sha256FilePath string, sha256Size int64, modTime time.Time) {
+	ft.AddOrUpdateSidecar(sha256FilePath, ".sha256", sha256Size, modTime)
+}
+
+// AddOrUpdateSidecar adds or updates a sidecar file (e.g., "data.zip.md5") in the tracker
+// When a data file has several sidecars, the one whose suffix comes first in
+// sidecarSuffixes is used. Returns true if this sidecar is the one selected
+func (ft *FileTracker) AddOrUpdateSidecar(sidecarPath, suffix string, sidecarSize int64, modTime time.Time) bool {
 	ft.mutex.Lock()
 	defer ft.mutex.Unlock()
 
+	// The sidecar of a delivered pair waits for its deferred deletion
+	if _, held := ft.deferred[sidecarPath]; held {
+		return false
+	}
+
 	// Extract filename from path (e.g., "data.zip.sha256")
-	sha256File := filepath.Base(sha256FilePath)
+	sidecarFile := filepath.Base(sidecarPath)
 
-	// Derive the data filename by removing ".sha256" suffix
-	// "data.zip.sha256" -> "data.zip"
-	dataFile := sha256File[:len(sha256File)-7] // Remove ".sha256"
+	// Derive the data file path by removing the suffix
+	// "/upload/data.zip.sha256" -> "/upload/data.zip"
+	dataPath := trimSidecarSuffix(sidecarPath, suffix)
+	key := ft.keyOf(dataPath)
+	dataFile := filepath.Base(dataPath)
+	algorithm := strings.TrimPrefix(suffix, ".")
 
 	// Check if we already track this data file
-	if pair, exists := ft.files[dataFile]; exists {
+	if pair, exists := ft.files[key]; exists {
+		// Keep a higher-priority sidecar that was already selected
+		if pair.SHA256Path != "" && pair.SHA256Path != sidecarPath &&
+			ft.sidecarRank(pair.SHA256File) < ft.sidecarRank(sidecarFile) {
+			return false
+		}
+
+		// A pair verified and left in place is only re-verified against a
+		// replaced sidecar when configured; otherwise the change is ignored
+		if pair.Skipped && pair.SHA256Path == sidecarPath &&
+			(pair.SHA256Size != sidecarSize || !pair.SHA256MTime.Equal(modTime)) && ft.reverifyOnChange {
+			resetForReverify(pair)
+		}
+
 		// Update existing entry
-		pair.SHA256File = sha256File
-		pair.SHA256Path = sha256FilePath
+		pair.SHA256File = sidecarFile
+		pair.SHA256Path = sidecarPath
+		pair.SHA256Size = sidecarSize
+		pair.SHA256MTime = modTime
+		pair.SidecarAlgorithm = algorithm
 		pair.HasBothFiles = true // Both files now exist
+		ft.recordArrival(pair, &pair.SidecarSeen)
 	} else {
 		// Create new entry (data file not yet seen)
-		ft.files[dataFile] = &FilePair{
-			DataFile:     dataFile,
-			SHA256File:   sha256File,
-			SHA256Path:   sha256FilePath,
-			FirstSeen:    time.Now(),
-			HasBothFiles: false, // Data file not yet present
+		now := time.Now()
+		ft.files[key] = &FilePair{
+			Key:              key,
+			DataFile:         dataFile,
+			SHA256File:       sidecarFile,
+			SHA256Path:       sidecarPath,
+			SHA256Size:       sidecarSize,
+			SHA256MTime:      modTime,
+			SidecarAlgorithm: algorithm,
+			FirstSeen:        now,
+			SidecarSeen:      now,
+			HasBothFiles:     false, // Data file not yet present
 		}
 	}
+
+	return true
+}
+
+// SidecarSuffixes returns the sidecar suffixes in priority order
+func (ft *FileTracker) SidecarSuffixes() []string {
+	return ft.sidecarSuffixes
+}
+
+// sidecarRank returns the priority of a sidecar file name (lower is preferred)
+func (ft *FileTracker) sidecarRank(sidecarFile string) int {
+	for rank, suffix := range ft.sidecarSuffixes {
+		if ft.hasSuffix(sidecarFile, suffix) {
+			return rank
+		}
+	}
+	return len(ft.sidecarSuffixes)
+}
+
+// hasSuffix reports whether a file name ends with a sidecar suffix, ignoring case when configured
+func (ft *FileTracker) hasSuffix(filename, suffix string) bool {
+	if ft.caseInsensitive {
+		n := len(filename) - len(suffix)
+		return n >= 0 && strings.EqualFold(filename[n:], suffix)
+	}
+	return strings.HasSuffix(filename, suffix)
+}
+
+// trimSidecarSuffix removes the sidecar suffix from a sidecar path, whatever its case
+// ("/upload/DATA.ZIP.SHA256" -> "/upload/DATA.ZIP"); other paths are returned as is
+func trimSidecarSuffix(sidecarPath, suffix string) string {
+	if n := len(sidecarPath) - len(suffix); n >= 0 && strings.EqualFold(sidecarPath[n:], suffix) {
+		return sidecarPath[:n]
+	}
+	return sidecarPath
+}
+
+// dataPath returns the data file's path, derived from the sidecar's until the data file is seen
+// Unlike the key, it keeps the case of the name on disk
+func (fp FilePair) dataPath() string {
+	if fp.DataFilePath != "" {
+		return fp.DataFilePath
+	}
+	return trimSidecarSuffix(fp.SHA256Path, "."+fp.SidecarAlgorithm)
+}
+
+// hashAlgorithm returns the algorithm a pair is verified with: the one chosen by
+// its directives, else the one implied by its sidecar's suffix, else sha256
+func (fp FilePair) hashAlgorithm() string {
+	if (fp.Directives == nil || fp.Directives.Algorithm == "") && fp.SidecarAlgorithm != "" {
+		return fp.SidecarAlgorithm
+	}
+	return fp.Directives.algorithm()
+}
+
+// SetDirectives attaches (or, with nil, clears) the parsed directives of a data file
+func (ft *FileTracker) SetDirectives(key string, directives *FileDirectives) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists {
+		pair.Directives = directives
+	}
+}
+
+// SetEmbeddedHash records the expected hash found in a data file's name
+// The pair needs no .sha256 file and becomes ready for verification immediately
+func (ft *FileTracker) SetEmbeddedHash(key string, hash string) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists {
+		pair.EmbeddedHash = hash
+		pair.HasBothFiles = true
+	}
+}
+
+// MarkExternalHash marks a data file whose expected hash comes from the ExpectedHashProvider
+// The pair needs no .sha256 file and becomes ready for verification immediately
+func (ft *FileTracker) MarkExternalHash(key string) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists {
+		pair.ExternalHash = true
+		pair.HasBothFiles = true
+	}
+}
+
+// MarkArchiveCheck marks a data file verified by its own archive checksums
+// The pair needs no .sha256 file and becomes ready for verification immediately
+func (ft *FileTracker) MarkArchiveCheck(key string) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists {
+		pair.ArchiveCheck = true
+		pair.HasBothFiles = true
+	}
+}
+
+// MarkEmbeddedChecksum marks a data file verified against a checksum member inside it
+// The pair needs no .sha256 file and becomes ready for verification immediately
+func (ft *FileTracker) MarkEmbeddedChecksum(key string) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists {
+		pair.EmbeddedChecksum = true
+		pair.HasBothFiles = true
+	}
 }
 
 // MarkBothFilesPresent updates a file pair when both files exist
 // This is called after confirming both data and .sha256 files are present
-func (ft *FileTracker) MarkBothFilesPresent(dataFile string) {
+func (ft *FileTracker) MarkBothFilesPresent(key string) {
 	ft.mutex.Lock()
 	defer ft.mutex.Unlock()
 
-	if pair, exists := ft.files[dataFile]; exists {
+	if pair, exists := ft.files[ft.keyOf(key)]; exists {
 		pair.HasBothFiles = true
 	}
 }
 
 // GetReadyForVerification returns all file pairs that are ready for verification
-// A pair is ready when BOTH files exist (data + .sha256)
+// A pair is ready when BOTH files exist (data + .sha256), it is not already
+// queued or being verified, and its next retry time (if any) has passed
 func (ft *FileTracker) GetReadyForVerification() []FilePair {
 	ft.mutex.RLock()
 	defer ft.mutex.RUnlock()
 
 	var ready []FilePair
+	now := time.Now()
 
 	for _, pair := range ft.files {
-		// Must have both files and paths must be set
-		if pair.HasBothFiles && pair.DataFilePath != "" && pair.SHA256Path != "" {
-			ready = append(ready, *pair)
+		// Must have both files and paths must be set (or the hash from elsewhere)
+		if !pair.HasBothFiles || pair.DataFilePath == "" || (pair.SHA256Path == "" && pair.EmbeddedHash == "" && !pair.ExternalHash && !pair.ArchiveCheck && !pair.EmbeddedChecksum) {
+			continue
 		}
+
+		// Skip pairs already handed to the worker pool or left in place on collision
+		if pair.InFlight || pair.Skipped {
+			continue
+		}
+
+		// Skip pairs that failed recently and are waiting for their next retry
+		if now.Before(pair.NextRetry) {
+			continue
+		}
+
+		ready = append(ready, *pair)
 	}
 
 	return ready
 }
 
+// MarkInFlight flags a file pair as submitted to the worker pool
+// so the coordinator does not submit it again while it is being verified
+func (ft *FileTracker) MarkInFlight(key string) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists {
+		pair.InFlight = true
+	}
+}
+
+// ClearInFlight releases a file pair so it can be submitted again
+// This is called when a job was not run (e.g. its destination is read-only)
+func (ft *FileTracker) ClearInFlight(key string) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists {
+		pair.InFlight = false
+	}
+}
+
+// RecordQueueFull releases a file pair whose job could not be queued and
+// returns how long it has been unable to enter the queue; warn is true the
+// first time that wait reaches warnAfter in the current blocked period
+func (ft *FileTracker) RecordQueueFull(key string, warnAfter time.Duration) (waited time.Duration, warn bool) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	pair, exists := ft.files[ft.keyOf(key)]
+	if !exists {
+		return 0, false
+	}
+
+	now := time.Now()
+	pair.InFlight = false
+	if pair.QueueBlocked.IsZero() {
+		pair.QueueBlocked = now
+	}
+	waited = now.Sub(pair.QueueBlocked)
+	if waited >= warnAfter && !pair.QueueWarned {
+		pair.QueueWarned = true
+		warn = true
+	}
+	return waited, warn
+}
+
+// MarkQueued records that a file pair's job entered the queue, ending any blocked period
+func (ft *FileTracker) MarkQueued(key string) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists {
+		pair.QueueBlocked = time.Time{}
+		pair.QueueWarned = false
+	}
+}
+
+// RecordChanged handles a data file that changed while it was being verified
+// (e.g. re-uploaded). The retry window restarts and the attempt is not counted
+// as a failure; the pair is resubmitted on a later coordinator tick
+func (ft *FileTracker) RecordChanged(key string, dataSize int64, modTime time.Time) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists {
+		now := time.Now()
+		pair.InFlight = false
+		pair.DataSize = dataSize
+		pair.DataModTime = modTime
+		pair.LastHash = ""
+		pair.FirstSeen = now
+		pair.NextRetry = now.Add(ft.changeSettleDelay)
+	}
+}
+
+// resetForReverify makes a pair left in place ready to be verified from scratch
+func resetForReverify(pair *FilePair) {
+	pair.Skipped = false
+	pair.Fingerprint = nil
+	pair.FirstSeen = time.Now()
+	pair.RetryCount = 0
+	pair.LastError = ""
+	pair.NextRetry = time.Time{}
+}
+
+// LeftInPlace returns the fingerprint of a pair left in the source folder (nil = not left in place or none recorded)
+func (ft *FileTracker) LeftInPlace(key string) *ContentFingerprint {
+	ft.mutex.RLock()
+	defer ft.mutex.RUnlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists && pair.Skipped {
+		return pair.Fingerprint
+	}
+	return nil
+}
+
+// SetFingerprint records the fingerprint of a pair left in the source folder
+func (ft *FileTracker) SetFingerprint(key string, fingerprint *ContentFingerprint) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists && pair.Skipped {
+		pair.Fingerprint = fingerprint
+	}
+}
+
+// Reverify releases a pair left in the source folder for a new verification
+func (ft *FileTracker) Reverify(key string) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists && pair.Skipped {
+		resetForReverify(pair)
+	}
+}
+
+// MarkSkipped flags a file pair that was left in the source folder because
+// its destination already exists; it will not be submitted again
+func (ft *FileTracker) MarkSkipped(key string) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists {
+		pair.InFlight = false
+		pair.Skipped = true
+	}
+}
+
+// RecordFailure records a failed verification attempt for a file pair
+// The pair is released for resubmission once nextRetry has passed
+func (ft *FileTracker) RecordFailure(key, errorMessage string, nextRetry time.Time) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists {
+		pair.InFlight = false
+		pair.RetryCount++
+		pair.LastError = errorMessage
+		pair.LastAttempt = time.Now()
+		pair.NextRetry = nextRetry
+	}
+}
+
+// RecordHash remembers the data file hash an attempt computed, for the integrity oracle
+// It is forgotten when the data file changes
+func (ft *FileTracker) RecordHash(key, algorithm, hash string, hashedAt time.Time) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	if pair, exists := ft.files[ft.keyOf(key)]; exists {
+		pair.LastHash = hash
+		pair.LastHashAlgo = algorithm
+		pair.LastHashed = hashedAt
+	}
+}
+
+// GetFailingFiles returns all file pairs whose most recent attempt failed
+func (ft *FileTracker) GetFailingFiles() []FailingFileInfo {
+	ft.mutex.RLock()
+	defer ft.mutex.RUnlock()
+
+	failing := []FailingFileInfo{}
+
+	for _, pair := range ft.files {
+		if pair.LastError == "" {
+			continue
+		}
+
+		failing = append(failing, FailingFileInfo{
+			DataFile:      pair.DataFile,
+			DataFilePath:  pair.DataFilePath,
+			SHA256Path:    pair.SHA256Path,
+			LastError:     pair.LastError,
+			RetryCount:    pair.RetryCount,
+			FirstSeen:     pair.FirstSeen,
+			LastAttempt:   pair.LastAttempt,
+			NextRetry:     pair.NextRetry,
+			RetryDeadline: pair.FirstSeen.Add(ft.retryTimeout),
+		})
+	}
+
+	// Sort by filename for stable output
+	sort.Slice(failing, func(i, j int) bool {
+		return failing[i].DataFilePath < failing[j].DataFilePath
+	})
+
+	return failing
+}
+
 // GetExpiredFiles returns file pairs that have exceeded retry timeout
 // These files should be moved to DLQ
 func (ft *FileTracker) GetExpiredFiles() []FilePair {
@@ -145,19 +575,72 @@ func (ft *FileTracker) GetExpiredFiles() []FilePair {
 	return expired
 }
 
+// GetExpiredSidecars returns sidecar-only pairs whose data file has not
+// arrived within the retry timeout
+func (ft *FileTracker) GetExpiredSidecars() []FilePair {
+	ft.mutex.RLock()
+	defer ft.mutex.RUnlock()
+
+	var expired []FilePair
+	now := time.Now()
+
+	for _, pair := range ft.files {
+		if pair.DataFilePath == "" && pair.SHA256Path != "" && now.Sub(pair.FirstSeen) >= ft.retryTimeout {
+			expired = append(expired, *pair)
+		}
+	}
+
+	return expired
+}
+
 // Remove removes a file pair from tracking
 // This is called after successful verification or after moving to DLQ
-func (ft *FileTracker) Remove(dataFile string) {
+func (ft *FileTracker) Remove(key string) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	delete(ft.files, ft.keyOf(key))
+}
+
+// DeferDeletion stops tracking a delivered pair and holds its sidecar until the deletion is due
+func (ft *FileTracker) DeferDeletion(deletion DeferredDeletion) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	delete(ft.files, deletion.Pair.Key)
+	ft.deferred[deletion.Pair.SHA256Path] = deletion
+}
+
+// TakeDueDeletions returns the deferred deletions that are due and releases their sidecars
+func (ft *FileTracker) TakeDueDeletions() []DeferredDeletion {
 	ft.mutex.Lock()
 	defer ft.mutex.Unlock()
 
-	delete(ft.files, dataFile)
+	var due []DeferredDeletion
+	now := time.Now()
+
+	for sidecarPath, deletion := range ft.deferred {
+		if !now.Before(deletion.Due) {
+			due = append(due, deletion)
+			delete(ft.deferred, sidecarPath)
+		}
+	}
+
+	return due
+}
+
+// HoldsSidecar reports whether a sidecar belongs to a delivered pair awaiting its deferred deletion
+func (ft *FileTracker) HoldsSidecar(sidecarPath string) bool {
+	ft.mutex.RLock()
+	defer ft.mutex.RUnlock()
+
+	_, held := ft.deferred[sidecarPath]
+	return held
 }
 
 // RemoveByPath removes a file pair by its data file path
 func (ft *FileTracker) RemoveByPath(dataFilePath string) {
-	dataFile := filepath.Base(dataFilePath)
-	ft.Remove(dataFile)
+	ft.Remove(filepath.Clean(dataFilePath))
 }
 
 // GetPendingCount returns the number of file pairs currently being tracked
@@ -168,12 +651,12 @@ func (ft *FileTracker) GetPendingCount() int {
 	return len(ft.files)
 }
 
-// GetFilePair returns a specific file pair by data filename
-func (ft *FileTracker) GetFilePair(dataFile string) (*FilePair, bool) {
+// GetFilePair returns a specific file pair by its tracker key
+func (ft *FileTracker) GetFilePair(key string) (*FilePair, bool) {
 	ft.mutex.RLock()
 	defer ft.mutex.RUnlock()
 
-	pair, exists := ft.files[dataFile]
+	pair, exists := ft.files[ft.keyOf(key)]
 	if !exists {
 		return nil, false
 	}
@@ -184,11 +667,11 @@ func (ft *FileTracker) GetFilePair(dataFile string) (*FilePair, bool) {
 }
 
 // IsExpired checks if a specific file pair has exceeded retry timeout
-func (ft *FileTracker) IsExpired(dataFile string) bool {
+func (ft *FileTracker) IsExpired(key string) bool {
 	ft.mutex.RLock()
 	defer ft.mutex.RUnlock()
 
-	pair, exists := ft.files[dataFile]
+	pair, exists := ft.files[ft.keyOf(key)]
 	if !exists {
 		return false
 	}
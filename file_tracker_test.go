@@ -0,0 +1,89 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSidecarPriorityWhateverTheArrivalOrder(t *testing.T) {
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "data.zip")
+	sha256Path, md5Path := dataPath+".sha256", dataPath+".md5"
+	now := time.Now()
+
+	orders := map[string][]string{
+		"preferred first": {sha256Path, md5Path},
+		"preferred last":  {md5Path, sha256Path},
+	}
+	for name, order := range orders {
+		t.Run(name, func(t *testing.T) {
+			tracker := NewFileTracker(time.Hour, 0, []string{".sha256", ".md5"}, false, false)
+			tracker.AddOrUpdateDataFile(dataPath, 4, now)
+			for _, sidecar := range order {
+				selected := tracker.AddOrUpdateSidecar(sidecar, filepath.Ext(sidecar), 32, now)
+				if want := sidecar == sha256Path || order[0] == sidecar; selected != want {
+					t.Errorf("%s selected = %v, want %v", filepath.Base(sidecar), selected, want)
+				}
+			}
+
+			pair, ok := tracker.GetFilePair(tracker.keyOf(dataPath))
+			if !ok {
+				t.Fatal("pair not tracked")
+			}
+			if pair.SHA256Path != sha256Path || pair.hashAlgorithm() != AlgorithmSHA256 {
+				t.Errorf("sidecar %s (%s), want data.zip.sha256 (sha256)", pair.SHA256File, pair.hashAlgorithm())
+			}
+		})
+	}
+}
+
+func TestLowerPrioritySidecarIsUsedAlone(t *testing.T) {
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "data.zip")
+	tracker := NewFileTracker(time.Hour, 0, []string{".sha256", ".md5"}, false, false)
+	tracker.AddOrUpdateDataFile(dataPath, 4, time.Now())
+	if !tracker.AddOrUpdateSidecar(dataPath+".md5", ".md5", 32, time.Now()) {
+		t.Fatal("only sidecar not selected")
+	}
+
+	pair, _ := tracker.GetFilePair(tracker.keyOf(dataPath))
+	if pair == nil || pair.hashAlgorithm() != AlgorithmMD5 {
+		t.Errorf("pair %+v, want it verified with md5", pair)
+	}
+}
+
+func TestReverifyOnSidecarChange(t *testing.T) {
+	dataPath := filepath.Join(t.TempDir(), "data.zip")
+	sidecarPath := dataPath + ".sha256"
+	scanned := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		reverify     bool
+		size         int64
+		modTime      time.Time
+		wantReverify bool
+	}{
+		{"unchanged sidecar", true, 64, scanned, false},
+		{"rewritten sidecar", true, 64, scanned.Add(time.Minute), true},
+		{"resized sidecar", true, 65, scanned, true},
+		{"rewritten sidecar, option off", false, 64, scanned.Add(time.Minute), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracker := NewFileTracker(time.Hour, 0, []string{".sha256"}, test.reverify, false)
+			tracker.AddOrUpdateDataFile(dataPath, 4, scanned)
+			tracker.AddOrUpdateSHA256File(sidecarPath, 64, scanned)
+			key := tracker.keyOf(dataPath)
+			tracker.MarkSkipped(key)
+
+			tracker.AddOrUpdateSHA256File(sidecarPath, test.size, test.modTime)
+
+			pair, _ := tracker.GetFilePair(key)
+			if reverified := !pair.Skipped; reverified != test.wantReverify {
+				t.Errorf("re-verified = %v, want %v", reverified, test.wantReverify)
+			}
+		})
+	}
+}
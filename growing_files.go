@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+/*
+GrowingFiles verifies append-only files that keep growing (logs, journals)
+in place, one appended range at a time (source.growing).
+
+The producer appends data to a file matching source.growing.patterns and then
+a line for the new range to its segment sidecar "<file><suffix>" (default
+".segments"):
+
+	# offset length hash
+	0       4096   9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+	4096    1024   60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752
+
+Responsibilities:
+1. Walk the source folder with the scanner's rules on its own ticker; the
+   scanner leaves growing files and their segment sidecars alone
+2. Hash only the segments after a file's verified offset, in order, once the
+   file is long enough to hold them, and advance the offset on a match
+3. Keep the verified offsets in the state file, so a restart does not hash
+   verified ranges again
+4. Log each verified segment (action "segment") and alert on a mismatch,
+   a gap in the segments or a file shorter than its verified offset; the
+   offset stays put until the segment checks out
+
+Does NOT:
+- Move, delete or retry growing files: they stay in the source folder, only
+  their verified offsets advance
+- Use the pair lifecycle (retry timeout, DLQ) of ordinary data files
+- Follow a rotated file: a file shorter than its verified offset is verified
+  again from the start
+*/
+
+// ActionSegment is logged for a segment of a growing file verified in place
+const ActionSegment = "segment"
+
+// GrowingFiles tracks the verified offset of each growing file
+type GrowingFiles struct {
+	patterns     []string
+	suffix       string // Segment sidecar suffix
+	algorithm    string
+	stateFile    string
+	bufferSize   int
+	hashEncoding string
+	scanner      *FileScanner // Source folder and walk rules
+	resultLogger ResultLogger
+	statsTracker *StatsTracker
+	logLevel     *LogLevel
+	files        map[string]*growingFile // Key: data file path; only used by Run
+	running      atomic.Bool
+}
+
+// growingFile is the persisted state of a growing file
+type growingFile struct {
+	Offset   int64  `json:"offset"`            // Bytes verified so far
+	Segments int    `json:"segments"`          // Segments verified so far
+	Problem  string `json:"problem,omitempty"` // Why the offset is stuck (reported once)
+}
+
+// growingSegment is one line of a segment sidecar
+type growingSegment struct {
+	offset int64
+	length int64
+	hash   string // Normalized hex
+	line   int
+}
+
+// NewGrowingFiles creates the growing-file verifier and loads the verified offsets
+func NewGrowingFiles(cfg GrowingConfig, scanner *FileScanner, resultLogger ResultLogger, statsTracker *StatsTracker, bufferSize int, hashEncoding string, logLevel *LogLevel) (*GrowingFiles, error) {
+	gf := &GrowingFiles{
+		patterns:     cfg.Patterns,
+		suffix:       cfg.Suffix,
+		algorithm:    cfg.Algorithm,
+		stateFile:    cfg.StateFile,
+		bufferSize:   bufferSize,
+		hashEncoding: hashEncoding,
+		scanner:      scanner,
+		resultLogger: resultLogger,
+		statsTracker: statsTracker,
+		logLevel:     logLevel,
+		files:        make(map[string]*growingFile),
+	}
+
+	data, err := os.ReadFile(cfg.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return gf, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read growing file state: %w", err)
+	}
+	if err := json.Unmarshal(data, &gf.files); err != nil {
+		return nil, fmt.Errorf("%s: invalid growing file state: %w", cfg.StateFile, err)
+	}
+	return gf, nil
+}
+
+// Owns reports whether a file name is a growing file or its segment sidecar (none without patterns)
+func (cfg GrowingConfig) Owns(filename string) bool {
+	return len(cfg.Patterns) > 0 && matchesAny(cfg.Patterns, strings.TrimSuffix(filename, cfg.Suffix))
+}
+
+// Run verifies the new segments of every growing file; a pass requested while another is running is skipped
+func (gf *GrowingFiles) Run() {
+	if !gf.running.CompareAndSwap(false, true) {
+		return
+	}
+	defer gf.running.Store(false)
+
+	changed := false
+	err := filepath.WalkDir(gf.scanner.sourceFolder, func(fullPath string, entry iofs.DirEntry, err error) error {
+		if err != nil {
+			if fullPath == gf.scanner.sourceFolder {
+				return err
+			}
+			return nil
+		}
+		if fullPath == gf.scanner.sourceFolder {
+			return nil
+		}
+		if entry.IsDir() {
+			if !gf.scanner.recursive || gf.scanner.isExcluded(fullPath) {
+				return iofs.SkipDir
+			}
+			return nil
+		}
+
+		filename := entry.Name()
+		if !matchesAny(gf.patterns, filename) || gf.scanner.isExcluded(fullPath) || gf.scanner.isInProgress(filename) {
+			return nil
+		}
+		if gf.verify(fullPath) {
+			changed = true
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[Growing] Failed to scan %s for growing files: %v\n", gf.scanner.sourceFolder, err)
+	}
+
+	if changed {
+		if err := gf.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "[Growing] Failed to save verified offsets: %v\n", err)
+		}
+	}
+}
+
+// verify checks the segments of a growing file past its verified offset
+// Returns true if its state changed
+func (gf *GrowingFiles) verify(dataPath string) bool {
+	info, err := os.Stat(dataPath)
+	if err != nil {
+		return false
+	}
+
+	state, known := gf.files[dataPath]
+	if !known {
+		state = &growingFile{}
+		gf.files[dataPath] = state
+	}
+	changed := !known
+
+	// A rotated or truncated file starts over
+	if info.Size() < state.Offset {
+		fmt.Fprintf(os.Stderr, "[Growing] CRITICAL: %s is %d bytes, shorter than its verified offset %d; verifying it again from the start\n",
+			dataPath, info.Size(), state.Offset)
+		*state = growingFile{}
+		changed = true
+	}
+
+	segments, err := readSegments(dataPath+gf.suffix, gf.hashEncoding, gf.algorithm)
+	if errors.Is(err, os.ErrNotExist) {
+		return changed
+	}
+	if err != nil {
+		return gf.report(dataPath, state, err.Error()) || changed
+	}
+
+	for _, segment := range segments {
+		end := segment.offset + segment.length
+		if end <= state.Offset {
+			continue
+		}
+		if segment.offset != state.Offset {
+			return gf.report(dataPath, state, fmt.Sprintf("segment on line %d starts at %d, expected %d (segments must be contiguous)",
+				segment.line, segment.offset, state.Offset)) || changed
+		}
+		// Not fully written yet
+		if end > info.Size() {
+			break
+		}
+
+		startTime := clockNow()
+		computed, err := hashFileRange(dataPath, segment.offset, segment.length, gf.bufferSize, gf.algorithm)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[Growing] Failed to hash %s at %d: %v\n", dataPath, segment.offset, err)
+			break
+		}
+		if computed != segment.hash {
+			return gf.report(dataPath, state, fmt.Sprintf("segment %d-%d (line %d) does not match: expected %s, computed %s",
+				segment.offset, end, segment.line, segment.hash, computed)) || changed
+		}
+
+		if state.Problem != "" {
+			fmt.Printf("[Growing] %s verifies again from offset %d\n", dataPath, state.Offset)
+		}
+		*state = growingFile{Offset: end, Segments: state.Segments + 1}
+		changed = true
+		gf.logSegment(dataPath, segment, computed, clockNow().Sub(startTime))
+	}
+	return changed
+}
+
+// report alerts once on why a growing file's offset cannot advance
+// Returns true if the problem is new
+func (gf *GrowingFiles) report(dataPath string, state *growingFile, problem string) bool {
+	if state.Problem == problem {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "[Growing] CRITICAL: %s stuck at verified offset %d: %s\n", dataPath, state.Offset, problem)
+	gf.statsTracker.IncrementFailure(0, 0)
+	state.Problem = problem
+	return true
+}
+
+// logSegment records a verified segment like a verified file
+func (gf *GrowingFiles) logSegment(dataPath string, segment growingSegment, hash string, duration time.Duration) {
+	gf.statsTracker.IncrementSuccess(duration, segment.length)
+	if gf.logLevel.Get() == "DEBUG" || gf.logLevel.Get() == "INFO" {
+		fmt.Printf("[Growing] ✓ SEGMENT: %s %d-%d (%.2f KB)\n",
+			filepath.Base(dataPath), segment.offset, segment.offset+segment.length, float64(segment.length)/1024.0)
+	}
+
+	result := VerificationResult{
+		Job:          VerificationJob{FilePair: FilePair{DataFile: filepath.Base(dataPath), DataFilePath: dataPath, DataSize: segment.length}},
+		Success:      true,
+		ComputedHash: hash,
+		HoleBytes:    -1,
+		Duration:     duration,
+		Timestamp:    clockNow(),
+	}
+	entry := CreateCSVLogEntry(result, ActionSegment, dataPath, gf.scanner.sourceFolder)
+	if err := gf.resultLogger.LogVerification(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "[Growing] Failed to log verification: %v\n", err)
+	}
+}
+
+// save writes the verified offsets atomically, forgetting files that are gone
+func (gf *GrowingFiles) save() error {
+	for dataPath := range gf.files {
+		if !FileExists(dataPath) {
+			delete(gf.files, dataPath)
+		}
+	}
+	data, err := json.MarshalIndent(gf.files, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := gf.stateFile + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, gf.stateFile); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// readSegments parses a segment sidecar: "<offset> <length> <hash>" per line, # comments
+// A last line without its newline may still be being written and is left for the next pass
+func readSegments(path, encoding, algo string) ([]growingSegment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+
+	var segments []growingSegment
+	for i, text := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(text)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		lineNumber := i + 1
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s line %d: expected \"<offset> <length> <hash>\"", filepath.Base(path), lineNumber)
+		}
+		offset, errOffset := strconv.ParseInt(fields[0], 10, 64)
+		length, errLength := strconv.ParseInt(fields[1], 10, 64)
+		if errOffset != nil || errLength != nil || offset < 0 || length <= 0 {
+			return nil, fmt.Errorf("%s line %d: invalid offset or length", filepath.Base(path), lineNumber)
+		}
+		hash, err := parseDigest(fields[2], encoding, algo)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", filepath.Base(path), lineNumber, err)
+		}
+		segments = append(segments, growingSegment{offset: offset, length: length, hash: hash, line: lineNumber})
+	}
+	return segments, nil
+}
+
+// hashFileRange hashes length bytes of a file starting at offset
+func hashFileRange(path string, offset, length int64, bufferSize int, algo string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return hashReader(io.NewSectionReader(file, offset, length), bufferSize, algo)
+}
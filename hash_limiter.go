@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sync/atomic"
+)
+
+/*
+HashLimiter bounds how many files are hashed at the same time.
+
+Workers hold a slot only while hashing, so the number of workers (how many
+jobs are in flight) and the CPU spent hashing can be sized separately, e.g.
+20 workers hashing on at most 4 cores.
+
+Responsibilities:
+1. Hand out at most concurrency.hashingSlots slots (0 = unlimited)
+2. Count the slots in use and the workers waiting for one (for /metrics)
+
+Does NOT:
+- Pin goroutines to CPUs or change GOMAXPROCS
+*/
+
+// HashLimiter is a counting semaphore around the hashing hot path
+type HashLimiter struct {
+	slots   chan struct{} // nil = unlimited
+	inUse   atomic.Int64
+	waiting atomic.Int64
+}
+
+// NewHashLimiter creates a limiter with the given number of slots (0 = unlimited)
+func NewHashLimiter(slots int) *HashLimiter {
+	limiter := &HashLimiter{}
+	if slots > 0 {
+		limiter.slots = make(chan struct{}, slots)
+	}
+	return limiter
+}
+
+// Acquire blocks until a hashing slot is free
+func (l *HashLimiter) Acquire() {
+	if l.slots != nil {
+		l.waiting.Add(1)
+		l.slots <- struct{}{}
+		l.waiting.Add(-1)
+	}
+	l.inUse.Add(1)
+}
+
+// Release frees a slot taken by Acquire
+func (l *HashLimiter) Release() {
+	l.inUse.Add(-1)
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// Capacity returns the number of slots (0 = unlimited)
+func (l *HashLimiter) Capacity() int {
+	return cap(l.slots)
+}
+
+// InUse returns the number of workers hashing right now
+func (l *HashLimiter) InUse() int64 {
+	return l.inUse.Load()
+}
+
+// Waiting returns the number of workers waiting for a slot
+func (l *HashLimiter) Waiting() int64 {
+	return l.waiting.Load()
+}
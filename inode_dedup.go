@@ -0,0 +1,173 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+InodeDedup hashes the content of hard-linked data files once
+(verification.inodeDedup), for sources that fan files out as hard links.
+
+Data files sharing a device and inode are the same content. The first link
+to be verified hashes it; every other link reuses that hash, waiting for it
+when the first is still being hashed, and is checked against its own
+sidecar. A link that reused a hash records the link it came from (CSV
+column Linked_To).
+
+Responsibilities:
+1. Recognize links of the same inode, and that the content has not changed
+   since it was hashed (size and modification time)
+2. Let exactly one worker hash an inode at a time; the others wait for it
+3. Forget an inode once every link has reused its hash, or once its hash is
+   older than the retry timeout (links moved away or deleted never claim it)
+
+Does NOT:
+- Apply to anyMatch sidecars or pre-hash transforms (like known hashes)
+- Work where the platform reports no inodes (every link is hashed on its own)
+- Share hashes between different algorithms
+*/
+
+// inodeKey identifies a file independently of its links
+type inodeKey struct {
+	device uint64
+	inode  uint64
+}
+
+// inodeHash is the hash of an inode, or the promise of one while a link is hashed
+type inodeHash struct {
+	done      chan struct{} // Closed once the hashing link has finished
+	path      string        // Link that was hashed
+	algorithm string
+	hash      string // Empty when hashing failed
+	size      int64
+	modTime   time.Time
+	remaining uint64    // Links still expected to reuse the hash
+	hashedAt  time.Time // When the hashing link finished
+}
+
+// InodeDedup tracks the hashes of hard-linked data files
+type InodeDedup struct {
+	mutex     sync.Mutex
+	hashes    map[inodeKey]*inodeHash
+	ttl       time.Duration // How long a hash waits for the remaining links
+	lastSweep time.Time
+}
+
+// NewInodeDedup creates an empty inode hash registry whose hashes are forgotten
+// ttl after they were computed (the retry timeout: a link showing up later is
+// hashed again)
+func NewInodeDedup(ttl time.Duration) *InodeDedup {
+	return &InodeDedup{hashes: make(map[inodeKey]*inodeHash), ttl: ttl}
+}
+
+// Claim returns the hash another link of path's inode computed with algo, and that link
+// Without one, the caller hashes the file and passes the hash (empty = failed) to
+// release; release may be called more than once, the first call counts
+// progress is called while waiting for another link, an error from it ends the wait
+func (d *InodeDedup) Claim(path, algo string, progress ProgressFunc) (hash, linkedTo string, release func(string), err error) {
+	noop := func(string) {}
+	for {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", "", noop, nil
+		}
+		key, links, ok := fileInode(info)
+		if !ok || links < 2 {
+			return "", "", noop, nil
+		}
+
+		d.mutex.Lock()
+		d.sweep()
+		entry, exists := d.hashes[key]
+		if exists {
+			select {
+			case <-entry.done:
+			default:
+				// Another link is being hashed, wait for its result
+				d.mutex.Unlock()
+				if err := d.wait(entry, progress); err != nil {
+					return "", "", noop, err
+				}
+				continue
+			}
+			// The link that was hashed is hashed again when it is retried
+			if entry.path != path && entry.hash != "" && entry.algorithm == algo &&
+				entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+				entry.remaining--
+				if entry.remaining == 0 {
+					delete(d.hashes, key)
+				}
+				d.mutex.Unlock()
+				return entry.hash, entry.path, noop, nil
+			}
+		}
+
+		// This link hashes the inode for the others
+		entry = &inodeHash{
+			done:      make(chan struct{}),
+			path:      path,
+			algorithm: algo,
+			size:      info.Size(),
+			modTime:   info.ModTime(),
+			remaining: links - 1,
+		}
+		d.hashes[key] = entry
+		d.mutex.Unlock()
+
+		var once sync.Once
+		return "", "", func(hash string) {
+			once.Do(func() { d.finish(key, entry, hash) })
+		}, nil
+	}
+}
+
+// sweep forgets the hashes older than the TTL, at most once per TTL since a
+// hash is only reused within it anyway; d.mutex must be held
+func (d *InodeDedup) sweep() {
+	now := clockNow()
+	if now.Sub(d.lastSweep) < d.ttl {
+		return
+	}
+	d.lastSweep = now
+	for key, entry := range d.hashes {
+		if !entry.hashedAt.IsZero() && now.Sub(entry.hashedAt) >= d.ttl {
+			delete(d.hashes, key)
+		}
+	}
+}
+
+// wait blocks until a link being hashed has finished, reporting progress so the
+// stall watchdog does not mistake the wait for a hung read
+func (d *InodeDedup) wait(entry *inodeHash, progress ProgressFunc) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-entry.done:
+			return nil
+		case <-ticker.C:
+			if progress != nil {
+				if err := progress(0); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// finish publishes the hash of an inode to the links waiting for it
+func (d *InodeDedup) finish(key inodeKey, entry *inodeHash, hash string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	entry.hash = hash
+	entry.hashedAt = clockNow()
+	// A failed hash is retried by whichever link comes next
+	if hash == "" && d.hashes[key] == entry {
+		delete(d.hashes, key)
+	}
+	close(entry.done)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInodeHashExpires(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	saved := clockNow
+	t.Cleanup(func() { clockNow = saved })
+	clockNow = func() time.Time { return now }
+
+	dir := t.TempDir()
+	first := writeTestFile(t, dir, "a.zip", "data")
+	for _, name := range []string{"b.zip", "c.zip"} {
+		if err := os.Link(first, filepath.Join(dir, name)); err != nil {
+			t.Skipf("hard links unsupported: %v", err)
+		}
+	}
+
+	dedup := NewInodeDedup(time.Hour)
+	hash, _, release, err := dedup.Claim(first, AlgorithmSHA256, nil)
+	if err != nil || hash != "" {
+		t.Fatalf("first link: hash %q, err %v, want to hash it", hash, err)
+	}
+	release(dataSHA256)
+
+	// Within the TTL the next link reuses the hash
+	hash, linkedTo, _, _ := dedup.Claim(filepath.Join(dir, "b.zip"), AlgorithmSHA256, nil)
+	if hash != dataSHA256 || linkedTo != first {
+		t.Fatalf("second link: hash %q linked to %q, want the hash of %s", hash, linkedTo, first)
+	}
+
+	// The last link never came in time: the inode is forgotten
+	now = now.Add(time.Hour)
+	hash, _, release, _ = dedup.Claim(filepath.Join(dir, "c.zip"), AlgorithmSHA256, nil)
+	release("")
+	if hash != "" {
+		t.Errorf("third link reused an expired hash")
+	}
+	if len(dedup.hashes) != 0 {
+		t.Errorf("%d inodes still tracked, want none", len(dedup.hashes))
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns the device and inode of a file and its number of hard links
+// ok is false where the file system reports no inode
+func fileInode(info os.FileInfo) (key inodeKey, links uint64, ok bool) {
+	stat, isStat := info.Sys().(*syscall.Stat_t)
+	if !isStat {
+		return inodeKey{}, 0, false
+	}
+	return inodeKey{device: uint64(stat.Dev), inode: stat.Ino}, uint64(stat.Nlink), true
+}
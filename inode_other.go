@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"os"
+)
+
+// fileInode reports no inode where it can't be read; every link is hashed on its own
+func fileInode(info os.FileInfo) (key inodeKey, links uint64, ok bool) {
+	return inodeKey{}, 0, false
+}
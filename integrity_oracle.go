@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+IntegrityOracle lets a file system that keeps its own block checksums (ZFS,
+Btrfs) vouch for a data file, so content already hashed once is not read
+again (verification.integrityOracle).
+
+A data file is hashed again when its verification is retried, e.g. after a
+mismatch while a corrected sidecar is on its way. Before hashing, the worker
+asks the oracle since when the file is known clean: unchanged and with every
+block matching its file system checksum (the last clean scrub). When that is
+no later than the attempt that hashed the file, the earlier hash is reused
+and the attempt is logged and counted as short-circuited.
+
+Responsibilities:
+1. Define the provider interface backends implement
+2. Provide the default backend ("none"), which knows nothing and so always
+   forces a hash
+3. Select the configured backend by name
+
+Does NOT:
+- Ship a ZFS or Btrfs backend yet (they register in integrityOracles)
+- Skip the first hash of a file: the oracle only vouches that content
+  already hashed is still intact
+- Apply to anyMatch sidecars or pre-hash transforms (like known hashes)
+*/
+
+// IntegrityOracleNone is the default backend, it never vouches for a file
+const IntegrityOracleNone = "none"
+
+// IntegrityOracle reports what the file system knows about a file's integrity
+type IntegrityOracle interface {
+	// CleanSince returns the time since which the file is known unchanged and intact (zero = unknown)
+	CleanSince(path string) (time.Time, error)
+}
+
+// integrityOracles maps verification.integrityOracle names to their backends
+var integrityOracles = map[string]func() IntegrityOracle{
+	IntegrityOracleNone: func() IntegrityOracle { return unknownIntegrity{} },
+}
+
+// NewIntegrityOracle returns the backend configured by name
+func NewIntegrityOracle(name string) (IntegrityOracle, error) {
+	newOracle, ok := integrityOracles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown integrity oracle %q (supported: %s)", name, integrityOracleNames())
+	}
+	return newOracle(), nil
+}
+
+// integrityOracleNames lists the registered backends for messages
+func integrityOracleNames() string {
+	names := make([]string, 0, len(integrityOracles))
+	for name := range integrityOracles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// unknownIntegrity is the default oracle: nothing is known, every file is hashed
+type unknownIntegrity struct{}
+
+// CleanSince always reports unknown
+func (unknownIntegrity) CleanSince(path string) (time.Time, error) {
+	return time.Time{}, nil
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+IntentLog is a write-ahead log of deliveries (destination.intentLog), so a
+crash in the middle of one is finished or undone at the next start instead
+of leaving a delivered file with its sidecar still in the source, or without
+its CSV row.
+
+A delivery is a move to the verified folder, the deletion of the sidecar
+and its companions, the result marker, the statistics and the CSV row. Each
+delivery appends fsynced records, one JSON object per line:
+
+	begin      before the move: source, sidecar, destination, hash and the
+	           CSV row and marker to write
+	delivered  once the data file is in the verified folder, with its path
+	done       once the CSV row is logged
+	abort      when the move failed (retried, skipped or sent to the DLQ)
+
+Responsibilities:
+1. At startup, before the scanner starts, replay every delivery begun but
+   not done or aborted:
+   - delivered (recorded, or the source data file is gone and the planned
+     name holds the verified hash): finish it; delete the sidecar and its
+     companions, write the marker, count it and log its CSV row
+   - source data file still there: roll it back; remove a partial copy and
+     leave the pair for the scanner to verify again
+   - neither: report it, nothing is known to be safe to do
+2. Start the log over once every delivery in it is settled
+
+Does NOT:
+- Find a delivery renamed by onCollision "rename" that crashed before it was
+  recorded as delivered (only the planned name is probed)
+- Probe deliveries of transformed data files, whose delivered content does
+  not hash to the verified hash; they are finished only when recorded
+- Cover failures (DLQ moves), tar stream members or deferred sidecar
+  deletions (a sidecar kept by sidecarDeleteDelay is left for the orphan
+  janitor)
+- Avoid a second CSV row when the crash falls between logging the row and
+  recording done, or a second delivery (per onCollision) when it falls
+  between a cross-device copy getting its name and the source's deletion
+*/
+
+// intentLogCompactSize is how large the log may grow before it starts over once no delivery is open
+const intentLogCompactSize = 1 << 20 // 1 MiB
+
+// Intent log record types
+const (
+	intentBegin     = "begin"
+	intentDelivered = "delivered"
+	intentDone      = "done"
+	intentAbort     = "abort"
+)
+
+// intentFile is a source file as the scanner observed it
+type intentFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// intentRecord is one line of the intent log
+type intentRecord struct {
+	Op string `json:"op"`
+	ID uint64 `json:"id"`
+
+	// begin
+	Source      *intentFile    `json:"source,omitempty"`
+	Sidecar     *intentFile    `json:"sidecar,omitempty"`
+	Directives  *intentFile    `json:"directives,omitempty"`
+	Transforms  []string       `json:"transforms,omitempty"`
+	KeepSidecar bool           `json:"keepSidecar,omitempty"` // Deletion deferred by sidecarDeleteDelay
+	Planned     string         `json:"planned,omitempty"`     // Destination path unless onCollision renames it
+	Check       *DeliveryCheck `json:"check,omitempty"`       // Hash the delivery must have (nil = cannot be probed)
+	Compressed  bool           `json:"compressed,omitempty"`
+	Entry       *CSVLogEntry   `json:"entry,omitempty"`
+	Marker      *ResultFile    `json:"marker,omitempty"`
+
+	// delivered
+	Dest string `json:"dest,omitempty"`
+}
+
+// IntentLog appends delivery records to the intent log file
+type IntentLog struct {
+	mutex  sync.Mutex
+	file   *os.File
+	nextID uint64
+	open   int // Deliveries begun but not yet done or aborted
+}
+
+// NewIntentLog opens the intent log, which must have been replayed with RecoverIntentLog first
+func NewIntentLog(path string) (*IntentLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create intent log folder: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open intent log: %w", err)
+	}
+	return &IntentLog{file: file, nextID: 1}, nil
+}
+
+// Begin records a delivery about to move result's data file to planned
+// Returns the delivery's ID (0 = not recorded, or a nil log)
+func (il *IntentLog) Begin(result VerificationResult, planned string, compressed, keepSidecar bool, entry CSVLogEntry, marker *ResultFile) uint64 {
+	if il == nil {
+		return 0
+	}
+
+	pair := result.Job.FilePair
+	record := intentRecord{
+		Op:          intentBegin,
+		Source:      &intentFile{Path: pair.DataFilePath, Size: pair.DataSize, ModTime: pair.DataModTime},
+		Transforms:  result.Job.Transforms,
+		KeepSidecar: keepSidecar,
+		Planned:     planned,
+		Compressed:  compressed,
+		Entry:       &entry,
+		Marker:      marker,
+	}
+	if pair.SHA256Path != "" {
+		record.Sidecar = &intentFile{Path: pair.SHA256Path, Size: pair.SHA256Size, ModTime: pair.SHA256MTime}
+	}
+	if pair.Directives != nil {
+		record.Directives = &intentFile{Path: pair.Directives.Path, Size: pair.Directives.Size, ModTime: pair.Directives.ModTime}
+	}
+	// A transformed data file does not hash to the verified hash, it can't be probed
+	if len(result.Job.Transforms) == 0 {
+		record.Check = &DeliveryCheck{Algorithm: pair.hashAlgorithm(), Hash: result.ComputedHash, BufferSize: result.Job.BufferSize}
+		if compressed {
+			record.Check.Transforms = []string{"gunzip"}
+		}
+	}
+
+	il.mutex.Lock()
+	defer il.mutex.Unlock()
+
+	record.ID = il.nextID
+	if err := il.append(record); err != nil {
+		fmt.Fprintf(os.Stderr, "[IntentLog] Failed to record delivery of %s, it is not crash-safe: %v\n", pair.DataFile, err)
+		return 0
+	}
+	il.nextID++
+	il.open++
+	return record.ID
+}
+
+// Delivered records that a delivery's data file is now at dest
+func (il *IntentLog) Delivered(id uint64, dest string) {
+	il.settle(intentRecord{Op: intentDelivered, ID: id, Dest: dest}, false)
+}
+
+// Done records that a delivery is complete
+func (il *IntentLog) Done(id uint64) {
+	il.settle(intentRecord{Op: intentDone, ID: id}, true)
+}
+
+// Abort records that a delivery's move did not happen
+func (il *IntentLog) Abort(id uint64) {
+	il.settle(intentRecord{Op: intentAbort, ID: id}, true)
+}
+
+// settle appends a record of a begun delivery, closing it when final
+// Safe to call on a nil log or with ID 0 (does nothing)
+func (il *IntentLog) settle(record intentRecord, final bool) {
+	if il == nil || record.ID == 0 {
+		return
+	}
+
+	il.mutex.Lock()
+	defer il.mutex.Unlock()
+
+	if err := il.append(record); err != nil {
+		fmt.Fprintf(os.Stderr, "[IntentLog] Failed to record %s of delivery %d: %v\n", record.Op, record.ID, err)
+	}
+	if final {
+		il.open--
+		il.compact()
+	}
+}
+
+// append writes and syncs one record; the caller holds the mutex
+func (il *IntentLog) append(record intentRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := il.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return il.file.Sync()
+}
+
+// compact starts the log over when it has grown and no delivery is open; the caller holds the mutex
+func (il *IntentLog) compact() {
+	if il.open > 0 {
+		return
+	}
+	info, err := il.file.Stat()
+	if err != nil || info.Size() < intentLogCompactSize {
+		return
+	}
+	if err := il.file.Truncate(0); err != nil {
+		fmt.Fprintf(os.Stderr, "[IntentLog] Failed to compact the intent log: %v\n", err)
+	}
+}
+
+// Close closes the log file
+func (il *IntentLog) Close() error {
+	if il == nil {
+		return nil
+	}
+	return il.file.Close()
+}
+
+// RecoverIntentLog finishes or rolls back the deliveries an earlier run left open in the
+// intent log at path, then starts the log over (features name the companions a finished delivery removes)
+func RecoverIntentLog(path, sourceFolder string, features Features, resultLogger ResultLogger, statsTracker *StatsTracker) error {
+	open, err := readOpenIntents(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read intent log: %w", err)
+	}
+
+	finished, rolledBack, unresolved := 0, 0, 0
+	for _, intent := range open {
+		switch recoverIntent(intent, sourceFolder, features, resultLogger, statsTracker) {
+		case intentDone:
+			finished++
+		case intentAbort:
+			rolledBack++
+		default:
+			unresolved++
+		}
+	}
+	if len(open) > 0 {
+		fmt.Printf("[IntentLog] Recovered %d interrupted deliveries: %d finished, %d rolled back, %d unresolved\n",
+			len(open), finished, rolledBack, unresolved)
+	}
+
+	// Every delivery in the log is settled now
+	if err := os.Truncate(path, 0); err != nil {
+		return fmt.Errorf("failed to reset intent log: %w", err)
+	}
+	return nil
+}
+
+// readOpenIntents returns the begin records of deliveries neither done nor aborted,
+// with the destination of those recorded as delivered, in log order
+func readOpenIntents(path string) ([]*intentRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var order []uint64
+	begun := make(map[uint64]*intentRecord)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var record intentRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A torn last line is a record whose fsync never returned
+			fmt.Fprintf(os.Stderr, "[IntentLog] Ignoring unreadable line %d: %v\n", lineNumber, err)
+			continue
+		}
+		switch record.Op {
+		case intentBegin:
+			if record.Source == nil || record.Entry == nil {
+				continue
+			}
+			begun[record.ID] = &record
+			order = append(order, record.ID)
+		case intentDelivered:
+			if intent := begun[record.ID]; intent != nil {
+				intent.Dest = record.Dest
+			}
+		case intentDone, intentAbort:
+			delete(begun, record.ID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var open []*intentRecord
+	for _, id := range order {
+		if intent := begun[id]; intent != nil {
+			open = append(open, intent)
+		}
+	}
+	return open, nil
+}
+
+// recoverIntent finishes or rolls back one open delivery
+// Returns intentDone when finished, intentAbort when rolled back and "" when unresolved
+func recoverIntent(intent *intentRecord, sourceFolder string, features Features, resultLogger ResultLogger, statsTracker *StatsTracker) string {
+	source := intent.Source
+	sourceExists := FileExists(source.Path)
+
+	dest := intent.Dest
+	if dest == "" && !sourceExists && intent.Check != nil && FileExists(intent.Planned) && intent.Check.confirm(intent.Planned) == nil {
+		// Moved but not yet recorded as delivered
+		dest = intent.Planned
+	}
+
+	if dest == "" {
+		if !sourceExists {
+			fmt.Fprintf(os.Stderr, "[IntentLog] CRITICAL: Delivery of %s was interrupted and neither the source nor %s holds it, check the verified folder\n",
+				source.Path, intent.Planned)
+			return ""
+		}
+		// Not delivered: drop a partial copy, the scanner verifies the pair again
+		removeTempFiles(intent.Planned)
+		fmt.Printf("[IntentLog] Rolled back delivery of %s, it will be verified again\n", source.Path)
+		return intentAbort
+	}
+
+	pair := FilePair{DataFile: filepath.Base(source.Path), DataFilePath: source.Path}
+	if !intent.KeepSidecar {
+		if sidecar := intent.Sidecar; sidecar != nil {
+			pair.SHA256Path, pair.SHA256File = sidecar.Path, filepath.Base(sidecar.Path)
+			pair.SHA256Size, pair.SHA256MTime = sidecar.Size, sidecar.ModTime
+		}
+		if directives := intent.Directives; directives != nil {
+			pair.Directives = &FileDirectives{Path: directives.Path, Size: directives.Size, ModTime: directives.ModTime}
+		}
+		features.deleteSourceCompanions(pair, intent.Transforms, sourceFolder, "[IntentLog]")
+	}
+
+	if intent.Marker != nil {
+		intent.Marker.Path = dest
+		if err := intent.Marker.write(); err != nil {
+			fmt.Fprintf(os.Stderr, "[IntentLog] Failed to write result file: %v\n", err)
+		}
+	}
+
+	entry := *intent.Entry
+	entry.DestinationPath = escapeControlChars(dest)
+	if intent.Compressed {
+		if size, err := GetFileSize(dest); err == nil {
+			entry.CompressedBytes = size
+		}
+	}
+	statsTracker.IncrementSuccess(time.Duration(entry.Duration*float64(time.Second)), entry.SizeBytes)
+	if err := resultLogger.LogVerification(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "[IntentLog] Failed to log verification: %v\n", err)
+	}
+
+	fmt.Printf("[IntentLog] Finished delivery of %s to %s\n", source.Path, dest)
+	return intentDone
+}
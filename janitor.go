@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Janitor purges old files from the verified folder or the DLQ (one janitor
+per folder).
+
+Responsibilities:
+1. Delete files older than the retention age
+2. Delete oldest files first while the folder exceeds the size cap
+3. Report how many files and bytes were reclaimed
+4. For the DLQ: record its size and warn once when it grows past the alert
+   threshold (many failures at once point at a systemic upstream problem),
+   before the volume fills
+
+Age is measured from when a file arrived in the verified folder (its
+status-change time where the platform provides it), not from its original
+modification time, which a rename preserves. The arrival time is read the
+first time a pass sees a file and remembered, so a later chmod, chown or new
+hard link, which also changes the status-change time, does not make the file
+young again. After a restart it is read again.
+
+A subfolder that cannot be read is reported and skipped; the rest of the
+folder is still purged.
+
+Files that arrived within the grace period, and temporary ".tmp" files of
+cross-filesystem moves, are never touched, so a file that is still being
+delivered cannot be deleted underneath a worker.
+
+Does NOT:
+- Touch the source folder
+- Decide when to run (the coordinator triggers it on a slow ticker)
+*/
+
+// janitorGracePeriod protects files that arrived recently from purging
+const janitorGracePeriod = 5 * time.Minute
+
+// Janitor enforces retention on the verified folder or the DLQ
+type Janitor struct {
+	destinations *Destinations             // Purges the folder current at each pass
+	dlq          bool                      // Purges the DLQ instead of the verified folder
+	maxAge       time.Duration             // 0 = no age limit
+	maxSize      int64                     // Bytes, 0 = no size cap
+	alertSize    int64                     // DLQ bytes that trigger an alert, 0 = none
+	alertFiles   int64                     // DLQ files that trigger an alert, 0 = none
+	alerting     bool                      // The DLQ was above its alert threshold at the last pass
+	arrivals     map[string]janitorArrival // Arrival of each file seen by the last pass (only Run's pass uses it)
+	statsTracker *StatsTracker
+	logLevel     *LogLevel
+	running      atomic.Bool
+}
+
+// janitorPass is the outcome of one purge pass
+type janitorPass struct {
+	purgedFiles int
+	purgedBytes int64
+	files       int64 // Files left in the folder
+	bytes       int64 // Bytes left in the folder
+}
+
+// janitorArrival is when a file was first seen to have arrived, and which file it was
+type janitorArrival struct {
+	inode   inodeKey // Zero where the platform reports no inodes
+	arrived time.Time
+}
+
+// janitorFile is a candidate for purging
+type janitorFile struct {
+	path    string
+	size    int64
+	arrived time.Time
+}
+
+// NewVerifiedJanitor creates a janitor for the verified folder
+func NewVerifiedJanitor(destinations *Destinations, maxAge time.Duration, maxSize int64, statsTracker *StatsTracker, logLevel *LogLevel) *Janitor {
+	return &Janitor{
+		destinations: destinations,
+		maxAge:       maxAge,
+		maxSize:      maxSize,
+		statsTracker: statsTracker,
+		logLevel:     logLevel,
+	}
+}
+
+// NewDLQJanitor creates a janitor for the DLQ
+func NewDLQJanitor(destinations *Destinations, cfg DLQRetentionConfig, statsTracker *StatsTracker, logLevel *LogLevel) *Janitor {
+	return &Janitor{
+		destinations: destinations,
+		dlq:          true,
+		maxAge:       cfg.MaxAge,
+		maxSize:      cfg.MaxSizeBytes,
+		alertSize:    cfg.AlertSizeBytes,
+		alertFiles:   cfg.AlertFiles,
+		statsTracker: statsTracker,
+		logLevel:     logLevel,
+	}
+}
+
+// Run performs one purge pass; a pass requested while another is running is skipped
+func (j *Janitor) Run() {
+	if !j.running.CompareAndSwap(false, true) {
+		return
+	}
+	defer j.running.Store(false)
+
+	folder, name := j.destinations.Get().Verified, "verified folder"
+	if j.dlq {
+		folder, name = j.destinations.Get().DLQ, "DLQ"
+	}
+	pass, err := j.purge(folder, clockNow())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[Janitor] Failed to scan %s: %v\n", folder, err)
+		return
+	}
+	if pass.purgedFiles > 0 {
+		if j.dlq {
+			j.statsTracker.RecordDLQPurge(int64(pass.purgedFiles), pass.purgedBytes)
+		} else {
+			j.statsTracker.RecordPurge(int64(pass.purgedFiles), pass.purgedBytes)
+		}
+	}
+
+	if j.logLevel.Get() == "DEBUG" || (pass.purgedFiles > 0 && j.logLevel.Get() == "INFO") {
+		fmt.Printf("[Janitor] Purged %d files (%.2f MB) from %s\n", pass.purgedFiles, float64(pass.purgedBytes)/1024/1024, name)
+	}
+
+	if j.dlq {
+		j.statsTracker.SetDLQUsage(pass.files, pass.bytes)
+		j.checkAlert(pass)
+	}
+}
+
+// checkAlert warns when the DLQ grows past its alert threshold (once per crossing)
+func (j *Janitor) checkAlert(pass janitorPass) {
+	over := (j.alertSize > 0 && pass.bytes > j.alertSize) || (j.alertFiles > 0 && pass.files > j.alertFiles)
+	if over && !j.alerting {
+		fmt.Fprintf(os.Stderr, "[Janitor] CRITICAL: DLQ holds %d files (%.2f MB), above its alert threshold; many files are failing, check the senders\n",
+			pass.files, float64(pass.bytes)/1024/1024)
+	} else if !over && j.alerting && (j.logLevel.Get() == "DEBUG" || j.logLevel.Get() == "INFO") {
+		fmt.Printf("[Janitor] DLQ back below its alert threshold: %d files (%.2f MB)\n",
+			pass.files, float64(pass.bytes)/1024/1024)
+	}
+	j.alerting = over
+}
+
+// arrivalTime returns when a file arrived: as remembered from an earlier pass,
+// unless another file has taken its name since, else its status-change time
+func (j *Janitor) arrivalTime(path string, info os.FileInfo) janitorArrival {
+	inode, _, _ := fileInode(info)
+	if known, ok := j.arrivals[path]; ok && known.inode == inode {
+		return known
+	}
+	return janitorArrival{inode: inode, arrived: fileArrivalTime(info)}
+}
+
+// purge deletes expired files, then the oldest files while the folder is over its cap
+func (j *Janitor) purge(folder string, now time.Time) (janitorPass, error) {
+	var candidates []janitorFile
+	var pass janitorPass
+	arrivals := make(map[string]janitorArrival)
+
+	err := filepath.WalkDir(folder, func(path string, entry iofs.DirEntry, err error) error {
+		if err != nil {
+			// The folder itself must be readable, a subfolder that is not is skipped
+			if path == folder {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "[Janitor] Skipping %s: %v\n", path, err)
+			if entry != nil && entry.IsDir() {
+				return iofs.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Vanished since the directory was read
+			return nil
+		}
+
+		pass.files++
+		pass.bytes += info.Size()
+		arrival := j.arrivalTime(path, info)
+		arrivals[path] = arrival
+		arrived := arrival.arrived
+		if strings.HasSuffix(path, ".tmp") || now.Sub(arrived) < janitorGracePeriod {
+			return nil
+		}
+		candidates = append(candidates, janitorFile{path: path, size: info.Size(), arrived: arrived})
+		return nil
+	})
+	if err != nil {
+		return pass, err
+	}
+	// Files no longer in the folder are forgotten
+	j.arrivals = arrivals
+
+	// Oldest first, so the size cap removes the oldest deliveries
+	sort.Slice(candidates, func(a, b int) bool { return candidates[a].arrived.Before(candidates[b].arrived) })
+
+	for _, file := range candidates {
+		expired := j.maxAge > 0 && now.Sub(file.arrived) > j.maxAge
+		overCap := j.maxSize > 0 && pass.bytes > j.maxSize
+		if !expired && !overCap {
+			// Candidates are sorted, so nothing later is expired either
+			break
+		}
+
+		if err := os.Remove(file.path); err != nil {
+			fmt.Fprintf(os.Stderr, "[Janitor] Failed to delete %s: %v\n", file.path, err)
+			continue
+		}
+		if j.logLevel.Get() == "DEBUG" {
+			fmt.Printf("[Janitor] Deleted %s\n", file.path)
+		}
+
+		pass.purgedFiles++
+		pass.purgedBytes += file.size
+		pass.files--
+		pass.bytes -= file.size
+	}
+
+	return pass, nil
+}
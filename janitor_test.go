@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestJanitor creates a verified folder janitor deleting files older than maxAge
+func newTestJanitor(verified string, maxAge time.Duration) *Janitor {
+	destinations := NewDestinations(SourceConfig{}, DestinationConfig{VerifiedFolder: verified})
+	return NewVerifiedJanitor(destinations, maxAge, 0, NewStatsTracker(), NewLogLevel("ERROR"))
+}
+
+func TestJanitorRemembersArrivalAcrossMetadataChanges(t *testing.T) {
+	verified := t.TempDir()
+	path := writeTestFile(t, verified, "data.zip", "data")
+	janitor := newTestJanitor(verified, time.Hour)
+
+	// First pass: the file has just arrived
+	if _, err := janitor.purge(verified, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if !FileExists(path) {
+		t.Fatal("new file purged")
+	}
+
+	// The file arrived two hours ago; a chmod now must not make it young again
+	arrival := janitor.arrivals[path]
+	arrival.arrived = arrival.arrived.Add(-2 * time.Hour)
+	janitor.arrivals[path] = arrival
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pass, err := janitor.purge(verified, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pass.purgedFiles != 1 || FileExists(path) {
+		t.Errorf("purged %d files, want the expired file purged", pass.purgedFiles)
+	}
+}
+
+func TestJanitorForgetsReplacedFile(t *testing.T) {
+	verified := t.TempDir()
+	path := writeTestFile(t, verified, "data.zip", "old")
+	janitor := newTestJanitor(verified, time.Hour)
+	if _, err := janitor.purge(verified, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	arrival := janitor.arrivals[path]
+	if arrival.inode == (inodeKey{}) {
+		t.Skip("no inodes on this platform")
+	}
+	arrival.arrived = arrival.arrived.Add(-2 * time.Hour)
+	janitor.arrivals[path] = arrival
+
+	// A new delivery under the same name (onCollision overwrite) is a new file
+	replacement := writeTestFile(t, t.TempDir(), "data.zip", "new")
+	if err := os.Rename(replacement, path); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := janitor.purge(verified, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if !FileExists(path) {
+		t.Error("new delivery purged with the age of the file it replaced")
+	}
+}
+
+func TestJanitorSkipsUnreadableSubfolder(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions do not apply to root")
+	}
+	verified := t.TempDir()
+	locked := filepath.Join(verified, "locked")
+	if err := os.Mkdir(locked, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, locked, "kept.zip", "data")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(locked, 0755) })
+	path := writeTestFile(t, verified, "data.zip", "data")
+
+	janitor := newTestJanitor(verified, time.Hour)
+	pass, err := janitor.purge(verified, time.Now().Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("purge failed on an unreadable subfolder: %v", err)
+	}
+	if pass.purgedFiles != 1 || FileExists(path) {
+		t.Errorf("purged %d files, want the readable expired file purged", pass.purgedFiles)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+KafkaLogger publishes verification and statistics records to a Kafka topic.
+
+Responsibilities:
+1. Encode each record as a JSON message keyed by filename
+2. Deliver messages in batches with at-least-once semantics (acks=all,
+   a batch is retried until the brokers acknowledge it)
+3. Buffer messages in memory while the brokers are unreachable
+4. Apply the overflow policy when the buffer is full:
+   - block: wait for space (back-pressure on the workers)
+   - drop:  discard the record and report an error
+   - spill: append the record to a local CSV file for later replay
+
+Does NOT:
+- Speak the Kafka protocol itself (that's kafka_producer.go)
+*/
+
+// Overflow policies applied when the Kafka buffer is full
+const (
+	KafkaOverflowBlock = "block"
+	KafkaOverflowDrop  = "drop"
+	KafkaOverflowSpill = "spill"
+)
+
+// kafkaBatchSize is the maximum number of messages sent in one produce request
+const kafkaBatchSize = 100
+
+// kafkaStatsKey is the message key used for statistics records
+const kafkaStatsKey = "stats"
+
+// kafkaVerificationMessage is the JSON payload for a verification record
+type kafkaVerificationMessage struct {
+	Type            string  `json:"type"`
+	Timestamp       string  `json:"timestamp"`
+	Filename        string  `json:"filename"`
+	SHA256          string  `json:"sha256"`
+	SizeBytes       int64   `json:"sizeBytes"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Action          string  `json:"action"`
+	DestinationPath string  `json:"destinationPath"`
+	ShadowHash      string  `json:"shadowHash,omitempty"`
+	HoleBytes       *int64  `json:"holeBytes,omitempty"`       // nil = not checked
+	CompressedBytes int64   `json:"compressedBytes,omitempty"` // 0 = delivered as is
+	SourcePath      string  `json:"sourcePath,omitempty"`
+	LinkedTo        string  `json:"linkedTo,omitempty"` // Hard link whose hash was reused
+}
+
+// kafkaStatsMessage is the JSON payload for a statistics record
+type kafkaStatsMessage struct {
+	Type               string  `json:"type"`
+	Timestamp          string  `json:"timestamp"`
+	TotalProcessed     int64   `json:"totalProcessed"`
+	SuccessCount       int64   `json:"successCount"`
+	FailureCount       int64   `json:"failureCount"`
+	PendingCount       int64   `json:"pendingCount"`
+	AverageDuration    float64 `json:"averageDuration"`
+	TotalBytesVerified int64   `json:"totalBytesVerified"`
+}
+
+// KafkaLogger handles buffered delivery of records to Kafka
+type KafkaLogger struct {
+	producer   *kafkaProducer
+	queue      chan kafkaMessage
+	overflow   string
+	spillPath  string
+	spillMutex sync.Mutex
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewKafkaLogger creates a Kafka sink and starts its delivery routine
+func NewKafkaLogger(cfg KafkaConfig) (*KafkaLogger, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("no Kafka brokers configured")
+	}
+
+	logger := &KafkaLogger{
+		producer:  newKafkaProducer(cfg.Brokers, cfg.Topic, cfg.ClientID, cfg.Timeout),
+		queue:     make(chan kafkaMessage, cfg.BufferSize),
+		overflow:  cfg.Overflow,
+		spillPath: cfg.SpillFile,
+		stopChan:  make(chan struct{}),
+	}
+
+	// Start delivery routine
+	logger.wg.Add(1)
+	go logger.sendLoop()
+
+	return logger, nil
+}
+
+// LogVerification queues a verification record for delivery
+func (l *KafkaLogger) LogVerification(entry CSVLogEntry) error {
+	value, err := json.Marshal(newVerificationMessage(entry))
+	if err != nil {
+		return fmt.Errorf("failed to encode verification message: %w", err)
+	}
+
+	return l.enqueue(kafkaMessage{Key: []byte(entry.Filename), Value: value, Time: clockNow()})
+}
+
+// LogStats queues a statistics record for delivery
+func (l *KafkaLogger) LogStats(entry StatsEntry) error {
+	value, err := json.Marshal(newStatsMessage(entry))
+	if err != nil {
+		return fmt.Errorf("failed to encode stats message: %w", err)
+	}
+
+	return l.enqueue(kafkaMessage{Key: []byte(kafkaStatsKey), Value: value, Time: clockNow()})
+}
+
+// newVerificationMessage builds the JSON payload of a verification record (also sent to /events)
+func newVerificationMessage(entry CSVLogEntry) kafkaVerificationMessage {
+	var holeBytes *int64
+	if entry.HoleBytes >= 0 {
+		holeBytes = &entry.HoleBytes
+	}
+	return kafkaVerificationMessage{
+		Type:            "verification",
+		Timestamp:       entry.Timestamp,
+		Filename:        entry.Filename,
+		SHA256:          entry.SHA256,
+		SizeBytes:       entry.SizeBytes,
+		DurationSeconds: entry.Duration,
+		Action:          entry.Action,
+		DestinationPath: entry.DestinationPath,
+		ShadowHash:      entry.ShadowHash,
+		HoleBytes:       holeBytes,
+		CompressedBytes: entry.CompressedBytes,
+		SourcePath:      entry.SourcePath,
+		LinkedTo:        entry.LinkedTo,
+	}
+}
+
+// newStatsMessage builds the JSON payload of a statistics record (also sent to /events)
+func newStatsMessage(entry StatsEntry) kafkaStatsMessage {
+	return kafkaStatsMessage{
+		Type:               "stats",
+		Timestamp:          entry.Timestamp,
+		TotalProcessed:     entry.TotalProcessed,
+		SuccessCount:       entry.SuccessCount,
+		FailureCount:       entry.FailureCount,
+		PendingCount:       entry.PendingCount,
+		AverageDuration:    entry.AverageDuration,
+		TotalBytesVerified: entry.TotalBytesVerified,
+	}
+}
+
+// Close delivers any buffered messages and closes the broker connections
+func (l *KafkaLogger) Close() error {
+	// Signal stop to delivery routine
+	close(l.stopChan)
+
+	// Wait for delivery routine to drain the buffer
+	l.wg.Wait()
+
+	return l.producer.Close()
+}
+
+// enqueue adds a message to the delivery buffer, applying the overflow policy when full
+func (l *KafkaLogger) enqueue(msg kafkaMessage) error {
+	select {
+	case l.queue <- msg:
+		return nil
+	default:
+	}
+
+	switch l.overflow {
+	case KafkaOverflowBlock:
+		select {
+		case l.queue <- msg:
+			return nil
+		case <-l.stopChan:
+			return fmt.Errorf("kafka sink is closed, record for %s not sent", msg.Key)
+		}
+	case KafkaOverflowSpill:
+		if err := l.spill([]kafkaMessage{msg}); err != nil {
+			return err
+		}
+		return nil
+	default:
+		return fmt.Errorf("kafka buffer full (%d messages), record for %s dropped", cap(l.queue), msg.Key)
+	}
+}
+
+// sendLoop delivers queued messages in batches
+func (l *KafkaLogger) sendLoop() {
+	defer l.wg.Done()
+
+	for {
+		select {
+		case msg := <-l.queue:
+			l.deliver(l.collectBatch(msg))
+		case <-l.stopChan:
+			l.drain()
+			return
+		}
+	}
+}
+
+// collectBatch gathers up to kafkaBatchSize already-buffered messages
+func (l *KafkaLogger) collectBatch(first kafkaMessage) []kafkaMessage {
+	batch := []kafkaMessage{first}
+	for len(batch) < kafkaBatchSize {
+		select {
+		case msg := <-l.queue:
+			batch = append(batch, msg)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// deliver produces a batch, retrying with backoff until the brokers acknowledge it
+// or the logger is stopped
+func (l *KafkaLogger) deliver(batch []kafkaMessage) {
+	backoff := 1 * time.Second
+
+	for {
+		err := l.producer.Produce(batch)
+		if err == nil {
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "[Kafka] Delivery of %d records failed, retrying in %s: %v\n", len(batch), backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-l.stopChan:
+			// Make one final attempt during shutdown
+			if err := l.producer.Produce(batch); err != nil {
+				l.undelivered(batch)
+			}
+			return
+		}
+
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// drain makes a single delivery attempt for every buffered message on shutdown
+func (l *KafkaLogger) drain() {
+	for {
+		select {
+		case msg := <-l.queue:
+			batch := l.collectBatch(msg)
+			if err := l.producer.Produce(batch); err != nil {
+				l.undelivered(batch)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// undelivered preserves messages that could not be delivered at shutdown
+// They are spilled to the local file when configured, otherwise written to stderr
+func (l *KafkaLogger) undelivered(batch []kafkaMessage) {
+	if l.spillPath != "" {
+		if err := l.spill(batch); err == nil {
+			return
+		}
+	}
+
+	for _, msg := range batch {
+		fmt.Fprintf(os.Stderr, "[Kafka] Undelivered record: %s\n", msg.Value)
+	}
+}
+
+// spill appends messages to the local spill CSV (timestamp, key, JSON value)
+func (l *KafkaLogger) spill(batch []kafkaMessage) error {
+	l.spillMutex.Lock()
+	defer l.spillMutex.Unlock()
+
+	file, err := os.OpenFile(l.spillPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open kafka spill file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	for _, msg := range batch {
+		record := []string{msg.Time.Format(time.RFC3339Nano), string(msg.Key), string(msg.Value)}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write kafka spill record: %w", err)
+		}
+	}
+	if err := syncWriter(writer, file); err != nil {
+		return fmt.Errorf("failed to flush kafka spill file: %w", err)
+	}
+
+	return nil
+}
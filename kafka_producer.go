@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"sort"
+	"time"
+)
+
+/*
+kafkaProducer is a minimal Kafka producer used by the Kafka result sink.
+
+It speaks just enough of the Kafka wire protocol for our low message rate:
+- Metadata v1 to discover the partitions of the topic and their leaders
+- Produce v3 with uncompressed RecordBatch v2, acks=all (brokers 0.11+)
+
+Messages are assigned to partitions by a hash of their key over all the
+partitions of the topic, so every record for the same file lands on the same
+partition in order. A leader election does not move a key: while its
+partition has no leader, producing fails and the batch is retried.
+
+Does NOT:
+- Compress, authenticate (SASL) or encrypt (TLS)
+- Provide idempotence or transactions (delivery is at-least-once)
+*/
+
+// Kafka API keys and versions used by the producer
+const (
+	kafkaAPIProduce       = 0
+	kafkaAPIMetadata      = 3
+	kafkaProduceVersion   = 3
+	kafkaMetadataVersion  = 1
+	kafkaAcksAll          = -1
+	kafkaRecordBatchMagic = 2
+	kafkaNoProducerID     = -1
+	kafkaNoPartitionEpoch = -1
+	kafkaErrorNone        = 0
+	kafkaMaxResponseSize  = 64 * 1024 * 1024
+)
+
+// crc32c is the Castagnoli table used for RecordBatch checksums
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaMessage is a single record to produce
+type kafkaMessage struct {
+	Key   []byte
+	Value []byte
+	Time  time.Time
+}
+
+// kafkaProducer delivers messages to a single topic
+type kafkaProducer struct {
+	brokers       []string
+	topic         string
+	clientID      string
+	timeout       time.Duration
+	correlationID int32
+	partitions    []int32          // Sorted partition IDs of the topic, with or without a leader
+	leaders       map[int32]string // Partition ID -> leader broker address (absent = no leader)
+	conns         map[string]net.Conn
+}
+
+// newKafkaProducer creates a producer; connections are opened on first use
+func newKafkaProducer(brokers []string, topic, clientID string, timeout time.Duration) *kafkaProducer {
+	return &kafkaProducer{
+		brokers:  brokers,
+		topic:    topic,
+		clientID: clientID,
+		timeout:  timeout,
+		conns:    make(map[string]net.Conn),
+	}
+}
+
+// Produce writes messages to their partitions and waits for all in-sync replicas
+// On any error the cached metadata and connections are discarded, so the next
+// call rediscovers the cluster
+func (p *kafkaProducer) Produce(msgs []kafkaMessage) error {
+	if p.leaders == nil {
+		if err := p.refreshMetadata(); err != nil {
+			p.reset()
+			return err
+		}
+	}
+
+	// Group messages by partition, preserving order within each partition
+	// Nothing is sent unless every partition has a leader, so a retried batch is not partly duplicated
+	byPartition := make(map[int32][]kafkaMessage)
+	for _, msg := range msgs {
+		partition := p.partitionFor(msg.Key)
+		if _, ok := p.leaders[partition]; !ok {
+			p.reset()
+			return fmt.Errorf("partition %s/%d has no leader", p.topic, partition)
+		}
+		byPartition[partition] = append(byPartition[partition], msg)
+	}
+
+	for partition, batch := range byPartition {
+		if err := p.producePartition(partition, batch); err != nil {
+			p.reset()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// partitionFor returns the partition of a message key
+func (p *kafkaProducer) partitionFor(key []byte) int32 {
+	return p.partitions[crc32.ChecksumIEEE(key)%uint32(len(p.partitions))]
+}
+
+// Close closes all broker connections
+func (p *kafkaProducer) Close() error {
+	p.reset()
+	return nil
+}
+
+// reset drops cached metadata and connections
+func (p *kafkaProducer) reset() {
+	for addr, conn := range p.conns {
+		conn.Close()
+		delete(p.conns, addr)
+	}
+	p.leaders = nil
+	p.partitions = nil
+}
+
+// refreshMetadata asks the bootstrap brokers for the topic's partition leaders
+func (p *kafkaProducer) refreshMetadata() error {
+	body := &kafkaEncoder{}
+	body.int32(1)
+	body.string(p.topic)
+
+	var lastErr error
+	for _, broker := range p.brokers {
+		resp, err := p.roundTrip(broker, kafkaAPIMetadata, kafkaMetadataVersion, body.Bytes())
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return p.parseMetadata(resp)
+	}
+
+	return fmt.Errorf("failed to fetch metadata from any broker: %w", lastErr)
+}
+
+// parseMetadata decodes a Metadata v1 response
+func (p *kafkaProducer) parseMetadata(resp []byte) error {
+	d := &kafkaDecoder{buf: resp}
+
+	brokers := make(map[int32]string)
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		nodeID := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		brokers[nodeID] = net.JoinHostPort(host, fmt.Sprintf("%d", port))
+	}
+	d.int32() // controller ID
+
+	leaders := make(map[int32]string)
+	var partitions []int32
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		topicErr := d.int16()
+		name := d.string()
+		d.int8() // is_internal
+		for m := d.int32(); m > 0 && d.err == nil; m-- {
+			partitionErr := d.int16()
+			partition := d.int32()
+			leader := d.int32()
+			d.skipInt32Array() // replicas
+			d.skipInt32Array() // in-sync replicas
+
+			if name != p.topic {
+				continue
+			}
+			// A partition without a leader (e.g. LEADER_NOT_AVAILABLE) still counts for key hashing
+			partitions = append(partitions, partition)
+			if addr, ok := brokers[leader]; ok && partitionErr == kafkaErrorNone {
+				leaders[partition] = addr
+			}
+		}
+		if name == p.topic && topicErr != kafkaErrorNone {
+			return fmt.Errorf("metadata for topic %s returned error code %d", p.topic, topicErr)
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("failed to decode metadata: %w", d.err)
+	}
+	if len(partitions) == 0 {
+		return fmt.Errorf("topic %s has no partitions", p.topic)
+	}
+
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	p.leaders = leaders
+	p.partitions = partitions
+
+	return nil
+}
+
+// producePartition sends one record batch to the partition leader
+func (p *kafkaProducer) producePartition(partition int32, msgs []kafkaMessage) error {
+	batch := encodeRecordBatch(msgs)
+
+	body := &kafkaEncoder{}
+	body.int16(-1) // transactional_id: null
+	body.int16(kafkaAcksAll)
+	body.int32(int32(p.timeout / time.Millisecond))
+	body.int32(1) // one topic
+	body.string(p.topic)
+	body.int32(1) // one partition
+	body.int32(partition)
+	body.bytes(batch)
+
+	resp, err := p.roundTrip(p.leaders[partition], kafkaAPIProduce, kafkaProduceVersion, body.Bytes())
+	if err != nil {
+		return err
+	}
+
+	// Produce v3 response: [topic [partition error_code base_offset log_append_time]] throttle_time
+	d := &kafkaDecoder{buf: resp}
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		d.string()
+		for m := d.int32(); m > 0 && d.err == nil; m-- {
+			d.int32() // partition
+			errorCode := d.int16()
+			d.int64() // base offset
+			d.int64() // log append time
+			if errorCode != kafkaErrorNone {
+				return fmt.Errorf("produce to %s/%d returned error code %d", p.topic, partition, errorCode)
+			}
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("failed to decode produce response: %w", d.err)
+	}
+
+	return nil
+}
+
+// roundTrip sends a request to a broker and returns the response body
+func (p *kafkaProducer) roundTrip(addr string, apiKey, apiVersion int16, body []byte) ([]byte, error) {
+	conn, ok := p.conns[addr]
+	if !ok {
+		var err error
+		conn, err = net.DialTimeout("tcp", addr, p.timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+		}
+		p.conns[addr] = conn
+	}
+
+	p.correlationID++
+	correlationID := p.correlationID
+
+	// Request header v1: api_key api_version correlation_id client_id
+	req := &kafkaEncoder{}
+	req.int32(0) // size placeholder
+	req.int16(apiKey)
+	req.int16(apiVersion)
+	req.int32(correlationID)
+	req.string(p.clientID)
+	req.buf.Write(body)
+	frame := req.Bytes()
+	binary.BigEndian.PutUint32(frame, uint32(len(frame)-4))
+
+	conn.SetDeadline(time.Now().Add(p.timeout))
+	if _, err := conn.Write(frame); err != nil {
+		return nil, fmt.Errorf("failed to send request to %s: %w", addr, err)
+	}
+
+	var sizeBuf [4]byte
+	if _, err := io.ReadFull(conn, sizeBuf[:]); err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", addr, err)
+	}
+	size := binary.BigEndian.Uint32(sizeBuf[:])
+	if size < 4 || size > kafkaMaxResponseSize {
+		return nil, fmt.Errorf("invalid response size %d from %s", size, addr)
+	}
+
+	resp := make([]byte, size)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", addr, err)
+	}
+	if got := int32(binary.BigEndian.Uint32(resp)); got != correlationID {
+		return nil, fmt.Errorf("correlation ID mismatch from %s: expected %d, got %d", addr, correlationID, got)
+	}
+
+	return resp[4:], nil
+}
+
+// encodeRecordBatch encodes messages as an uncompressed RecordBatch v2
+func encodeRecordBatch(msgs []kafkaMessage) []byte {
+	firstTimestamp := msgs[0].Time.UnixMilli()
+	maxTimestamp := firstTimestamp
+
+	records := &kafkaEncoder{}
+	for i, msg := range msgs {
+		timestamp := msg.Time.UnixMilli()
+		if timestamp > maxTimestamp {
+			maxTimestamp = timestamp
+		}
+
+		record := &kafkaEncoder{}
+		record.int8(0) // attributes
+		record.varint(timestamp - firstTimestamp)
+		record.varint(int64(i)) // offset delta
+		record.varint(int64(len(msg.Key)))
+		record.buf.Write(msg.Key)
+		record.varint(int64(len(msg.Value)))
+		record.buf.Write(msg.Value)
+		record.varint(0) // no headers
+
+		records.varint(int64(record.buf.Len()))
+		records.buf.Write(record.Bytes())
+	}
+
+	// Fields covered by the CRC (attributes through the records)
+	crcBody := &kafkaEncoder{}
+	crcBody.int16(0) // attributes: no compression, create time
+	crcBody.int32(int32(len(msgs) - 1))
+	crcBody.int64(firstTimestamp)
+	crcBody.int64(maxTimestamp)
+	crcBody.int64(kafkaNoProducerID)
+	crcBody.int16(-1) // producer epoch
+	crcBody.int32(-1) // base sequence
+	crcBody.int32(int32(len(msgs)))
+	crcBody.buf.Write(records.Bytes())
+
+	batch := &kafkaEncoder{}
+	batch.int64(0) // base offset, assigned by the broker
+	batch.int32(int32(4 + 1 + 4 + crcBody.buf.Len()))
+	batch.int32(kafkaNoPartitionEpoch)
+	batch.int8(kafkaRecordBatchMagic)
+	batch.int32(int32(crc32.Checksum(crcBody.Bytes(), crc32c)))
+	batch.buf.Write(crcBody.Bytes())
+
+	return batch.Bytes()
+}
+
+// kafkaEncoder writes big-endian Kafka protocol primitives
+type kafkaEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *kafkaEncoder) Bytes() []byte { return e.buf.Bytes() }
+func (e *kafkaEncoder) int8(v int8)   { e.buf.WriteByte(byte(v)) }
+func (e *kafkaEncoder) int16(v int16) { binary.Write(&e.buf, binary.BigEndian, v) }
+func (e *kafkaEncoder) int32(v int32) { binary.Write(&e.buf, binary.BigEndian, v) }
+func (e *kafkaEncoder) int64(v int64) { binary.Write(&e.buf, binary.BigEndian, v) }
+
+// string writes an int16 length-prefixed string
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf.WriteString(s)
+}
+
+// bytes writes an int32 length-prefixed byte slice
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf.Write(b)
+}
+
+// varint writes a zig-zag encoded variable-length integer
+func (e *kafkaEncoder) varint(v int64) {
+	e.buf.Write(binary.AppendVarint(nil, v))
+}
+
+// kafkaDecoder reads big-endian Kafka protocol primitives
+// The first error is sticky; subsequent reads return zero values
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+// next consumes n bytes, recording an error if the buffer is too short
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string reads an int16 length-prefixed (nullable) string
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+// skipInt32Array skips an array of int32 values
+func (d *kafkaDecoder) skipInt32Array() {
+	n := d.int32()
+	if n > 0 {
+		d.next(int(n) * 4)
+	}
+}
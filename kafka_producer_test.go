@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wire builds a Kafka protocol frame from big-endian values, independently of kafkaEncoder:
+// int8/int16/int32/int64 as is, a string as an int16 length and its bytes, []byte raw
+func wire(t *testing.T, values ...any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			binary.Write(&buf, binary.BigEndian, int16(len(v)))
+			buf.WriteString(v)
+		case []byte:
+			buf.Write(v)
+		case int8, int16, int32, int64:
+			binary.Write(&buf, binary.BigEndian, v)
+		default:
+			t.Fatalf("unsupported wire value %T", value)
+		}
+	}
+	return buf.Bytes()
+}
+
+// unhex decodes a hex frame written with spaces and newlines between fields
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// testRecordBatchMessages is the input of recordBatchFrame
+var testRecordBatchMessages = []kafkaMessage{
+	{Key: []byte("a.zip"), Value: []byte("{}"), Time: time.UnixMilli(1700000000000)},
+	{Key: []byte("b.zip"), Value: []byte(`{"x":1}`), Time: time.UnixMilli(1700000000005)},
+}
+
+// recordBatchFrame is testRecordBatchMessages as an uncompressed RecordBatch v2,
+// with the CRC-32C computed separately from the producer
+const recordBatchFrame = `
+	0000000000000000 00000052 ffffffff 02 75e1821e
+	0000 00000001 0000018bcfe56800 0000018bcfe56805
+	ffffffffffffffff ffff ffffffff 00000002
+	1a 00 00 00 0a 612e7a6970 04 7b7d 00
+	24 00 0a 02 0a 622e7a6970 0e 7b2278223a317d 00
+`
+
+func TestEncodeRecordBatch(t *testing.T) {
+	got := encodeRecordBatch(testRecordBatchMessages)
+	if want := unhex(t, recordBatchFrame); !bytes.Equal(got, want) {
+		t.Errorf("record batch\n got %x\nwant %x", got, want)
+	}
+}
+
+// metadataResponse is a Metadata v1 response for topic "results" with three partitions:
+// 0 led by broker 1, 1 without a leader (LEADER_NOT_AVAILABLE), 2 led by broker 2;
+// and another topic that must be ignored
+func metadataResponse(t *testing.T, host string, port int32) []byte {
+	return wire(t,
+		int32(2), // brokers
+		int32(1), host, port, int16(-1),
+		int32(2), host, port+1, int16(-1),
+		int32(1), // controller
+		int32(2), // topics
+		int16(0), "results", int8(0), int32(3),
+		int16(0), int32(0), int32(1), int32(1), int32(1), int32(1), int32(1),
+		int16(5), int32(1), int32(-1), int32(0), int32(0),
+		int16(0), int32(2), int32(2), int32(1), int32(2), int32(1), int32(2),
+		int16(0), "other", int8(0), int32(1),
+		int16(0), int32(0), int32(1), int32(0), int32(0),
+	)
+}
+
+func TestParseMetadata(t *testing.T) {
+	p := newKafkaProducer(nil, "results", "test", time.Second)
+	if err := p.parseMetadata(metadataResponse(t, "k1", 9092)); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []int32{0, 1, 2}; !slices.Equal(p.partitions, want) {
+		t.Errorf("partitions = %v, want %v", p.partitions, want)
+	}
+	want := map[int32]string{0: "k1:9092", 2: "k1:9093"}
+	if len(p.leaders) != len(want) || p.leaders[0] != want[0] || p.leaders[2] != want[2] {
+		t.Errorf("leaders = %v, want %v", p.leaders, want)
+	}
+}
+
+func TestParseMetadataTruncated(t *testing.T) {
+	p := newKafkaProducer(nil, "results", "test", time.Second)
+	frame := metadataResponse(t, "k1", 9092)
+	if err := p.parseMetadata(frame[:len(frame)-3]); err == nil {
+		t.Error("truncated metadata accepted")
+	}
+}
+
+func TestPartitionForIgnoresLeaders(t *testing.T) {
+	// The same partitions, all with a leader
+	allLed := newKafkaProducer(nil, "results", "test", time.Second)
+	allLed.partitions = []int32{0, 1, 2}
+	allLed.leaders = map[int32]string{0: "k1:9092", 1: "k1:9092", 2: "k1:9093"}
+
+	electing := newKafkaProducer(nil, "results", "test", time.Second)
+	if err := electing.parseMetadata(metadataResponse(t, "k1", 9092)); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		key := []byte("file-" + strconv.Itoa(i) + ".zip")
+		if a, b := allLed.partitionFor(key), electing.partitionFor(key); a != b {
+			t.Fatalf("%s moved from partition %d to %d during the election", key, a, b)
+		}
+	}
+}
+
+func TestProduceFailsWithoutLeader(t *testing.T) {
+	p := newKafkaProducer(nil, "results", "test", time.Second)
+	if err := p.parseMetadata(metadataResponse(t, "k1", 9092)); err != nil {
+		t.Fatal(err)
+	}
+
+	var key []byte
+	for i := 0; key == nil; i++ {
+		if candidate := []byte("file-" + strconv.Itoa(i)); p.partitionFor(candidate) == 1 {
+			key = candidate
+		}
+	}
+
+	// No connection is attempted, the brokers do not exist
+	err := p.Produce([]kafkaMessage{{Key: key, Value: []byte("{}"), Time: time.UnixMilli(0)}})
+	if err == nil || !strings.Contains(err.Error(), "no leader") {
+		t.Fatalf("err = %v, want a missing leader error", err)
+	}
+	if p.leaders != nil {
+		t.Error("metadata kept after a failed produce, it would not be refreshed")
+	}
+}
+
+// fakeBroker answers one connection with responses in order, handing each request to check
+type fakeBroker struct {
+	listener  net.Listener
+	host      string
+	port      int32
+	responses [][]byte
+	requests  chan []byte
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on localhost: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	addr := listener.Addr().(*net.TCPAddr)
+	return &fakeBroker{listener: listener, host: "127.0.0.1", port: int32(addr.Port), requests: make(chan []byte, 10)}
+}
+
+// serve answers requests with b.responses, echoing their correlation IDs
+func (b *fakeBroker) serve() {
+	conn, err := b.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for _, response := range b.responses {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		request := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+		b.requests <- request
+
+		frame := binary.BigEndian.AppendUint32(nil, uint32(4+len(response)))
+		frame = append(frame, request[4:8]...) // correlation ID
+		conn.Write(append(frame, response...))
+	}
+}
+
+func TestProduceRoundTrip(t *testing.T) {
+	broker := newFakeBroker(t)
+	// Every partition led by this broker
+	metadata := wire(t,
+		int32(1), int32(1), broker.host, broker.port, int16(-1),
+		int32(1),
+		int32(1), int16(0), "results", int8(0), int32(1),
+		int16(0), int32(0), int32(1), int32(1), int32(1), int32(1), int32(1),
+	)
+	produced := wire(t, int32(1), "results", int32(1), int32(0), int16(0), int64(42), int64(-1), int32(0))
+	broker.responses = [][]byte{metadata, produced}
+	go broker.serve()
+
+	p := newKafkaProducer([]string{net.JoinHostPort(broker.host, strconv.Itoa(int(broker.port)))}, "results", "filesha", 1500*time.Millisecond)
+	defer p.Close()
+	if err := p.Produce(testRecordBatchMessages); err != nil {
+		t.Fatal(err)
+	}
+
+	wantMetadata := wire(t, int16(kafkaAPIMetadata), int16(1), int32(1), "filesha", int32(1), "results")
+	if got := <-broker.requests; !bytes.Equal(got, wantMetadata) {
+		t.Errorf("metadata request\n got %x\nwant %x", got, wantMetadata)
+	}
+
+	batch := unhex(t, recordBatchFrame)
+	wantProduce := wire(t,
+		int16(kafkaAPIProduce), int16(3), int32(2), "filesha",
+		int16(-1), int16(-1), int32(1500), // transactional ID, acks=all, timeout
+		int32(1), "results", int32(1), int32(0),
+		int32(len(batch)), batch,
+	)
+	if got := <-broker.requests; !bytes.Equal(got, wantProduce) {
+		t.Errorf("produce request\n got %x\nwant %x", got, wantProduce)
+	}
+}
+
+func TestProduceReportsPartitionError(t *testing.T) {
+	broker := newFakeBroker(t)
+	metadata := wire(t,
+		int32(1), int32(1), broker.host, broker.port, int16(-1),
+		int32(1),
+		int32(1), int16(0), "results", int8(0), int32(1),
+		int16(0), int32(0), int32(1), int32(1), int32(1), int32(1), int32(1),
+	)
+	// NOT_LEADER_OR_FOLLOWER
+	rejected := wire(t, int32(1), "results", int32(1), int32(0), int16(6), int64(-1), int64(-1), int32(0))
+	broker.responses = [][]byte{metadata, rejected}
+	go broker.serve()
+
+	p := newKafkaProducer([]string{net.JoinHostPort(broker.host, strconv.Itoa(int(broker.port)))}, "results", "filesha", time.Second)
+	defer p.Close()
+	err := p.Produce(testRecordBatchMessages)
+	if err == nil || !strings.Contains(err.Error(), "error code 6") {
+		t.Fatalf("err = %v, want error code 6", err)
+	}
+	if p.leaders != nil {
+		t.Error("metadata kept after a rejected produce, it would not be refreshed")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+KnownHashes is an allow-list of content the operator already trusts, for
+reference files that are delivered again and again.
+
+The list file (verification.knownHashesFile) has one entry per line, the hex
+hash followed by the file size in bytes and optionally a name for reference:
+
+	# hash                                                           size     name
+	9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08 1048576  reference.zip
+
+Responsibilities:
+1. Load the list once at startup
+2. Tell the worker whether a sidecar's expected hash and the data file's size
+   match an entry (verification.trustKnownHashes); such a file is delivered
+   as verified without being hashed
+
+Does NOT:
+- Prove the data file has that content: only the sidecar and the size are
+  checked, the upstream match is trusted (an opt-in performance shortcut)
+- Apply to hashes from file names, manifests or the database, or to files
+  with pre-hash transforms
+*/
+
+// KnownHashes maps trusted hashes (lowercase hex) to the size of their content
+type KnownHashes struct {
+	sizes map[string]int64
+}
+
+// LoadKnownHashes reads a known-good hash list
+func LoadKnownHashes(path string) (*KnownHashes, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open known hashes file: %w", err)
+	}
+	defer file.Close()
+
+	known := &KnownHashes{sizes: make(map[string]int64)}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s line %d: expected \"<hash> <size>\"", path, lineNumber)
+		}
+		hash := strings.ToLower(fields[0])
+		if _, err := hex.DecodeString(hash); err != nil || hash == "" {
+			return nil, fmt.Errorf("%s line %d: invalid hex hash %q", path, lineNumber, fields[0])
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("%s line %d: invalid size %q", path, lineNumber, fields[1])
+		}
+		known.sizes[hash] = size
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read known hashes file: %w", err)
+	}
+
+	return known, nil
+}
+
+// Trusted reports whether a hash is known good for content of the given size
+func (k *KnownHashes) Trusted(hash string, size int64) bool {
+	knownSize, exists := k.sizes[hash]
+	return exists && knownSize == size
+}
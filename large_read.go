@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+/*
+LargeReads hashes data files with few, large reads (verification.largeRead),
+for source folders on FUSE mounts backed by an object store (s3fs, gcsfuse,
+rclone mount, ...) where every read is a round trip to the backend.
+
+Responsibilities:
+1. Read data files with largeRead.bufferSize reads instead of
+   verification.bufferSize (also for transforms and checkpointed files)
+2. Copy a file into the hasher with a single io.CopyBuffer instead of the
+   chunked read loop
+3. With largeRead.readahead, read the next buffers while the current one is
+   hashed, so the backend's latency overlaps with hashing
+
+The tradeoff is memory: each worker hashing a file holds readahead+1 buffers
+of bufferSize. On a local disk this buys nothing, the kernel already reads
+ahead, which is why the mode is off by default.
+
+Does NOT:
+- Change how sidecars, archive members or growing-file segments are read
+- Give the kernel readahead hints (fadvise): readahead is done by reading
+  into spare buffers
+*/
+
+// defaultLargeReadBuffer is the read size of the large-read mode unless configured
+const defaultLargeReadBuffer = 16 << 20 // 16 MiB
+
+// LargeReads holds the large-read settings
+type LargeReads struct {
+	bufferSize int
+	readahead  int // Buffers read ahead of the one being hashed
+}
+
+// NewLargeReads creates the large-read mode
+func NewLargeReads(cfg LargeReadConfig) *LargeReads {
+	return &LargeReads{bufferSize: cfg.BufferSize, readahead: cfg.Readahead}
+}
+
+// hashReaderTo hashes everything read from r, also writing it to shadow (nil = none)
+func (lr *LargeReads) hashReaderTo(r io.Reader, algo string, shadow io.Writer) (string, error) {
+	newHasher, ok := hashAlgorithms[algo]
+	if !ok {
+		return "", fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+	hasher := newHasher()
+	var w io.Writer = hasher
+	if shadow != nil {
+		w = io.MultiWriter(hasher, shadow)
+	}
+
+	if err := lr.copy(w, r); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// copy copies r to w in bufferSize reads, reading ahead when configured
+func (lr *LargeReads) copy(w io.Writer, r io.Reader) error {
+	if lr.readahead == 0 {
+		// Hide any WriterTo (os.File falls back to 32 KiB reads)
+		_, err := io.CopyBuffer(w, struct{ io.Reader }{r}, make([]byte, lr.bufferSize))
+		return err
+	}
+
+	type chunk struct {
+		data []byte
+		err  error
+	}
+	chunks := make(chan chunk, lr.readahead)
+	free := make(chan []byte, lr.readahead+1)
+	for i := 0; i <= lr.readahead; i++ {
+		free <- make([]byte, lr.bufferSize)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+
+	// The reader fills free buffers while the caller hashes the full ones
+	go func() {
+		defer close(chunks)
+		for {
+			var buffer []byte
+			select {
+			case buffer = <-free:
+			case <-stop:
+				return
+			}
+			n, err := io.ReadFull(r, buffer)
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = io.EOF
+			}
+			if n > 0 {
+				select {
+				case chunks <- chunk{data: buffer[:n]}:
+				case <-stop:
+					return
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				select {
+				case chunks <- chunk{err: err}:
+				case <-stop:
+				}
+				return
+			}
+		}
+	}()
+
+	for c := range chunks {
+		if c.err != nil {
+			return c.err
+		}
+		if _, err := w.Write(c.data); err != nil {
+			return err
+		}
+		free <- c.data[:cap(c.data)]
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+/*
+LatestLinks keeps a fixed path pointing at the newest verified file of each
+kind (destination.latestLinks), for consumers polling a fixed path instead of
+watching the verified folder.
+
+Each rule names a link, e.g. "latest.zip" for data files matching "*.zip"
+(first match applies). After every delivery, the link in the folder the file
+was delivered to is pointed at it: a relative symlink, created under a
+temporary name and renamed over the old link so readers never see it
+missing. Where symlinks are not supported (some FUSE and SMB mounts, FAT:
+creating one fails with EPERM, ENOTSUP or ENOSYS), "<name>.pointer" is
+written instead, the file name of the newest file followed by a newline,
+also replaced atomically. Any other failure is reported and the link is
+tried again on the next delivery.
+
+Does NOT:
+- Replace a regular file that has the link's name (e.g. a delivered
+  "latest.zip"): the link is not updated and this is reported once
+- Follow a file renamed or removed later (retention): the link dangles until
+  the next delivery
+- Order deliveries by verification time: the link follows the last delivery
+  to finish
+*/
+
+// latestPointerSuffix is appended to a link's name for its pointer file
+const latestPointerSuffix = ".pointer"
+
+// LatestLinks updates the latest links after each delivery
+type LatestLinks struct {
+	rules    []LatestLinkRule
+	logLevel *LogLevel
+
+	mutex    sync.Mutex
+	pointers map[string]bool // Folders without symlink support, pointer files are written
+	blocked  map[string]bool // Link paths taken by a regular file, already reported
+}
+
+// NewLatestLinks creates the latest links for the configured rules
+func NewLatestLinks(rules []LatestLinkRule, logLevel *LogLevel) *LatestLinks {
+	return &LatestLinks{
+		rules:    rules,
+		logLevel: logLevel,
+		pointers: make(map[string]bool),
+		blocked:  make(map[string]bool),
+	}
+}
+
+// Update points the link of the first rule matching dataFile (its name in the source)
+// at the file delivered to deliveredPath
+// Safe to call on a nil LatestLinks (does nothing)
+func (ll *LatestLinks) Update(dataFile, deliveredPath string) {
+	if ll == nil {
+		return
+	}
+
+	for _, rule := range ll.rules {
+		if matched, _ := filepath.Match(rule.Pattern, dataFile); matched {
+			ll.point(filepath.Join(filepath.Dir(deliveredPath), rule.Name), filepath.Base(deliveredPath))
+			return
+		}
+	}
+}
+
+// point replaces the link at linkPath (or its pointer file) to name a file in the same folder
+func (ll *LatestLinks) point(linkPath, target string) {
+	// Concurrent deliveries would share the temporary name
+	ll.mutex.Lock()
+	defer ll.mutex.Unlock()
+
+	folder := filepath.Dir(linkPath)
+	if target == filepath.Base(linkPath) {
+		return
+	}
+	if !ll.pointers[folder] {
+		if info, err := os.Lstat(linkPath); err == nil && info.Mode()&os.ModeSymlink == 0 {
+			if !ll.blocked[linkPath] {
+				ll.blocked[linkPath] = true
+				fmt.Fprintf(os.Stderr, "[LatestLinks] %s is a regular file, not updating it\n", linkPath)
+			}
+			return
+		}
+
+		tmpPath := linkPath + ".tmp"
+		os.Remove(tmpPath)
+		err := os.Symlink(target, tmpPath)
+		if err == nil {
+			if err = os.Rename(tmpPath, linkPath); err != nil {
+				os.Remove(tmpPath)
+				fmt.Fprintf(os.Stderr, "[LatestLinks] Failed to update %s: %v\n", linkPath, err)
+			}
+			return
+		}
+		if !symlinksUnsupported(err) {
+			fmt.Fprintf(os.Stderr, "[LatestLinks] Failed to update %s: %v\n", linkPath, err)
+			return
+		}
+
+		ll.pointers[folder] = true
+		if ll.logLevel.Get() == "WARN" || ll.logLevel.Get() == "DEBUG" {
+			fmt.Fprintf(os.Stderr, "[LatestLinks] Symlinks not supported in %s (%v), writing %s files instead\n",
+				folder, err, latestPointerSuffix)
+		}
+	}
+
+	pointerPath := linkPath + latestPointerSuffix
+	tmpPath := pointerPath + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(target+"\n"), 0644); err != nil {
+		os.Remove(tmpPath)
+		fmt.Fprintf(os.Stderr, "[LatestLinks] Failed to write %s: %v\n", pointerPath, err)
+		return
+	}
+	if err := os.Rename(tmpPath, pointerPath); err != nil {
+		os.Remove(tmpPath)
+		fmt.Fprintf(os.Stderr, "[LatestLinks] Failed to update %s: %v\n", pointerPath, err)
+	}
+}
+
+// symlinksUnsupported reports whether a failure to create a symlink means the
+// file system has none, rather than e.g. a permission or space problem
+func symlinksUnsupported(err error) bool {
+	return errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.ENOSYS)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestSymlinksUnsupported(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{syscall.EPERM, true},
+		{syscall.ENOTSUP, true},
+		{syscall.ENOSYS, true},
+		{syscall.EACCES, false},
+		{syscall.ENOSPC, false},
+		{syscall.ENOENT, false},
+	}
+	for _, test := range tests {
+		err := &os.LinkError{Op: "symlink", Old: "data.zip", New: "latest.zip.tmp", Err: test.err}
+		if got := symlinksUnsupported(err); got != test.want {
+			t.Errorf("%v: unsupported = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestLatestLinkFailureKeepsSymlinks(t *testing.T) {
+	// Symlinks can't be created in a missing folder, that does not make them unsupported
+	folder := filepath.Join(t.TempDir(), "missing")
+	links := NewLatestLinks([]LatestLinkRule{{Pattern: "*.zip", Name: "latest.zip"}}, NewLogLevel("ERROR"))
+	links.Update("data.zip", filepath.Join(folder, "data.zip"))
+
+	if links.pointers[folder] {
+		t.Error("folder switched to pointer files")
+	}
+}
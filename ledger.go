@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+/*
+LedgerLogger keeps a tamper-evident, append-only record of verified files for
+chain of custody (output sink "ledger", output.ledger.file).
+
+Each line is one JSON entry:
+
+	{"prevHash":"…","filename":"data.zip","fileHash":"…","timestamp":"2026-01-02 15:04:05","entryHash":"…"}
+
+entryHash is the SHA-256 of prevHash, filename, fileHash and timestamp joined
+by newlines, and prevHash is the entryHash of the entry before it (64 zeros
+for the first). Changing, removing or reordering an entry breaks the link of
+the entry after it; "go-filesha-verifier verify-ledger <file>" walks the
+chain and reports the first broken link.
+
+Responsibilities:
+1. Continue the chain of an existing ledger at startup; a ledger whose last
+   entry is damaged (e.g. a torn write) is refused until it is looked at
+2. Append and fsync one entry per verified file
+3. Check entries and their links for verify-ledger
+
+Does NOT:
+- Detect the newest entries being cut off, or the whole chain being rewritten:
+  anchor the latest entryHash somewhere else for that
+- Record failed verifications or statistics
+*/
+
+// ledgerGenesis is the prevHash of a ledger's first entry
+var ledgerGenesis = strings.Repeat("0", sha256.Size*2)
+
+// ledgerTailSize bounds how much of an existing ledger is read to find its last entry
+const ledgerTailSize = 64 * 1024
+
+// ErrLedgerBroken is returned for an entry that is damaged or does not chain to the one before it
+var ErrLedgerBroken = errors.New("ledger chain broken")
+
+// ledgerEntry is one line of the ledger
+type ledgerEntry struct {
+	PrevHash  string `json:"prevHash"`
+	Filename  string `json:"filename"`
+	FileHash  string `json:"fileHash"`
+	Timestamp string `json:"timestamp"`
+	EntryHash string `json:"entryHash"`
+}
+
+// computeHash returns the hash that chains this entry
+func (e ledgerEntry) computeHash() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{e.PrevHash, e.Filename, e.FileHash, e.Timestamp}, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseLedgerEntry decodes a ledger line and checks its own hash, and its link when prevHash is given
+func parseLedgerEntry(line []byte, prevHash string) (ledgerEntry, error) {
+	var entry ledgerEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return entry, fmt.Errorf("%w: unreadable entry: %v", ErrLedgerBroken, err)
+	}
+	if entry.EntryHash != entry.computeHash() {
+		return entry, fmt.Errorf("%w: entry for %s was altered (entryHash does not match its content)", ErrLedgerBroken, entry.Filename)
+	}
+	if prevHash != "" && entry.PrevHash != prevHash {
+		return entry, fmt.Errorf("%w: entry for %s does not follow the previous entry (an entry was removed, inserted or reordered)", ErrLedgerBroken, entry.Filename)
+	}
+	return entry, nil
+}
+
+// LedgerLogger appends a hash-chained entry for every verified file
+type LedgerLogger struct {
+	mutex    sync.Mutex
+	file     *os.File
+	lastHash string // entryHash of the newest entry
+}
+
+// NewLedgerLogger opens the ledger for appending and continues its chain
+func NewLedgerLogger(cfg LedgerConfig) (*LedgerLogger, error) {
+	file, err := os.OpenFile(cfg.File, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+
+	lastHash, err := ledgerLastHash(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", cfg.File, err)
+	}
+
+	return &LedgerLogger{file: file, lastHash: lastHash}, nil
+}
+
+// ledgerLastHash returns the entryHash of a ledger's last entry (the genesis hash when empty)
+func ledgerLastHash(file *os.File) (string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.Size() == 0 {
+		return ledgerGenesis, nil
+	}
+
+	offset := max(info.Size()-ledgerTailSize, 0)
+	tail := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read ledger: %w", err)
+	}
+	if !bytes.HasSuffix(tail, []byte("\n")) {
+		return "", fmt.Errorf("%w: the last entry is incomplete", ErrLedgerBroken)
+	}
+	tail = tail[:len(tail)-1]
+	last := tail[bytes.LastIndexByte(tail, '\n')+1:]
+
+	entry, err := parseLedgerEntry(last, "")
+	if err != nil {
+		return "", err
+	}
+	return entry.EntryHash, nil
+}
+
+// LogVerification appends the verified file to the chain and syncs it to disk
+func (l *LedgerLogger) LogVerification(entry CSVLogEntry) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	record := ledgerEntry{
+		PrevHash:  l.lastHash,
+		Filename:  entry.Filename,
+		FileHash:  entry.SHA256,
+		Timestamp: entry.Timestamp,
+	}
+	record.EntryHash = record.computeHash()
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode ledger entry: %w", err)
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append ledger entry: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync ledger: %w", err)
+	}
+
+	l.lastHash = record.EntryHash
+	return nil
+}
+
+// LogStats does nothing, the ledger only records verified files
+func (l *LedgerLogger) LogStats(entry StatsEntry) error {
+	return nil
+}
+
+// Close closes the ledger file
+func (l *LedgerLogger) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.file.Close()
+}
+
+// walkLedger checks every entry and link of a ledger in order
+// Returns the number of intact entries and, for a broken chain, the line of the first broken link
+func walkLedger(r io.Reader) (int, int, error) {
+	reader := bufio.NewReader(r)
+	prevHash := ledgerGenesis
+	entries := 0
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if len(line) == 0 && errors.Is(err, io.EOF) {
+			return entries, 0, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return entries, 0, fmt.Errorf("failed to read ledger: %w", err)
+		}
+		if !bytes.HasSuffix(line, []byte("\n")) {
+			return entries, lineNumber, fmt.Errorf("%w: the last entry is incomplete", ErrLedgerBroken)
+		}
+
+		entry, err := parseLedgerEntry(bytes.TrimSuffix(line, []byte("\n")), prevHash)
+		if err != nil {
+			return entries, lineNumber, err
+		}
+		prevHash = entry.EntryHash
+		entries++
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// validLogLevels lists the accepted logging levels
+var validLogLevels = map[string]bool{"DEBUG": true, "INFO": true, "WARN": true, "ERROR": true}
+
+// LogLevel holds the active logging level shared by all components
+// It can be changed at runtime and takes effect immediately everywhere
+type LogLevel struct {
+	value atomic.Value // string
+}
+
+// NewLogLevel creates a shared log level holder
+func NewLogLevel(level string) *LogLevel {
+	l := &LogLevel{}
+	l.value.Store(level)
+	return l
+}
+
+// Get returns the current logging level
+func (l *LogLevel) Get() string {
+	return l.value.Load().(string)
+}
+
+// Set changes the logging level after validating it
+func (l *LogLevel) Set(level string) error {
+	if !validLogLevels[level] {
+		return fmt.Errorf("invalid log level %q: must be one of DEBUG, INFO, WARN, ERROR", level)
+	}
+	l.value.Store(level)
+	return nil
+}
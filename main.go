@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"regexp"
+	"sort"
 	"syscall"
 	"time"
 )
@@ -15,25 +18,54 @@ Main application entry point.
 
 Orchestrates all components:
 1. Load configuration
-2. Initialize CSV logger, statistics tracker, file tracker
+2. Initialize result logger, statistics tracker, file tracker
 3. Start file scanner
 4. Start worker pool
 5. Run coordinator loop that:
    - Submits ready files to worker pool
    - Handles expired files (move to DLQ)
    - Logs periodic statistics
-6. Handle graceful shutdown on SIGINT/SIGTERM
+6. Handle graceful shutdown on SIGINT/SIGTERM, or after maxRuntime
+   (listing the files still pending and exiting with code 124), or with
+   --once as soon as every file found has been delivered or failed
+7. Reload destination folders on SIGHUP
 */
 
+// coordinatorInterval is how often the coordinator submits ready files
+const coordinatorInterval = 1 * time.Second
+
+// statsInterval is how often the coordinator logs statistics (and pushes them to StatsD)
+const statsInterval = 30 * time.Second
+
 // Build-time variables injected via -ldflags during compilation
 var (
 	version   string // Application version (e.g., "v1.0.0")
 	buildTime string // Build timestamp in ISO 8601 format
 	buildID   string // SHA256 hash of the compiled binary
 	release   string // Release type: PRODUCTION, DEVELOPMENT, etc.
+
+	releaseNotesHash string // SHA256 of the .RN.yaml file shipped with the binary (optional)
 )
 
+// exitMaxRuntime is the exit code when maxRuntime stopped the service (as timeout(1) uses)
+const exitMaxRuntime = 124
+
+// exitFilesFailed is the exit code when a file failed during the run (exitNonZeroOnAnyFailure)
+const exitFilesFailed = 2
+
+// maxRuntimeShutdownGrace bounds the graceful shutdown after maxRuntime, a hung file can't hold it
+const maxRuntimeShutdownGrace = 30 * time.Second
+
 func main() {
+	startTime := time.Now()
+
+	// Runs after every other deferred cleanup, so results are flushed before a nonzero exit
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
 
 	// Default to DEVELOPMENT if not set at build time
 	if release == "" {
@@ -54,12 +86,40 @@ func main() {
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  %s                          # Run with config.yaml from current directory\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s --config /path/to/config.yaml\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --profile prod           # Merge config.prod.yaml onto config.yaml\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s --version                # Show version information\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --once                   # Verify the files in the source folder, then exit\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --max-runtime 2h         # Stop after 2 hours (exit code 124)\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --fail-on-any-failure    # Exit with code 2 if any file failed\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --release-notes-dir /opt/app # Look for *.RN.yaml there\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s verify data.zip [hash]   # Check one file (use - for stdin)\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s verify-batch list.csv    # Check the files in a path,expected_hash CSV\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s verify-ledger ledger.jsonl # Check the hash chain of a ledger\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s check-config --config c.yaml # Validate a configuration and exit\n", os.Args[0])
+	}
+
+	// Ad-hoc subcommands bypass the service entirely
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(runVerifyCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify-batch" {
+		os.Exit(runVerifyBatchCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify-ledger" {
+		os.Exit(runVerifyLedgerCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check-config" {
+		os.Exit(runCheckConfigCommand(os.Args[2:]))
 	}
 
 	// Define flags
 	configFile := flag.String("config", "config.yaml", "Path to configuration file (default: config.yaml in current directory)")
+	profile := flag.String("profile", "", "Merge the profile's overrides onto the configuration file (e.g. prod reads config.prod.yaml)")
 	showVersion := flag.Bool("version", false, "Print version information and exit")
+	once := flag.Bool("once", false, "Verify the files in the source folder, then exit (exit code 2 if any failed)")
+	maxRuntime := flag.Duration("max-runtime", 0, "Stop after running this long and exit with code 124 (overrides spec.maxRuntime)")
+	failOnAnyFailure := flag.Bool("fail-on-any-failure", false, "Exit with code 2 after shutdown if any file failed (sets spec.exitNonZeroOnAnyFailure)")
+	releaseNotesDir := flag.String("release-notes-dir", "", "Directory holding the release notes (overrides "+releaseNotesDirEnv+" and spec.releaseNotesDir)")
 	flag.BoolVar(showVersion, "v", false, "Print version information and exit (shorthand)")
 	flag.Parse()
 
@@ -72,9 +132,23 @@ func main() {
 
 	PrintVersionInfo("Go FTP Transfer Service", release, version, buildTime, buildID)
 
+	// Load configuration (folders are only touched once the release is validated)
+	config, err := ParseConfig(*configFile, *profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
 	if release == "PRODUCTION" {
 		fmt.Println("\nValidating release version...")
-		if err := ValidateVersion(version, buildTime, buildID); err != nil {
+		rnDir := *releaseNotesDir
+		if rnDir == "" {
+			rnDir = os.Getenv(releaseNotesDirEnv)
+		}
+		if rnDir == "" {
+			rnDir = config.Spec.ReleaseNotesDir
+		}
+		if err := ValidateVersion(version, buildTime, buildID, releaseNotesHash, rnDir); err != nil {
 			fmt.Fprintf(os.Stderr, "ERROR: Release verification failed\n%v\n", err)
 			fmt.Fprintln(os.Stderr, "\nThis indicates a potential version mismatch or tampering.")
 			fmt.Fprintln(os.Stderr, "Please ensure you're running the correct binary with matching release notes.")
@@ -85,67 +159,384 @@ func main() {
 		fmt.Printf("\nRunning in %s mode - Skipping version validation\n", release)
 	}
 
-	// Load configuration
-	config, err := LoadConfig(*configFile)
-	if err != nil {
+	if err := PrepareFolders(config); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
 		os.Exit(1)
 	}
 
+	if *maxRuntime > 0 {
+		config.Spec.MaxRuntime = *maxRuntime
+	}
+	// A batch run reports failed files in its exit code
+	if *failOnAnyFailure || *once {
+		config.Spec.ExitNonZeroOnAnyFailure = true
+	}
+
 	// Print configuration
 	PrintConfig(config)
 
-	// Initialize CSV logger
-	csvLogger, err := NewCSVLogger(
-		config.Spec.Output.VerificationFile,
-		config.Spec.Output.StatsFile,
-		config.Spec.Output.FlushInterval,
-	)
+	// Initialize tracing (no-op unless enabled)
+	shutdownTracing, err := InitTracing(config.Spec.Tracing)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize tracing: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Initialize result logger (CSV, syslog, ...)
+	resultLogger, err := NewResultLogger(config.Spec.Output, config.Spec.Database)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create result logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer resultLogger.Close()
+
+	// Expected hashes from the database or Redis instead of .sha256 files (optional)
+	var hashProvider ExpectedHashProvider
+	var redisProvider *RedisHashProvider
+	if config.Spec.Verification.ExpectedHashSource == HashSourceDatabase {
+		provider, err := NewDatabaseHashProvider(config.Spec.Database)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create expected hash provider: %v\n", err)
+			os.Exit(1)
+		}
+		defer provider.Close()
+		hashProvider = provider
+	} else if config.Spec.Verification.ExpectedHashSource == HashSourceRedis {
+		redisProvider = NewRedisHashProvider(config.Spec.Redis)
+		defer redisProvider.Close()
+		hashProvider = redisProvider
+	}
+
+	// Known-good hashes trusted without hashing (optional)
+	var knownHashes *KnownHashes
+	if config.Spec.Verification.TrustKnownHashes {
+		knownHashes, err = LoadKnownHashes(config.Spec.Verification.KnownHashesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load known hashes: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Optional components, handed to the scanner, workers and other sources
+	var features Features
+
+	// Known-bad hashes quarantined on sight (optional)
+	if config.Spec.Verification.DenyListFile != "" {
+		features.DenyList, err = LoadDenyList(config.Spec.Verification.DenyListFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load deny-list: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Sidecar signatures checked before their hash is trusted (optional)
+	if config.Spec.Verification.Signature.PublicKeyFile != "" {
+		features.Signatures, err = LoadSignatureVerifier(config.Spec.Verification.Signature)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load signature key: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Content verified earlier in this run, trusted without hashing (optional)
+	var verdicts *VerdictCache
+	if config.Spec.Verification.VerdictCacheSize > 0 {
+		verdicts = NewVerdictCache(config.Spec.Verification.VerdictCacheSize)
+	}
+
+	// File system integrity backend consulted before re-hashing a file
+	integrity, err := NewIntegrityOracle(config.Spec.Verification.IntegrityOracle)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create CSV logger: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to set up integrity oracle: %v\n", err)
 		os.Exit(1)
 	}
-	defer csvLogger.Close()
+
+	// Hard links of the same file share one hash (optional)
+	if config.Spec.Verification.InodeDedup {
+		features.InodeDedup = NewInodeDedup(config.Spec.Verification.RetryTimeout)
+	}
+
+	// Shared log level, adjustable at runtime through the API
+	logLevel := NewLogLevel(config.Spec.Logging.Level)
+
+	// Pause scanning and submission during maintenance windows (optional)
+	if len(config.Spec.Maintenance.Windows) > 0 {
+		features.Maintenance, err = NewMaintenanceSchedule(config.Spec.Maintenance, logLevel)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to set up maintenance windows: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Push statistics to StatsD (optional)
+	var statsdEmitter *StatsDEmitter
+	if config.Spec.StatsD.Enabled {
+		statsdEmitter, err = NewStatsDEmitter(config.Spec.StatsD, logLevel)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to set up StatsD: %v\n", err)
+			os.Exit(1)
+		}
+		defer statsdEmitter.Close()
+	}
+
+	// Stream the same records to /events clients (optional)
+	var events *EventHub
+	if config.Spec.API.Enabled && config.Spec.API.Events {
+		events = NewEventHub(config.Spec.API.EventBuffer, logLevel)
+		resultLogger = &MultiLogger{loggers: []ResultLogger{resultLogger, events}}
+	}
+
+	// How data files are read for hashing, bounding the memory of the bspatch transform
+	features.Hasher = &FileHasher{BSPatchMaxBytes: config.Spec.Verification.BSPatchMaxBytes}
+
+	// Checkpointed hashing of very large files (optional)
+	if checkpoint := config.Spec.Verification.Checkpoint; checkpoint.Folder != "" {
+		checkpointer, err := NewCheckpointer(checkpoint.Folder, checkpoint.MinSizeBytes, checkpoint.IntervalBytes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create checkpointer: %v\n", err)
+			os.Exit(1)
+		}
+		if pruned := checkpointer.Prune(); pruned > 0 && (logLevel.Get() == "DEBUG" || logLevel.Get() == "INFO") {
+			fmt.Printf("[Main] Removed %d outdated hash checkpoints\n", pruned)
+		}
+		features.Hasher.Checkpoints = checkpointer
+	}
+
+	// Re-verify pairs left in the source when sampling shows they changed (optional)
+	if config.Spec.Verification.ChangeDetector.Enabled {
+		features.ChangeDetector = NewChangeDetector(config.Spec.Verification.ChangeDetector, logLevel)
+	}
+
+	// Large reads for data files on object-store mounts (optional)
+	if config.Spec.Verification.LargeRead.Enabled {
+		features.Hasher.LargeReads = NewLargeReads(config.Spec.Verification.LargeRead)
+	}
+
+	// Links to the newest verified file of each kind (optional)
+	if len(config.Spec.Destination.LatestLinks) > 0 {
+		features.LatestLinks = NewLatestLinks(config.Spec.Destination.LatestLinks, logLevel)
+	}
+
+	// Per-chunk verification of resumable uploads (optional)
+	if config.Spec.Verification.ChunkManifest.Enabled {
+		features.ChunkManifests = NewChunkManifests(config.Spec.Verification.ChunkManifest)
+	}
 
 	// Initialize statistics tracker
 	statsTracker := NewStatsTracker()
 
-	// Initialize file tracker
-	fileTracker := NewFileTracker(config.Spec.Verification.RetryTimeout)
+	// Finish or roll back deliveries a crash interrupted, then record new ones (optional)
+	if config.Spec.Destination.IntentLog != "" {
+		if err := RecoverIntentLog(config.Spec.Destination.IntentLog, config.Spec.Source.Folder, features, resultLogger, statsTracker); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to recover intent log: %v\n", err)
+			os.Exit(1)
+		}
+		intentLog, err := NewIntentLog(config.Spec.Destination.IntentLog)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open intent log: %v\n", err)
+			os.Exit(1)
+		}
+		defer intentLog.Close()
+		features.IntentLog = intentLog
+	}
 
-	// Initialize file scanner
-	scanner := NewFileScanner(
-		config.Spec.Source.Folder,
+	// Initialize file tracker
+	fileTracker := NewFileTracker(
+		config.Spec.Verification.RetryTimeout,
 		config.Spec.Source.PeriodicScanInterval,
-		config.Spec.Verification.FileFilters,
-		fileTracker,
-		config.Spec.Logging.Level,
+		config.Spec.Verification.SidecarSuffixes,
+		config.Spec.Verification.ReverifyOnSidecarChange,
+		config.Spec.Verification.CaseInsensitive,
 	)
 
+	// The tracker is the source of truth for pending files
+	statsTracker.SetPendingSource(func() int64 {
+		return int64(fileTracker.GetPendingCount())
+	})
+
+	// How long each half of a pair waited for the other (diagnoses upload ordering)
+	fileTracker.SetPairedObserver(func(pair FilePair, skew time.Duration) {
+		statsTracker.RecordArrivalSkew(skew)
+		if config.Spec.Logging.ArrivalSkew {
+			if skew >= 0 {
+				fmt.Printf("[Tracker] %s arrived %s after %s\n", pair.SHA256File, skew.Round(time.Millisecond), pair.DataFile)
+			} else {
+				fmt.Printf("[Tracker] %s arrived %s after %s\n", pair.DataFile, (-skew).Round(time.Millisecond), pair.SHA256File)
+			}
+		}
+	})
+
+	// Live view of the files being hashed (shared by workers and the API)
+	progress := NewProgressRegistry()
+
+	// Destination folders, replaceable at runtime (SIGHUP or the API)
+	destinations := NewDestinations(config.Spec.Source, config.Spec.Destination)
+
+	// Batch manifests (optional)
+	var manifests *ManifestRegistry
+	if manifest := config.Spec.Verification.Manifest; manifest.Name != "" {
+		manifests = NewManifestRegistry(manifest.Name, config.Spec.Source.Folder, manifest.Hierarchy,
+			config.Spec.Verification.HashEncoding, manifest.MissingTimeout)
+	}
+
+	// Initialize file scanner
+	scanner := NewFileScanner(FileScannerOptions{
+		SourceFolder:       config.Spec.Source.Folder,
+		ScanInterval:       config.Spec.Source.PeriodicScanInterval,
+		PreScanCommand:     config.Spec.Source.PreScanCommand,
+		PreScanTimeout:     config.Spec.Source.PreScanTimeout,
+		FileFilters:        config.Spec.Verification.FileFilters,
+		FilterMode:         config.Spec.Verification.FilterMode,
+		ExcludeFilters:     config.Spec.Verification.ExcludeFilters,
+		FilenameHash:       filenameHashPattern(config.Spec.Verification),
+		HashSource:         config.Spec.Verification.ExpectedHashSource,
+		ArchiveIntegrity:   config.Spec.Verification.ArchiveIntegrity,
+		EmbeddedChecksum:   config.Spec.Verification.EmbeddedChecksum.Patterns,
+		DuplicateSidecars:  config.Spec.Verification.DuplicateSidecars,
+		Recursive:          config.Spec.Source.Recursive,
+		Parallelism:        config.Spec.Source.ScanParallelism,
+		ExcludePatterns:    config.Spec.Source.ExcludePatterns,
+		InProgressSuffixes: config.Spec.Source.InProgressSuffixes,
+		InProgressPrefixes: config.Spec.Source.InProgressPrefixes,
+		MinModTime:         modTimeCutoff(config.Spec.Source, startTime),
+		Destinations:       destinations,
+		OnCollision:        config.Spec.Destination.OnCollision,
+		Manifests:          manifests,
+		Growing:            config.Spec.Source.Growing,
+		Features:           features,
+		Tracker:            fileTracker,
+		LogLevel:           logLevel,
+	})
+
+	// Growing files verified in place, left alone by the scanner (optional)
+	var growingFiles *GrowingFiles
+	if growing := config.Spec.Source.Growing; len(growing.Patterns) > 0 {
+		growingFiles, err = NewGrowingFiles(growing, scanner, resultLogger, statsTracker,
+			config.Spec.Verification.BufferSize, config.Spec.Verification.HashEncoding, logLevel)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to set up growing files: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Shared hashing slots, so the CPU spent hashing is bounded regardless of worker count
+	hashLimiter := NewHashLimiter(config.Spec.Concurrency.HashingSlots)
+
+	// Batch summaries (optional)
+	var batches *BatchTracker
+	if config.Spec.Output.Batches.GroupBy != "" {
+		batches, err = NewBatchTracker(config.Spec.Output.Batches, config.Spec.Source.Folder, logLevel)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create batch tracker: %v\n", err)
+			os.Exit(1)
+		}
+		defer batches.Close()
+	}
+
+	// Source paths are logged relative to the source folder unless full paths are asked for
+	sourcePathBase := config.Spec.Source.Folder
+	if config.Spec.Output.SourcePath == SourcePathFull {
+		sourcePathBase = ""
+	}
+
 	// Initialize worker pool
-	workerPool := NewWorkerPoolManager(
-		config.Spec.Concurrency.QueueSize,
-		config.Spec.Concurrency.Workers,
-		csvLogger,
-		statsTracker,
-		fileTracker,
-		config.Spec.Destination.VerifiedFolder,
-		config.Spec.Destination.DlqFolder,
-		config.Spec.Destination.RemoveFromSource,
-		config.Spec.Logging.Level,
-	)
+	workerPool := NewWorkerPoolManager(WorkerPoolOptions{
+		QueueSize:        config.Spec.Concurrency.QueueSize,
+		Workers:          config.Spec.Concurrency.Workers,
+		ResultLogger:     resultLogger,
+		StatsTracker:     statsTracker,
+		FileTracker:      fileTracker,
+		HashProvider:     hashProvider,
+		KnownHashes:      knownHashes,
+		Verdicts:         verdicts,
+		Integrity:        integrity,
+		Progress:         progress,
+		HashLimiter:      hashLimiter,
+		Batches:          batches,
+		SourceFolder:     config.Spec.Source.Folder,
+		SourcePathBase:   sourcePathBase,
+		Destinations:     destinations,
+		RemoveFromSource: config.Spec.Destination.RemoveFromSource,
+		OnCollision:      config.Spec.Destination.OnCollision,
+		ResultFiles:      config.Spec.Destination.ResultFiles,
+		CrashOnPanic:     config.Spec.Concurrency.CrashOnPanic,
+		StallTimeout:     config.Spec.Concurrency.StallTimeout,
+		Features:         features,
+		LogLevel:         logLevel,
+	})
+
+	// Initialize API server (optional)
+	var apiServer *APIServer
+	if config.Spec.API.Enabled {
+		apiServer = NewAPIServer(
+			config.Spec.API.ListenAddress,
+			fileTracker,
+			statsTracker,
+			progress,
+			hashLimiter,
+			destinations,
+			manifests,
+			redisProvider,
+			events,
+			features.Maintenance,
+			!config.Spec.API.DisableMetrics,
+			workerPool,
+			logLevel,
+		)
+	}
+
+	// Initialize tar stream source (optional)
+	var tarListener *TarListener
+	if config.Spec.Source.TarListener.Address != "" {
+		tarListener = NewTarListener(
+			config.Spec.Source.TarListener,
+			config.Spec.Verification,
+			destinations,
+			config.Spec.Destination.OnCollision,
+			resultLogger,
+			statsTracker,
+			hashLimiter,
+			features,
+			logLevel,
+		)
+	}
+
+	// Check the verified folder against the verification log before anything is delivered (optional)
+	if config.Spec.Output.Reconcile != ReconcileOff {
+		if err := ReconcileVerifiedFolder(config.Spec.Output.Reconcile, config.Spec.Output.VerificationFile, config.Spec.Destination.VerifiedFolder,
+			config.Spec.Verification.BufferSize, resultLogger, logLevel); err != nil {
+			fmt.Fprintf(os.Stderr, "[Reconcile] Failed to reconcile the verified folder: %v\n", err)
+		}
+	}
 
 	// Start components
 	scanner.Start()
 	workerPool.Start()
+	if apiServer != nil {
+		apiServer.Start()
+	}
+	if tarListener != nil {
+		if err := tarListener.Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start tar listener: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	fmt.Println("=== Application Started ===")
 	fmt.Printf("Press Ctrl+C to stop\n")
 	fmt.Println("===========================")
 
+	// Tell systemd we are ready once the source folder has been scanned (no-op outside systemd)
+	go func() {
+		<-scanner.FirstScanDone()
+		if err := sdNotify("READY=1"); err != nil {
+			fmt.Fprintf(os.Stderr, "[Main] Failed to notify systemd: %v\n", err)
+		}
+	}()
+
 	// Setup signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
 	// Create context for coordinator
 	ctx, cancel := context.WithCancel(context.Background())
@@ -153,11 +544,58 @@ func main() {
 
 	// Run coordinator in background
 	coordinatorDone := make(chan struct{})
-	go coordinator(ctx, config, fileTracker, workerPool, statsTracker, csvLogger, coordinatorDone)
+	go coordinator(ctx, config, fileTracker, scanner, workerPool, statsTracker, resultLogger, destinations, batches,
+		features, growingFiles, statsdEmitter, logLevel, coordinatorDone)
+
+	// Wait for shutdown signal or the runtime limit (nil channel never fires without one)
+	// SIGHUP reloads the destination folders, anything else shuts down
+	var deadline <-chan time.Time
+	if config.Spec.MaxRuntime > 0 {
+		deadline = time.After(config.Spec.MaxRuntime - time.Since(startTime))
+	}
+	// In batch mode (--once) the run ends when no file is left to verify
+	var batchDone <-chan struct{}
+	if *once {
+		batchDone = watchBatchDone(scanner, fileTracker)
+	}
+	timedOut := false
+wait:
+	for {
+		select {
+		case sig := <-sigChan:
+			if sig == syscall.SIGHUP {
+				reloadDestinations(*configFile, *profile, destinations)
+				continue
+			}
+			fmt.Println("\n[Main] Shutdown signal received, stopping gracefully...")
+		case <-deadline:
+			timedOut = true
+			fmt.Fprintf(os.Stderr, "\n[Main] Maximum runtime %s exceeded, stopping gracefully...\n", config.Spec.MaxRuntime)
+			reportPending(fileTracker)
+
+			// Files still queued are verified during shutdown, but not for longer than the grace period
+			time.AfterFunc(maxRuntimeShutdownGrace, func() {
+				fmt.Fprintf(os.Stderr, "[Main] Shutdown did not finish within %s, exiting\n", maxRuntimeShutdownGrace)
+				os.Exit(exitMaxRuntime)
+			})
+		case <-batchDone:
+			fmt.Println("\n[Main] All files processed, stopping (--once)...")
+		}
+		break wait
+	}
+	if err := sdNotify("STOPPING=1"); err != nil {
+		fmt.Fprintf(os.Stderr, "[Main] Failed to notify systemd: %v\n", err)
+	}
+
+	// Stop API server
+	if apiServer != nil {
+		apiServer.Stop()
+	}
 
-	// Wait for shutdown signal
-	<-sigChan
-	fmt.Println("\n[Main] Shutdown signal received, stopping gracefully...")
+	// Stop accepting tar streams
+	if tarListener != nil {
+		tarListener.Stop()
+	}
 
 	// Cancel coordinator context
 	cancel()
@@ -171,12 +609,121 @@ func main() {
 	// Stop worker pool
 	workerPool.Stop()
 
+	// Flush pending trace spans
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		fmt.Fprintf(os.Stderr, "[Main] Failed to flush traces: %v\n", err)
+	}
+	shutdownCancel()
+
 	// Final statistics
 	fmt.Println("\n=== Final Statistics ===")
 	statsTracker.PrintStatistics()
 	fmt.Println("========================")
 
 	fmt.Println("[Main] Shutdown complete")
+
+	if timedOut {
+		exitCode = exitMaxRuntime
+	} else if config.Spec.ExitNonZeroOnAnyFailure && statsTracker.HadFailures() {
+		fmt.Fprintf(os.Stderr, "[Main] Files failed during this run, exiting with code %d\n", exitFilesFailed)
+		exitCode = exitFilesFailed
+	}
+}
+
+// watchBatchDone returns a channel that is closed once the first scan has
+// finished and every file found since has been delivered or failed (--once)
+// Files arriving during the run are verified too; sources other than the
+// scanner (tar streams, growing files) are not waited for
+func watchBatchDone(scanner *FileScanner, fileTracker *FileTracker) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		<-scanner.FirstScanDone()
+		ticker := time.NewTicker(coordinatorInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if batchFinished(fileTracker.GetAllFiles()) {
+				close(done)
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// batchFinished reports whether none of the tracked pairs still needs verifying
+// Pairs left in place by the skip policy are never retried, so they don't count
+func batchFinished(tracked []FilePair) bool {
+	for _, pair := range tracked {
+		if !pair.Skipped {
+			return false
+		}
+	}
+	return true
+}
+
+// reportPending lists the files still tracked when maxRuntime stops the service
+func reportPending(fileTracker *FileTracker) {
+	pending := fileTracker.GetAllFiles()
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Key < pending[j].Key })
+
+	fmt.Fprintf(os.Stderr, "[Main] %d files still pending\n", len(pending))
+	for _, pair := range pending {
+		var state string
+		switch {
+		case pair.InFlight:
+			state = "being verified"
+		case pair.Skipped:
+			state = "left in place, destination exists"
+		case pair.DataFilePath == "":
+			state = "waiting for the data file"
+		case !pair.HasBothFiles:
+			state = "waiting for the sidecar"
+		case pair.RetryCount > 0:
+			state = fmt.Sprintf("%d failed attempts, last: %s", pair.RetryCount, pair.LastError)
+		default:
+			state = "waiting to be verified"
+		}
+		fmt.Fprintf(os.Stderr, "[Main]   %s (%s)\n", pair.Key, state)
+	}
+}
+
+// modTimeCutoff returns the modification time before which files are ignored
+// The later of minModTime and (with startFromNow) the process start time wins
+func modTimeCutoff(source SourceConfig, startTime time.Time) time.Time {
+	cutoff := source.MinModTime
+	if source.StartFromNow && startTime.After(cutoff) {
+		cutoff = startTime
+	}
+	return cutoff
+}
+
+// reloadDestinations re-reads the configuration file (and profile) and switches to its destination folders
+// Other settings require a restart; on any error the current folders are kept
+func reloadDestinations(configPath, profile string, destinations *Destinations) {
+	config, err := LoadConfig(configPath, profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[Main] Reload failed, keeping current destinations: %v\n", err)
+		return
+	}
+
+	folders := destinationFolders(config.Spec.Destination)
+	if err := destinations.Update(folders); err != nil {
+		fmt.Fprintf(os.Stderr, "[Main] Reload failed, keeping current destinations: %v\n", err)
+		return
+	}
+
+	fmt.Printf("[Main] Destinations reloaded: verified=%s dlq=%s quarantine=%s emptySidecar=%s mispaired=%s\n",
+		folders.Verified, folders.DLQ, folders.Quarantine, folders.EmptySidecar, folders.Mispaired)
+}
+
+// filenameHashPattern compiles verification.filenameHashPattern (nil when not set)
+// The pattern was already checked by validateConfig
+func filenameHashPattern(verification VerificationConfig) *regexp.Regexp {
+	if verification.FilenameHashPattern == "" {
+		return nil
+	}
+	return regexp.MustCompile(verification.FilenameHashPattern)
 }
 
 // coordinator is the main control loop that submits jobs and handles timeouts
@@ -184,68 +731,221 @@ func coordinator(
 	ctx context.Context,
 	config *Config,
 	fileTracker *FileTracker,
+	scanner *FileScanner,
 	workerPool *WorkerPoolManager,
 	statsTracker *StatsTracker,
-	csvLogger *CSVLogger,
+	resultLogger ResultLogger,
+	destinations *Destinations,
+	batches *BatchTracker,
+	features Features,
+	growingFiles *GrowingFiles,
+	statsdEmitter *StatsDEmitter,
+	logLevel *LogLevel,
 	done chan struct{},
 ) {
 	defer close(done)
 
-	// Coordinator runs every 1 second
-	ticker := time.NewTicker(1 * time.Second)
+	// Coordinator runs every coordinatorInterval
+	ticker := time.NewTicker(coordinatorInterval)
 	defer ticker.Stop()
 
 	// Stats logging ticker
-	statsTicker := time.NewTicker(30 * time.Second)
+	statsTicker := time.NewTicker(statsInterval)
 	defer statsTicker.Stop()
 
+	// Heartbeat ticker (optional, nil channel never fires when disabled)
+	var heartbeatChan <-chan time.Time
+	if config.Spec.Logging.HeartbeatInterval > 0 {
+		heartbeatTicker := time.NewTicker(config.Spec.Logging.HeartbeatInterval)
+		defer heartbeatTicker.Stop()
+		heartbeatChan = heartbeatTicker.C
+	}
+	lastHeartbeatProcessed := int64(0)
+
+	// systemd watchdog keepalives (only when WATCHDOG_USEC is set)
+	var watchdogChan <-chan time.Time
+	if interval := sdWatchdogInterval(); interval > 0 {
+		watchdogTicker := time.NewTicker(interval)
+		defer watchdogTicker.Stop()
+		watchdogChan = watchdogTicker.C
+	}
+
+	// Verified-folder and DLQ retention janitors (optional)
+	var janitor, dlqJanitor *Janitor
+	var janitorChan <-chan time.Time
+	retention := config.Spec.Destination.Retention
+	if retention.MaxAge > 0 || retention.MaxSizeBytes > 0 {
+		janitor = NewVerifiedJanitor(
+			destinations,
+			retention.MaxAge,
+			retention.MaxSizeBytes,
+			statsTracker,
+			logLevel,
+		)
+	}
+	if config.Spec.Destination.DLQRetention.enabled() {
+		dlqJanitor = NewDLQJanitor(destinations, config.Spec.Destination.DLQRetention, statsTracker, logLevel)
+	}
+	if janitor != nil || dlqJanitor != nil {
+		janitorTicker := time.NewTicker(retention.CheckInterval)
+		defer janitorTicker.Stop()
+		janitorChan = janitorTicker.C
+	}
+
+	// Orphan sidecar janitor (optional)
+	var orphanJanitor *OrphanJanitor
+	var orphanChan <-chan time.Time
+	if orphanCleanup := config.Spec.Destination.OrphanCleanup; orphanCleanup.GracePeriod > 0 {
+		orphanJanitor = NewOrphanJanitor(orphanCleanup, scanner, fileTracker, destinations,
+			config.Spec.Destination.OnCollision, logLevel)
+		orphanTicker := time.NewTicker(orphanCleanup.CheckInterval)
+		defer orphanTicker.Stop()
+		orphanChan = orphanTicker.C
+	}
+
+	// Growing files verified in place (optional)
+	var growingChan <-chan time.Time
+	if growing := config.Spec.Source.Growing; len(growing.Patterns) > 0 {
+		growingTicker := time.NewTicker(growing.CheckInterval)
+		defer growingTicker.Stop()
+		growingChan = growingTicker.C
+	}
+
+	// Stall watchdog (optional), checks a few times per timeout
+	var stallChan <-chan time.Time
+	if stallTimeout := config.Spec.Concurrency.StallTimeout; stallTimeout > 0 {
+		stallTicker := time.NewTicker(max(stallTimeout/4, time.Second))
+		defer stallTicker.Stop()
+		stallChan = stallTicker.C
+	}
+
+	// Blocking submission (optional) waits on its own goroutine, stopped with ctx
+	var feeder *SubmitFeeder
+	if config.Spec.Concurrency.SubmitMode == SubmitModeBlocking {
+		feeder = StartSubmitFeeder(ctx, workerPool, fileTracker, config.Spec.Concurrency.SubmitWarnAfter, logLevel)
+		defer feeder.Wait()
+	}
+
 	retryTimeout := config.Spec.Verification.RetryTimeout
 	bufferSize := config.Spec.Verification.BufferSize
-	logLevel := config.Spec.Logging.Level
 
 	for {
 		select {
 		case <-ticker.C:
-			// Get files ready for verification
-			readyFiles := fileTracker.GetReadyForVerification()
+			// A read-only destination pauses submission until it is writable again
+			if readOnly := destinations.ReadOnly(); readOnly != "" && destinations.RecheckReadOnly() {
+				if logLevel.Get() == "INFO" || logLevel.Get() == "DEBUG" {
+					fmt.Printf("[Coordinator] %s is writable again, resuming verification\n", readOnly)
+				}
+			}
+
+			// Get files ready for verification (none while a maintenance window is open)
+			var readyFiles []FilePair
+			if destinations.ReadOnly() == "" && !features.Maintenance.Paused() {
+				readyFiles = fileTracker.GetReadyForVerification()
+			}
 
-			if logLevel == "DEBUG" && len(readyFiles) > 0 {
+			if logLevel.Get() == "DEBUG" && len(readyFiles) > 0 {
 				fmt.Printf("[Coordinator] Found %d files ready for verification\n", len(readyFiles))
 			}
 
-			// Submit verification jobs
+			// Submit verification jobs, tracking files the full queue keeps out
+			var queueStalled int64
+			var queueWaitMax time.Duration
 			for _, filePair := range readyFiles {
 				// Calculate retry deadline based on first seen time
 				retryDeadline := filePair.FirstSeen.Add(retryTimeout)
 
-				// Create verification job
+				// Create verification job with its trace span
+				traceCtx, span := startJobSpan(filePair)
 				job := VerificationJob{
-					FilePair:      filePair,
-					RetryDeadline: retryDeadline,
-					BufferSize:    bufferSize,
+					FilePair:           filePair,
+					RetryDeadline:      retryDeadline,
+					BufferSize:         bufferSize,
+					HashEncoding:       config.Spec.Verification.HashEncoding,
+					AnyMatch:           config.Spec.Verification.AnyMatch,
+					ChecksumMember:     config.Spec.Verification.EmbeddedChecksum.Member,
+					CheckFilename:      config.Spec.Verification.CheckSidecarFilename,
+					Transforms:         transformsFor(config.Spec.Verification.Transforms, filePair.DataFile),
+					ShadowAlgorithm:    config.Spec.Verification.ShadowAlgorithm,
+					ConfirmDelivery:    config.Spec.Destination.ConfirmDelivery,
+					SidecarDeleteDelay: config.Spec.Destination.SidecarDeleteDelay,
+					DetectSparse:       config.Spec.Verification.DetectSparse,
+					DetectAlgorithm:    config.Spec.Verification.DetectAlgorithm,
+					PreserveSparse:     config.Spec.Destination.PreserveSparse,
+					NamingTemplate:     config.Spec.Destination.NamingTemplate,
+					CompressLevel:      compressionFor(config.Spec.Destination.Compress, filePair.DataFile),
+					SubmittedAt:        time.Now(),
+					TraceContext:       traceCtx,
+				}
+
+				// Prevent resubmission while the job is queued or running
+				fileTracker.MarkInFlight(filePair.Key)
+
+				// In blocking mode the feeder waits for room in the queue, in order
+				if feeder != nil {
+					feeder.Submit(job, span)
+					continue
 				}
 
 				// Submit job to worker pool
 				if !workerPool.SubmitJob(job) {
-					if logLevel == "WARN" || logLevel == "DEBUG" {
+					waited, warn := fileTracker.RecordQueueFull(filePair.Key, config.Spec.Concurrency.SubmitWarnAfter)
+					endSpan(span, fmt.Errorf("worker queue full"))
+					if logLevel.Get() == "WARN" || logLevel.Get() == "DEBUG" {
 						fmt.Fprintf(os.Stderr, "[Coordinator] Worker queue full, job for %s will retry later\n", filePair.DataFile)
+						if warn {
+							fmt.Fprintf(os.Stderr, "[Coordinator] %s has been waiting %s to enter the queue, workers can't keep up\n",
+								filePair.DataFile, waited.Round(time.Second))
+						}
+					}
+					if waited >= config.Spec.Concurrency.SubmitWarnAfter {
+						queueStalled++
+					}
+					if waited > queueWaitMax {
+						queueWaitMax = waited
 					}
+				} else if !filePair.QueueBlocked.IsZero() {
+					fileTracker.MarkQueued(filePair.Key)
 				}
 			}
+			statsTracker.SetQueueWait(queueStalled, queueWaitMax)
+
+			// Delete the sidecars of deliveries that stayed intact through their grace period
+			completeDeferredDeletions(fileTracker, config.Spec.Source.Folder, features, logLevel)
+
+			// Set aside sidecars whose data file never arrived
+			if config.Spec.Destination.OrphanSidecars != OrphanSidecarsLeave {
+				expireOrphanSidecars(fileTracker, destinations, config.Spec.Destination.OrphanSidecars,
+					config.Spec.Destination.OnCollision, logLevel)
+			}
 
 			// Update pending count in statistics
 			pendingCount := int64(fileTracker.GetPendingCount())
 			statsTracker.SetPendingCount(pendingCount)
 
+			// Report batches whose files have all been processed
+			if batches != nil {
+				batches.ReportCompleted(fileTracker.GetAllFiles())
+			}
+
 		case <-statsTicker.C:
+			// Self-check: the cached pending count should match the tracker
+			if cached, actual := statsTracker.ReconcilePending(); cached != actual {
+				if logLevel.Get() == "WARN" || logLevel.Get() == "DEBUG" {
+					fmt.Fprintf(os.Stderr, "[Coordinator] Pending count drift: stats=%d tracker=%d\n", cached, actual)
+				}
+			}
+
 			// Log periodic statistics
 			stats := statsTracker.GetStatistics()
 			statsEntry := CreateStatsEntry(stats)
-			if err := csvLogger.LogStats(statsEntry); err != nil {
+			if err := resultLogger.LogStats(statsEntry); err != nil {
 				fmt.Fprintf(os.Stderr, "[Coordinator] Failed to log stats: %v\n", err)
 			}
+			statsdEmitter.Emit(stats, workerPool.GetQueueLength())
 
-			if logLevel == "INFO" || logLevel == "DEBUG" {
+			if logLevel.Get() == "INFO" || logLevel.Get() == "DEBUG" {
 				fmt.Printf("[Stats] Processed: %d | Success: %d | Failed: %d | Pending: %d | Queue: %d/%d\n",
 					stats.TotalProcessed,
 					stats.SuccessCount,
@@ -256,12 +956,137 @@ func coordinator(
 				)
 			}
 
+		case now := <-heartbeatChan:
+			// Report that the service is alive, even when idle
+			stats := statsTracker.GetStatistics()
+			fmt.Printf("[Heartbeat] alive, pending=%d, queue=%d, processed=%d since last heartbeat\n",
+				fileTracker.GetPendingCount(),
+				workerPool.GetQueueLength(),
+				stats.TotalProcessed-lastHeartbeatProcessed,
+			)
+			lastHeartbeatProcessed = stats.TotalProcessed
+			statsTracker.SetLastHeartbeat(now)
+
+		case <-watchdogChan:
+			// Sent from the coordinator loop so a stalled loop lets systemd restart us
+			if err := sdNotify("WATCHDOG=1"); err != nil && logLevel.Get() == "DEBUG" {
+				fmt.Fprintf(os.Stderr, "[Coordinator] Failed to send watchdog ping: %v\n", err)
+			}
+
+		case <-janitorChan:
+			// Purge in the background so a large folder never delays submissions
+			if janitor != nil {
+				go janitor.Run()
+			}
+			if dlqJanitor != nil {
+				go dlqJanitor.Run()
+			}
+
+		case <-orphanChan:
+			go orphanJanitor.Run()
+
+		case <-growingChan:
+			if !features.Maintenance.Paused() {
+				go growingFiles.Run()
+			}
+
+		case <-stallChan:
+			workerPool.RestartStalled()
+
 		case <-ctx.Done():
 			// Shutdown signal received
-			if logLevel == "DEBUG" || logLevel == "INFO" {
+			if logLevel.Get() == "DEBUG" || logLevel.Get() == "INFO" {
 				fmt.Println("[Coordinator] Stopping...")
 			}
 			return
 		}
 	}
 }
+
+// completeDeferredDeletions checks the delivered file of each pair whose sidecar
+// deletion is due: an intact delivery has its sidecar deleted, a damaged one is
+// moved back to the source with its sidecar so the pair is verified again, and
+// for a vanished one the sidecar is kept (it is handled as an orphan sidecar)
+func completeDeferredDeletions(fileTracker *FileTracker, sourceFolder string, features Features, logLevel *LogLevel) {
+	for _, deletion := range fileTracker.TakeDueDeletions() {
+		pair := deletion.Pair
+
+		err := checkDelivery(deletion)
+		if err == nil {
+			features.deleteSourceCompanions(pair, deletion.Transforms, sourceFolder, "[Coordinator]")
+			if logLevel.Get() == "DEBUG" {
+				fmt.Printf("[Coordinator] Delivery of %s confirmed, deleted %s\n", pair.DataFile, pair.SHA256File)
+			}
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "[Coordinator] CRITICAL: delivery of %s failed after verification: %v, keeping %s\n",
+			pair.DataFile, err, pair.SHA256File)
+
+		// Bring a damaged delivery back unless a new upload took its place
+		// (a compressed one stays, it is not the file that was verified)
+		if !deletion.Compressed && FileExists(deletion.DeliveredPath) && !FileExists(pair.DataFilePath) {
+			if err := moveFile(deletion.DeliveredPath, pair.DataFilePath); err != nil {
+				fmt.Fprintf(os.Stderr, "[Coordinator] Failed to move %s back to the source: %v\n", deletion.DeliveredPath, err)
+			} else if logLevel.Get() == "WARN" || logLevel.Get() == "DEBUG" {
+				fmt.Fprintf(os.Stderr, "[Coordinator] Moved %s back to %s for re-verification\n", deletion.DeliveredPath, pair.DataFilePath)
+			}
+		}
+	}
+}
+
+// checkDelivery confirms a delivered file is still there, complete and (with a check) intact
+func checkDelivery(deletion DeferredDeletion) error {
+	info, err := os.Stat(deletion.DeliveredPath)
+	if err != nil {
+		return fmt.Errorf("delivered file unavailable: %w", err)
+	}
+	if deletion.Compressed {
+		size, err := gzipOriginalSize(deletion.DeliveredPath)
+		if err != nil {
+			return fmt.Errorf("delivered file unreadable: %w", err)
+		}
+		if size != uint32(deletion.Pair.DataSize) {
+			return fmt.Errorf("delivered file decompresses to %d bytes (mod 2^32), verified %d", size, deletion.Pair.DataSize)
+		}
+		return deletion.Check.confirm(deletion.DeliveredPath)
+	}
+	if info.Size() != deletion.Pair.DataSize {
+		return fmt.Errorf("delivered file is %d bytes, verified %d", info.Size(), deletion.Pair.DataSize)
+	}
+	return deletion.Check.confirm(deletion.DeliveredPath)
+}
+
+// expireOrphanSidecars moves sidecars whose data file has not arrived within the
+// retry timeout to the DLQ or quarantine folder, and stops tracking them
+func expireOrphanSidecars(fileTracker *FileTracker, destinations *Destinations, action, onCollision string, logLevel *LogLevel) {
+	for _, pair := range fileTracker.GetExpiredSidecars() {
+		// The data file may have arrived since the last scan
+		if FileExists(pair.dataPath()) {
+			continue
+		}
+
+		// Quarantine falls back to the DLQ if the quarantine folder was cleared at runtime
+		folders := destinations.Get()
+		folder := folders.DLQ
+		if action == OrphanSidecarsQuarantine && folders.Quarantine != "" {
+			folder = folders.Quarantine
+		}
+		_, err := MoveToFolder(pair.SHA256Path, folder, onCollision)
+		if err != nil && !errors.Is(err, ErrCollisionSkipped) {
+			fmt.Fprintf(os.Stderr, "[Coordinator] Failed to move orphan sidecar %s: %v\n", pair.SHA256File, err)
+			continue
+		}
+
+		fileTracker.Remove(pair.Key)
+		if logLevel.Get() == "WARN" || logLevel.Get() == "DEBUG" {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[Coordinator] Data file %s never arrived, left %s in place: %v\n",
+					pair.DataFile, pair.SHA256File, err)
+			} else {
+				fmt.Fprintf(os.Stderr, "[Coordinator] Data file %s never arrived, moved %s to %s\n",
+					pair.DataFile, pair.SHA256File, folder)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpireOrphanSidecars(t *testing.T) {
+	for _, action := range []string{OrphanSidecarsDLQ, OrphanSidecarsQuarantine} {
+		t.Run(action, func(t *testing.T) {
+			pool := newTestPool(t)
+			tracker := NewFileTracker(0, 0, []string{".sha256"}, false, false)
+			orphan := writeTestFile(t, pool.source, "lost.zip.sha256", dataSHA256)
+			arrived := writeTestFile(t, pool.source, "late.zip.sha256", dataSHA256)
+			tracker.AddOrUpdateSHA256File(orphan, 64, time.Now())
+			tracker.AddOrUpdateSHA256File(arrived, 64, time.Now())
+			// The data file of late.zip arrived after the last scan
+			writeTestFile(t, pool.source, "late.zip", "data")
+
+			expireOrphanSidecars(tracker, pool.destinations, action, CollisionRename, NewLogLevel("ERROR"))
+
+			folder := pool.folders.DLQ
+			if action == OrphanSidecarsQuarantine {
+				folder = pool.folders.Quarantine
+			}
+			if FileExists(orphan) || !FileExists(filepath.Join(folder, "lost.zip.sha256")) {
+				t.Errorf("orphan sidecar not moved to %s", folder)
+			}
+			if !FileExists(arrived) {
+				t.Error("sidecar whose data file arrived was moved")
+			}
+			if _, tracked := tracker.GetFilePair(tracker.keyOf(filepath.Join(pool.source, "lost.zip"))); tracked {
+				t.Error("orphan sidecar still tracked")
+			}
+		})
+	}
+}
+
+func TestBatchFinished(t *testing.T) {
+	if !batchFinished(nil) {
+		t.Error("no tracked files: batch not finished")
+	}
+	if !batchFinished([]FilePair{{Key: "a.zip", Skipped: true}}) {
+		t.Error("only skipped files: batch not finished")
+	}
+	if batchFinished([]FilePair{{Key: "a.zip", Skipped: true}, {Key: "b.zip", RetryCount: 1}}) {
+		t.Error("file waiting for a retry: batch finished")
+	}
+}
+
+func TestOrphanSidecarWaitsForRetryTimeout(t *testing.T) {
+	pool := newTestPool(t)
+	tracker := NewFileTracker(time.Hour, 0, []string{".sha256"}, false, false)
+	sidecar := writeTestFile(t, pool.source, "data.zip.sha256", dataSHA256)
+	tracker.AddOrUpdateSHA256File(sidecar, 64, time.Now())
+
+	if expired := tracker.GetExpiredSidecars(); len(expired) != 0 {
+		t.Errorf("expired %v before the retry timeout", expired)
+	}
+	expireOrphanSidecars(tracker, pool.destinations, OrphanSidecarsDLQ, CollisionRename, NewLogLevel("ERROR"))
+	if !FileExists(sidecar) {
+		t.Error("sidecar moved before the retry timeout")
+	}
+}
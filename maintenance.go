@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+MaintenanceSchedule pauses scanning and job submission during configured time
+windows, e.g. while nightly storage backups run (maintenance.windows).
+
+A window is "[days] HH:MM-HH:MM" in maintenance.timezone (default: local
+time), e.g. "01:00-03:30", "Sat,Sun 00:00-06:00" or "Mon-Fri 22:00-02:00".
+Days name the day a window starts on; a window whose end is not after its
+start runs past midnight.
+
+Responsibilities:
+1. Parse the windows at startup
+2. Tell the scanner and the coordinator whether a window is open now, and
+   log when a pause starts and ends
+3. Report the pause state and the next window for GET /maintenance
+
+Does NOT:
+- Stop jobs already queued or being hashed: they finish during the window
+- Forget tracked files: they are submitted once the window is over
+- Extend retry deadlines: a file whose retryTimeout ran out during the window
+  gets one more attempt afterwards before it goes to the DLQ
+*/
+
+// weekdayNames maps the day names accepted in maintenance windows
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// maintenanceWindow is one parsed maintenance window
+type maintenanceWindow struct {
+	spec     string
+	days     [7]bool // Days the window starts on, by time.Weekday
+	start    int     // Minutes after midnight
+	duration time.Duration
+}
+
+// MaintenanceSchedule tracks whether a maintenance window is open
+type MaintenanceSchedule struct {
+	windows  []maintenanceWindow
+	location *time.Location
+	logLevel *LogLevel
+
+	mutex  sync.Mutex
+	paused bool      // A window was open at the last check
+	window string    // The open window
+	until  time.Time // When the open window closes
+}
+
+// MaintenanceStatus is the JSON form of the schedule for GET /maintenance
+type MaintenanceStatus struct {
+	Windows   []string   `json:"windows"`
+	Timezone  string     `json:"timezone"`
+	Paused    bool       `json:"paused"`
+	Window    string     `json:"window,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	NextStart *time.Time `json:"nextStart,omitempty"`
+}
+
+// NewMaintenanceSchedule parses the maintenance windows
+func NewMaintenanceSchedule(cfg MaintenanceConfig, logLevel *LogLevel) (*MaintenanceSchedule, error) {
+	location := time.Local
+	if cfg.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(cfg.Timezone); err != nil {
+			return nil, fmt.Errorf("maintenance.timezone: %w", err)
+		}
+	}
+
+	schedule := &MaintenanceSchedule{location: location, logLevel: logLevel}
+	for _, spec := range cfg.Windows {
+		window, err := parseMaintenanceWindow(spec)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance.windows: %q: %w", spec, err)
+		}
+		schedule.windows = append(schedule.windows, window)
+	}
+	return schedule, nil
+}
+
+// parseMaintenanceWindow parses "[days] HH:MM-HH:MM"
+func parseMaintenanceWindow(spec string) (maintenanceWindow, error) {
+	window := maintenanceWindow{spec: spec}
+	fields := strings.Fields(spec)
+	var times string
+	switch len(fields) {
+	case 1:
+		times = fields[0]
+		for day := range window.days {
+			window.days[day] = true
+		}
+	case 2:
+		times = fields[1]
+		if err := parseWeekdays(fields[0], &window.days); err != nil {
+			return window, err
+		}
+	default:
+		return window, fmt.Errorf("expected \"[days] HH:MM-HH:MM\"")
+	}
+
+	from, to, ok := strings.Cut(times, "-")
+	if !ok {
+		return window, fmt.Errorf("expected a time range HH:MM-HH:MM")
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return window, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return window, err
+	}
+	if end <= start {
+		end += 24 * 60
+	}
+	window.start = start
+	window.duration = time.Duration(end-start) * time.Minute
+	return window, nil
+}
+
+// parseWeekdays parses day names and ranges such as "Mon-Fri" or "Sat,Sun"
+func parseWeekdays(spec string, days *[7]bool) error {
+	for _, part := range strings.Split(spec, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := weekdayNames[strings.ToLower(from)]
+		if !ok {
+			return fmt.Errorf("unknown day %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdayNames[strings.ToLower(to)]; !ok {
+				return fmt.Errorf("unknown day %q", to)
+			}
+		}
+		// Ranges may wrap around the week, e.g. "Fri-Mon"
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock parses "HH:MM" into minutes after midnight
+func parseClock(clock string) (int, error) {
+	hours, minutes, ok := strings.Cut(clock, ":")
+	h, errH := strconv.Atoi(hours)
+	m, errM := strconv.Atoi(minutes)
+	if !ok || errH != nil || errM != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return h*60 + m, nil
+}
+
+// openAt returns the window open at now and when it closes (ok is false when none is open)
+func (ms *MaintenanceSchedule) openAt(now time.Time) (string, time.Time, bool) {
+	now = now.In(ms.location)
+	var open string
+	var until time.Time
+	for _, window := range ms.windows {
+		// A window open now started today or, past midnight, yesterday
+		for daysBack := 0; daysBack <= 1; daysBack++ {
+			start := window.startOn(now.AddDate(0, 0, -daysBack), ms.location)
+			end := start.Add(window.duration)
+			if window.days[start.Weekday()] && !now.Before(start) && now.Before(end) && end.After(until) {
+				open, until = window.spec, end
+			}
+		}
+	}
+	return open, until, open != ""
+}
+
+// nextStart returns when the next window opens after now (zero = no windows)
+func (ms *MaintenanceSchedule) nextStart(now time.Time) time.Time {
+	now = now.In(ms.location)
+	var next time.Time
+	for _, window := range ms.windows {
+		for daysAhead := 0; daysAhead <= 7; daysAhead++ {
+			start := window.startOn(now.AddDate(0, 0, daysAhead), ms.location)
+			if window.days[start.Weekday()] && start.After(now) {
+				if next.IsZero() || start.Before(next) {
+					next = start
+				}
+				break
+			}
+		}
+	}
+	return next
+}
+
+// startOn returns when the window starts on the day of t
+func (w maintenanceWindow) startOn(t time.Time, location *time.Location) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, w.start/60, w.start%60, 0, 0, location)
+}
+
+// Paused reports whether a maintenance window is open, logging when a pause starts or ends
+// Safe to call on a nil schedule (never paused)
+func (ms *MaintenanceSchedule) Paused() bool {
+	if ms == nil {
+		return false
+	}
+
+	window, until, open := ms.openAt(clockNow())
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if open && !ms.paused {
+		if ms.logLevel.Get() == "DEBUG" || ms.logLevel.Get() == "INFO" {
+			fmt.Printf("[Maintenance] Window %s open, scanning and verification paused until %s\n", window, until.Format("2006-01-02 15:04"))
+		}
+	} else if !open && ms.paused {
+		if ms.logLevel.Get() == "DEBUG" || ms.logLevel.Get() == "INFO" {
+			fmt.Printf("[Maintenance] Window %s over, resuming scanning and verification\n", ms.window)
+		}
+	}
+	ms.paused, ms.window, ms.until = open, window, until
+	return open
+}
+
+// Status returns the schedule and the pause state for the API
+func (ms *MaintenanceSchedule) Status() MaintenanceStatus {
+	paused := ms.Paused()
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	status := MaintenanceStatus{Timezone: ms.location.String(), Paused: paused}
+	for _, window := range ms.windows {
+		status.Windows = append(status.Windows, window.spec)
+	}
+	if paused {
+		until := ms.until
+		status.Window, status.Until = ms.window, &until
+	}
+	if next := ms.nextStart(clockNow()); !next.IsZero() {
+		status.NextStart = &next
+	}
+	return status
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ManifestRegistry holds the batch manifests found in the source folder.
+
+A manifest (verification.manifest.name, e.g. MANIFEST.sha256) lists the
+expected files of the folder it is in, in sha256sum format:
+
+	<hash>  data1.zip
+	<hash> *data2.zip
+
+With verification.manifest.hierarchy (recursive scanning), a manifest also
+covers the subfolders below it, listing their files by relative path
+("<hash>  2024/01/data1.zip"); a data file belongs to the nearest manifest
+in its folder or a parent folder up to the source folder, e.g. a single
+root manifest for a whole archival drop.
+
+Responsibilities:
+1. Load a folder's manifest when it or a data file in it is scanned,
+   reloading it when it changes
+2. Give the expected hash of a listed data file (no .sha256 file is needed)
+3. Record data files present in a manifest folder but not listed in its
+   manifest ("extra" files, possible contamination; the scanner refuses them)
+4. Report entries whose file has not arrived within missingTimeout of the
+   manifest being loaded ("missing" files)
+5. Serve both discrepancies for GET /manifests
+
+Does NOT:
+- Verify files (listed files go through the worker pool like any other)
+- Delete or move manifests (remove a manifest once its batch is done)
+*/
+
+// ManifestRegistry tracks manifests, extra files and missing entries
+type ManifestRegistry struct {
+	mutex          sync.Mutex
+	name           string // File name of manifests
+	sourceFolder   string
+	hierarchy      bool // Manifests cover their subfolders too
+	hashEncoding   string
+	missingTimeout time.Duration
+	manifests      map[string]*manifestState // Key: manifest path
+	extras         map[string]ExtraFile      // Key: extra file path
+}
+
+// manifestState is a loaded manifest
+type manifestState struct {
+	size     int64
+	modTime  time.Time
+	loadedAt time.Time
+	entries  map[string]*manifestEntry // Key: slash-separated path relative to the manifest folder
+	invalid  bool                      // Could not be parsed, the folder is treated as having no manifest
+}
+
+// manifestEntry is one expected file of a manifest
+type manifestEntry struct {
+	hash      string
+	arrivedAt time.Time // Zero until the file is seen
+	reported  bool      // Missing entry already logged
+}
+
+// ExtraFile is a file found in a manifest folder but not listed in the manifest
+type ExtraFile struct {
+	Path     string    `json:"path"`
+	Manifest string    `json:"manifest"`
+	SeenAt   time.Time `json:"seenAt"`
+}
+
+// MissingFile is a manifest entry whose file has not arrived in time
+type MissingFile struct {
+	Manifest      string    `json:"manifest"`
+	Name          string    `json:"name"`
+	ExpectedSince time.Time `json:"expectedSince"`
+}
+
+// ManifestReport lists both kinds of manifest discrepancies
+type ManifestReport struct {
+	Extra   []ExtraFile   `json:"extra"`
+	Missing []MissingFile `json:"missing"`
+}
+
+// NewManifestRegistry creates a registry for manifests named name
+func NewManifestRegistry(name, sourceFolder string, hierarchy bool, hashEncoding string, missingTimeout time.Duration) *ManifestRegistry {
+	return &ManifestRegistry{
+		name:           name,
+		sourceFolder:   sourceFolder,
+		hierarchy:      hierarchy,
+		hashEncoding:   hashEncoding,
+		missingTimeout: missingTimeout,
+		manifests:      make(map[string]*manifestState),
+		extras:         make(map[string]ExtraFile),
+	}
+}
+
+// IsManifest reports whether a file name is a manifest
+func (mr *ManifestRegistry) IsManifest(filename string) bool {
+	return filename == mr.name
+}
+
+// Track loads a manifest found by the scanner (or reloads it if it changed)
+func (mr *ManifestRegistry) Track(manifestPath string) {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+
+	mr.load(manifestPath)
+}
+
+// Lookup returns the manifest covering a data file and the file's expected hash
+// manifestPath is "" when no (valid) manifest covers the file; hash is "" when
+// the covering manifest does not list it
+func (mr *ManifestRegistry) Lookup(dataFilePath string) (manifestPath, hash string) {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+
+	// The file's own folder first, then its parents up to the source folder
+	var state *manifestState
+	dir := filepath.Dir(dataFilePath)
+	for {
+		manifestPath = filepath.Join(dir, mr.name)
+		if state = mr.load(manifestPath); state != nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if !mr.hierarchy || parent == dir || !isWithinFolder(parent, mr.sourceFolder) {
+			return "", ""
+		}
+		dir = parent
+	}
+
+	// Entries are keyed by the path relative to the manifest's folder
+	relPath, err := filepath.Rel(filepath.Dir(manifestPath), dataFilePath)
+	if err != nil {
+		return manifestPath, ""
+	}
+	entry, listed := state.entries[filepath.ToSlash(relPath)]
+	if !listed {
+		return manifestPath, ""
+	}
+	if entry.arrivedAt.IsZero() {
+		entry.arrivedAt = time.Now()
+	}
+	return manifestPath, entry.hash
+}
+
+// load returns the state of a manifest, (re)parsing it when new or changed
+// Returns nil when the manifest does not exist or cannot be parsed
+func (mr *ManifestRegistry) load(manifestPath string) *manifestState {
+	info, err := os.Stat(manifestPath)
+	if err != nil {
+		delete(mr.manifests, manifestPath)
+		return nil
+	}
+
+	current := mr.manifests[manifestPath]
+	if current != nil && current.size == info.Size() && current.modTime.Equal(info.ModTime()) {
+		if current.invalid {
+			return nil
+		}
+		return current
+	}
+
+	state := &manifestState{size: info.Size(), modTime: info.ModTime(), loadedAt: time.Now()}
+	mr.manifests[manifestPath] = state
+
+	entries, err := mr.parse(manifestPath)
+	if err != nil {
+		state.invalid = true
+		fmt.Fprintf(os.Stderr, "[Manifest] Ignoring invalid manifest %s: %v\n", manifestPath, err)
+		return nil
+	}
+
+	// Files already seen stay arrived when the manifest is rewritten
+	if current != nil {
+		for name, entry := range entries {
+			if previous, exists := current.entries[name]; exists {
+				entry.arrivedAt = previous.arrivedAt
+				entry.reported = previous.reported
+			}
+		}
+		state.loadedAt = current.loadedAt
+	}
+	state.entries = entries
+
+	return state
+}
+
+// parse reads the entries of a manifest
+func (mr *ManifestRegistry) parse(manifestPath string) (map[string]*manifestEntry, error) {
+	data, err := readSidecar(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	return parseManifest(data, mr.hashEncoding, mr.hierarchy)
+}
+
+// parseManifest parses manifest content, keyed by slash-separated relative path
+// Names in subfolders are only accepted with hierarchy
+func parseManifest(data []byte, hashEncoding string, hierarchy bool) (map[string]*manifestEntry, error) {
+	entries := make(map[string]*manifestEntry)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("line %d: expected \"<hash>  <file name>\"", lineNumber)
+		}
+		hash, err := parseDigest(parts[0], hashEncoding, AlgorithmSHA256)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+
+		// Names are relative to the manifest folder, with sha256sum's binary-mode marker removed
+		name := strings.TrimPrefix(strings.TrimSpace(line[len(parts[0]):]), "*")
+		name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+		if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("line %d: invalid file name %q", lineNumber, name)
+		}
+		if !hierarchy && strings.Contains(name, "/") {
+			return nil, fmt.Errorf("line %d: %q is in a subfolder (requires verification.manifest.hierarchy)", lineNumber, name)
+		}
+		entries[name] = &manifestEntry{hash: hash}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// RecordExtra records a file found in a manifest folder that its manifest does not list
+// Returns true the first time the file is recorded
+func (mr *ManifestRegistry) RecordExtra(filePath, manifestPath string) bool {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+
+	if _, exists := mr.extras[filePath]; exists {
+		return false
+	}
+	mr.extras[filePath] = ExtraFile{Path: filePath, Manifest: manifestPath, SeenAt: time.Now()}
+	return true
+}
+
+// ReportMissing logs entries that became missing since the last call
+// and forgets manifests that no longer exist, with their extra files
+func (mr *ManifestRegistry) ReportMissing(logLevel *LogLevel) {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+
+	for manifestPath := range mr.manifests {
+		if _, err := os.Stat(manifestPath); err != nil {
+			delete(mr.manifests, manifestPath)
+		}
+	}
+	for filePath, extra := range mr.extras {
+		if _, exists := mr.manifests[extra.Manifest]; !exists {
+			delete(mr.extras, filePath)
+		}
+	}
+
+	for _, missing := range mr.missing(time.Now()) {
+		entry := mr.manifests[missing.Manifest].entries[missing.Name]
+		if entry.reported {
+			continue
+		}
+		entry.reported = true
+
+		if logLevel.Get() == "WARN" || logLevel.Get() == "DEBUG" {
+			fmt.Fprintf(os.Stderr, "[Manifest] MISSING %s listed in %s, not arrived after %s\n",
+				missing.Name, missing.Manifest, mr.missingTimeout)
+		}
+	}
+}
+
+// missing returns the entries not arrived within missingTimeout, caller holds the lock
+func (mr *ManifestRegistry) missing(now time.Time) []MissingFile {
+	var missing []MissingFile
+	for manifestPath, state := range mr.manifests {
+		if state.invalid || now.Sub(state.loadedAt) < mr.missingTimeout {
+			continue
+		}
+		for name, entry := range state.entries {
+			if entry.arrivedAt.IsZero() {
+				missing = append(missing, MissingFile{
+					Manifest:      manifestPath,
+					Name:          name,
+					ExpectedSince: state.loadedAt,
+				})
+			}
+		}
+	}
+	return missing
+}
+
+// Report returns the current extra files and missing entries
+func (mr *ManifestRegistry) Report() ManifestReport {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+
+	report := ManifestReport{
+		Extra:   []ExtraFile{},
+		Missing: mr.missing(time.Now()),
+	}
+	for _, extra := range mr.extras {
+		report.Extra = append(report.Extra, extra)
+	}
+	if report.Missing == nil {
+		report.Missing = []MissingFile{}
+	}
+
+	// Sort for stable output
+	sort.Slice(report.Extra, func(i, j int) bool { return report.Extra[i].Path < report.Extra[j].Path })
+	sort.Slice(report.Missing, func(i, j int) bool {
+		if report.Missing[i].Manifest != report.Missing[j].Manifest {
+			return report.Missing[i].Manifest < report.Missing[j].Manifest
+		}
+		return report.Missing[i].Name < report.Missing[j].Name
+	})
+
+	return report
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+/*
+Metrics renders runtime statistics in the Prometheus text exposition format.
+
+Responsibilities:
+1. Translate a Statistics snapshot into counters, gauges and histograms
+2. Write them in a form any Prometheus-compatible scraper understands
+
+Does NOT:
+- Collect statistics (that's statistics.go, progress.go and hash_limiter.go)
+- Serve HTTP (that's api_server.go, GET /metrics)
+*/
+
+// metricsNamespace prefixes every exported metric name
+const metricsNamespace = "filesha"
+
+// writeMetrics writes all metrics derived from the statistics and progress snapshots
+// destinationReadOnly is true while a destination folder is on a read-only mount,
+// maintenancePaused while a maintenance window pauses verification
+func writeMetrics(w io.Writer, stats Statistics, progress ProgressSnapshot, hashLimiter *HashLimiter, destinationReadOnly, maintenancePaused bool) {
+	writeMetric(w, "files_processed_total", "counter",
+		"Files whose verification finished (verified or failed).", float64(stats.TotalProcessed))
+	writeMetric(w, "files_verified_total", "counter",
+		"Files that passed verification.", float64(stats.SuccessCount))
+	writeMetric(w, "files_failed_total", "counter",
+		"Files that failed verification and were given up on.", float64(stats.FailureCount))
+	writeMetric(w, "files_pending", "gauge",
+		"Files currently tracked but not yet processed.", float64(stats.PendingCount))
+	writeMetric(w, "pending_drift", "gauge",
+		"Difference between tracked files and the cached pending count at the last reconciliation.", float64(stats.PendingDrift))
+	writeMetric(w, "files_mispaired_total", "counter",
+		"Pairs whose sidecar named a different data file (moved to the mispaired folder).", float64(stats.MispairedCount))
+	writeMetric(w, "worker_panics_total", "counter",
+		"Panics recovered while processing a file (the file is retried, the worker keeps running).", float64(stats.WorkerPanics))
+	writeMetric(w, "worker_stalls_total", "counter",
+		"Workers replaced because their hashing made no progress for stallTimeout (the file is retried).", float64(stats.WorkerStalls))
+	writeMetric(w, "integrity_oracle_reused_total", "counter",
+		"Attempts that reused an earlier hash because the integrity oracle vouched the file stayed intact.", float64(stats.OracleReused))
+	writeMetric(w, "bytes_verified_total", "counter",
+		"Bytes of data files hashed by finished verifications.", float64(stats.TotalBytesVerified))
+	writeMetric(w, "janitor_purged_files_total", "counter",
+		"Files deleted from the verified folder by the retention janitor.", float64(stats.PurgedFiles))
+	writeMetric(w, "janitor_purged_bytes_total", "counter",
+		"Bytes reclaimed from the verified folder by the retention janitor.", float64(stats.PurgedBytes))
+	writeMetric(w, "dlq_purged_files_total", "counter",
+		"Files deleted from the DLQ by its retention janitor.", float64(stats.DLQPurgedFiles))
+	writeMetric(w, "dlq_purged_bytes_total", "counter",
+		"Bytes reclaimed from the DLQ by its retention janitor.", float64(stats.DLQPurgedBytes))
+	writeMetric(w, "dlq_files", "gauge",
+		"Files in the DLQ at the last DLQ janitor pass (0 without destination.dlqRetention).", float64(stats.DLQFiles))
+	writeMetric(w, "dlq_bytes", "gauge",
+		"Bytes in the DLQ at the last DLQ janitor pass (0 without destination.dlqRetention).", float64(stats.DLQBytes))
+	writeMetric(w, "verification_duration_seconds_total", "counter",
+		"Cumulative time spent verifying files.", stats.TotalDuration.Seconds())
+	writeMetric(w, "sidecar_read_retries_total", "counter",
+		"Sidecar reads retried after a transient error such as a sharing lock.", float64(sidecarReadRetries.Load()))
+	writeMetric(w, "queue_submit_stalled_files", "gauge",
+		"Ready files unable to enter the full worker queue for longer than submitWarnAfter.", float64(stats.QueueStalled))
+	writeMetric(w, "queue_submit_wait_seconds_max", "gauge",
+		"Longest time a ready file has currently been unable to enter the worker queue.", stats.QueueWaitMax.Seconds())
+	writeMetric(w, "hashing_files", "gauge",
+		"Files being hashed right now.", float64(progress.Files))
+	writeMetric(w, "hashing_bytes", "gauge",
+		"Total size of the files being hashed right now.", float64(progress.TotalBytes))
+	writeMetric(w, "hashing_hashed_bytes", "gauge",
+		"Bytes already hashed of the files being hashed right now.", float64(progress.HashedBytes))
+	writeMetric(w, "hashing_slots", "gauge",
+		"Files that may be hashed at the same time (0 = no limit).", float64(hashLimiter.Capacity()))
+	writeMetric(w, "hashing_slots_in_use", "gauge",
+		"Workers hashing right now.", float64(hashLimiter.InUse()))
+	writeMetric(w, "hashing_slots_waiting", "gauge",
+		"Workers waiting for a free hashing slot.", float64(hashLimiter.Waiting()))
+	writeMetric(w, "destination_read_only", "gauge",
+		"1 while a destination folder is on a read-only mount and verification is paused.", boolValue(destinationReadOnly))
+	writeMetric(w, "maintenance_paused", "gauge",
+		"1 while a maintenance window pauses scanning and verification.", boolValue(maintenancePaused))
+	writeMetric(w, "uptime_seconds", "gauge",
+		"Seconds since the verifier started.", time.Since(stats.StartTime).Seconds())
+	writeSkewHistogram(w, stats.DataFirstSkew, stats.SidecarFirstSkew)
+}
+
+// writeSkewHistogram writes the pair arrival skew histogram, labelled by the half that arrived first
+func writeSkewHistogram(w io.Writer, dataFirst, sidecarFirst SkewHistogram) {
+	fullName := metricsNamespace + "_pair_arrival_skew_seconds"
+	fmt.Fprintf(w, "# HELP %s Time between the arrival of a data file and its sidecar, by the file that arrived first.\n", fullName)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", fullName)
+	for _, series := range []struct {
+		first     string
+		histogram SkewHistogram
+	}{{"data", dataFirst}, {"sidecar", sidecarFirst}} {
+		for i, bound := range arrivalSkewBuckets {
+			fmt.Fprintf(w, "%s_bucket{first=%q,le=\"%g\"} %d\n", fullName, series.first, bound.Seconds(), series.histogram.Buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{first=%q,le=\"+Inf\"} %d\n", fullName, series.first, series.histogram.Count)
+		fmt.Fprintf(w, "%s_sum{first=%q} %g\n", fullName, series.first, series.histogram.Sum.Seconds())
+		fmt.Fprintf(w, "%s_count{first=%q} %d\n", fullName, series.first, series.histogram.Count)
+	}
+}
+
+// writeMetric writes a single unlabelled metric with its HELP and TYPE lines
+func writeMetric(w io.Writer, name, kind, help string, value float64) {
+	fullName := metricsNamespace + "_" + name
+	fmt.Fprintf(w, "# HELP %s %s\n", fullName, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", fullName, kind)
+	fmt.Fprintf(w, "%s %g\n", fullName, value)
+}
+
+// boolValue converts a flag to a gauge value
+func boolValue(flag bool) float64 {
+	if flag {
+		return 1
+	}
+	return 0
+}
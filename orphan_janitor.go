@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+/*
+OrphanJanitor removes sidecars left in the source folder without their data
+file, e.g. from partial earlier runs (destination.orphanCleanup).
+
+orphanSidecars handles a sidecar the tracker has waited retryTimeout for;
+with "leave", or when the verifier restarts, the next scan tracks the same
+orphan again and the half-pair is held forever. This janitor looks at the
+source folder itself instead.
+
+Responsibilities:
+1. Walk the source folder with the scanner's rules (recursion, exclude
+   patterns, temp names) on its own ticker, looking for sidecars whose
+   data file does not exist
+2. Note when each orphan was first seen; a sidecar that changes, or whose
+   data file appears, starts over
+3. Delete or quarantine an orphan (with its signature) once it has been an
+   orphan for the grace period, and stop tracking its half-pair
+4. Log every cleanup action
+
+Does NOT:
+- Touch sidecars of delivered files waiting for their deferred deletion
+- Remember orphans across restarts (the grace period starts again)
+*/
+
+// Actions taken on orphan sidecars by the orphan janitor
+const (
+	OrphanCleanupDelete     = "delete"     // Delete the sidecar
+	OrphanCleanupQuarantine = "quarantine" // Move the sidecar to the quarantine folder
+)
+
+// OrphanJanitor deletes or quarantines sidecars whose data file never arrived
+type OrphanJanitor struct {
+	gracePeriod  time.Duration
+	action       string
+	scanner      *FileScanner // Source folder and walk rules
+	tracker      *FileTracker
+	destinations *Destinations
+	onCollision  string
+	orphans      map[string]orphanSighting // Key: sidecar path; only used by Run
+	logLevel     *LogLevel
+	running      atomic.Bool
+}
+
+// orphanSighting is a sidecar found without its data file
+type orphanSighting struct {
+	size    int64
+	modTime time.Time
+	since   time.Time // First pass that found it orphaned (unchanged since)
+}
+
+// NewOrphanJanitor creates a janitor for orphan sidecars in the scanner's source folder
+func NewOrphanJanitor(cfg OrphanCleanupConfig, scanner *FileScanner, tracker *FileTracker, destinations *Destinations, onCollision string, logLevel *LogLevel) *OrphanJanitor {
+	return &OrphanJanitor{
+		gracePeriod:  cfg.GracePeriod,
+		action:       cfg.Action,
+		scanner:      scanner,
+		tracker:      tracker,
+		destinations: destinations,
+		onCollision:  onCollision,
+		orphans:      make(map[string]orphanSighting),
+		logLevel:     logLevel,
+	}
+}
+
+// Run performs one cleanup pass; a pass requested while another is running is skipped
+func (oj *OrphanJanitor) Run() {
+	if !oj.running.CompareAndSwap(false, true) {
+		return
+	}
+	defer oj.running.Store(false)
+
+	now := time.Now()
+	seen := make(map[string]bool)
+	cleaned := 0
+
+	err := filepath.WalkDir(oj.scanner.sourceFolder, func(fullPath string, entry iofs.DirEntry, err error) error {
+		if err != nil {
+			if fullPath == oj.scanner.sourceFolder {
+				return err
+			}
+			return nil
+		}
+		if fullPath == oj.scanner.sourceFolder {
+			return nil
+		}
+		if entry.IsDir() {
+			if !oj.scanner.recursive || oj.scanner.isExcluded(fullPath) {
+				return iofs.SkipDir
+			}
+			return nil
+		}
+
+		filename := entry.Name()
+		suffix := oj.scanner.sidecarSuffix(filename)
+		if suffix == "" || oj.scanner.isExcluded(fullPath) || oj.scanner.isInProgress(filename) {
+			return nil
+		}
+		if FileExists(trimSidecarSuffix(fullPath, suffix)) || oj.tracker.HoldsSidecar(fullPath) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+
+		// The grace period runs from the first pass that found the sidecar orphaned, unchanged
+		seen[fullPath] = true
+		sighting, known := oj.orphans[fullPath]
+		if !known || sighting.size != info.Size() || !sighting.modTime.Equal(info.ModTime()) {
+			oj.orphans[fullPath] = orphanSighting{size: info.Size(), modTime: info.ModTime(), since: now}
+			return nil
+		}
+		if now.Sub(sighting.since) < oj.gracePeriod {
+			return nil
+		}
+
+		if oj.clean(fullPath, suffix, sighting) {
+			delete(oj.orphans, fullPath)
+			cleaned++
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[Janitor] Failed to scan %s for orphan sidecars: %v\n", oj.scanner.sourceFolder, err)
+		return
+	}
+
+	// Forget sidecars that are gone or found their data file
+	for sidecarPath := range oj.orphans {
+		if !seen[sidecarPath] {
+			delete(oj.orphans, sidecarPath)
+		}
+	}
+
+	if oj.logLevel.Get() == "DEBUG" {
+		fmt.Printf("[Janitor] Orphan sidecar pass: %d cleaned, %d waiting out the grace period\n", cleaned, len(oj.orphans))
+	}
+}
+
+// clean deletes or quarantines an orphan sidecar and its signature, returning true on success
+func (oj *OrphanJanitor) clean(sidecarPath, suffix string, sighting orphanSighting) bool {
+	// The data file may have arrived during the pass
+	dataPath := trimSidecarSuffix(sidecarPath, suffix)
+	if FileExists(dataPath) {
+		return false
+	}
+
+	paths := []string{sidecarPath}
+	if signatures := oj.scanner.features.Signatures; signatures != nil && FileExists(signatures.SignaturePath(sidecarPath)) {
+		paths = append(paths, signatures.SignaturePath(sidecarPath))
+	}
+
+	// Left in place if the quarantine folder was cleared at runtime
+	quarantineFolder := oj.destinations.Get().Quarantine
+	if oj.action == OrphanCleanupQuarantine && quarantineFolder == "" {
+		return false
+	}
+
+	for i, path := range paths {
+		var err error
+		done := "deleted"
+		if oj.action == OrphanCleanupQuarantine {
+			var newPath string
+			newPath, err = MoveToFolder(path, quarantineFolder, oj.onCollision)
+			done = "moved to " + newPath
+		} else if i == 0 {
+			// Never delete a sidecar rewritten since it was found
+			err = SafeDeleteFile(path, oj.scanner.sourceFolder, sighting.size, sighting.modTime)
+		} else {
+			err = DeleteWithinFolder(path, oj.scanner.sourceFolder)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[Janitor] Failed to clean up orphan sidecar %s: %v\n", path, err)
+			if i == 0 {
+				return false
+			}
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[Janitor] Orphan sidecar %s: no data file for %s, %s\n", path, oj.gracePeriod, done)
+	}
+
+	oj.tracker.Remove(dataPath)
+	return true
+}
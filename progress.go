@@ -0,0 +1,139 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+ProgressRegistry keeps a live view of the files the workers are hashing.
+
+Responsibilities:
+1. Register a file when a worker starts hashing it and drop it when done
+2. Accumulate the bytes hashed so far as the worker reads the file
+3. Aggregate all in-progress files into one snapshot (files, bytes, percent)
+   for GET /progress and the filesha_hashing_* metrics
+4. Note when each file last made progress, so the stall watchdog can find
+   workers stuck on a read (concurrency.stallTimeout)
+
+Does NOT:
+- Hash files (that's sha_verifier.go, which reports reads via ProgressFunc)
+- Keep any history (finished files are in statistics.go)
+*/
+
+// ProgressRegistry tracks the files currently being hashed
+type ProgressRegistry struct {
+	mutex sync.Mutex
+	files map[string]*FileProgress
+}
+
+// FileProgress is the hashing progress of one file
+type FileProgress struct {
+	DataFile     string    `json:"dataFile"`
+	Worker       int       `json:"worker"`
+	TotalBytes   int64     `json:"totalBytes"`
+	HashedBytes  int64     `json:"hashedBytes"`
+	Percent      float64   `json:"percent"`
+	StartedAt    time.Time `json:"startedAt"`
+	LastProgress time.Time `json:"lastProgressAt"` // Last read (or the start)
+	key          string    // Tracker key
+}
+
+// ProgressSnapshot aggregates all files currently being hashed
+type ProgressSnapshot struct {
+	Files       int            `json:"files"`
+	TotalBytes  int64          `json:"totalBytes"`
+	HashedBytes int64          `json:"hashedBytes"`
+	Percent     float64        `json:"percent"`
+	InProgress  []FileProgress `json:"inProgress"`
+}
+
+// NewProgressRegistry creates an empty registry
+func NewProgressRegistry() *ProgressRegistry {
+	return &ProgressRegistry{
+		files: make(map[string]*FileProgress),
+	}
+}
+
+// Start registers a file a worker is about to hash
+func (p *ProgressRegistry) Start(key, dataFile string, workerID int, totalBytes int64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	p.files[key] = &FileProgress{
+		DataFile:     dataFile,
+		Worker:       workerID,
+		TotalBytes:   totalBytes,
+		StartedAt:    now,
+		LastProgress: now,
+		key:          key,
+	}
+}
+
+// Add records bytes hashed for a registered file
+func (p *ProgressRegistry) Add(key string, bytes int64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if file, exists := p.files[key]; exists {
+		file.HashedBytes += bytes
+		file.LastProgress = time.Now()
+	}
+}
+
+// End removes a file once its worker has finished with it
+func (p *ProgressRegistry) End(key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	delete(p.files, key)
+}
+
+// Snapshot returns the aggregated progress of all files being hashed
+func (p *ProgressRegistry) Snapshot() ProgressSnapshot {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	snapshot := ProgressSnapshot{InProgress: []FileProgress{}}
+	for _, file := range p.files {
+		entry := *file
+		entry.Percent = percentOf(entry.HashedBytes, entry.TotalBytes)
+
+		snapshot.Files++
+		snapshot.TotalBytes += entry.TotalBytes
+		snapshot.HashedBytes += entry.HashedBytes
+		snapshot.InProgress = append(snapshot.InProgress, entry)
+	}
+	snapshot.Percent = percentOf(snapshot.HashedBytes, snapshot.TotalBytes)
+
+	// Sort by start time for stable output
+	sort.Slice(snapshot.InProgress, func(i, j int) bool {
+		return snapshot.InProgress[i].StartedAt.Before(snapshot.InProgress[j].StartedAt)
+	})
+
+	return snapshot
+}
+
+// Stalled returns the files that have made no progress for at least timeout
+func (p *ProgressRegistry) Stalled(timeout time.Duration) []FileProgress {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var stalled []FileProgress
+	for _, file := range p.files {
+		if time.Since(file.LastProgress) >= timeout {
+			stalled = append(stalled, *file)
+		}
+	}
+	return stalled
+}
+
+// percentOf returns done as a percentage of total (0 when there is nothing to hash)
+func percentOf(done, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(done) / float64(total) * 100.0
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Reconciliation cross-checks the verified folder against the verification CSV
+once at startup (output.reconcile), so a crash between delivering a file and
+logging it (or the other way round) does not go unnoticed.
+
+The newest record per destination path decides what the log claims: a
+delivery ("moved", "compressed", "received", "reconciled") claims the file is in the verified folder, a
+"missing" record that it is gone.
+
+Responsibilities:
+1. Report files in the verified folder that no record claims (unlogged) and
+   logged deliveries whose file is gone (absent)
+2. With "repair", hash each unlogged file and record it with action
+   "reconciled", and record each absent file with action "missing", so the
+   next reconciliation finds the log and the folder in agreement
+
+Does NOT:
+- Move, copy or delete any file, in either mode
+- Check deliveries outside the current verified folder (directives, a folder
+  switched through the API) or in rotated-away CSV files
+- Tell a file taken away by a downstream consumer from one lost in a crash:
+  both are absent
+*/
+
+// Reconciliation modes (output.reconcile)
+const (
+	ReconcileOff    = "off"    // No reconciliation (default)
+	ReconcileReport = "report" // Report discrepancies, change nothing
+	ReconcileRepair = "repair" // Also record them so the log matches the folder
+)
+
+// Actions recorded by reconciliation
+const (
+	ActionReconciled = "reconciled" // Found in the verified folder without a record, hashed and recorded
+	ActionMissing    = "missing"    // Recorded as delivered but no longer in the verified folder
+)
+
+// reconcileListLimit bounds how many discrepancies of each kind are listed by name
+const reconcileListLimit = 20
+
+// loggedDelivery is the newest verification record of a destination path
+type loggedDelivery struct {
+	action string
+	hash   string
+	size   int64
+}
+
+// ReconcileVerifiedFolder compares the verified folder with the verification CSV and,
+// in repair mode, records the discrepancies through resultLogger
+func ReconcileVerifiedFolder(mode, verificationFile, verifiedFolder string, bufferSize int, resultLogger ResultLogger, logLevel *LogLevel) error {
+	start := clockNow()
+	logged, err := readLoggedDeliveries(verificationFile)
+	if err != nil {
+		return err
+	}
+
+	present := make(map[string]bool)
+	err = filepath.WalkDir(verifiedFolder, func(fullPath string, entry iofs.DirEntry, err error) error {
+		if err != nil {
+			if fullPath == verifiedFolder {
+				return err
+			}
+			return nil
+		}
+		// Temp files are copies that never got their final name, result files are not deliveries
+		if entry.Type().IsRegular() && !strings.HasSuffix(entry.Name(), ".tmp") && !strings.HasSuffix(entry.Name(), resultFileSuffix) &&
+			!strings.HasSuffix(entry.Name(), latestPointerSuffix) {
+			present[fullPath] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read verified folder: %w", err)
+	}
+
+	var unlogged, absent []string
+	for path := range present {
+		if record, ok := logged[path]; !ok || record.action == ActionMissing {
+			unlogged = append(unlogged, path)
+		}
+	}
+	for path, record := range logged {
+		if record.action != ActionMissing && !present[path] && isWithinFolder(path, verifiedFolder) {
+			absent = append(absent, path)
+		}
+	}
+	sort.Strings(unlogged)
+	sort.Strings(absent)
+
+	if len(unlogged) == 0 && len(absent) == 0 {
+		if logLevel.Get() == "DEBUG" || logLevel.Get() == "INFO" {
+			fmt.Printf("[Reconcile] %s matches the verification log (%d files)\n", verifiedFolder, len(present))
+		}
+		return nil
+	}
+
+	// The report is what reconciliation was enabled for, it is printed at every level
+	reportDiscrepancies("in the verified folder but not logged", unlogged)
+	reportDiscrepancies("logged as delivered but not in the verified folder", absent)
+	if mode != ReconcileRepair {
+		return nil
+	}
+
+	recorded := 0
+	for _, path := range unlogged {
+		if err := recordReconciled(path, bufferSize, resultLogger); err != nil {
+			fmt.Fprintf(os.Stderr, "[Reconcile] Failed to record %s: %v\n", path, err)
+			continue
+		}
+		recorded++
+	}
+	for _, path := range absent {
+		record := logged[path]
+		entry := CreateCSVLogEntry(VerificationResult{
+			Job:          VerificationJob{FilePair: FilePair{DataFile: filepath.Base(path), DataSize: record.size}},
+			ComputedHash: record.hash,
+			HoleBytes:    -1,
+			Timestamp:    clockNow(),
+		}, ActionMissing, path, "")
+		if err := resultLogger.LogVerification(entry); err != nil {
+			fmt.Fprintf(os.Stderr, "[Reconcile] Failed to record %s: %v\n", path, err)
+			continue
+		}
+		recorded++
+	}
+
+	if logLevel.Get() == "DEBUG" || logLevel.Get() == "INFO" {
+		fmt.Printf("[Reconcile] Recorded %d of %d discrepancies in %s\n", recorded, len(unlogged)+len(absent), clockNow().Sub(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// reportDiscrepancies lists the paths of one kind of discrepancy, up to reconcileListLimit by name
+func reportDiscrepancies(kind string, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "[Reconcile] %d files %s\n", len(paths), kind)
+	for i, path := range paths {
+		if i == reconcileListLimit {
+			fmt.Fprintf(os.Stderr, "[Reconcile]   ... and %d more\n", len(paths)-reconcileListLimit)
+			break
+		}
+		fmt.Fprintf(os.Stderr, "[Reconcile]   %s\n", path)
+	}
+}
+
+// recordReconciled hashes an unlogged file in the verified folder and records it
+func recordReconciled(path string, bufferSize int, resultLogger ResultLogger) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	startTime := clockNow()
+	hash, err := computeFileHash(path, bufferSize, AlgorithmSHA256, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	entry := CreateCSVLogEntry(VerificationResult{
+		Job:          VerificationJob{FilePair: FilePair{DataFile: filepath.Base(path), DataSize: info.Size()}},
+		Success:      true,
+		ComputedHash: hash,
+		HoleBytes:    -1,
+		Duration:     clockNow().Sub(startTime),
+		Timestamp:    clockNow(),
+	}, ActionReconciled, path, "")
+	return resultLogger.LogVerification(entry)
+}
+
+// readLoggedDeliveries returns the newest record of each destination path in the verification CSV
+// A damaged last row (e.g. torn by the crash being reconciled) ends the read
+func readLoggedDeliveries(verificationFile string) (map[string]loggedDelivery, error) {
+	file, err := os.Open(verificationFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]loggedDelivery{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open verification CSV file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 // Older files have fewer columns
+
+	logged := make(map[string]loggedDelivery)
+	columns := map[string]int{}
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[Reconcile] Stopped reading %s: %v\n", verificationFile, err)
+			break
+		}
+
+		// The header names the columns; it is written again if the file was recreated
+		if len(row) > 0 && row[0] == "Timestamp" {
+			for i, name := range row {
+				columns[name] = i
+			}
+			continue
+		}
+
+		action := csvField(row, columns, "Action")
+		path := csvField(row, columns, "Destination_Path")
+		switch action {
+		case ActionMoved, ActionCompressed, ActionReceived, ActionReconciled, ActionMissing:
+		default:
+			continue
+		}
+		if path == "" {
+			continue
+		}
+		var size int64
+		fmt.Sscan(csvField(row, columns, "Size_Bytes"), &size)
+		logged[path] = loggedDelivery{action: action, hash: csvField(row, columns, "SHA256"), size: size}
+	}
+	return logged, nil
+}
+
+// csvField returns the named column of a row (empty when the row is too short or has no such column)
+func csvField(row []string, columns map[string]int, name string) string {
+	i, ok := columns[name]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return row[i]
+}
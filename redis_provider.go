@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+RedisHashProvider reads expected hashes that an ingestion pipeline publishes
+to Redis as "<keyPrefix><data file name>" -> expected hash, instead of
+sidecars (verification.expectedHashSource: redis).
+
+Responsibilities:
+1. Look up the expected hash of a data file by its name
+2. Remember answers for their remaining Redis TTL (capped by redis.cacheFor),
+   so retries don't query Redis again and no answer outlives its key
+3. Report a missing key as ErrNotInRedis: the hash may not be published yet,
+   so the worker retries the file like any failed attempt until retryTimeout
+4. Survive Redis outages: lookups fail (and are retried) while Redis is
+   unreachable, the outage and the recovery are logged once each, and
+   GET /readyz reports not ready in between
+
+Speaks the Redis protocol (RESP) directly over one connection, so only GET,
+PTTL, AUTH and SELECT are supported; TLS and Cluster are not.
+
+Does NOT:
+- Write to Redis
+- Cache misses (a key published later is found on the next attempt)
+*/
+
+// redisCacheSweep is the cache size above which expired answers are dropped
+const redisCacheSweep = 10000
+
+// ErrNotInRedis is returned when Redis has no key for a data file (not published yet, or expired)
+var ErrNotInRedis = errors.New("file not listed in Redis")
+
+// RedisHashProvider reads expected hashes from Redis
+type RedisHashProvider struct {
+	cfg         RedisConfig
+	mutex       sync.Mutex
+	conn        net.Conn // nil until connected or after an error
+	reader      *bufio.Reader
+	cache       map[string]redisAnswer // Key: Redis key
+	unavailable error                  // Why Redis is unreachable (nil = reachable)
+}
+
+// redisAnswer is a cached expected hash
+type redisAnswer struct {
+	hash    string
+	expires time.Time
+}
+
+// NewRedisHashProvider creates a provider; it connects on first use
+func NewRedisHashProvider(cfg RedisConfig) *RedisHashProvider {
+	return &RedisHashProvider{
+		cfg:   cfg,
+		cache: make(map[string]redisAnswer),
+	}
+}
+
+// ExpectedHash returns the expected hash published for the data file's name
+func (p *RedisHashProvider) ExpectedHash(filePair FilePair) (string, error) {
+	key := p.cfg.KeyPrefix + filePair.DataFile
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	if answer, exists := p.cache[key]; exists && now.Before(answer.expires) {
+		return answer.hash, nil
+	}
+	delete(p.cache, key)
+
+	replies, err := p.query([]string{"GET", key}, []string{"PTTL", key})
+	if err != nil {
+		p.setUnavailable(err)
+		return "", fmt.Errorf("failed to query Redis: %w", err)
+	}
+	p.setUnavailable(nil)
+
+	hash, found := replies[0].(string)
+	if !found {
+		return "", fmt.Errorf("%w: %s", ErrNotInRedis, key)
+	}
+	hash = strings.TrimSpace(hash)
+
+	// Never remember an answer longer than Redis keeps the key
+	expires := now.Add(p.cfg.CacheFor)
+	if ttl, ok := replies[1].(int64); ok && ttl >= 0 && time.Duration(ttl)*time.Millisecond < p.cfg.CacheFor {
+		expires = now.Add(time.Duration(ttl) * time.Millisecond)
+	}
+	if len(p.cache) >= redisCacheSweep {
+		for cached, answer := range p.cache {
+			if !now.Before(answer.expires) {
+				delete(p.cache, cached)
+			}
+		}
+	}
+	p.cache[key] = redisAnswer{hash: hash, expires: expires}
+
+	return hash, nil
+}
+
+// Unavailable returns why Redis is unreachable, nil while it is reachable
+func (p *RedisHashProvider) Unavailable() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.unavailable
+}
+
+// setUnavailable records whether the last query reached Redis, logging each change; caller holds the lock
+func (p *RedisHashProvider) setUnavailable(err error) {
+	if err != nil && p.unavailable == nil {
+		fmt.Fprintf(os.Stderr, "[Redis] CRITICAL: %s unreachable, files waiting for their expected hash are retried until it is back: %v\n",
+			p.cfg.Address, err)
+	} else if err == nil && p.unavailable != nil {
+		fmt.Printf("[Redis] %s reachable again, expected hash lookups resumed\n", p.cfg.Address)
+	}
+	p.unavailable = err
+}
+
+// query sends commands in one round trip and returns their replies; caller holds the lock
+// A reply is a string, an int64 or nil (missing key); any error closes the connection
+func (p *RedisHashProvider) query(commands ...[]string) ([]interface{}, error) {
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	replies, err := p.roundTrip(commands)
+	if err != nil {
+		p.conn.Close()
+		p.conn = nil
+		return nil, err
+	}
+	return replies, nil
+}
+
+// connect dials Redis, authenticates and selects the database; caller holds the lock
+func (p *RedisHashProvider) connect() error {
+	conn, err := net.DialTimeout("tcp", p.cfg.Address, p.cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	p.conn = conn
+	p.reader = bufio.NewReader(conn)
+
+	var setup [][]string
+	if p.cfg.Password != "" {
+		setup = append(setup, []string{"AUTH", p.cfg.Password})
+	}
+	if p.cfg.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(p.cfg.DB)})
+	}
+	if len(setup) > 0 {
+		if _, err := p.roundTrip(setup); err != nil {
+			conn.Close()
+			p.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// roundTrip writes commands as RESP arrays and reads one reply per command
+func (p *RedisHashProvider) roundTrip(commands [][]string) ([]interface{}, error) {
+	p.conn.SetDeadline(time.Now().Add(p.cfg.Timeout))
+
+	var request strings.Builder
+	for _, args := range commands {
+		fmt.Fprintf(&request, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(&request, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := io.WriteString(p.conn, request.String()); err != nil {
+		return nil, err
+	}
+
+	// Read every reply even after an error reply, so the connection stays in sync
+	replies := make([]interface{}, len(commands))
+	var replyErr error
+	for i := range commands {
+		reply, err := readRedisReply(p.reader)
+		if errors.Is(err, errRedisReply) {
+			if replyErr == nil {
+				replyErr = err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, replyErr
+}
+
+// errRedisReply wraps an error reply sent by Redis (e.g. wrong password)
+var errRedisReply = errors.New("redis error")
+
+// readRedisReply reads a simple string, error, integer or bulk string reply
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty reply from Redis")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("%w: %s", errRedisReply, line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk length from Redis: %q", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	default:
+		return nil, fmt.Errorf("unsupported reply from Redis: %q", line)
+	}
+}
+
+// Close closes the Redis connection
+func (p *RedisHashProvider) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
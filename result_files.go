@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+Result files are per-file markers, "<datafile>.result.json", written next to
+each data file the verifier is done with (destination.resultFiles), for
+downstream tooling that watches folders rather than reading the
+verification log:
+
+	{"outcome":"verified","file":"data.zip","path":"/in/data.zip",
+	 "algorithm":"sha256","computedHash":"9f86...","expectedHash":"9f86...",
+	 "durationSeconds":0.42,"timestamp":"2026-10-16T10:04:36Z"}
+
+Responsibilities:
+1. Follow the data file to wherever it was delivered: the verified folder
+   (under its naming template or compressed name, so "data.zip.gz" gets
+   "data.zip.gz.result.json") or the DLQ and other failure folders
+2. Write each marker atomically (temp file + rename), so a watcher never
+   reads a partial one
+
+Does NOT:
+- Write markers for failed attempts that will be retried, pairs left in the
+  source folder, sidecars whose data file never arrived, or tar stream
+  members (their results go back to the sender)
+- Remove markers: they age out with their data file (retention janitors)
+*/
+
+// resultFileSuffix is appended to a delivered data file's path for its result marker
+const resultFileSuffix = ".result.json"
+
+// Outcomes recorded in result files
+const (
+	ResultVerified = "verified"
+	ResultFailed   = "failed"
+)
+
+// ResultFile is the content of a result marker
+type ResultFile struct {
+	Outcome         string  `json:"outcome"`
+	File            string  `json:"file"` // Name of the data file as found in the source
+	Path            string  `json:"path"` // Where the data file is now
+	Algorithm       string  `json:"algorithm"`
+	ComputedHash    string  `json:"computedHash,omitempty"`
+	ExpectedHash    string  `json:"expectedHash,omitempty"`
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Timestamp       string  `json:"timestamp"`
+}
+
+// WriteResultFile writes the result marker of a data file delivered to dataPath
+func WriteResultFile(dataPath, outcome string, result VerificationResult) error {
+	return newResultFile(dataPath, outcome, result).write()
+}
+
+// newResultFile builds the result marker of a data file delivered to dataPath
+func newResultFile(dataPath, outcome string, result VerificationResult) ResultFile {
+	return ResultFile{
+		Outcome:         outcome,
+		File:            result.Job.FilePair.DataFile,
+		Path:            dataPath,
+		Algorithm:       result.Job.FilePair.hashAlgorithm(),
+		ComputedHash:    result.ComputedHash,
+		ExpectedHash:    result.ExpectedHash,
+		Error:           result.ErrorMessage,
+		DurationSeconds: result.Duration.Seconds(),
+		Timestamp:       result.Timestamp.Format(time.RFC3339),
+	}
+}
+
+// write writes the marker next to the data file at its Path
+func (rf ResultFile) write() error {
+	content, err := json.Marshal(rf)
+	if err != nil {
+		return err
+	}
+
+	// ".tmp" keeps the janitors and reconciliation away from it
+	resultPath := rf.Path + resultFileSuffix
+	tmpPath := resultPath + ".tmp"
+	if err := os.WriteFile(tmpPath, append(content, '\n'), 0644); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(resultPath), err)
+	}
+	if err := os.Rename(tmpPath, resultPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename %s: %w", filepath.Base(resultPath), err)
+	}
+	return nil
+}
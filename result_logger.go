@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ResultLogger is implemented by every sink that records verification
+// results and periodic statistics (CSV files, syslog, Kafka, database, ...)
+type ResultLogger interface {
+	LogVerification(entry CSVLogEntry) error
+	LogStats(entry StatsEntry) error
+	Close() error
+}
+
+// MultiLogger fans out every record to several result sinks
+type MultiLogger struct {
+	loggers []ResultLogger
+}
+
+// NewResultLogger creates the result sinks selected in the output configuration
+func NewResultLogger(output OutputConfig, database DatabaseConfig) (ResultLogger, error) {
+	var loggers []ResultLogger
+
+	for _, sink := range output.Sinks {
+		var logger ResultLogger
+		var err error
+
+		switch sink {
+		case "csv":
+			logger, err = NewCSVLogger(output.VerificationFile, output.StatsFile, output.FlushInterval, output.FlushImmediately)
+			if err != nil && output.CSVOptional {
+				// Keep verifying, with the records written to stderr instead
+				fmt.Fprintf(os.Stderr, "WARNING: CSV output unavailable, logging verification records to stderr: %v\n", err)
+				logger, err = NewStderrLogger(), nil
+			}
+		case "syslog":
+			logger, err = NewSyslogLogger(output.Syslog)
+		case "kafka":
+			logger, err = NewKafkaLogger(output.Kafka)
+		case "database":
+			logger, err = NewDatabaseLogger(database)
+		case "ledger":
+			logger, err = NewLedgerLogger(output.Ledger)
+		default:
+			err = fmt.Errorf("unknown output sink: %s", sink)
+		}
+
+		if err != nil {
+			// Close sinks that were already opened
+			for _, opened := range loggers {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("failed to create %s sink: %w", sink, err)
+		}
+
+		loggers = append(loggers, logger)
+	}
+
+	if len(loggers) == 1 {
+		return loggers[0], nil
+	}
+
+	return &MultiLogger{loggers: loggers}, nil
+}
+
+// LogVerification writes a verification record to every sink
+func (m *MultiLogger) LogVerification(entry CSVLogEntry) error {
+	var errs []error
+	for _, logger := range m.loggers {
+		if err := logger.LogVerification(entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("errors logging verification: %v", errs)
+	}
+	return nil
+}
+
+// LogStats writes a statistics record to every sink
+func (m *MultiLogger) LogStats(entry StatsEntry) error {
+	var errs []error
+	for _, logger := range m.loggers {
+		if err := logger.LogStats(entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("errors logging stats: %v", errs)
+	}
+	return nil
+}
+
+// Close closes every sink
+func (m *MultiLogger) Close() error {
+	var errs []error
+	for _, logger := range m.loggers {
+		if err := logger.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("errors closing result loggers: %v", errs)
+	}
+	return nil
+}
+
+// StderrLogger writes verification records to stderr in CSV format
+// It stands in for the CSV sink when its files cannot be opened (output.csvOptional)
+type StderrLogger struct {
+	mutex  sync.Mutex
+	writer *csv.Writer
+}
+
+// NewStderrLogger creates a logger writing to stderr
+func NewStderrLogger() *StderrLogger {
+	return &StderrLogger{writer: csv.NewWriter(os.Stderr)}
+}
+
+// LogVerification writes a verification record to stderr
+func (l *StderrLogger) LogVerification(entry CSVLogEntry) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.writer.Write([]string{
+		"[Result]",
+		entry.Timestamp,
+		entry.Filename,
+		entry.SHA256,
+		fmt.Sprintf("%d", entry.SizeBytes),
+		fmt.Sprintf("%.2f", entry.SizeKB),
+		fmt.Sprintf("%.4f", entry.Duration),
+		entry.Action,
+		entry.DestinationPath,
+		entry.ShadowHash,
+		formatHoleBytes(entry.HoleBytes),
+		formatCompressedBytes(entry.CompressedBytes),
+		entry.SourcePath,
+		entry.LinkedTo,
+	})
+	l.writer.Flush()
+	return l.writer.Error()
+}
+
+// LogStats does nothing, periodic statistics are already printed by the coordinator
+func (l *StderrLogger) LogStats(entry StatsEntry) error {
+	return nil
+}
+
+// Close flushes pending output
+func (l *StderrLogger) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.writer.Flush()
+	return l.writer.Error()
+}
@@ -1,30 +1,107 @@
 package main
 
 import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/crc64"
 	"io"
+	"io/fs"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Supported encodings for digests stored in .sha256 files
+const (
+	HashEncodingAuto   = "auto"   // Detect from the token shape
+	HashEncodingHex    = "hex"    // 64 hex characters
+	HashEncodingBase64 = "base64" // 44 base64 characters (standard or URL alphabet)
+)
+
+// Supported hash algorithms
+const (
+	AlgorithmSHA256 = "sha256"
+	AlgorithmSHA512 = "sha512"
+	AlgorithmSHA1   = "sha1"
+	AlgorithmMD5    = "md5"
+
+	// Non-cryptographic checksums: much faster, they detect accidental corruption
+	// (bit rot, truncated transfers) but NOT deliberate tampering
+	AlgorithmXXHash = "xxhash" // XXH64, 16 hex digits, as printed by xxhsum
+	AlgorithmCRC64  = "crc64"  // CRC-64/ECMA-182, 16 hex digits
+)
+
+// crc64Table is the ECMA-182 polynomial table used by the crc64 algorithm
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// hashAlgorithms maps algorithm names to hasher constructors
+var hashAlgorithms = map[string]func() hash.Hash{
+	AlgorithmSHA256: sha256.New,
+	AlgorithmSHA512: sha512.New,
+	AlgorithmSHA1:   sha1.New,
+	AlgorithmMD5:    md5.New,
+	AlgorithmXXHash: func() hash.Hash { return xxhash.New() },
+	AlgorithmCRC64:  func() hash.Hash { return crc64.New(crc64Table) },
+}
+
+// Sidecar reads are retried briefly because upload clients on SMB shares
+// can hold a sharing lock on the .sha256 file for a few milliseconds
+const (
+	sidecarReadAttempts = 4
+	sidecarReadBackoff  = 50 * time.Millisecond // Doubled after each failed attempt
 )
 
+// Verification errors that retrying cannot change
+var (
+	ErrHashMismatch        = errors.New("hash mismatch")
+	ErrInvalidExpectedHash = errors.New("invalid expected hash")
+	ErrEmptySidecar        = errors.New("empty sidecar")
+	ErrMispairedSidecar    = errors.New("sidecar names a different file")
+	ErrWrongAlgorithm      = errors.New("sidecar hash is for a different algorithm")
+)
+
+// sidecarReadRetries counts sidecar reads that had to be retried (exported via /metrics)
+var sidecarReadRetries atomic.Int64
+
 // ReadSHA256File reads the expected SHA256 hash from a .sha256 file
-// The file typically contains the hash in hex format, optionally followed by the filename
+// The file typically contains the hash in hex (or base64) format, optionally followed by the filename
 // Example formats:
 //
 //	abc123def456...  data.zip
 //	abc123def456...
-func ReadSHA256File(sha256Path string) (string, error) {
-	data, err := os.ReadFile(sha256Path)
+//	q83vEjRWeJ...=
+//
+// The digest length is checked against algo (normally sha256)
+// The hash is always returned in lowercase hexadecimal format
+func ReadSHA256File(sha256Path, encoding, algo string) (string, error) {
+	data, err := readSidecar(sha256Path)
 	if err != nil {
 		return "", fmt.Errorf("failed to read SHA256 file: %w", err)
 	}
 
+	return ParseSHA256Content(data, encoding, algo)
+}
+
+// ParseSHA256Content extracts the expected hash from the contents of a .sha256 file
+func ParseSHA256Content(data []byte, encoding, algo string) (string, error) {
 	// Convert to string and clean up
 	content := strings.TrimSpace(string(data))
 	if content == "" {
-		return "", fmt.Errorf("SHA256 file is empty")
+		return "", ErrEmptySidecar
 	}
 
 	// Split by whitespace (hash might be followed by filename)
@@ -34,24 +111,270 @@ func ReadSHA256File(sha256Path string) (string, error) {
 	}
 
 	// First part is the hash
-	hash := strings.ToLower(parts[0])
+	return parseDigest(parts[0], encoding, algo)
+}
+
+// ReadSHA256Candidates reads every acceptable hash from a .sha256 file listing
+// several of them, one per line (same line format as ReadSHA256File)
+func ReadSHA256Candidates(sha256Path, encoding, algo string) ([]string, error) {
+	data, err := readSidecar(sha256Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SHA256 file: %w", err)
+	}
+
+	return ParseSHA256Candidates(data, encoding, algo)
+}
+
+// ParseSHA256Candidates extracts one expected hash from each non-blank line
+// Any line that cannot be parsed invalidates the whole file
+func ParseSHA256Candidates(data []byte, encoding, algo string) ([]string, error) {
+	var candidates []string
+	for lineNumber, line := range strings.Split(string(data), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+
+		hash, err := parseDigest(parts[0], encoding, algo)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber+1, err)
+		}
+		candidates = append(candidates, hash)
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrEmptySidecar
+	}
+	return candidates, nil
+}
+
+// CheckSidecarFilename checks that every file name listed after a hash in a
+// .sha256 file is dataFile ("<hash>  data.zip" or "<hash> *data.zip")
+// Lines holding only a hash pass; a different name returns ErrMispairedSidecar
+func CheckSidecarFilename(sha256Path, dataFile string) error {
+	data, err := readSidecar(sha256Path)
+	if err != nil {
+		return fmt.Errorf("failed to read SHA256 file: %w", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			continue
+		}
+
+		// The name may carry sha256sum's binary-mode marker and a (Unix or Windows) path
+		name := strings.TrimPrefix(strings.Join(parts[1:], " "), "*")
+		if path.Base(strings.ReplaceAll(name, "\\", "/")) != dataFile {
+			return fmt.Errorf("%w: %s names %q", ErrMispairedSidecar, filepath.Base(sha256Path), name)
+		}
+	}
+
+	return nil
+}
 
-	// Validate hash format (should be 64 hex characters for SHA256)
-	if len(hash) != 64 {
-		return "", fmt.Errorf("invalid SHA256 hash length: expected 64, got %d", len(hash))
+// readSidecar reads a .sha256 file, retrying transient errors such as sharing locks
+// A missing file is not transient and is returned immediately
+func readSidecar(sha256Path string) ([]byte, error) {
+	backoff := sidecarReadBackoff
+
+	for attempt := 1; ; attempt++ {
+		data, err := os.ReadFile(sha256Path)
+		if err == nil || errors.Is(err, fs.ErrNotExist) || attempt == sidecarReadAttempts {
+			return data, err
+		}
+
+		sidecarReadRetries.Add(1)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// digestSize returns the raw digest length in bytes of a hash algorithm
+func digestSize(algo string) (int, error) {
+	newHasher, ok := hashAlgorithms[algo]
+	if !ok {
+		return 0, fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+	return newHasher().Size(), nil
+}
+
+// parseDigest decodes a hex or base64 digest for algo and normalizes it to lowercase hex
+// A digest whose length fits another supported algorithm is reported as ErrWrongAlgorithm
+func parseDigest(token, encoding, algo string) (string, error) {
+	size, err := digestSize(algo)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := decodeDigest(token, encoding, size, strings.ToUpper(algo))
+	if err != nil {
+		if others := digestAlgorithms(token, encoding, algo); len(others) > 0 {
+			for i, other := range others {
+				others[i] = strings.ToUpper(other)
+			}
+			return "", fmt.Errorf("%w: sidecar looks like %s but configured algorithm is %s",
+				ErrWrongAlgorithm, strings.Join(others, " or "), strings.ToUpper(algo))
+		}
+		return "", err
+	}
+	return hash, nil
+}
+
+// digestAlgorithms returns the supported algorithms other than algo that token is a valid digest of, sorted
+func digestAlgorithms(token, encoding, algo string) []string {
+	var matches []string
+	for other, newHasher := range hashAlgorithms {
+		if other == algo {
+			continue
+		}
+		if _, err := decodeDigest(token, encoding, newHasher().Size(), other); err == nil {
+			matches = append(matches, other)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// detectSidecarAlgorithm returns the algorithm a sidecar's digest belongs to when it is not algo
+// ok is false unless exactly one other supported algorithm fits (xxhash and crc64 share a length)
+func detectSidecarAlgorithm(sha256Path, encoding, algo string) (detected string, ok bool) {
+	data, err := readSidecar(sha256Path)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Fields(string(data))
+	if len(parts) == 0 {
+		return "", false
+	}
+
+	others := digestAlgorithms(parts[0], encoding, algo)
+	if len(others) != 1 {
+		return "", false
+	}
+	return others[0], true
+}
+
+// decodeDigest decodes a hex or base64 digest of size bytes (name is used in errors)
+func decodeDigest(token, encoding string, size int, name string) (string, error) {
+
+	switch encoding {
+	case HashEncodingHex:
+		return parseHexDigest(token, size, name)
+	case HashEncodingBase64:
+		return parseBase64Digest(token, size, name)
+	default:
+		// A hex digest has a fixed length that its base64 form never has
+		if len(token) == hex.EncodedLen(size) {
+			return parseHexDigest(token, size, name)
+		}
+		if hash, err := parseBase64Digest(token, size, name); err == nil {
+			return hash, nil
+		}
+		return parseHexDigest(token, size, name)
+	}
+}
+
+// parseHexDigest validates a hex encoded digest of size bytes
+func parseHexDigest(token string, size int, name string) (string, error) {
+	hash := strings.ToLower(token)
+
+	// Validate hash format (e.g. 64 hex characters for SHA256)
+	if len(hash) != hex.EncodedLen(size) {
+		return "", fmt.Errorf("invalid %s hash length: expected %d, got %d", name, hex.EncodedLen(size), len(hash))
 	}
 
 	// Validate it's a valid hex string
 	if _, err := hex.DecodeString(hash); err != nil {
-		return "", fmt.Errorf("invalid SHA256 hash format: %w", err)
+		return "", fmt.Errorf("invalid %s hash format: %w", name, err)
 	}
 
 	return hash, nil
 }
 
+// parseBase64Digest decodes a base64 encoded digest of size bytes into lowercase hex
+// Both the standard and URL-safe alphabets are accepted, with or without padding
+func parseBase64Digest(token string, size int, name string) (string, error) {
+	encodings := []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	}
+
+	for _, enc := range encodings {
+		raw, err := enc.DecodeString(token)
+		if err != nil {
+			continue
+		}
+		if len(raw) != size {
+			return "", fmt.Errorf("invalid base64 %s hash length: expected %d bytes, got %d", name, size, len(raw))
+		}
+		return hex.EncodeToString(raw), nil
+	}
+
+	return "", fmt.Errorf("invalid base64 %s hash format: %q", name, token)
+}
+
 // ComputeFileSHA256 computes the SHA256 hash of a file using the specified buffer size
 // Returns the hash in lowercase hexadecimal format
 func ComputeFileSHA256(filePath string, bufferSize int) (string, error) {
+	return ComputeFileHash(filePath, bufferSize, AlgorithmSHA256)
+}
+
+// ComputeFileHash computes the hash of a file with the given algorithm
+// Returns the hash in lowercase hexadecimal format
+func ComputeFileHash(filePath string, bufferSize int, algo string) (string, error) {
+	return computeFileHash(filePath, bufferSize, algo, nil, nil, nil)
+}
+
+// ProgressFunc is called with the number of bytes read after every read while hashing
+// A non-nil error stops hashing with that error (e.g. the job was cancelled)
+type ProgressFunc func(bytesRead int) error
+
+// progressReader reports every read to a ProgressFunc
+type progressReader struct {
+	r        io.Reader
+	progress ProgressFunc
+}
+
+// Read reads from the underlying reader and reports the bytes read
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	if n > 0 {
+		if stop := p.progress(n); stop != nil {
+			return n, stop
+		}
+	}
+	return n, err
+}
+
+// FileHasher is how data files are read for hashing: large reads for object-store
+// mounts, checkpoints for very large files, and the memory bound of the bspatch
+// transform. A nil FileHasher reads with the job's buffer size and the default bound
+type FileHasher struct {
+	LargeReads      *LargeReads   // nil = bufferSize reads
+	Checkpoints     *Checkpointer // nil = never resume
+	BSPatchMaxBytes int64         // 0 = defaultBSPatchMaxBytes
+}
+
+// computeFileHash is FileHasher.hashFile with plain reads
+func computeFileHash(filePath string, bufferSize int, algo string, transforms []string, shadow io.Writer, progress ProgressFunc) (string, error) {
+	return (*FileHasher)(nil).hashFile(filePath, bufferSize, algo, transforms, shadow, progress)
+}
+
+// bspatchMaxBytes returns the memory bound of the bspatch transform
+func (h *FileHasher) bspatchMaxBytes() int64 {
+	if h == nil || h.BSPatchMaxBytes == 0 {
+		return defaultBSPatchMaxBytes
+	}
+	return h.BSPatchMaxBytes
+}
+
+// hashFile computes the hash of a file, reporting progress when progress is not nil
+// The content is passed through the named pre-hash transforms first (see transforms.go)
+// A non-nil shadow is fed the file's bytes in the same pass (e.g. a second hasher)
+func (h *FileHasher) hashFile(filePath string, bufferSize int, algo string, transforms []string, shadow io.Writer, progress ProgressFunc) (string, error) {
 	// Open file for reading
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -59,17 +382,84 @@ func ComputeFileSHA256(filePath string, bufferSize int) (string, error) {
 	}
 	defer file.Close()
 
-	// Create SHA256 hasher
-	hasher := sha256.New()
+	var largeReads *LargeReads
+	var checkpoints *Checkpointer
+	if h != nil {
+		largeReads, checkpoints = h.LargeReads, h.Checkpoints
+	}
+
+	// Object-store mounts are read in large buffers
+	if largeReads != nil {
+		bufferSize = largeReads.bufferSize
+	}
+
+	// Progress and the shadow see the file itself, not the transformed content
+	if len(transforms) > 0 {
+		raw := withProgress(file, progress)
+		if shadow != nil {
+			raw = io.TeeReader(raw, shadow)
+		}
+		r, err := applyTransforms(raw, transforms, filePath, h.bspatchMaxBytes())
+		if err != nil {
+			return "", fmt.Errorf("transform failed: %w", err)
+		}
+		hash, err := hashReader(r, bufferSize, algo)
+		if err == nil && shadow != nil {
+			// A transform may stop before the end of the file (e.g. trailing bytes after a gzip stream)
+			if _, err := io.Copy(io.Discard, raw); err != nil {
+				return "", fmt.Errorf("failed to read file: %w", err)
+			}
+		}
+		return hash, err
+	}
+
+	// Large files can resume from a checkpoint left by an interrupted run
+	// (not with a shadow, which would miss the part hashed before the checkpoint)
+	if checkpoints != nil && shadow == nil {
+		return checkpoints.hashFile(file, filePath, bufferSize, algo, progress)
+	}
+	if largeReads != nil {
+		return largeReads.hashReaderTo(withProgress(file, progress), algo, shadow)
+	}
+	return hashReaderTo(withProgress(file, progress), bufferSize, algo, shadow)
+}
+
+// withProgress wraps r to report reads to progress (r itself when progress is nil)
+func withProgress(r io.Reader, progress ProgressFunc) io.Reader {
+	if progress == nil {
+		return r
+	}
+	return &progressReader{r: r, progress: progress}
+}
+
+// hashReader computes the hash of everything read from r with the given algorithm
+// This is the hashing core: it never touches the filesystem, so any stream
+// (stdin, decompressed archives, rate-limited readers) can be hashed
+func hashReader(r io.Reader, bufferSize int, algo string) (string, error) {
+	return hashReaderTo(r, bufferSize, algo, nil)
+}
+
+// hashReaderTo is hashReader that also writes everything read to shadow (nil = none)
+func hashReaderTo(r io.Reader, bufferSize int, algo string, shadow io.Writer) (string, error) {
+	// Create hasher for the requested algorithm
+	newHasher, ok := hashAlgorithms[algo]
+	if !ok {
+		return "", fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+	hasher := newHasher()
+	var w io.Writer = hasher
+	if shadow != nil {
+		w = io.MultiWriter(hasher, shadow)
+	}
 
 	// Create buffer with specified size for efficient reading
 	buffer := make([]byte, bufferSize)
 
 	// Read file in chunks and update hash
 	for {
-		bytesRead, err := file.Read(buffer)
+		bytesRead, err := r.Read(buffer)
 		if bytesRead > 0 {
-			hasher.Write(buffer[:bytesRead])
+			w.Write(buffer[:bytesRead])
 		}
 		if err == io.EOF {
 			break
@@ -86,31 +476,116 @@ func ComputeFileSHA256(filePath string, bufferSize int) (string, error) {
 	return hashString, nil
 }
 
-// VerifyFile verifies that a data file matches its SHA256 checksum
-// Returns computed hash, expected hash, and any error
-func VerifyFile(dataFilePath, sha256FilePath string, bufferSize int) (computed string, expected string, err error) {
+// VerifyFile is FileHasher.VerifyFile with plain reads
+func VerifyFile(dataFilePath, sha256FilePath string, bufferSize int, hashEncoding, algo string, anyMatch bool, transforms []string, shadow io.Writer, progress ProgressFunc) (computed string, expected string, err error) {
+	return (*FileHasher)(nil).VerifyFile(dataFilePath, sha256FilePath, bufferSize, hashEncoding, algo, anyMatch, transforms, shadow, progress)
+}
+
+// VerifyFile verifies that a data file matches the checksum in its sidecar
+// algo selects the hash algorithm (normally sha256); transforms and progress may be nil
+// With anyMatch the sidecar lists several acceptable hashes, one per line, and
+// the file passes if it matches any of them
+// A non-nil shadow is fed the file's bytes in the same pass without affecting the verdict
+// Returns computed hash, expected hash (the candidate that matched), and any error
+func (h *FileHasher) VerifyFile(dataFilePath, sha256FilePath string, bufferSize int, hashEncoding, algo string, anyMatch bool, transforms []string, shadow io.Writer, progress ProgressFunc) (computed string, expected string, err error) {
+	if anyMatch {
+		return h.verifyFileCandidates(dataFilePath, sha256FilePath, bufferSize, hashEncoding, algo, transforms, shadow, progress)
+	}
+
 	// Read expected hash from .sha256 file
-	expectedHash, err := ReadSHA256File(sha256FilePath)
+	expectedHash, err := ReadSHA256File(sha256FilePath, hashEncoding, algo)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to read expected hash: %w", err)
 	}
 
 	// Compute actual hash of data file
-	computedHash, err := ComputeFileSHA256(dataFilePath, bufferSize)
+	computedHash, err := h.hashFile(dataFilePath, bufferSize, algo, transforms, shadow, progress)
 	if err != nil {
 		return "", expectedHash, fmt.Errorf("failed to compute hash: %w", err)
 	}
 
-	// Compare hashes (case-insensitive)
-	if strings.ToLower(computedHash) != strings.ToLower(expectedHash) {
-		return computedHash, expectedHash, fmt.Errorf("hash mismatch")
+	if !digestsEqual(computedHash, expectedHash) {
+		return computedHash, expectedHash, ErrHashMismatch
 	}
 
 	return computedHash, expectedHash, nil
 }
 
+// verifyFileCandidates verifies a data file against every hash listed in its sidecar
+// On a miss the first candidate is reported as the expected hash
+func (h *FileHasher) verifyFileCandidates(dataFilePath, sha256FilePath string, bufferSize int, hashEncoding, algo string, transforms []string, shadow io.Writer, progress ProgressFunc) (string, string, error) {
+	candidates, err := ReadSHA256Candidates(sha256FilePath, hashEncoding, algo)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read expected hash: %w", err)
+	}
+
+	computedHash, err := h.hashFile(dataFilePath, bufferSize, algo, transforms, shadow, progress)
+	if err != nil {
+		return "", candidates[0], fmt.Errorf("failed to compute hash: %w", err)
+	}
+
+	for _, candidate := range candidates {
+		if digestsEqual(computedHash, candidate) {
+			return computedHash, candidate, nil
+		}
+	}
+
+	if len(candidates) == 1 {
+		return computedHash, candidates[0], ErrHashMismatch
+	}
+	return computedHash, candidates[0], fmt.Errorf("%w: none of %d candidates matched", ErrHashMismatch, len(candidates))
+}
+
+// VerifyFileAgainst is FileHasher.VerifyFileAgainst with plain reads
+func VerifyFileAgainst(dataFilePath, expected string, bufferSize int, hashEncoding, algo string, transforms []string, shadow io.Writer, progress ProgressFunc) (computed string, expectedHash string, err error) {
+	return (*FileHasher)(nil).VerifyFileAgainst(dataFilePath, expected, bufferSize, hashEncoding, algo, transforms, shadow, progress)
+}
+
+// VerifyFileAgainst verifies a data file against an expected digest given directly
+// (e.g. embedded in the file name) instead of one read from a .sha256 file
+// An expected digest that cannot be parsed is reported as ErrInvalidExpectedHash
+func (h *FileHasher) VerifyFileAgainst(dataFilePath, expected string, bufferSize int, hashEncoding, algo string, transforms []string, shadow io.Writer, progress ProgressFunc) (computed string, expectedHash string, err error) {
+	expectedHash, err = parseDigest(expected, hashEncoding, algo)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidExpectedHash, err)
+	}
+
+	computedHash, err := h.hashFile(dataFilePath, bufferSize, algo, transforms, shadow, progress)
+	if err != nil {
+		return "", expectedHash, fmt.Errorf("failed to compute hash: %w", err)
+	}
+
+	if !digestsEqual(computedHash, expectedHash) {
+		return computedHash, expectedHash, ErrHashMismatch
+	}
+
+	return computedHash, expectedHash, nil
+}
+
+// digestsEqual compares two digests by their raw bytes rather than their text,
+// so a base64 sidecar and a hex computed hash of the same digest are equal
+// Either side may be hex (any case) or base64; an undecodable digest equals nothing
+func digestsEqual(a, b string) bool {
+	rawA, okA := rawDigest(a)
+	rawB, okB := rawDigest(b)
+	return okA && okB && bytes.Equal(rawA, rawB)
+}
+
+// rawDigest decodes a hex or base64 digest to its bytes
+func rawDigest(digest string) ([]byte, bool) {
+	if raw, err := hex.DecodeString(digest); err == nil {
+		return raw, true
+	}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if raw, err := encoding.DecodeString(digest); err == nil {
+			return raw, true
+		}
+	}
+	return nil, false
+}
+
 // VerifyFileMatch is a boolean helper that returns true if verification succeeds
-func VerifyFileMatch(dataFilePath, sha256FilePath string, bufferSize int) bool {
-	_, _, err := VerifyFile(dataFilePath, sha256FilePath, bufferSize)
+func VerifyFileMatch(dataFilePath, sha256FilePath string, bufferSize int, hashEncoding, algo string) bool {
+	_, _, err := VerifyFile(dataFilePath, sha256FilePath, bufferSize, hashEncoding, algo, false, nil, nil, nil)
 	return err == nil
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// dataSHA256 is the SHA256 of "data", the content of the test data files
+const dataSHA256 = "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7"
+
+// base64Of re-encodes a hex digest in base64 with enc
+func base64Of(t *testing.T, hexDigest string, enc *base64.Encoding) string {
+	t.Helper()
+	raw, err := hex.DecodeString(hexDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc.EncodeToString(raw)
+}
+
+func TestParseSidecarEncodings(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		encoding string
+	}{
+		{"hex", dataSHA256 + "  data.zip\n", HashEncodingAuto},
+		{"uppercase hex", "3A6EB0790F39AC87C94F3856B2DD2C5D110E6811602261A9A923D3BB23ADC8B7", HashEncodingAuto},
+		{"base64", base64Of(t, dataSHA256, base64.StdEncoding) + "  data.zip\n", HashEncodingAuto},
+		{"base64 without padding", base64Of(t, dataSHA256, base64.RawStdEncoding), HashEncodingAuto},
+		{"base64 URL alphabet", base64Of(t, dataSHA256, base64.URLEncoding), HashEncodingAuto},
+		{"hex hint", dataSHA256, HashEncodingHex},
+		{"base64 hint", base64Of(t, dataSHA256, base64.StdEncoding), HashEncodingBase64},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hash, err := ParseSHA256Content([]byte(test.content), test.encoding, AlgorithmSHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hash != dataSHA256 {
+				t.Errorf("hash = %s, want %s", hash, dataSHA256)
+			}
+		})
+	}
+}
+
+func TestParseSidecarEncodingHintRejectsOtherEncoding(t *testing.T) {
+	if _, err := ParseSHA256Content([]byte(base64Of(t, dataSHA256, base64.StdEncoding)), HashEncodingHex, AlgorithmSHA256); err == nil {
+		t.Error("base64 digest accepted with the hex hint")
+	}
+	if _, err := ParseSHA256Content([]byte(dataSHA256), HashEncodingBase64, AlgorithmSHA256); err == nil {
+		t.Error("hex digest accepted with the base64 hint")
+	}
+}
+
+func TestVerifyFileWithBothEncodings(t *testing.T) {
+	for _, sidecar := range []string{dataSHA256, base64Of(t, dataSHA256, base64.StdEncoding)} {
+		dir := t.TempDir()
+		dataPath := writeTestFile(t, dir, "data.zip", "data")
+		sidecarPath := writeTestFile(t, dir, "data.zip.sha256", sidecar+"  data.zip\n")
+
+		computed, expected, err := VerifyFile(dataPath, sidecarPath, 4096, HashEncodingAuto, AlgorithmSHA256, false, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("sidecar %s: %v", sidecar, err)
+		}
+		// Hashes are normalized to hex for comparison and logging
+		if computed != dataSHA256 || expected != dataSHA256 {
+			t.Errorf("sidecar %s: computed %s, expected %s, want both %s", sidecar, computed, expected, dataSHA256)
+		}
+	}
+
+	dir := t.TempDir()
+	dataPath := writeTestFile(t, dir, "data.zip", "changed")
+	sidecarPath := writeTestFile(t, dir, "data.zip.sha256", base64Of(t, dataSHA256, base64.StdEncoding))
+	if _, _, err := VerifyFile(dataPath, sidecarPath, 4096, HashEncodingAuto, AlgorithmSHA256, false, nil, nil, nil); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("err = %v, want ErrHashMismatch", err)
+	}
+}
+
+func TestDigestsEqualAcrossEncodings(t *testing.T) {
+	const otherSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	tests := []struct {
+		name     string
+		expected string
+		want     bool
+	}{
+		{"base64", base64Of(t, dataSHA256, base64.StdEncoding), true},
+		{"base64 without padding", base64Of(t, dataSHA256, base64.RawStdEncoding), true},
+		{"base64 URL alphabet", base64Of(t, dataSHA256, base64.RawURLEncoding), true},
+		{"uppercase hex", strings.ToUpper(dataSHA256), true},
+		{"base64 of another digest", base64Of(t, otherSHA256, base64.StdEncoding), false},
+		{"undecodable", "not a digest", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The computed hash is always hex
+			if got := digestsEqual(dataSHA256, test.expected); got != test.want {
+				t.Errorf("digestsEqual(%s, %s) = %v, want %v", dataSHA256, test.expected, got, test.want)
+			}
+		})
+	}
+}
+
+func TestVerifyFileAnyMatch(t *testing.T) {
+	const (
+		emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		abcSHA256   = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	)
+	dir := t.TempDir()
+	dataPath := writeTestFile(t, dir, "data.zip", "data")
+
+	// The matching hash is not the first one listed
+	sidecarPath := writeTestFile(t, dir, "data.zip.sha256", emptySHA256+"  data.zip\n\n"+dataSHA256+"  data.zip\n"+abcSHA256+"\n")
+	computed, expected, err := VerifyFile(dataPath, sidecarPath, 4096, HashEncodingAuto, AlgorithmSHA256, true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if computed != dataSHA256 || expected != dataSHA256 {
+		t.Errorf("computed %s, expected %s, want the matching candidate %s", computed, expected, dataSHA256)
+	}
+
+	// No candidate matches: the first one is reported
+	sidecarPath = writeTestFile(t, dir, "data.zip.sha256", emptySHA256+"\n"+abcSHA256+"\n")
+	_, expected, err = VerifyFile(dataPath, sidecarPath, 4096, HashEncodingAuto, AlgorithmSHA256, true, nil, nil, nil)
+	if !errors.Is(err, ErrHashMismatch) || !strings.Contains(err.Error(), "none of 2 candidates") {
+		t.Errorf("err = %v, want a mismatch of both candidates", err)
+	}
+	if expected != emptySHA256 {
+		t.Errorf("expected %s, want the first candidate", expected)
+	}
+
+	// One unparsable line invalidates the whole sidecar, even with a matching line
+	sidecarPath = writeTestFile(t, dir, "data.zip.sha256", dataSHA256+"\nnot-a-hash\n")
+	if _, _, err = VerifyFile(dataPath, sidecarPath, 4096, HashEncodingAuto, AlgorithmSHA256, true, nil, nil, nil); err == nil || errors.Is(err, ErrHashMismatch) {
+		t.Errorf("err = %v, want the invalid line reported", err)
+	}
+}
+
+// Digests of "data" with other algorithms
+const (
+	dataMD5    = "8d777f385d3dfec8815d20f7496026dc"
+	dataSHA512 = "77c7ce9a5d86bb386d443bb96390faa120633158699c8844c30b13ab0bf92760b7e4416aea397db91b4ac0e5dd56b8ef7e4b066162ab1fdc088319ce6defc876"
+)
+
+func TestParseDigestOfAnotherAlgorithm(t *testing.T) {
+	tests := []struct {
+		digest    string
+		algorithm string
+		looksLike string // "" = not reported as another algorithm
+	}{
+		{dataMD5, AlgorithmSHA256, "MD5"},
+		{dataSHA512, AlgorithmSHA256, "SHA512"},
+		{"0123456789abcdef", AlgorithmSHA256, "CRC64 or XXHASH"},
+		{dataSHA256, AlgorithmMD5, "SHA256"},
+		{base64Of(t, dataMD5, base64.StdEncoding), AlgorithmSHA256, "MD5"},
+		{"not-a-digest-of-any-length", AlgorithmSHA256, ""},
+		{dataSHA256[:63], AlgorithmSHA256, ""},
+	}
+	for _, test := range tests {
+		_, err := ParseSHA256Content([]byte(test.digest), HashEncodingAuto, test.algorithm)
+		if test.looksLike == "" {
+			if err == nil || errors.Is(err, ErrWrongAlgorithm) {
+				t.Errorf("%s as %s: err = %v, want an invalid digest", test.digest, test.algorithm, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrWrongAlgorithm) || !strings.Contains(err.Error(), "looks like "+test.looksLike+" ") {
+			t.Errorf("%s as %s: err = %v, want it to look like %s", test.digest, test.algorithm, err, test.looksLike)
+		}
+	}
+}
+
+func TestDetectSidecarAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		digest string
+		want   string // "" = not detected
+	}{
+		{dataMD5, AlgorithmMD5},
+		{dataSHA512, AlgorithmSHA512},
+		{"0123456789abcdef", ""}, // Either XXH64 or CRC64
+		{"", ""},
+	}
+	for _, test := range tests {
+		path := writeTestFile(t, dir, "data.zip.sha256", test.digest+"  data.zip\n")
+		got, ok := detectSidecarAlgorithm(path, HashEncodingAuto, AlgorithmSHA256)
+		if ok != (test.want != "") || got != test.want {
+			t.Errorf("digest %q: detected %q (%v), want %q", test.digest, got, ok, test.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/*
+Sidecar signatures let high-assurance partners sign their sidecars, so a
+sidecar forged or altered on the way cannot vouch for a tampered data file
+(verification.signature).
+
+The signature is a detached ed25519 signature over the exact bytes of the
+sidecar, stored next to it as "<sidecar>.sig" (configurable suffix): the raw
+64 bytes, or their base64 or hex encoding. It can be made with
+"openssl pkeyutl -sign -rawin -inkey partner.pem -in data.zip.sha256 -out data.zip.sha256.sig".
+The public key file holds the 32-byte key raw, in base64 or hex, or PEM
+encoded ("PUBLIC KEY", as written by "openssl pkey -pubout").
+
+Responsibilities:
+1. Load the partner public key at startup
+2. Verify a sidecar's signature before its hash is trusted; a missing
+   signature fails when signatures are required (it may still be uploading,
+   so it is retried until the retry timeout), an invalid one fails at once
+3. Name the signature file that travels with its sidecar (deleted on
+   delivery, moved with the data file on failure)
+
+Does NOT:
+- Support OpenPGP signatures
+- Apply to hashes from file names, manifests or the database
+- Remove the signature of an orphaned sidecar
+*/
+
+// Signature failures; both send the data file to the quarantine folder (DLQ if none)
+var (
+	ErrInvalidSignature = errors.New("invalid sidecar signature")
+	ErrMissingSignature = errors.New("missing sidecar signature")
+)
+
+// SignatureVerifier verifies detached ed25519 signatures of sidecars
+type SignatureVerifier struct {
+	publicKey ed25519.PublicKey
+	suffix    string // Appended to the sidecar name
+	required  bool   // Unsigned sidecars fail
+}
+
+// LoadSignatureVerifier reads the public key sidecars are verified with
+func LoadSignatureVerifier(cfg SignatureConfig) (*SignatureVerifier, error) {
+	data, err := os.ReadFile(cfg.PublicKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	publicKey, err := parsePublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.PublicKeyFile, err)
+	}
+
+	return &SignatureVerifier{publicKey: publicKey, suffix: cfg.Suffix, required: cfg.Required}, nil
+}
+
+// parsePublicKey decodes an ed25519 public key in PEM, raw, base64 or hex form
+func parsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PEM public key: %w", err)
+		}
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is %T, not ed25519", key)
+		}
+		return publicKey, nil
+	}
+
+	raw, ok := decodeKeyBytes(data, ed25519.PublicKeySize)
+	if !ok {
+		return nil, fmt.Errorf("not an ed25519 public key (expected PEM or %d bytes raw, base64 or hex)", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// decodeKeyBytes accepts exactly size bytes, raw or as base64 or hex text
+func decodeKeyBytes(data []byte, size int) ([]byte, bool) {
+	if len(data) == size {
+		return data, true
+	}
+
+	text := strings.TrimSpace(string(data))
+	if raw, err := hex.DecodeString(text); err == nil && len(raw) == size {
+		return raw, true
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if raw, err := enc.DecodeString(text); err == nil && len(raw) == size {
+			return raw, true
+		}
+	}
+	return nil, false
+}
+
+// SignaturePath returns the path of a sidecar's signature file
+func (sv *SignatureVerifier) SignaturePath(sidecarPath string) string {
+	return sidecarPath + sv.suffix
+}
+
+// IsSignature reports whether a file name ends with the signature suffix
+func (sv *SignatureVerifier) IsSignature(filename string) bool {
+	return strings.HasSuffix(filename, sv.suffix)
+}
+
+// Verify checks a sidecar's signature and returns the sidecar content it covers
+// An unsigned sidecar is accepted unless signatures are required
+func (sv *SignatureVerifier) Verify(sidecarPath string) ([]byte, error) {
+	content, err := readSidecar(sidecarPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sidecar: %w", err)
+	}
+
+	data, err := os.ReadFile(sv.SignaturePath(sidecarPath))
+	if errors.Is(err, os.ErrNotExist) {
+		if sv.required {
+			return nil, fmt.Errorf("%w: %s not found", ErrMissingSignature, sv.SignaturePath(sidecarPath))
+		}
+		return content, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature: %w", err)
+	}
+
+	signature, ok := decodeKeyBytes(data, ed25519.SignatureSize)
+	if !ok {
+		return nil, fmt.Errorf("%w: not a %d-byte ed25519 signature", ErrInvalidSignature, ed25519.SignatureSize)
+	}
+	if !ed25519.Verify(sv.publicKey, content, signature) {
+		return nil, fmt.Errorf("%w: does not match the sidecar content", ErrInvalidSignature)
+	}
+	return content, nil
+}
+
+// Unchanged reports whether a sidecar still has the content that was verified
+func (sv *SignatureVerifier) Unchanged(sidecarPath string, verified []byte) bool {
+	content, err := readSidecar(sidecarPath)
+	return err == nil && bytes.Equal(content, verified)
+}
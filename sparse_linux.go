@@ -0,0 +1,45 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lseek whence values locating data and holes in sparse files
+const (
+	seekData = 3 // SEEK_DATA
+	seekHole = 4 // SEEK_HOLE
+)
+
+// dataRegions returns the regions of a file that hold data, skipping holes
+// File systems without hole support report the whole file as data
+// The file offset is left undefined
+func dataRegions(file *os.File, size int64) ([]fileRegion, error) {
+	var regions []fileRegion
+	for offset := int64(0); offset < size; {
+		start, err := file.Seek(offset, seekData)
+		if errors.Is(err, syscall.ENXIO) {
+			// Only a hole is left up to the end of the file
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		end, err := file.Seek(start, seekHole)
+		if err != nil {
+			return nil, err
+		}
+		if end > size {
+			end = size
+		}
+		if end <= start {
+			break
+		}
+		regions = append(regions, fileRegion{start: start, end: end})
+		offset = end
+	}
+	return regions, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSparseFile creates a file of size bytes holding "data" at its start and its end,
+// with a hole between; it skips the test when the file system stores the hole as data
+func writeSparseFile(t *testing.T, dir string, size int64) string {
+	t.Helper()
+	path := filepath.Join(dir, "sparse.img")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteAt([]byte("data"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteAt([]byte("data"), size-4); err != nil {
+		t.Fatal(err)
+	}
+
+	holes, err := sparseHoleBytes(path, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holes == 0 {
+		t.Skip("holes are not supported here")
+	}
+	return path
+}
+
+func TestSparseHoleBytes(t *testing.T) {
+	const size = 4 << 20
+	path := writeSparseFile(t, t.TempDir(), size)
+
+	holes, _ := sparseHoleBytes(path, size)
+	if holes <= 0 || holes >= size-8 {
+		t.Errorf("holes = %d, want most of the %d bytes but not the data", holes, size)
+	}
+
+	dense := writeTestFile(t, t.TempDir(), "dense.zip", "data")
+	if holes, err := sparseHoleBytes(dense, 4); err != nil || holes != 0 {
+		t.Errorf("dense file: holes = %d, err = %v, want none", holes, err)
+	}
+}
+
+func TestSparseCopyKeepsHoles(t *testing.T) {
+	const size = 4 << 20
+	dir := t.TempDir()
+	source := writeSparseFile(t, dir, size)
+	want, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sparse := range []bool{true, false} {
+		dest := filepath.Join(dir, "copy.img")
+		if err := copyFile(source, dest, sparse); err != nil {
+			t.Fatalf("sparse %v: %v", sparse, err)
+		}
+		got, err := os.ReadFile(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("sparse %v: copy differs from the source", sparse)
+		}
+		if holes, _ := sparseHoleBytes(dest, size); sparse && holes == 0 {
+			t.Error("sparse copy is fully allocated")
+		}
+	}
+}
@@ -1,10 +1,20 @@
 package main
 
 import (
+	"sort"
 	"sync"
 	"time"
 )
 
+// durationSamples is how many recent processing durations are kept for percentiles
+const durationSamples = 1000
+
+// arrivalSkewBuckets are the upper bounds of the pair arrival skew histograms
+var arrivalSkewBuckets = []time.Duration{
+	time.Second, 5 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour,
+}
+
 // StatsTracker manages runtime statistics for file verification operations
 type StatsTracker struct {
 	mutex          sync.RWMutex
@@ -13,7 +23,28 @@ type StatsTracker struct {
 	failureCount   int64
 	pendingCount   int64
 	totalDuration  time.Duration
+	totalBytes     int64
 	startTime      time.Time
+	lastHeartbeat  time.Time
+	pendingSource  func() int64 // Live pending count (the file tracker), nil = use pendingCount
+	pendingDrift   int64        // Live minus cached pending count at the last reconciliation
+	purgedFiles    int64
+	purgedBytes    int64
+	dlqPurgedFiles int64
+	dlqPurgedBytes int64
+	dlqFiles       int64 // DLQ contents at the last DLQ janitor pass
+	dlqBytes       int64
+	mispaired      int64
+	panics         int64
+	stalls         int64
+	oracleReused   int64
+	hadFailures    bool // A file failed during the run (kept across ResetStatistics)
+	queueStalled   int64
+	queueWaitMax   time.Duration
+	durations      []time.Duration // Ring of the most recent processing durations
+	nextDuration   int             // Next slot to overwrite once durations is full
+	dataFirst      SkewHistogram   // Pairs whose data file arrived first
+	sidecarFirst   SkewHistogram   // Pairs whose sidecar arrived first
 }
 
 // NewStatsTracker creates a new statistics tracker
@@ -23,24 +54,77 @@ func NewStatsTracker() *StatsTracker {
 	}
 }
 
-// IncrementSuccess increments the success counter and updates total duration
-func (s *StatsTracker) IncrementSuccess(duration time.Duration) {
+// IncrementSuccess increments the success counter and updates total duration and bytes
+func (s *StatsTracker) IncrementSuccess(duration time.Duration, bytes int64) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	s.successCount++
 	s.totalProcessed++
 	s.totalDuration += duration
+	s.totalBytes += bytes
+	s.recordDuration(duration)
 }
 
-// IncrementFailure increments the failure counter and updates total duration
-func (s *StatsTracker) IncrementFailure(duration time.Duration) {
+// IncrementFailure increments the failure counter and updates total duration and bytes
+func (s *StatsTracker) IncrementFailure(duration time.Duration, bytes int64) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	s.failureCount++
+	s.hadFailures = true
 	s.totalProcessed++
 	s.totalDuration += duration
+	s.totalBytes += bytes
+	s.recordDuration(duration)
+}
+
+// HadFailures reports whether any file failed since startup, even if the counters were reset
+func (s *StatsTracker) HadFailures() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.hadFailures
+}
+
+// recordDuration keeps a processing duration for percentiles, caller holds the lock
+func (s *StatsTracker) recordDuration(duration time.Duration) {
+	if len(s.durations) < durationSamples {
+		s.durations = append(s.durations, duration)
+		return
+	}
+	s.durations[s.nextDuration] = duration
+	s.nextDuration = (s.nextDuration + 1) % durationSamples
+}
+
+// RecordArrivalSkew adds the time between the arrival of a pair's halves to its histogram
+// skew is positive when the sidecar arrived after the data file
+func (s *StatsTracker) RecordArrivalSkew(skew time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	histogram := &s.dataFirst
+	if skew < 0 {
+		histogram = &s.sidecarFirst
+		skew = -skew
+	}
+	if histogram.Buckets == nil {
+		histogram.Buckets = make([]int64, len(arrivalSkewBuckets))
+	}
+	for i, bound := range arrivalSkewBuckets {
+		if skew <= bound {
+			histogram.Buckets[i]++
+		}
+	}
+	histogram.Count++
+	histogram.Sum += skew
+}
+
+// snapshot returns a copy of the histogram with a count for every bucket
+func (h SkewHistogram) snapshot() SkewHistogram {
+	buckets := make([]int64, len(arrivalSkewBuckets))
+	copy(buckets, h.Buckets)
+	return SkewHistogram{Buckets: buckets, Count: h.Count, Sum: h.Sum}
 }
 
 // SetPendingCount sets the current number of pending files
@@ -51,6 +135,31 @@ func (s *StatsTracker) SetPendingCount(count int64) {
 	s.pendingCount = count
 }
 
+// SetPendingSource makes the given function the source of truth for the pending count
+// GetStatistics then reads it on demand instead of the cached value set by SetPendingCount
+func (s *StatsTracker) SetPendingSource(source func() int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.pendingSource = source
+}
+
+// ReconcilePending compares the cached pending count against the live source
+// Returns both values and records their difference for /metrics
+func (s *StatsTracker) ReconcilePending() (cached, actual int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cached = s.pendingCount
+	actual = cached
+	if s.pendingSource != nil {
+		actual = s.pendingSource()
+	}
+	s.pendingDrift = actual - cached
+
+	return cached, actual
+}
+
 // IncrementPending increments the pending counter
 func (s *StatsTracker) IncrementPending() {
 	s.mutex.Lock()
@@ -69,18 +178,117 @@ func (s *StatsTracker) DecrementPending() {
 	}
 }
 
+// RecordPurge adds files and bytes reclaimed by the verified-folder janitor
+func (s *StatsTracker) RecordPurge(files, bytes int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.purgedFiles += files
+	s.purgedBytes += bytes
+}
+
+// RecordDLQPurge adds files and bytes reclaimed by the DLQ janitor
+func (s *StatsTracker) RecordDLQPurge(files, bytes int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.dlqPurgedFiles += files
+	s.dlqPurgedBytes += bytes
+}
+
+// SetDLQUsage records what the DLQ holds, as found by the DLQ janitor
+func (s *StatsTracker) SetDLQUsage(files, bytes int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.dlqFiles = files
+	s.dlqBytes = bytes
+}
+
+// IncrementMispaired counts a pair whose sidecar named a different data file
+func (s *StatsTracker) IncrementMispaired() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.mispaired++
+}
+
+// IncrementPanics counts a panic recovered by a worker
+func (s *StatsTracker) IncrementPanics() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.panics++
+}
+
+// IncrementStalls counts a worker replaced by the stall watchdog
+func (s *StatsTracker) IncrementStalls() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.stalls++
+}
+
+// IncrementOracleReused counts an attempt that reused an earlier hash on the integrity oracle's word
+func (s *StatsTracker) IncrementOracleReused() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.oracleReused++
+}
+
+// SetQueueWait records how many ready files have been waiting too long to
+// enter the queue and the longest current wait
+func (s *StatsTracker) SetQueueWait(stalled int64, longest time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.queueStalled = stalled
+	s.queueWaitMax = longest
+}
+
+// SetLastHeartbeat records when the coordinator last reported it was alive
+func (s *StatsTracker) SetLastHeartbeat(t time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastHeartbeat = t
+}
+
 // GetStatistics returns a snapshot of current statistics
 func (s *StatsTracker) GetStatistics() Statistics {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
+	pendingCount := s.pendingCount
+	if s.pendingSource != nil {
+		pendingCount = s.pendingSource()
+	}
+
 	return Statistics{
-		TotalProcessed: s.totalProcessed,
-		SuccessCount:   s.successCount,
-		FailureCount:   s.failureCount,
-		PendingCount:   s.pendingCount,
-		TotalDuration:  s.totalDuration,
-		StartTime:      s.startTime,
+		TotalProcessed:     s.totalProcessed,
+		SuccessCount:       s.successCount,
+		FailureCount:       s.failureCount,
+		PendingCount:       pendingCount,
+		PendingDrift:       s.pendingDrift,
+		TotalDuration:      s.totalDuration,
+		TotalBytesVerified: s.totalBytes,
+		PurgedFiles:        s.purgedFiles,
+		PurgedBytes:        s.purgedBytes,
+		DLQPurgedFiles:     s.dlqPurgedFiles,
+		DLQPurgedBytes:     s.dlqPurgedBytes,
+		DLQFiles:           s.dlqFiles,
+		DLQBytes:           s.dlqBytes,
+		MispairedCount:     s.mispaired,
+		WorkerPanics:       s.panics,
+		WorkerStalls:       s.stalls,
+		OracleReused:       s.oracleReused,
+		QueueStalled:       s.queueStalled,
+		QueueWaitMax:       s.queueWaitMax,
+		DataFirstSkew:      s.dataFirst.snapshot(),
+		SidecarFirstSkew:   s.sidecarFirst.snapshot(),
+		StartTime:          s.startTime,
+		LastHeartbeat:      s.lastHeartbeat,
 	}
 }
 
@@ -96,6 +304,33 @@ func (s *StatsTracker) GetAverageDuration() time.Duration {
 	return s.totalDuration / time.Duration(s.totalProcessed)
 }
 
+// GetDurationPercentiles returns the given percentiles (0-100) of the most
+// recent processing durations, all zero before anything was processed
+func (s *StatsTracker) GetDurationPercentiles(percentiles ...float64) []time.Duration {
+	s.mutex.RLock()
+	sorted := append([]time.Duration(nil), s.durations...)
+	s.mutex.RUnlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	values := make([]time.Duration, len(percentiles))
+	if len(sorted) == 0 {
+		return values
+	}
+	for i, p := range percentiles {
+		// Nearest rank
+		rank := int(p/100*float64(len(sorted))+0.5) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		if rank >= len(sorted) {
+			rank = len(sorted) - 1
+		}
+		values[i] = sorted[rank]
+	}
+	return values
+}
+
 // GetSuccessRate returns the success rate as a percentage (0-100)
 func (s *StatsTracker) GetSuccessRate() float64 {
 	s.mutex.RLock()
@@ -151,6 +386,20 @@ func (s *StatsTracker) Reset() {
 	s.failureCount = 0
 	s.pendingCount = 0
 	s.totalDuration = 0
+	s.totalBytes = 0
+	s.pendingDrift = 0
+	s.purgedFiles = 0
+	s.purgedBytes = 0
+	s.dlqPurgedFiles = 0
+	s.dlqPurgedBytes = 0
+	s.mispaired = 0
+	s.panics = 0
+	s.stalls = 0
+	s.oracleReused = 0
+	s.durations = nil
+	s.nextDuration = 0
+	s.dataFirst = SkewHistogram{}
+	s.sidecarFirst = SkewHistogram{}
 	s.startTime = time.Now()
 }
 
@@ -168,6 +417,7 @@ func (s *StatsTracker) PrintStatistics() {
 	println("Success Count:   ", stats.SuccessCount)
 	println("Failure Count:   ", stats.FailureCount)
 	println("Pending Count:   ", stats.PendingCount)
+	println("Bytes Verified:  ", stats.TotalBytesVerified)
 	println("Success Rate:    ", successRate, "%")
 	println("Failure Rate:    ", failureRate, "%")
 	println("Average Duration:", avgDuration.String())
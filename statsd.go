@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+StatsDEmitter pushes runtime statistics to a StatsD server over UDP every time
+the coordinator logs its periodic statistics (statsd.enabled), for monitoring
+stacks that ingest StatsD rather than scrape Prometheus.
+
+Metrics, each prefixed with statsd.prefix (default "filesha"):
+
+	files_processed, files_verified, files_failed, bytes_verified  counters
+	files_pending, queue_depth                                     gauges
+	verification_duration                                          timer (ms)
+
+Responsibilities:
+1. Turn the StatsTracker's running totals into counter increments since the
+   last push, so StatsD can sum them per flush
+2. Send the average verification duration of the files finished since the
+   last push as one timing
+3. Pack the metrics into as few datagrams as fit the network MTU
+
+Does NOT:
+- Send a timing per file: the duration is averaged over the stats interval
+- Retry or buffer: a push that fails is reported and its increments are
+  carried over to the next one
+- Replace GET /metrics; it is served alongside unless api.disableMetrics is set
+*/
+
+// statsdMaxPacket keeps datagrams below a common Ethernet MTU
+const statsdMaxPacket = 1432
+
+// StatsDEmitter sends statistics to a StatsD server
+type StatsDEmitter struct {
+	conn     net.Conn
+	prefix   string
+	logLevel *LogLevel
+	last     Statistics // Totals at the last successful push
+}
+
+// NewStatsDEmitter creates an emitter sending to a StatsD server at address (host:port)
+func NewStatsDEmitter(cfg StatsDConfig, logLevel *LogLevel) (*StatsDEmitter, error) {
+	conn, err := net.Dial("udp", cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve StatsD server: %w", err)
+	}
+	return &StatsDEmitter{conn: conn, prefix: cfg.Prefix, logLevel: logLevel}, nil
+}
+
+// Emit pushes the statistics; queueDepth is the number of jobs waiting for a worker
+// Safe to call on a nil emitter (does nothing)
+func (e *StatsDEmitter) Emit(stats Statistics, queueDepth int) {
+	if e == nil {
+		return
+	}
+
+	// Statistics reset through the API start the totals over
+	if stats.TotalProcessed < e.last.TotalProcessed {
+		e.last = Statistics{}
+	}
+
+	processed := stats.TotalProcessed - e.last.TotalProcessed
+	lines := []string{
+		e.line("files_processed", processed, "c"),
+		e.line("files_verified", stats.SuccessCount-e.last.SuccessCount, "c"),
+		e.line("files_failed", stats.FailureCount-e.last.FailureCount, "c"),
+		e.line("bytes_verified", stats.TotalBytesVerified-e.last.TotalBytesVerified, "c"),
+		e.line("files_pending", stats.PendingCount, "g"),
+		e.line("queue_depth", int64(queueDepth), "g"),
+	}
+	if processed > 0 {
+		average := (stats.TotalDuration - e.last.TotalDuration) / time.Duration(processed)
+		lines = append(lines, e.line("verification_duration", average.Milliseconds(), "ms"))
+	}
+
+	if err := e.send(lines); err != nil {
+		if e.logLevel.Get() == "WARN" || e.logLevel.Get() == "DEBUG" {
+			fmt.Fprintf(os.Stderr, "[StatsD] Failed to push statistics: %v\n", err)
+		}
+		return
+	}
+	e.last = stats
+}
+
+// Close releases the socket
+func (e *StatsDEmitter) Close() error {
+	if e == nil {
+		return nil
+	}
+	return e.conn.Close()
+}
+
+// line formats one metric in the StatsD line protocol
+func (e *StatsDEmitter) line(name string, value int64, kind string) string {
+	return fmt.Sprintf("%s.%s:%d|%s", e.prefix, name, value, kind)
+}
+
+// send writes the lines, newline-separated, in datagrams of at most statsdMaxPacket bytes
+func (e *StatsDEmitter) send(lines []string) error {
+	var packet strings.Builder
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacket {
+			if _, err := e.conn.Write([]byte(packet.String())); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() == 0 {
+		return nil
+	}
+	_, err := e.conn.Write([]byte(packet.String()))
+	return err
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+SubmitFeeder submits jobs in blocking mode (concurrency.submitMode: blocking)
+on a goroutine of its own, so the coordinator hands ready files over without
+waiting and keeps up its other duties (stats, janitors, orphan sidecars)
+while the worker queue is full.
+
+Responsibilities:
+1. Submit the jobs handed over, in order, waiting for room in the queue
+2. Warn when a job waited longer than concurrency.submitWarnAfter
+3. On shutdown, release the files not submitted yet (ClearInFlight) so a
+   full queue never delays it
+
+Does NOT:
+- Bound its backlog: files stay in flight while waiting, so each ready file
+  is handed over once and the backlog never outgrows the tracked files
+- Submit in nonblocking mode, the coordinator does that itself
+*/
+
+// feederJob is a job waiting for room in the worker queue, with its trace span
+type feederJob struct {
+	job  VerificationJob
+	span trace.Span
+}
+
+// SubmitFeeder queues jobs for blocking submission
+type SubmitFeeder struct {
+	workerPool  *WorkerPoolManager
+	fileTracker *FileTracker
+	warnAfter   time.Duration
+	logLevel    *LogLevel
+
+	mutex   sync.Mutex
+	pending []feederJob
+	wake    chan struct{} // Signalled when a job is handed over
+	done    chan struct{} // Closed once the feeder has stopped
+}
+
+// StartSubmitFeeder starts a feeder that runs until ctx is cancelled
+func StartSubmitFeeder(ctx context.Context, workerPool *WorkerPoolManager, fileTracker *FileTracker, warnAfter time.Duration, logLevel *LogLevel) *SubmitFeeder {
+	feeder := &SubmitFeeder{
+		workerPool:  workerPool,
+		fileTracker: fileTracker,
+		warnAfter:   warnAfter,
+		logLevel:    logLevel,
+		wake:        make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	go feeder.run(ctx)
+	return feeder
+}
+
+// Submit hands a job over, it is submitted after the ones handed over before it
+// The job's file must already be marked in flight
+func (f *SubmitFeeder) Submit(job VerificationJob, span trace.Span) {
+	f.mutex.Lock()
+	f.pending = append(f.pending, feederJob{job: job, span: span})
+	f.mutex.Unlock()
+
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Wait blocks until the feeder has stopped (after ctx was cancelled)
+func (f *SubmitFeeder) Wait() {
+	<-f.done
+}
+
+// run submits the pending jobs one by one until ctx is cancelled
+func (f *SubmitFeeder) run(ctx context.Context) {
+	defer close(f.done)
+	defer func() { f.release(ctx.Err()) }()
+
+	for {
+		next, ok := f.next()
+		if !ok {
+			select {
+			case <-f.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		blockedAt := time.Now()
+		if !f.workerPool.SubmitJobBlocking(ctx, next.job) {
+			f.fileTracker.ClearInFlight(next.job.FilePair.Key)
+			endSpan(next.span, ctx.Err())
+			return
+		}
+		waited := time.Since(blockedAt)
+		if waited >= f.warnAfter && (f.logLevel.Get() == "WARN" || f.logLevel.Get() == "DEBUG") {
+			fmt.Fprintf(os.Stderr, "[Coordinator] %s waited %s to enter the queue, workers can't keep up\n",
+				next.job.FilePair.DataFile, waited.Round(time.Second))
+		}
+	}
+}
+
+// next takes the oldest pending job, false when there is none
+func (f *SubmitFeeder) next() (feederJob, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.pending) == 0 {
+		return feederJob{}, false
+	}
+	next := f.pending[0]
+	f.pending = f.pending[1:]
+	return next, true
+}
+
+// release gives the files never submitted back to the tracker
+func (f *SubmitFeeder) release(reason error) {
+	f.mutex.Lock()
+	pending := f.pending
+	f.pending = nil
+	f.mutex.Unlock()
+
+	for _, waiting := range pending {
+		f.fileTracker.ClearInFlight(waiting.job.FilePair.Key)
+		endSpan(waiting.span, reason)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestSubmitFeederNeverBlocksTheCaller(t *testing.T) {
+	// The pool is not started, its queue of 10 fills up and stays full
+	pool := newTestPool(t)
+	const files = 12
+	jobs := make([]VerificationJob, files)
+	for i := range jobs {
+		writeTestPair(t, pool.source, fmt.Sprintf("f%02d.zip", i))
+	}
+	for i := range jobs {
+		jobs[i] = pool.job(t, fmt.Sprintf("f%02d.zip", i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	feeder := StartSubmitFeeder(ctx, pool.WorkerPoolManager, pool.fileTracker, time.Hour, NewLogLevel("ERROR"))
+	handed := make(chan struct{})
+	go func() {
+		for _, job := range jobs {
+			pool.fileTracker.MarkInFlight(job.FilePair.Key)
+			feeder.Submit(job, noop.Span{})
+		}
+		close(handed)
+	}()
+	select {
+	case <-handed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Submit blocked on the full queue")
+	}
+	for deadline := time.Now().Add(5 * time.Second); pool.GetQueueLength() < 10; {
+		if time.Now().After(deadline) {
+			t.Fatalf("queue holds %d jobs, want 10", pool.GetQueueLength())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Shutdown is not held up by the full queue; the files never submitted are released
+	cancel()
+	feeder.Wait()
+	for i, job := range jobs {
+		pair, _ := pool.fileTracker.GetFilePair(job.FilePair.Key)
+		if queued := i < 10; pair.InFlight != queued {
+			t.Errorf("%s: in flight %v, want %v", job.FilePair.DataFile, pair.InFlight, queued)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+SyslogLogger sends verification and statistics records to a remote syslog endpoint.
+
+Responsibilities:
+1. Format each record as an RFC 5424 message with structured data fields
+2. Deliver messages over UDP or TCP (octet-counting framing, RFC 6587)
+3. Buffer messages while the endpoint is unreachable and reconnect with backoff
+4. Report (never silently drop) records that cannot be delivered
+
+Does NOT:
+- Write local files (that's csv_logger.go)
+*/
+
+// syslogEnterpriseID is the private enterprise number used in structured data IDs
+// 32473 is reserved by IANA for documentation and examples (RFC 5612)
+const syslogEnterpriseID = "32473"
+
+// syslogFacilities maps facility names to RFC 5424 facility codes
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "daemon": 3, "auth": 4, "syslog": 5,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Syslog severity levels used by this sink
+const (
+	syslogSeverityNotice = 5
+	syslogSeverityInfo   = 6
+)
+
+// SyslogLogger handles buffered delivery of records to a syslog endpoint
+type SyslogLogger struct {
+	network  string
+	address  string
+	facility int
+	appName  string
+	hostname string
+	queue    chan string
+	conn     net.Conn
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSyslogLogger creates a syslog sink and starts its delivery routine
+func NewSyslogLogger(cfg SyslogConfig) (*SyslogLogger, error) {
+	facility, ok := syslogFacilities[cfg.Facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility: %s", cfg.Facility)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	logger := &SyslogLogger{
+		network:  cfg.Network,
+		address:  cfg.Address,
+		facility: facility,
+		appName:  cfg.AppName,
+		hostname: hostname,
+		queue:    make(chan string, cfg.BufferSize),
+		stopChan: make(chan struct{}),
+	}
+
+	// Start delivery routine
+	logger.wg.Add(1)
+	go logger.sendLoop()
+
+	return logger, nil
+}
+
+// LogVerification queues a verification record for delivery
+func (l *SyslogLogger) LogVerification(entry CSVLogEntry) error {
+	params := []string{
+		sdParam("filename", entry.Filename),
+		sdParam("sha256", entry.SHA256),
+		sdParam("sizeBytes", fmt.Sprintf("%d", entry.SizeBytes)),
+		sdParam("durationSeconds", fmt.Sprintf("%.4f", entry.Duration)),
+		sdParam("action", entry.Action),
+		sdParam("destinationPath", entry.DestinationPath),
+	}
+	if entry.SourcePath != "" {
+		params = append(params, sdParam("sourcePath", entry.SourcePath))
+	}
+	if entry.ShadowHash != "" {
+		params = append(params, sdParam("shadowHash", entry.ShadowHash))
+	}
+	if entry.HoleBytes >= 0 {
+		params = append(params, sdParam("holeBytes", fmt.Sprintf("%d", entry.HoleBytes)))
+	}
+	if entry.CompressedBytes > 0 {
+		params = append(params, sdParam("compressedBytes", fmt.Sprintf("%d", entry.CompressedBytes)))
+	}
+	if entry.LinkedTo != "" {
+		params = append(params, sdParam("linkedTo", entry.LinkedTo))
+	}
+
+	msg := l.formatMessage(syslogSeverityNotice, "verification", params,
+		fmt.Sprintf("verified %s", entry.Filename))
+
+	return l.enqueue(msg)
+}
+
+// LogStats queues a statistics record for delivery
+func (l *SyslogLogger) LogStats(entry StatsEntry) error {
+	params := []string{
+		sdParam("totalProcessed", fmt.Sprintf("%d", entry.TotalProcessed)),
+		sdParam("successCount", fmt.Sprintf("%d", entry.SuccessCount)),
+		sdParam("failureCount", fmt.Sprintf("%d", entry.FailureCount)),
+		sdParam("pendingCount", fmt.Sprintf("%d", entry.PendingCount)),
+		sdParam("averageDuration", fmt.Sprintf("%.4f", entry.AverageDuration)),
+		sdParam("totalBytesVerified", fmt.Sprintf("%d", entry.TotalBytesVerified)),
+	}
+
+	msg := l.formatMessage(syslogSeverityInfo, "stats", params, "statistics")
+
+	return l.enqueue(msg)
+}
+
+// Close delivers any buffered messages and closes the connection
+func (l *SyslogLogger) Close() error {
+	// Signal stop to delivery routine
+	close(l.stopChan)
+
+	// Wait for delivery routine to drain the buffer
+	l.wg.Wait()
+
+	if l.conn != nil {
+		if err := l.conn.Close(); err != nil {
+			return fmt.Errorf("failed to close syslog connection: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// formatMessage builds an RFC 5424 message
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID params...] MSG
+func (l *SyslogLogger) formatMessage(severity int, msgID string, params []string, text string) string {
+	priority := l.facility*8 + severity
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s [%s@%s %s] %s",
+		priority,
+		time.Now().Format(time.RFC3339Nano),
+		l.hostname,
+		l.appName,
+		os.Getpid(),
+		msgID,
+		msgID,
+		syslogEnterpriseID,
+		strings.Join(params, " "),
+		text,
+	)
+}
+
+// enqueue adds a message to the delivery buffer
+// Returns an error when the buffer is full so the caller can report the loss
+func (l *SyslogLogger) enqueue(msg string) error {
+	select {
+	case l.queue <- msg:
+		return nil
+	default:
+		return fmt.Errorf("syslog buffer full (%d messages), record not sent", cap(l.queue))
+	}
+}
+
+// sendLoop delivers queued messages, reconnecting when the endpoint goes away
+func (l *SyslogLogger) sendLoop() {
+	defer l.wg.Done()
+
+	for {
+		select {
+		case msg := <-l.queue:
+			l.deliver(msg)
+		case <-l.stopChan:
+			l.drain()
+			return
+		}
+	}
+}
+
+// deliver sends a single message, retrying with backoff until it succeeds
+// or the logger is stopped
+func (l *SyslogLogger) deliver(msg string) {
+	backoff := 1 * time.Second
+
+	for {
+		err := l.write(msg)
+		if err == nil {
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "[Syslog] Delivery to %s failed, retrying in %s: %v\n", l.address, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-l.stopChan:
+			// Make one final attempt during shutdown
+			if err := l.write(msg); err != nil {
+				fmt.Fprintf(os.Stderr, "[Syslog] Undelivered record: %s\n", msg)
+			}
+			return
+		}
+
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// drain makes a single delivery attempt for every buffered message on shutdown
+// Messages that still cannot be delivered are written to stderr
+func (l *SyslogLogger) drain() {
+	for {
+		select {
+		case msg := <-l.queue:
+			if err := l.write(msg); err != nil {
+				fmt.Fprintf(os.Stderr, "[Syslog] Undelivered record: %s\n", msg)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// write sends a message over the current connection, dialing if needed
+func (l *SyslogLogger) write(msg string) error {
+	if l.conn == nil {
+		conn, err := net.DialTimeout(l.network, l.address, 5*time.Second)
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		l.conn = conn
+	}
+
+	// TCP uses octet-counting framing, UDP sends one message per datagram
+	frame := msg
+	if l.network == "tcp" {
+		frame = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+
+	l.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := l.conn.Write([]byte(frame)); err != nil {
+		l.conn.Close()
+		l.conn = nil
+		return fmt.Errorf("failed to write: %w", err)
+	}
+
+	return nil
+}
+
+// sdParam formats a structured data parameter, escaping '"', '\' and ']'
+func sdParam(name, value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+	return fmt.Sprintf(`%s="%s"`, name, escaped)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+/*
+systemd integration for Type=notify service units.
+
+Responsibilities:
+1. Send READY=1 once the first scan has completed and the workers are up
+2. Send STOPPING=1 when a graceful shutdown begins
+3. Report the watchdog interval requested through WATCHDOG_USEC so the
+   coordinator can send WATCHDOG=1 keepalives
+
+Every function is a no-op when NOTIFY_SOCKET is not set, i.e. when the
+service is not started by systemd.
+
+Does NOT:
+- Link against libsystemd (the notify protocol is a single datagram)
+- Decide when the service is healthy (the coordinator pings only while its loop runs)
+*/
+
+// sdNotify sends a state update to the systemd notify socket
+// Returns nil without doing anything when not running under systemd
+func sdNotify(state string) error {
+	socketPath := os.Getenv("NOTIFY_SOCKET")
+	if socketPath == "" {
+		return nil
+	}
+
+	// A leading "@" selects the Linux abstract namespace, which net handles itself
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to send %q to notify socket: %w", state, err)
+	}
+
+	return nil
+}
+
+// sdWatchdogInterval returns how often WATCHDOG=1 should be sent
+// Half the timeout systemd requested in WATCHDOG_USEC, or 0 when the watchdog is
+// disabled or meant for another process (WATCHDOG_PID)
+func sdWatchdogInterval() time.Duration {
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return 0
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	if pidValue := os.Getenv("WATCHDOG_PID"); pidValue != "" {
+		pid, err := strconv.Atoi(pidValue)
+		if err != nil || pid != os.Getpid() {
+			return 0
+		}
+	}
+
+	return time.Duration(usec) * time.Microsecond / 2
+}
@@ -0,0 +1,600 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+TarListener is a network source: partners stream a tar archive over TCP
+instead of dropping files into the source folder (source.tarListener).
+
+Each connection carries one tar stream. Members are handled as they arrive:
+- "<datafile><suffix>" sidecars (any of verification.sidecarSuffixes) and a
+  manifest (source.tarListener.manifestName, sha256sum format with paths
+  relative to the archive root) give expected hashes
+- data files (matching the verification filters) are hashed while they are
+  written to a temporary file in the verified folder, so only one member at
+  a time is on disk and the tarball itself never is
+- other members are skipped
+
+A data member whose expected hash is already known (its sidecar or the
+manifest came first) is decided as soon as it is received; otherwise it
+waits on disk until the end of the stream for a sidecar that comes after it.
+Past maxPendingMembers or maxPendingBytes of such waiting members, the next
+one without an expected hash is rejected unread instead.
+Connections from outside allowedCIDRs, and past maxStreams streams at once,
+are closed without reading.
+Verified members are renamed into the verified folder (keeping their path
+inside the archive); mismatches and members without an expected hash go to
+the DLQ with a reason file. Members whose SHA-256 is on the deny-list go to
+the quarantine folder as soon as they are received. When the stream ends,
+one line per data member ("OK", "FAILED" or "SKIPPED", its name and a
+detail) is written back on the connection for the sender.
+
+Does NOT:
+- Use the file tracker or worker pool (a stream can't be retried; the sender
+  resends it)
+- Keep members of a stream that breaks off: complete members are still
+  decided, the one cut off is discarded and reported as failed
+*/
+
+// tarMetadataLimit caps the size of sidecar and manifest members read into memory
+const tarMetadataLimit = 16 << 20
+
+// tarTrailerLimit caps the padding read after the end of an archive
+const tarTrailerLimit = 1 << 20
+
+// Statuses reported back to the sender per data member
+const (
+	tarStatusOK      = "OK"
+	tarStatusFailed  = "FAILED"
+	tarStatusSkipped = "SKIPPED"
+)
+
+// TarListener accepts tar streams and verifies their members
+type TarListener struct {
+	cfg          TarListenerConfig
+	verification VerificationConfig
+	destinations *Destinations
+	onCollision  string
+	resultLogger ResultLogger
+	statsTracker *StatsTracker
+	hashLimiter  *HashLimiter
+	features     Features
+	allowed      []*net.IPNet  // Accepted senders (empty = any)
+	streams      chan struct{} // One slot per stream being received
+	listener     net.Listener
+	connsMutex   sync.Mutex
+	conns        map[net.Conn]struct{} // Open connections, closed on Stop
+	wg           sync.WaitGroup
+	logLevel     *LogLevel
+}
+
+// tarStream is the state of one tar stream
+type tarStream struct {
+	remote       string
+	expected     map[string]tarExpected    // Key: data member path, from sidecar members
+	manifest     map[string]*manifestEntry // From the manifest member (nil = none yet)
+	pending      []*tarMember              // Received data members waiting for an expected hash
+	pendingBytes int64                     // Size of the pending members
+	results      []string                  // Lines reported back to the sender
+}
+
+// tarExpected is an expected hash taken from a sidecar member
+type tarExpected struct {
+	hash      string
+	algorithm string
+}
+
+// tarMember is a data member received into a temporary file
+type tarMember struct {
+	name      string // Slash-separated path inside the archive
+	tempPath  string
+	size      int64
+	hash      string // Hash computed while receiving
+	algorithm string
+	sha256    string // For the deny-list, computed while receiving too (empty = no deny-list)
+	started   time.Time
+}
+
+// NewTarListener creates a tar stream listener
+// cfg.AllowedCIDRs must have been validated
+func NewTarListener(cfg TarListenerConfig, verification VerificationConfig, destinations *Destinations, onCollision string, resultLogger ResultLogger, statsTracker *StatsTracker, hashLimiter *HashLimiter, features Features, logLevel *LogLevel) *TarListener {
+	var allowed []*net.IPNet
+	for _, cidr := range cfg.AllowedCIDRs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			allowed = append(allowed, network)
+		}
+	}
+	return &TarListener{
+		allowed:      allowed,
+		streams:      make(chan struct{}, max(cfg.MaxStreams, 1)),
+		cfg:          cfg,
+		verification: verification,
+		destinations: destinations,
+		onCollision:  onCollision,
+		resultLogger: resultLogger,
+		statsTracker: statsTracker,
+		hashLimiter:  hashLimiter,
+		features:     features,
+		conns:        make(map[net.Conn]struct{}),
+		logLevel:     logLevel,
+	}
+}
+
+// Start opens the listener and accepts streams in the background
+func (tl *TarListener) Start() error {
+	listener, err := net.Listen("tcp", tl.cfg.Address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", tl.cfg.Address, err)
+	}
+	tl.listener = listener
+
+	tl.wg.Add(1)
+	go tl.acceptLoop()
+
+	if tl.logLevel.Get() == "DEBUG" || tl.logLevel.Get() == "INFO" {
+		fmt.Printf("[TarListener] Listening on %s\n", listener.Addr())
+	}
+	return nil
+}
+
+// Stop closes the listener and any open connections, abandoning streams in progress
+func (tl *TarListener) Stop() {
+	tl.listener.Close()
+
+	tl.connsMutex.Lock()
+	for conn := range tl.conns {
+		conn.Close()
+	}
+	tl.connsMutex.Unlock()
+
+	tl.wg.Wait()
+
+	if tl.logLevel.Get() == "DEBUG" || tl.logLevel.Get() == "INFO" {
+		fmt.Println("[TarListener] Stopped")
+	}
+}
+
+// acceptLoop hands each connection to its own goroutine
+func (tl *TarListener) acceptLoop() {
+	defer tl.wg.Done()
+
+	for {
+		conn, err := tl.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[TarListener] Failed to accept connection: %v\n", err)
+			continue
+		}
+
+		if !tl.isAllowed(conn.RemoteAddr()) {
+			fmt.Fprintf(os.Stderr, "[TarListener] Refusing stream from %s: not in allowedCIDRs\n", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		select {
+		case tl.streams <- struct{}{}:
+		default:
+			fmt.Fprintf(os.Stderr, "[TarListener] Refusing stream from %s: %d streams already in progress\n", conn.RemoteAddr(), cap(tl.streams))
+			conn.Close()
+			continue
+		}
+
+		tl.connsMutex.Lock()
+		tl.conns[conn] = struct{}{}
+		tl.connsMutex.Unlock()
+
+		tl.wg.Add(1)
+		go func() {
+			defer tl.wg.Done()
+			defer func() { <-tl.streams }()
+			tl.handleStream(conn)
+
+			tl.connsMutex.Lock()
+			delete(tl.conns, conn)
+			tl.connsMutex.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// isAllowed reports whether a sender's address is within allowedCIDRs
+func (tl *TarListener) isAllowed(addr net.Addr) bool {
+	if len(tl.allowed) == 0 {
+		return true
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range tl.allowed {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// idleReader fails a read that waits longer than timeout for data
+type idleReader struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (r idleReader) Read(buf []byte) (int, error) {
+	r.conn.SetReadDeadline(time.Now().Add(r.timeout))
+	return r.conn.Read(buf)
+}
+
+// handleStream verifies the members of one tar stream and reports back to the sender
+func (tl *TarListener) handleStream(conn net.Conn) {
+	stream := &tarStream{
+		remote:   conn.RemoteAddr().String(),
+		expected: make(map[string]tarExpected),
+	}
+	if tl.logLevel.Get() == "DEBUG" || tl.logLevel.Get() == "INFO" {
+		fmt.Printf("[TarListener] Receiving tar stream from %s\n", stream.remote)
+	}
+
+	reader := tar.NewReader(idleReader{conn: conn, timeout: tl.cfg.IdleTimeout})
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[TarListener] Stream from %s ended early: %v\n", stream.remote, err)
+			break
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name, ok := tarMemberName(header.Name)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "[TarListener] Skipping member with unsafe path %q from %s\n", header.Name, stream.remote)
+			continue
+		}
+
+		if err := tl.handleMember(stream, reader, header, name); err != nil {
+			// The stream is broken, members received so far are still decided
+			fmt.Fprintf(os.Stderr, "[TarListener] Stream from %s ended early in %s: %v\n", stream.remote, name, err)
+			stream.results = append(stream.results, tarResultLine(tarStatusFailed, name, "stream ended early"))
+			tl.statsTracker.IncrementFailure(0, 0)
+			break
+		}
+	}
+
+	// Members whose sidecar was expected later get their verdict now
+	for _, member := range stream.pending {
+		tl.decide(stream, member, true)
+	}
+
+	// Tar writers pad the archive to their record size; closing with that unread
+	// would reset the connection before the sender reads the results
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	io.Copy(io.Discard, io.LimitReader(conn, tarTrailerLimit))
+
+	// Report every data member back to the sender (it may have closed its side already)
+	conn.SetWriteDeadline(time.Now().Add(tl.cfg.IdleTimeout))
+	for _, line := range stream.results {
+		if _, err := io.WriteString(conn, line); err != nil {
+			break
+		}
+	}
+
+	if tl.logLevel.Get() == "DEBUG" || tl.logLevel.Get() == "INFO" {
+		fmt.Printf("[TarListener] Tar stream from %s done: %d data members\n", stream.remote, len(stream.results))
+	}
+}
+
+// handleMember processes one regular file member
+// Returns an error only when the stream itself failed
+func (tl *TarListener) handleMember(stream *tarStream, reader *tar.Reader, header *tar.Header, name string) error {
+	base := path.Base(name)
+
+	if base == tl.cfg.ManifestName {
+		data, err := readTarMetadata(reader)
+		if err != nil {
+			return err
+		}
+		manifest, err := parseManifest(data, tl.verification.HashEncoding, true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[TarListener] Ignoring invalid manifest %s from %s: %v\n", name, stream.remote, err)
+			return nil
+		}
+		// Entries are relative to the manifest's folder inside the archive
+		stream.manifest = make(map[string]*manifestEntry)
+		for entryName, entry := range manifest {
+			stream.manifest[path.Join(path.Dir(name), entryName)] = entry
+		}
+		tl.decidePending(stream)
+		return nil
+	}
+
+	for _, suffix := range tl.verification.SidecarSuffixes {
+		if !strings.HasSuffix(base, suffix) {
+			continue
+		}
+		data, err := readTarMetadata(reader)
+		if err != nil {
+			return err
+		}
+		algorithm := strings.TrimPrefix(suffix, ".")
+		hash, err := ParseSHA256Content(data, tl.verification.HashEncoding, algorithm)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[TarListener] Ignoring invalid sidecar %s from %s: %v\n", name, stream.remote, err)
+			return nil
+		}
+		stream.expected[strings.TrimSuffix(name, suffix)] = tarExpected{hash: hash, algorithm: algorithm}
+		tl.decidePending(stream)
+		return nil
+	}
+
+	if !tl.isDataFile(base) {
+		if tl.logLevel.Get() == "DEBUG" {
+			fmt.Printf("[TarListener] Skipping member %s from %s\n", name, stream.remote)
+		}
+		return nil
+	}
+
+	// A member that would wait for its sidecar is refused past the pending limits
+	if _, ok := tl.expectedFor(stream, name); !ok &&
+		(len(stream.pending) >= tl.cfg.MaxPendingMembers || stream.pendingBytes+header.Size > tl.cfg.MaxPendingBytes) {
+		reason := fmt.Sprintf("no expected hash before the member, and %d members (%d bytes) already wait for theirs", len(stream.pending), stream.pendingBytes)
+		fmt.Fprintf(os.Stderr, "[TarListener] ✗ FAILURE: %s from %s - %s\n", name, stream.remote, reason)
+		stream.results = append(stream.results, tarResultLine(tarStatusFailed, name, reason))
+		tl.statsTracker.IncrementFailure(0, header.Size)
+		return nil
+	}
+
+	member, err := tl.receive(stream, reader, header, name)
+	if err != nil {
+		return err
+	}
+	if !tl.decide(stream, member, false) {
+		stream.pending = append(stream.pending, member)
+		stream.pendingBytes += member.size
+	}
+	return nil
+}
+
+// isDataFile applies the verification filters to a member's file name
+func (tl *TarListener) isDataFile(filename string) bool {
+	if tl.verification.FilterMode == FilterModeExclude {
+		return !matchesAny(tl.verification.ExcludeFilters, filename)
+	}
+	return matchesAny(tl.verification.FileFilters, filename)
+}
+
+// receive writes a data member to a temporary file in the verified folder, hashing it on the way
+func (tl *TarListener) receive(stream *tarStream, reader *tar.Reader, header *tar.Header, name string) (*tarMember, error) {
+	member := &tarMember{name: name, algorithm: AlgorithmSHA256, started: clockNow()}
+	if expected, ok := stream.expected[name]; ok {
+		member.algorithm = expected.algorithm
+	}
+
+	// ".tmp" keeps the retention janitor away from it
+	file, err := os.CreateTemp(tl.destinations.Get().Verified, ".tarpart-*.tmp")
+	if err != nil {
+		// Nothing can be received without a place to put it
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	member.tempPath = file.Name()
+
+	tl.hashLimiter.Acquire()
+	hasher := hashAlgorithms[member.algorithm]()
+	writers := []io.Writer{file, hasher}
+	var denyHasher hash.Hash
+	if tl.features.DenyList != nil && member.algorithm != AlgorithmSHA256 {
+		denyHasher = sha256.New()
+		writers = append(writers, denyHasher)
+	}
+	member.size, err = io.CopyBuffer(io.MultiWriter(writers...), reader, make([]byte, tl.verification.BufferSize))
+	tl.hashLimiter.Release()
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(member.tempPath)
+		return nil, err
+	}
+	member.hash = fmt.Sprintf("%x", hasher.Sum(nil))
+	switch {
+	case denyHasher != nil:
+		member.sha256 = fmt.Sprintf("%x", denyHasher.Sum(nil))
+	case tl.features.DenyList != nil:
+		member.sha256 = member.hash
+	}
+
+	// Deliver with the permissions and modification time recorded in the archive
+	mode := os.FileMode(header.Mode).Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+	os.Chmod(member.tempPath, mode)
+	os.Chtimes(member.tempPath, header.ModTime, header.ModTime)
+
+	return member, nil
+}
+
+// decidePending decides the pending members whose expected hash is now known
+func (tl *TarListener) decidePending(stream *tarStream) {
+	var waiting []*tarMember
+	var waitingBytes int64
+	for _, member := range stream.pending {
+		if !tl.decide(stream, member, false) {
+			waiting = append(waiting, member)
+			waitingBytes += member.size
+		}
+	}
+	stream.pending, stream.pendingBytes = waiting, waitingBytes
+}
+
+// expectedFor returns the expected hash of a data member, a sidecar taking precedence over the manifest
+func (tl *TarListener) expectedFor(stream *tarStream, name string) (tarExpected, bool) {
+	if expected, ok := stream.expected[name]; ok {
+		return expected, true
+	}
+	if entry, ok := stream.manifest[name]; ok {
+		return tarExpected{hash: entry.hash, algorithm: AlgorithmSHA256}, true
+	}
+	return tarExpected{}, false
+}
+
+// decide verifies a received member and delivers it or sends it to the DLQ
+// Without an expected hash it returns false, unless final (the stream is over)
+func (tl *TarListener) decide(stream *tarStream, member *tarMember, final bool) bool {
+	// Known-bad content is quarantined without waiting for its expected hash
+	if entry, denied := tl.features.DenyList.Match(member.sha256); denied {
+		fmt.Fprintf(os.Stderr, "[TarListener] CRITICAL: %s from %s matches deny-list entry %s, quarantining it\n", member.name, stream.remote, entry)
+		folder := tl.destinations.Get().Quarantine
+		if folder == "" {
+			folder = tl.destinations.Get().DLQ
+		}
+		tl.reject(stream, member, fmt.Sprintf("%v: content matches deny-list entry %s", ErrDeniedHash, entry), folder)
+		return true
+	}
+
+	expected, ok := tl.expectedFor(stream, member.name)
+	if !ok && !final {
+		return false
+	}
+	if !ok {
+		tl.reject(stream, member, "no expected hash (no sidecar or manifest entry in the stream)", tl.destinations.Get().DLQ)
+		return true
+	}
+
+	// A sidecar that came after the member may name another algorithm
+	computed := member.hash
+	if expected.algorithm != member.algorithm {
+		var err error
+		computed, err = tl.features.Hasher.hashFile(member.tempPath, tl.verification.BufferSize, expected.algorithm, nil, nil, nil)
+		if err != nil {
+			tl.reject(stream, member, fmt.Sprintf("failed to hash with %s: %v", expected.algorithm, err), tl.destinations.Get().DLQ)
+			return true
+		}
+	}
+	if computed != expected.hash {
+		tl.reject(stream, member, fmt.Sprintf("%v: expected %s, got %s", ErrHashMismatch, expected.hash, computed), tl.destinations.Get().DLQ)
+		return true
+	}
+
+	tl.deliver(stream, member, computed)
+	return true
+}
+
+// deliver renames a verified member into the verified folder, keeping its path inside the archive
+func (tl *TarListener) deliver(stream *tarStream, member *tarMember, computed string) {
+	folders := tl.destinations.Get()
+	destFolder := filepath.Join(folders.Verified, filepath.FromSlash(path.Dir(member.name)))
+	err := os.MkdirAll(destFolder, 0755)
+	var destPath string
+	if err == nil {
+		// The temporary file is in the verified folder, so this is a rename
+		destPath, err = claimDestination(destFolder, path.Base(member.name), tl.onCollision, movingFile(member.tempPath))
+	}
+	if errors.Is(err, ErrCollisionSkipped) {
+		os.Remove(member.tempPath)
+		stream.results = append(stream.results, tarResultLine(tarStatusSkipped, member.name, "destination exists"))
+		if tl.logLevel.Get() == "WARN" || tl.logLevel.Get() == "DEBUG" {
+			fmt.Fprintf(os.Stderr, "[TarListener] Skipped %s from %s: %v\n", member.name, stream.remote, err)
+		}
+		return
+	}
+	if err != nil {
+		os.Remove(member.tempPath)
+		fmt.Fprintf(os.Stderr, "[TarListener] Failed to deliver %s from %s: %v\n", member.name, stream.remote, err)
+		stream.results = append(stream.results, tarResultLine(tarStatusFailed, member.name, "delivery failed"))
+		tl.statsTracker.IncrementFailure(clockNow().Sub(member.started), member.size)
+		return
+	}
+
+	duration := clockNow().Sub(member.started)
+	tl.statsTracker.IncrementSuccess(duration, member.size)
+	stream.results = append(stream.results, tarResultLine(tarStatusOK, member.name, computed))
+	tl.features.LatestLinks.Update(filepath.Base(member.name), destPath)
+
+	if tl.logLevel.Get() == "DEBUG" || tl.logLevel.Get() == "INFO" {
+		fmt.Printf("[TarListener] ✓ SUCCESS: %s from %s (%.2f KB)\n", member.name, stream.remote, float64(member.size)/1024.0)
+	}
+
+	result := VerificationResult{
+		Job:          VerificationJob{FilePair: FilePair{DataFile: member.name, DataSize: member.size}},
+		Success:      true,
+		ComputedHash: computed,
+		HoleBytes:    -1,
+		Duration:     duration,
+		Timestamp:    clockNow(),
+	}
+	if err := tl.resultLogger.LogVerification(CreateCSVLogEntry(result, ActionReceived, destPath, "")); err != nil {
+		fmt.Fprintf(os.Stderr, "[TarListener] Failed to log verification: %v\n", err)
+	}
+}
+
+// reject moves a member that failed verification to dlqFolder (the DLQ or the
+// quarantine folder) with a reason file
+func (tl *TarListener) reject(stream *tarStream, member *tarMember, reason, dlqFolder string) {
+	fmt.Fprintf(os.Stderr, "[TarListener] ✗ FAILURE: %s from %s - %s\n", member.name, stream.remote, reason)
+	stream.results = append(stream.results, tarResultLine(tarStatusFailed, member.name, reason))
+	tl.statsTracker.IncrementFailure(clockNow().Sub(member.started), member.size)
+
+	err := os.MkdirAll(dlqFolder, 0755)
+	var destPath string
+	if err == nil {
+		destPath, err = claimDestination(dlqFolder, path.Base(member.name), tl.onCollision, movingFile(member.tempPath))
+	}
+	if err != nil {
+		os.Remove(member.tempPath)
+		fmt.Fprintf(os.Stderr, "[TarListener] Failed to move %s to %s, discarded: %v\n", member.name, dlqFolder, err)
+		return
+	}
+	if err := WriteReasonFile(dlqFolder, filepath.Base(destPath), fmt.Sprintf("%s (tar stream from %s)", reason, stream.remote)); err != nil {
+		fmt.Fprintf(os.Stderr, "[TarListener] %v\n", err)
+	}
+}
+
+// tarMemberName cleans a member path, refusing absolute paths and ones leaving the archive root
+func tarMemberName(name string) (string, bool) {
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
+
+// readTarMetadata reads a sidecar or manifest member, which must be small
+func readTarMetadata(reader io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(reader, tarMetadataLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > tarMetadataLimit {
+		return nil, fmt.Errorf("sidecar or manifest member larger than %d bytes", tarMetadataLimit)
+	}
+	return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), nil
+}
+
+// tarResultLine formats a result line reported back to the sender
+func tarResultLine(status, name, detail string) string {
+	return fmt.Sprintf("%s %s %s\n", status, escapeControlChars(name), detail)
+}
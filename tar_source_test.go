@@ -0,0 +1,123 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTestTarListener starts a tar listener on a loopback port delivering to the folders of a test pool,
+// for "*.zip" data members and sidecarSuffix sidecars
+func startTestTarListener(t *testing.T, cfg TarListenerConfig, sidecarSuffix string, features Features) (*TarListener, *testPool) {
+	t.Helper()
+	pool := newTestPool(t)
+	cfg.Address = "127.0.0.1:0"
+	cfg.IdleTimeout = 5 * time.Second
+	cfg.ManifestName = "MANIFEST.sha256"
+	verification := VerificationConfig{
+		BufferSize:      4096,
+		FileFilters:     []string{"*.zip"},
+		FilterMode:      FilterModeInclude,
+		HashEncoding:    HashEncodingAuto,
+		SidecarSuffixes: []string{sidecarSuffix},
+	}
+	tl := NewTarListener(cfg, verification, pool.destinations, CollisionRename, pool.logger, NewStatsTracker(), NewHashLimiter(0), features, NewLogLevel("ERROR"))
+	if err := tl.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tl.Stop)
+	return tl, pool
+}
+
+// sendTar streams the members (name, content pairs) to the listener and returns the result lines
+func sendTar(t *testing.T, tl *TarListener, members ...string) string {
+	t.Helper()
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	for i := 0; i < len(members); i += 2 {
+		content := members[i+1]
+		if err := writer.WriteHeader(&tar.Header{Name: members[i], Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(writer, content)
+	}
+	writer.Close()
+
+	conn, err := net.Dial("tcp", tl.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(archive.Bytes())
+	conn.(*net.TCPConn).CloseWrite()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	results, _ := io.ReadAll(conn)
+	return string(results)
+}
+
+func TestTarPendingMembersLimit(t *testing.T) {
+	tl, pool := startTestTarListener(t, TarListenerConfig{MaxStreams: 1, MaxPendingMembers: 1, MaxPendingBytes: 1 << 20}, ".sha256", Features{})
+
+	// Both data members come before their sidecars; only the first may wait
+	results := sendTar(t, tl,
+		"a.zip", "data",
+		"b.zip", "data",
+		"a.zip.sha256", dataSHA256,
+		"b.zip.sha256", dataSHA256,
+	)
+	if !strings.Contains(results, "OK a.zip") || !strings.Contains(results, "FAILED b.zip") {
+		t.Errorf("results %q, want a.zip OK and b.zip FAILED", results)
+	}
+	if FileExists(filepath.Join(pool.folders.Verified, "b.zip")) {
+		t.Error("b.zip delivered past the pending limit")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(pool.folders.Verified, ".tarpart-*")); len(matches) > 0 {
+		t.Errorf("temporary files left: %v", matches)
+	}
+}
+
+func TestTarMemberLoggedAsReceived(t *testing.T) {
+	tl, pool := startTestTarListener(t, TarListenerConfig{MaxStreams: 1, MaxPendingMembers: 1, MaxPendingBytes: 1 << 20}, ".sha256", Features{})
+
+	if results := sendTar(t, tl, "a.zip.sha256", dataSHA256, "a.zip", "data"); !strings.Contains(results, "OK a.zip") {
+		t.Fatalf("results %q, want a.zip OK", results)
+	}
+	entries := pool.logger.entries
+	if len(entries) != 1 || entries[0].Action != ActionReceived || entries[0].DestinationPath != filepath.Join(pool.folders.Verified, "a.zip") {
+		t.Errorf("logged %+v, want a.zip received", entries)
+	}
+}
+
+func TestTarSenderOutsideAllowedCIDRs(t *testing.T) {
+	tl, pool := startTestTarListener(t, TarListenerConfig{AllowedCIDRs: []string{"192.0.2.0/24"}, MaxStreams: 1, MaxPendingMembers: 1, MaxPendingBytes: 1 << 20}, ".sha256", Features{})
+
+	if results := sendTar(t, tl, "a.zip.sha256", dataSHA256, "a.zip", "data"); results != "" {
+		t.Errorf("results %q, want the connection closed", results)
+	}
+	if FileExists(filepath.Join(pool.folders.Verified, "a.zip")) {
+		t.Error("a.zip delivered from a refused sender")
+	}
+}
+
+func TestTarDeniedMemberIsQuarantined(t *testing.T) {
+	list := writeTestFile(t, t.TempDir(), "deny-list.txt", dataSHA256+"\n")
+	loaded, err := LoadDenyList(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The MD5 sidecar matches; the content is still denied by its SHA-256
+	tl, pool := startTestTarListener(t, TarListenerConfig{MaxStreams: 1, MaxPendingMembers: 1, MaxPendingBytes: 1 << 20}, ".md5", Features{DenyList: loaded})
+	results := sendTar(t, tl, "a.zip.md5", dataMD5, "a.zip", "data")
+	if !strings.Contains(results, "FAILED a.zip "+ErrDeniedHash.Error()) {
+		t.Errorf("results %q, want a.zip denied", results)
+	}
+	if !FileExists(filepath.Join(pool.folders.Quarantine, "a.zip")) || FileExists(filepath.Join(pool.folders.Verified, "a.zip")) {
+		t.Error("a.zip not quarantined")
+	}
+}
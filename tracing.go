@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+/*
+Tracing provides optional OpenTelemetry instrumentation for verifications.
+
+Each file verification produces one trace:
+  verify_file (submitted by coordinator, ended by worker)
+  ├── queue_wait (time spent in the job queue)
+  ├── hash       (SHA256 computation)
+  └── move       (move to verified folder or DLQ)
+
+When tracing is disabled every helper returns a no-op span without
+building attributes, so there is no overhead on the hot path.
+*/
+
+// tracerName identifies spans produced by this service
+const tracerName = "go-filesha-verifier"
+
+// tracingEnabled is set once by InitTracing before any component starts
+var tracingEnabled bool
+
+// InitTracing configures the global OTLP trace exporter when tracing is enabled
+// Returns a shutdown function that flushes pending spans
+func InitTracing(cfg TracingConfig) (func(context.Context) error, error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", cfg.ServiceName),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	tracingEnabled = true
+
+	return provider.Shutdown, nil
+}
+
+// startJobSpan starts the root span for a file verification when it is submitted
+func startJobSpan(filePair FilePair) (context.Context, trace.Span) {
+	if !tracingEnabled {
+		return context.Background(), noop.Span{}
+	}
+
+	return otel.Tracer(tracerName).Start(context.Background(), "verify_file",
+		trace.WithAttributes(
+			attribute.String("file.name", filePair.DataFile),
+			attribute.String("file.path", filePair.DataFilePath),
+			attribute.Int64("file.size", filePair.DataSize),
+			attribute.String("hash.algorithm", filePair.hashAlgorithm()),
+		),
+	)
+}
+
+// startPhaseSpan starts a child span for one phase of a verification
+func startPhaseSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	if !tracingEnabled || ctx == nil {
+		return ctx, noop.Span{}
+	}
+
+	return otel.Tracer(tracerName).Start(ctx, name)
+}
+
+// recordQueueWait records the time a job spent waiting in the queue
+func recordQueueWait(ctx context.Context, submittedAt time.Time) {
+	if !tracingEnabled || ctx == nil {
+		return
+	}
+
+	_, span := otel.Tracer(tracerName).Start(ctx, "queue_wait", trace.WithTimestamp(submittedAt))
+	span.End()
+}
+
+// recordOutcome sets the verification outcome on the job's root span
+func recordOutcome(ctx context.Context, outcome string, err error) {
+	if !tracingEnabled || ctx == nil {
+		return
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("verification.outcome", outcome))
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// endSpan ends a span, marking it as failed when err is non-nil
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/*
+Pre-hash transforms turn a data file's content into the content its expected
+hash was computed over, for senders that hash something other than the bytes
+they deliver (e.g. the file after decompression, or after applying a patch).
+
+Transforms are chained per file name pattern (verification.transforms); the
+first rule whose pattern matches the data file name applies. They wrap the
+io.Reader that feeds the hasher, so a transform error is a verification
+failure like any read error.
+
+Supported transforms:
+- gunzip:  gzip decompression
+- bunzip2: bzip2 decompression
+- zlib:    zlib decompression
+- bspatch: apply the BSDIFF40 patch "<datafile>.bsdiff" to the content
+           (the patch is deleted with the sidecar on success, kept with the
+           data file in the DLQ on failure; the content is held in memory,
+           up to verification.bspatchMaxBytes each for the file, the patch
+           and the patched content)
+
+Does NOT:
+- Change the data file that is delivered (only what is hashed)
+- Resume from checkpoints (transformed streams are always hashed in one go)
+*/
+
+// bsdiffPatchSuffix names the patch file applied by the bspatch transform
+const bsdiffPatchSuffix = ".bsdiff"
+
+// defaultBSPatchMaxBytes is the default of verification.bspatchMaxBytes
+const defaultBSPatchMaxBytes = 512 << 20
+
+// preHashTransform wraps the content stream of the data file at dataFilePath,
+// holding at most maxBytes in memory per buffer where it buffers at all
+type preHashTransform func(r io.Reader, dataFilePath string, maxBytes int64) (io.Reader, error)
+
+// preHashTransforms maps transform names to their implementation
+var preHashTransforms = map[string]preHashTransform{
+	"gunzip": func(r io.Reader, _ string, _ int64) (io.Reader, error) {
+		return gzip.NewReader(r)
+	},
+	"bunzip2": func(r io.Reader, _ string, _ int64) (io.Reader, error) {
+		return bzip2.NewReader(r), nil
+	},
+	"zlib": func(r io.Reader, _ string, _ int64) (io.Reader, error) {
+		return zlib.NewReader(r)
+	},
+	"bspatch": applyBSDiffPatch,
+}
+
+// transformsFor returns the transforms of the first rule matching a data file name
+func transformsFor(rules []TransformRule, dataFile string) []string {
+	for _, rule := range rules {
+		if matched, _ := filepath.Match(rule.Pattern, dataFile); matched {
+			return rule.Steps
+		}
+	}
+	return nil
+}
+
+// transformInputs returns the extra files the transforms read besides the data file
+func transformInputs(transforms []string, dataFilePath string) []string {
+	var inputs []string
+	for _, name := range transforms {
+		if name == "bspatch" {
+			inputs = append(inputs, dataFilePath+bsdiffPatchSuffix)
+		}
+	}
+	return inputs
+}
+
+// applyTransforms chains the named transforms over r, in order
+func applyTransforms(r io.Reader, transforms []string, dataFilePath string, maxBytes int64) (io.Reader, error) {
+	for _, name := range transforms {
+		transform, ok := preHashTransforms[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown transform: %s", name)
+		}
+
+		var err error
+		if r, err = transform(r, dataFilePath, maxBytes); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return r, nil
+}
+
+// errInvalidPatch is returned for a patch that is not a valid BSDIFF40 patch
+var errInvalidPatch = errors.New("invalid bsdiff patch")
+
+// errPatchTooLarge is returned when bspatch would hold more than its bound in a buffer
+var errPatchTooLarge = errors.New("larger than verification.bspatchMaxBytes")
+
+// applyBSDiffPatch applies "<datafile>.bsdiff" to the content read from r,
+// holding up to maxBytes each for the content, the patch and the result
+func applyBSDiffPatch(r io.Reader, dataFilePath string, maxBytes int64) (io.Reader, error) {
+	patchFile, err := os.Open(dataFilePath + bsdiffPatchSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patch: %w", err)
+	}
+	defer patchFile.Close()
+	patch, err := readAllLimited(patchFile, maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patch: %w", err)
+	}
+
+	old, err := readAllLimited(r, maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	patched, err := bspatch(old, patch, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(patched), nil
+}
+
+// readAllLimited reads r to the end, failing once more than limit bytes were read
+func readAllLimited(r io.Reader, limit int64) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("%w (%d bytes)", errPatchTooLarge, limit)
+	}
+	return content, nil
+}
+
+// bspatch applies a BSDIFF40 patch (header, then bzip2 control, diff and extra blocks)
+// The patched content may be at most maxSize bytes
+func bspatch(old, patch []byte, maxSize int64) ([]byte, error) {
+	if len(patch) < 32 || string(patch[:8]) != "BSDIFF40" {
+		return nil, errInvalidPatch
+	}
+	ctrlLen := offtin(patch[8:16])
+	diffLen := offtin(patch[16:24])
+	newSize := offtin(patch[24:32])
+	// Each length is checked against what is left, their sum can't overflow
+	blocks := int64(len(patch)) - 32
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || ctrlLen > blocks || diffLen > blocks-ctrlLen {
+		return nil, errInvalidPatch
+	}
+	if newSize > maxSize {
+		return nil, fmt.Errorf("patched content of %d bytes is %w (%d bytes)", newSize, errPatchTooLarge, maxSize)
+	}
+
+	ctrl := bzip2.NewReader(bytes.NewReader(patch[32 : 32+ctrlLen]))
+	diff := bzip2.NewReader(bytes.NewReader(patch[32+ctrlLen : 32+ctrlLen+diffLen]))
+	extra := bzip2.NewReader(bytes.NewReader(patch[32+ctrlLen+diffLen:]))
+
+	patched := make([]byte, newSize)
+	var oldPos, newPos int64
+	var header [24]byte
+	for newPos < newSize {
+		// Each control triple: bytes to add from diff, bytes to copy from extra, old seek
+		if _, err := io.ReadFull(ctrl, header[:]); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidPatch, err)
+		}
+		addLen, copyLen, seek := offtin(header[0:8]), offtin(header[8:16]), offtin(header[16:24])
+		if addLen < 0 || copyLen < 0 || addLen > newSize-newPos || copyLen > newSize-newPos-addLen {
+			return nil, errInvalidPatch
+		}
+
+		if _, err := io.ReadFull(diff, patched[newPos:newPos+addLen]); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidPatch, err)
+		}
+		for i := int64(0); i < addLen; i++ {
+			if oldPos+i >= 0 && oldPos+i < int64(len(old)) {
+				patched[newPos+i] += old[oldPos+i]
+			}
+		}
+		newPos += addLen
+		oldPos += addLen
+
+		if _, err := io.ReadFull(extra, patched[newPos:newPos+copyLen]); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidPatch, err)
+		}
+		newPos += copyLen
+		oldPos += seek
+	}
+
+	return patched, nil
+}
+
+// offtin decodes bsdiff's 8-byte little-endian sign-magnitude integer
+func offtin(buf []byte) int64 {
+	value := int64(buf[7] & 0x7f)
+	for i := 6; i >= 0; i-- {
+		value = value<<8 | int64(buf[i])
+	}
+	if buf[7]&0x80 != 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+// bsdiffHeader builds a BSDIFF40 header with the given block lengths and patched size
+func bsdiffHeader(ctrlLen, diffLen, newSize int64) []byte {
+	header := []byte("BSDIFF40")
+	for _, value := range []int64{ctrlLen, diffLen, newSize} {
+		header = binary.LittleEndian.AppendUint64(header, uint64(value))
+	}
+	return header
+}
+
+func TestBSPatchRejectsOverflowingLengths(t *testing.T) {
+	patch := append(bsdiffHeader(math.MaxInt64-16, math.MaxInt64-16, 4), make([]byte, 64)...)
+	if _, err := bspatch(nil, patch, 1024); !errors.Is(err, errInvalidPatch) {
+		t.Errorf("err = %v, want errInvalidPatch", err)
+	}
+}
+
+func TestBSPatchRejectsOversizedContent(t *testing.T) {
+	patch := append(bsdiffHeader(0, 0, 1<<40), make([]byte, 64)...)
+	if _, err := bspatch(nil, patch, 1<<20); !errors.Is(err, errPatchTooLarge) {
+		t.Errorf("err = %v, want errPatchTooLarge", err)
+	}
+}
+
+func TestApplyBSDiffPatchBoundsTheFileInMemory(t *testing.T) {
+	dir := t.TempDir()
+	dataPath := writeTestFile(t, dir, "data.bin", "")
+	writeTestFile(t, dir, "data.bin"+bsdiffPatchSuffix, string(bsdiffHeader(0, 0, 0)))
+
+	// The file is read as a stream, only up to the limit
+	content := io.MultiReader(strings.NewReader(strings.Repeat("x", 17)), failingReader{})
+	if _, err := applyBSDiffPatch(content, dataPath, 16); !errors.Is(err, errPatchTooLarge) {
+		t.Errorf("err = %v, want errPatchTooLarge", err)
+	}
+
+	// A patch over the limit is refused too
+	writeTestFile(t, dir, "data.bin"+bsdiffPatchSuffix, string(bsdiffHeader(0, 0, 0))+strings.Repeat("x", 16))
+	if _, err := applyBSDiffPatch(bytes.NewReader(nil), dataPath, 16); !errors.Is(err, errPatchTooLarge) {
+		t.Errorf("err = %v, want errPatchTooLarge", err)
+	}
+}
+
+// failingReader fails every read, for streams that must not be read to the end
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read past the limit")
+}
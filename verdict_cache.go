@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sync"
+)
+
+/*
+VerdictCache remembers the content verified during this run, so files with
+the same content (templated reports, re-uploads of a file already delivered)
+reuse the earlier verdict (verification.verdictCacheSize).
+
+Responsibilities:
+1. Record the algorithm, hash and size of every data file verified against
+   a sidecar, once it has passed every check (deny-list included)
+2. Tell the worker whether a data file's fully computed hash and size match
+   content verified earlier in the run; a signed sidecar is then not read
+   again after hashing to confirm it did not change
+3. Stay bounded: the oldest entry is forgotten when the cache is full
+
+Does NOT:
+- Skip hashing: every file is hashed and must match its own sidecar
+- Skip the deny-list: every file is checked against it, the cache only
+  records content once it passed
+- Remember failures (a mismatch says nothing about another file expecting
+  the same hash)
+- Survive a restart
+*/
+
+// VerdictCache maps verified content (algorithm and hash) to its size
+type VerdictCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	sizes      map[string]int64 // Key: "<algorithm>:<hash>"
+	order      []string         // Keys, oldest first
+}
+
+// NewVerdictCache creates a cache holding up to maxEntries verdicts
+func NewVerdictCache(maxEntries int) *VerdictCache {
+	return &VerdictCache{
+		maxEntries: maxEntries,
+		sizes:      make(map[string]int64),
+	}
+}
+
+// Add records content verified in this run
+func (vc *VerdictCache) Add(algorithm, hash string, size int64) {
+	vc.mutex.Lock()
+	defer vc.mutex.Unlock()
+
+	key := algorithm + ":" + hash
+	if _, exists := vc.sizes[key]; !exists {
+		if len(vc.order) >= vc.maxEntries {
+			delete(vc.sizes, vc.order[0])
+			vc.order = vc.order[1:]
+		}
+		vc.order = append(vc.order, key)
+	}
+	vc.sizes[key] = size
+}
+
+// Verified reports whether content with this hash and size was verified earlier in the run
+// Always false on a nil cache (disabled)
+func (vc *VerdictCache) Verified(algorithm, hash string, size int64) bool {
+	if vc == nil {
+		return false
+	}
+
+	vc.mutex.Lock()
+	defer vc.mutex.Unlock()
+
+	verifiedSize, exists := vc.sizes[algorithm+":"+hash]
+	return exists && verifiedSize == size
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+VerifyBatchCommand implements the ad-hoc "verify-batch" subcommand for
+one-off audits of a list of files:
+
+	go-filesha-verifier verify-batch hashes.csv
+	go-filesha-verifier verify-batch -workers 8 -output audit.csv hashes.csv
+
+The input CSV has one "path,expected_hash" row per file; a first row whose
+hash column is not a valid digest is taken as a header, and lines starting
+with # are skipped. Relative paths are resolved from the current directory.
+
+Responsibilities:
+1. Hash the listed files on -workers goroutines with the same hashing core
+   as the service and the verify subcommand
+2. Write one results row per input row, in input order: the expected and
+   computed hash, PASS, FAIL (mismatch) or ERROR (unreadable file, invalid
+   expected hash), the error, the size and the time taken
+3. Print each entry that did not pass and a summary
+
+Exit codes: 0 all passed, 1 a mismatch, 2 usage, CSV or file errors.
+
+Does NOT:
+- Need a config file, or start any of the long-running components
+- Move, delete or log files
+*/
+
+// Results of a verify-batch entry
+const (
+	batchResultPass  = "PASS"
+	batchResultFail  = "FAIL"
+	batchResultError = "ERROR"
+)
+
+// batchEntry is one row of the verify-batch input and its result
+type batchEntry struct {
+	line     int // Line in the input CSV (for messages)
+	path     string
+	expected string // As listed in the input
+	computed string
+	result   string
+	err      error
+	size     int64
+	duration time.Duration
+}
+
+// runVerifyBatchCommand runs the verify-batch subcommand and returns the process exit code
+func runVerifyBatchCommand(args []string) int {
+	flags := flag.NewFlagSet("verify-batch", flag.ContinueOnError)
+	workers := flags.Int("workers", runtime.NumCPU(), "Files hashed concurrently")
+	bufferSize := flags.Int("buffer-size", 8*1024*1024, "Buffer size for reading each file")
+	encoding := flags.String("encoding", HashEncodingAuto, "Digest encoding: auto, hex, base64")
+	algorithm := flags.String("algorithm", AlgorithmSHA256, "Hash algorithm: sha256, sha512, sha1, md5, xxhash, crc64")
+	output := flags.String("output", "", "Results CSV (default: <csvfile> with .results.csv in place of .csv)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s verify-batch [OPTIONS] <csvfile>\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return verifyExitError
+	}
+	if flags.NArg() != 1 || *workers <= 0 || *bufferSize <= 0 {
+		flags.Usage()
+		return verifyExitError
+	}
+	if _, ok := hashAlgorithms[*algorithm]; !ok {
+		fmt.Fprintf(os.Stderr, "verify-batch: unsupported hash algorithm: %s\n", *algorithm)
+		return verifyExitError
+	}
+
+	inputPath := flags.Arg(0)
+	resultsPath := *output
+	if resultsPath == "" {
+		resultsPath = strings.TrimSuffix(inputPath, ".csv") + ".results.csv"
+	}
+
+	entries, err := readBatchCSV(inputPath, *encoding, *algorithm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "verify-batch: %v\n", err)
+		return verifyExitError
+	}
+
+	startTime := time.Now()
+	verifyBatch(entries, *workers, *bufferSize, *encoding, *algorithm)
+	elapsed := time.Since(startTime)
+
+	if err := writeBatchResults(resultsPath, entries); err != nil {
+		fmt.Fprintf(os.Stderr, "verify-batch: %v\n", err)
+		return verifyExitError
+	}
+
+	counts := make(map[string]int)
+	var totalBytes int64
+	for _, entry := range entries {
+		counts[entry.result]++
+		totalBytes += entry.size
+		switch entry.result {
+		case batchResultFail:
+			fmt.Printf("MISMATCH %s (line %d)\n  Expected: %s\n  Computed: %s\n", entry.path, entry.line, entry.expected, entry.computed)
+		case batchResultError:
+			fmt.Printf("ERROR %s (line %d): %v\n", entry.path, entry.line, entry.err)
+		}
+	}
+
+	fmt.Printf("Verified %d files (%d bytes) in %s: %d passed, %d failed, %d errors\n",
+		len(entries), totalBytes, elapsed.Round(time.Millisecond),
+		counts[batchResultPass], counts[batchResultFail], counts[batchResultError])
+	fmt.Printf("Results written to %s\n", resultsPath)
+
+	switch {
+	case counts[batchResultError] > 0:
+		return verifyExitError
+	case counts[batchResultFail] > 0:
+		return verifyExitMismatch
+	}
+	return verifyExitMatch
+}
+
+// readBatchCSV reads the "path,expected_hash" rows of the input CSV
+// An invalid expected hash is kept and reported as that entry's error
+func readBatchCSV(path, encoding, algo string) ([]*batchEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input CSV: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var entries []*batchEntry
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) < 2 || strings.TrimSpace(record[0]) == "" {
+			return nil, fmt.Errorf("%s:%d: expected \"path,expected_hash\"", path, line)
+		}
+
+		entry := &batchEntry{line: line, path: strings.TrimSpace(record[0]), expected: strings.TrimSpace(record[1])}
+		if _, err := parseDigest(entry.expected, encoding, algo); err != nil {
+			// A header row has no digest in its hash column
+			if first {
+				continue
+			}
+			entry.result = batchResultError
+			entry.err = fmt.Errorf("invalid expected hash: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s lists no files", path)
+	}
+	return entries, nil
+}
+
+// verifyBatch hashes the entries on the given number of goroutines and records each result
+func verifyBatch(entries []*batchEntry, workers, bufferSize int, encoding, algo string) {
+	jobs := make(chan *batchEntry)
+	var wg sync.WaitGroup
+	for range min(workers, len(entries)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				verifyBatchEntry(entry, bufferSize, encoding, algo)
+			}
+		}()
+	}
+
+	for _, entry := range entries {
+		if entry.result == "" {
+			jobs <- entry
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// verifyBatchEntry hashes one listed file and compares it with its expected hash
+func verifyBatchEntry(entry *batchEntry, bufferSize int, encoding, algo string) {
+	startTime := time.Now()
+	defer func() { entry.duration = time.Since(startTime) }()
+
+	if info, err := os.Stat(entry.path); err == nil {
+		entry.size = info.Size()
+	}
+
+	computed, expected, err := VerifyFileAgainst(entry.path, entry.expected, bufferSize, encoding, algo, nil, nil, nil)
+	entry.computed = computed
+	switch {
+	case errors.Is(err, ErrHashMismatch):
+		entry.result = batchResultFail
+	case err != nil:
+		entry.result = batchResultError
+		entry.err = err
+	default:
+		entry.expected = expected
+		entry.result = batchResultPass
+	}
+}
+
+// writeBatchResults writes one results row per entry, in input order
+func writeBatchResults(path string, entries []*batchEntry) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create results CSV: %w", err)
+	}
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Path", "ExpectedHash", "ComputedHash", "Result", "Error", "FileSize", "DurationMs"})
+	for _, entry := range entries {
+		errorMessage := ""
+		if entry.err != nil {
+			errorMessage = entry.err.Error()
+		}
+		writer.Write([]string{
+			entry.path,
+			entry.expected,
+			entry.computed,
+			entry.result,
+			errorMessage,
+			strconv.FormatInt(entry.size, 10),
+			strconv.FormatInt(entry.duration.Milliseconds(), 10),
+		})
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write results CSV: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write results CSV: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+VerifyCommand implements the ad-hoc "verify" subcommand.
+
+Checks a single file against its expected hash without a config file
+or any of the long-running components:
+
+	go-filesha-verifier verify data.zip                   # uses data.zip.sha256
+	go-filesha-verifier verify data.zip other.sha256      # explicit sidecar
+	go-filesha-verifier verify data.zip <hash>            # explicit hash
+	go-filesha-verifier verify - <hash>                   # hash stdin
+	go-filesha-verifier verify data.zip - < data.sha256   # sidecar from stdin
+	go-filesha-verifier verify data.zip data.zip.xxhash   # algorithm from the suffix
+	go-filesha-verifier verify -algorithm crc64 data.zip  # uses data.zip.crc64
+
+The algorithm is -algorithm if given, else the one named by the sidecar's
+suffix (.sha512, .md5, .xxhash, ...), else sha256.
+
+Exit codes: 0 match, 1 mismatch, 2 usage or I/O error.
+
+Does NOT:
+- Move, delete or log files (the service does that)
+*/
+
+// stdinArg selects standard input in place of a file argument
+const stdinArg = "-"
+
+// Exit codes of the verify subcommand
+const (
+	verifyExitMatch    = 0
+	verifyExitMismatch = 1
+	verifyExitError    = 2
+)
+
+// runVerifyCommand runs the verify subcommand and returns the process exit code
+func runVerifyCommand(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	bufferSize := flags.Int("buffer-size", 8*1024*1024, "Buffer size for reading the data")
+	encoding := flags.String("encoding", HashEncodingAuto, "Digest encoding: auto, hex, base64")
+	algorithm := flags.String("algorithm", "", "Hash algorithm: sha256, sha512, sha1, md5, xxhash, crc64 (default: from the sidecar suffix, else sha256)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s verify [OPTIONS] <data-file|-> [sidecar|hash|-]\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return verifyExitError
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 || *bufferSize <= 0 {
+		flags.Usage()
+		return verifyExitError
+	}
+	if _, ok := hashAlgorithms[*algorithm]; *algorithm != "" && !ok {
+		fmt.Fprintf(os.Stderr, "verify: unsupported hash algorithm: %s\n", *algorithm)
+		return verifyExitError
+	}
+
+	dataArg := flags.Arg(0)
+	expectedArg := flags.Arg(1)
+	algo := verifyAlgorithm(*algorithm, dataArg, expectedArg)
+
+	expected, err := resolveExpectedHash(dataArg, expectedArg, *encoding, algo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "verify: %v\n", err)
+		return verifyExitError
+	}
+
+	computed, err := hashDataArg(dataArg, *bufferSize, algo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "verify: %v\n", err)
+		return verifyExitError
+	}
+
+	if computed != expected {
+		fmt.Printf("MISMATCH %s\n  Expected: %s\n  Computed: %s\n", dataArg, expected, computed)
+		return verifyExitMismatch
+	}
+
+	fmt.Printf("OK %s  %s\n", computed, dataArg)
+	return verifyExitMatch
+}
+
+// verifyAlgorithm picks the algorithm: the flag, else the sidecar suffix, else sha256
+func verifyAlgorithm(flagValue, dataArg, expectedArg string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if expectedArg != "" && expectedArg != stdinArg {
+		if _, err := os.Stat(expectedArg); err == nil {
+			if algo := strings.TrimPrefix(filepath.Ext(expectedArg), "."); hashAlgorithms[algo] != nil {
+				return algo
+			}
+		}
+	}
+	return AlgorithmSHA256
+}
+
+// resolveExpectedHash determines the expected hash from the second argument
+// Accepts a sidecar path, a literal hash, or "-" for sidecar content on stdin
+// Without one, the sidecar is the data file name plus the algorithm's suffix
+func resolveExpectedHash(dataArg, expectedArg, encoding, algo string) (string, error) {
+	switch {
+	case expectedArg == stdinArg:
+		if dataArg == stdinArg {
+			return "", errors.New("data and sidecar cannot both be read from stdin")
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read sidecar from stdin: %w", err)
+		}
+		return ParseSHA256Content(data, encoding, algo)
+
+	case expectedArg == "":
+		if dataArg == stdinArg {
+			return "", errors.New("an expected hash is required when hashing stdin")
+		}
+		return ReadSHA256File(dataArg+"."+algo, encoding, algo)
+	}
+
+	// An existing file is a sidecar, anything else must be a literal hash
+	if _, err := os.Stat(expectedArg); err == nil {
+		return ReadSHA256File(expectedArg, encoding, algo)
+	}
+	hash, err := parseDigest(expectedArg, encoding, algo)
+	if err != nil {
+		return "", fmt.Errorf("%q is neither a sidecar file nor a valid hash: %w", expectedArg, err)
+	}
+	return hash, nil
+}
+
+// hashDataArg hashes the data file, or standard input for "-"
+func hashDataArg(dataArg string, bufferSize int, algo string) (string, error) {
+	if dataArg == stdinArg {
+		hash, err := hashReader(os.Stdin, bufferSize, algo)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash stdin: %w", err)
+		}
+		return hash, nil
+	}
+
+	return ComputeFileHash(dataArg, bufferSize, algo)
+}
@@ -25,29 +25,40 @@ type ReleaseNotes struct {
 //
 // This function:
 //  1. Locates the release notes YAML file
-//  2. Reads the first version entry
-//  3. Compares embedded version info with release notes
-//  4. Returns error if there's a mismatch
+//  2. Checks the file against the embedded release notes hash (if any)
+//  3. Reads the first version entry
+//  4. Compares embedded version info with release notes
+//  5. Returns error if there's a mismatch
 //
 // Parameters:
 //   - version: Embedded version string from build
 //   - buildTime: Embedded build timestamp from build
 //   - buildID: Embedded SHA256 hash from build
+//   - releaseNotesHash: Embedded SHA256 hash of the release notes file (empty = not checked)
+//   - releaseNotesDir: Directory holding the release notes (empty = auto-detect)
 //
 // Returns:
 //   - error: If validation fails or file cannot be read
 //
 // This validation ensures that:
 //   - The binary matches its release notes
+//   - The release notes were not edited to match a different binary
 //   - No tampering has occurred
 //   - Deployment is using the correct version
-func ValidateVersion(version, buildTime, buildID string) error {
+func ValidateVersion(version, buildTime, buildID, releaseNotesHash, releaseNotesDir string) error {
 	// Get release notes file path
-	rnFile, err := GetReleaseNotesFile()
+	rnFile, err := GetReleaseNotesFile(releaseNotesDir)
 	if err != nil {
 		return fmt.Errorf("failed to locate release notes file: %w", err)
 	}
 
+	// Verify the release notes themselves before trusting their content
+	if releaseNotesHash != "" {
+		if err := VerifyReleaseNotesHash(rnFile, releaseNotesHash); err != nil {
+			return err
+		}
+	}
+
 	// Read version info from release notes
 	releaseInfo, err := GetFirstVersionInfo(rnFile)
 	if err != nil {
@@ -70,28 +81,67 @@ func ValidateVersion(version, buildTime, buildID string) error {
 	return nil
 }
 
+// VerifyReleaseNotesHash checks the release notes file against the hash embedded at build time.
+//
+// Parameters:
+//   - filename: Path to the release notes YAML file
+//   - expectedHash: SHA256 hash (hex) of the file as built
+//
+// Returns:
+//   - error: If the file cannot be read or its hash differs
+func VerifyReleaseNotesHash(filename, expectedHash string) error {
+	computedHash, err := ComputeFileSHA256(filename, 64*1024)
+	if err != nil {
+		return fmt.Errorf("failed to hash release notes file %s: %w", filename, err)
+	}
+
+	if !strings.EqualFold(computedHash, expectedHash) {
+		return fmt.Errorf(
+			"release notes checksum mismatch, %s has been modified:\n"+
+				"  Expected: %s\n"+
+				"  Computed: %s",
+			filename, expectedHash, computedHash,
+		)
+	}
+
+	return nil
+}
+
+// releaseNotesDirEnv overrides the release notes directory (after --release-notes-dir)
+const releaseNotesDirEnv = "FILESHA_RELEASE_NOTES_DIR"
+
 // GetReleaseNotesFile searches for the release notes YAML file in the binary's directory.
 //
 // This function automatically locates the .RN.yaml file without requiring it to be
 // passed as an argument. It searches in the same directory where the binary is running.
+// When that directory cannot be determined (os.Executable fails, or the binary was
+// deleted or replaced while running), it warns and searches the current working
+// directory instead. An explicit directory is the only one searched.
 //
 // Example:
 //
 //	If binary is at: /opt/apps/go-ftp-transfer/ftp-uploader
 //	It will search:  /opt/apps/go-ftp-transfer/*.RN.yaml
 //
+// Parameters:
+//   - dir: Directory to search (empty = auto-detect)
+//
 // Returns:
 //   - string: Full path to the .RN.yaml file
-//   - error: If file not found or directory cannot be determined
-func GetReleaseNotesFile() (string, error) {
-	// Get the directory where the binary is located
-	exePath, err := os.Executable()
-	if err != nil {
-		return "", fmt.Errorf("failed to get executable path: %w", err)
+//   - error: If file not found or no directory can be determined
+func GetReleaseNotesFile(dir string) (string, error) {
+	if dir == "" {
+		var err error
+		if dir, err = executableDir(); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: Cannot determine the binary's directory (%v)\n", err)
+			fmt.Fprintf(os.Stderr, "WARNING: Searching the current directory for release notes; set --release-notes-dir, %s or spec.releaseNotesDir to choose another\n", releaseNotesDirEnv)
+			if dir, err = os.Getwd(); err != nil {
+				return "", fmt.Errorf("failed to get working directory: %w", err)
+			}
+		}
 	}
-	dir := filepath.Dir(exePath)
 
-	// Search for any file ending with .RN.yaml in the binary's directory
+	// Search for any file ending with .RN.yaml in the directory
 	rnFile, err := SearchFile(dir, "*.RN.yaml")
 	if err != nil {
 		return "", fmt.Errorf(".RN.yaml file not found in %s", dir)
@@ -100,6 +150,22 @@ func GetReleaseNotesFile() (string, error) {
 	return rnFile, nil
 }
 
+// executableDir returns the directory of the running binary, failing when the path
+// os.Executable reports no longer exists (Linux appends " (deleted)" to it)
+func executableDir() (string, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get executable path: %w", err)
+	}
+	if strings.HasSuffix(exePath, " (deleted)") {
+		return "", fmt.Errorf("executable %s was deleted", strings.TrimSuffix(exePath, " (deleted)"))
+	}
+	if _, err := os.Stat(exePath); err != nil {
+		return "", fmt.Errorf("executable path %s is unusable: %w", exePath, err)
+	}
+	return filepath.Dir(exePath), nil
+}
+
 // GetFirstVersionInfo reads the first version entry from the release notes YAML file.
 //
 // Parameters:
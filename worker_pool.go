@@ -2,25 +2,42 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 /*
 WorkerPool manages concurrent verification workers.
 
 Responsibilities:
-1. Maintain a fixed pool of worker goroutines (e.g., 10 workers)
+1. Maintain a pool of worker goroutines (e.g., 10 workers), resizable at
+   runtime (PATCH /pool); removed workers finish their current job first
 2. Distribute verification jobs to available workers via buffered channel
 3. Orchestrate the verification process:
    - Call sha_verifier.go to verify hash
    - Call file_operations.go to move/delete files
-   - Call csv_logger.go to log results
+   - Call the result logger (CSV, syslog) to log results
    - Call statistics.go to update metrics
 4. Handle both success and failure cases
-5. Graceful start/stop with proper cleanup
+5. Report the file each worker is processing (GET /pool)
+6. Graceful start/stop with proper cleanup
+7. Replace a worker whose hashing made no progress for stallTimeout (e.g. a
+   read hung on a network file system): its job is cancelled, the file is
+   re-queued as a failed attempt and a new worker takes its place. A read
+   blocked in the kernel cannot be interrupted, so the stuck goroutine is
+   left behind and discards its result if the read ever returns
 
 Does NOT:
 - Scan for files (that's file_scanner.go)
@@ -28,75 +45,309 @@ Does NOT:
 - Compute hashes directly (delegates to sha_verifier.go)
 */
 
+// Job outcomes returned by processJob (also recorded on the job's trace span)
+const (
+	OutcomeVerified = "verified"  // Delivered to the verified folder
+	OutcomeRetry    = "retry"     // Failed, will be retried
+	OutcomeDLQ      = "dlq"       // Failed for good, moved to the DLQ or its failure folder
+	OutcomeSkipped  = "skipped"   // Left in the source because the destination exists
+	OutcomeVanished = "vanished"  // Data file or sidecar disappeared before hashing
+	OutcomeReadOnly = "read-only" // A destination is read-only, left pending
+	OutcomeChanged  = "changed"   // Data file changed, waiting for it to settle
+	OutcomeStalled  = "stalled"   // Hashing stalled, the watchdog re-queued the file
+)
+
+// How the coordinator submits jobs to a full queue
+const (
+	SubmitModeNonblocking = "nonblocking" // Leave the file pending and try again next tick
+	SubmitModeBlocking    = "blocking"    // Wait for room, keeping files in submission order
+)
+
 // WorkerPoolManager manages the worker pool lifecycle
 type WorkerPoolManager struct {
 	jobQueue         chan VerificationJob
 	numWorkers       int
-	csvLogger        *CSVLogger
+	workersMutex     sync.Mutex
+	workers          map[int]*workerState // Key: worker ID
+	nextWorkerID     int
+	stopped          bool // Stop was called, the pool can no longer be resized
+	resultLogger     ResultLogger
 	statsTracker     *StatsTracker
 	fileTracker      *FileTracker
-	verifiedFolder   string
-	dlqFolder        string
+	hashProvider     ExpectedHashProvider // Expected hashes from outside .sha256 files (nil = sidecars only)
+	knownHashes      *KnownHashes         // Trusted hashes delivered without hashing (nil = disabled)
+	verdicts         *VerdictCache        // Content verified earlier in this run (nil = disabled)
+	integrity        IntegrityOracle      // Vouches for files hashed by an earlier attempt
+	progress         *ProgressRegistry    // Live view of the files being hashed
+	hashLimiter      *HashLimiter         // Bounds how many workers hash at once
+	batches          *BatchTracker        // Batch summaries (nil = disabled)
+	sourceFolder     string
+	sourcePathBase   string        // Source paths in results are relative to this (empty = full paths)
+	destinations     *Destinations // Current verified/DLQ/... folders, replaceable at runtime
 	removeFromSource bool
+	onCollision      string
+	resultFiles      bool          // Write a result marker next to each delivered data file
+	crashOnPanic     bool          // Let a panic in a job crash the process instead of recovering
+	stallTimeout     time.Duration // Replace a worker whose hashing makes no progress for this long (0 = never)
+	features         Features
 	ctx              context.Context
 	cancel           context.CancelFunc
 	wg               sync.WaitGroup
-	logLevel         string
+	logLevel         *LogLevel
+}
+
+// workerState is a running worker
+type workerState struct {
+	quit     chan struct{} // Closed to remove the worker after its current job
+	removing bool          // quit was closed, the worker is finishing its current job
+	file     string        // Data file being processed (empty = idle)
+	started  time.Time     // When processing of file started
+	hashing  *hashingJob   // Job whose file is being hashed (nil = not hashing)
+}
+
+// hashingJob is a job a worker is hashing, which the stall watchdog can give up on
+type hashingJob struct {
+	job     VerificationJob
+	cancel  context.CancelFunc // Stops the hashing at its next read
+	release func()             // Frees the hashing slot and the progress entry
+}
+
+// WorkerInfo describes one worker for GET /pool
+type WorkerInfo struct {
+	ID       int       `json:"id"`
+	File     string    `json:"file,omitempty"`
+	Started  time.Time `json:"started,omitzero"`
+	Removing bool      `json:"removing,omitempty"` // Stops once its current job is done
+}
+
+// PoolStatus is a snapshot of the worker pool
+type PoolStatus struct {
+	Workers       int          `json:"workers"`
+	QueueLength   int          `json:"queueLength"`
+	QueueCapacity int          `json:"queueCapacity"`
+	InFlight      []string     `json:"inFlight"`
+	WorkerStates  []WorkerInfo `json:"workerStates"`
+}
+
+// WorkerPoolOptions configures a worker pool
+type WorkerPoolOptions struct {
+	QueueSize        int
+	Workers          int
+	ResultLogger     ResultLogger
+	StatsTracker     *StatsTracker
+	FileTracker      *FileTracker
+	HashProvider     ExpectedHashProvider // Expected hashes from outside .sha256 files (nil = sidecars only)
+	KnownHashes      *KnownHashes         // Trusted hashes delivered without hashing (nil = disabled)
+	Verdicts         *VerdictCache        // Content verified earlier in this run (nil = disabled)
+	Integrity        IntegrityOracle      // Vouches for files hashed by an earlier attempt
+	Progress         *ProgressRegistry
+	HashLimiter      *HashLimiter
+	Batches          *BatchTracker // Batch summaries (nil = disabled)
+	SourceFolder     string
+	SourcePathBase   string // Source paths in results are relative to this (empty = full paths)
+	Destinations     *Destinations
+	RemoveFromSource bool
+	OnCollision      string
+	ResultFiles      bool
+	CrashOnPanic     bool
+	StallTimeout     time.Duration
+	Features         Features
+	LogLevel         *LogLevel
 }
 
 // NewWorkerPoolManager creates a new worker pool manager
-func NewWorkerPoolManager(
-	queueSize int,
-	numWorkers int,
-	csvLogger *CSVLogger,
-	statsTracker *StatsTracker,
-	fileTracker *FileTracker,
-	verifiedFolder string,
-	dlqFolder string,
-	removeFromSource bool,
-	logLevel string,
-) *WorkerPoolManager {
+func NewWorkerPoolManager(opts WorkerPoolOptions) *WorkerPoolManager {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &WorkerPoolManager{
-		jobQueue:         make(chan VerificationJob, queueSize),
-		numWorkers:       numWorkers,
-		csvLogger:        csvLogger,
-		statsTracker:     statsTracker,
-		fileTracker:      fileTracker,
-		verifiedFolder:   verifiedFolder,
-		dlqFolder:        dlqFolder,
-		removeFromSource: removeFromSource,
+		jobQueue:         make(chan VerificationJob, opts.QueueSize),
+		numWorkers:       opts.Workers,
+		workers:          make(map[int]*workerState),
+		resultLogger:     opts.ResultLogger,
+		statsTracker:     opts.StatsTracker,
+		fileTracker:      opts.FileTracker,
+		hashProvider:     opts.HashProvider,
+		knownHashes:      opts.KnownHashes,
+		verdicts:         opts.Verdicts,
+		integrity:        opts.Integrity,
+		progress:         opts.Progress,
+		hashLimiter:      opts.HashLimiter,
+		batches:          opts.Batches,
+		sourceFolder:     opts.SourceFolder,
+		sourcePathBase:   opts.SourcePathBase,
+		destinations:     opts.Destinations,
+		removeFromSource: opts.RemoveFromSource,
+		onCollision:      opts.OnCollision,
+		resultFiles:      opts.ResultFiles,
+		crashOnPanic:     opts.CrashOnPanic,
+		stallTimeout:     opts.StallTimeout,
+		features:         opts.Features,
 		ctx:              ctx,
 		cancel:           cancel,
-		logLevel:         logLevel,
+		logLevel:         opts.LogLevel,
 	}
 }
 
 // Start launches all worker goroutines
 func (wpm *WorkerPoolManager) Start() {
+	wpm.workersMutex.Lock()
 	for i := 0; i < wpm.numWorkers; i++ {
-		wpm.wg.Add(1)
-		go wpm.worker(i)
+		wpm.startWorker()
 	}
+	wpm.workersMutex.Unlock()
 
-	if wpm.logLevel == "DEBUG" || wpm.logLevel == "INFO" {
+	if wpm.logLevel.Get() == "DEBUG" || wpm.logLevel.Get() == "INFO" {
 		fmt.Printf("[WorkerPool] Started %d workers\n", wpm.numWorkers)
 	}
 }
 
+// startWorker launches one worker, caller holds workersMutex
+func (wpm *WorkerPoolManager) startWorker() {
+	workerID := wpm.nextWorkerID
+	wpm.nextWorkerID++
+
+	state := &workerState{quit: make(chan struct{})}
+	wpm.workers[workerID] = state
+	wpm.wg.Add(1)
+	go wpm.worker(workerID, state.quit)
+}
+
+// SetWorkers changes the number of workers
+// Extra workers are started at once; removed workers (the newest ones) stop
+// after finishing their current job
+func (wpm *WorkerPoolManager) SetWorkers(count int) error {
+	if count <= 0 {
+		return fmt.Errorf("worker count must be positive")
+	}
+
+	wpm.workersMutex.Lock()
+	defer wpm.workersMutex.Unlock()
+
+	if wpm.stopped {
+		return fmt.Errorf("worker pool is stopped")
+	}
+
+	previous := wpm.numWorkers
+	for wpm.numWorkers < count {
+		wpm.startWorker()
+		wpm.numWorkers++
+	}
+	for wpm.numWorkers > count {
+		// Remove the newest worker not already being removed
+		newest := -1
+		for workerID, state := range wpm.workers {
+			if !state.removing && workerID > newest {
+				newest = workerID
+			}
+		}
+		wpm.workers[newest].removing = true
+		close(wpm.workers[newest].quit)
+		wpm.numWorkers--
+	}
+
+	if previous != count && (wpm.logLevel.Get() == "DEBUG" || wpm.logLevel.Get() == "INFO") {
+		fmt.Printf("[WorkerPool] Workers changed from %d to %d\n", previous, count)
+	}
+	return nil
+}
+
+// Status returns a snapshot of the workers and the job queue
+func (wpm *WorkerPoolManager) Status() PoolStatus {
+	wpm.workersMutex.Lock()
+	defer wpm.workersMutex.Unlock()
+
+	status := PoolStatus{
+		Workers:       wpm.numWorkers,
+		QueueLength:   len(wpm.jobQueue),
+		QueueCapacity: cap(wpm.jobQueue),
+		InFlight:      []string{},
+		WorkerStates:  []WorkerInfo{},
+	}
+	for workerID, state := range wpm.workers {
+		status.WorkerStates = append(status.WorkerStates, WorkerInfo{
+			ID:       workerID,
+			File:     state.file,
+			Started:  state.started,
+			Removing: state.removing,
+		})
+		if state.file != "" {
+			status.InFlight = append(status.InFlight, state.file)
+		}
+	}
+
+	// Sort for stable output
+	sort.Slice(status.WorkerStates, func(i, j int) bool { return status.WorkerStates[i].ID < status.WorkerStates[j].ID })
+	sort.Strings(status.InFlight)
+
+	return status
+}
+
+// setCurrentFile records the data file a worker is processing ("" = idle)
+// Returns false when the worker was replaced by the stall watchdog
+func (wpm *WorkerPoolManager) setCurrentFile(workerID int, file string) bool {
+	wpm.workersMutex.Lock()
+	defer wpm.workersMutex.Unlock()
+
+	state, exists := wpm.workers[workerID]
+	if !exists {
+		return false
+	}
+	state.file = file
+	state.started = clockNow()
+	if file == "" {
+		state.started = time.Time{}
+	}
+	return true
+}
+
+// workerExited forgets a worker that has exited
+// A worker replaced by the stall watchdog was already forgotten
+func (wpm *WorkerPoolManager) workerExited(workerID int) {
+	wpm.workersMutex.Lock()
+	defer wpm.workersMutex.Unlock()
+
+	if _, exists := wpm.workers[workerID]; !exists {
+		return
+	}
+	delete(wpm.workers, workerID)
+	wpm.wg.Done()
+}
+
 // Stop gracefully stops all workers
 func (wpm *WorkerPoolManager) Stop() {
+	wpm.workersMutex.Lock()
+	wpm.stopped = true
+	wpm.workersMutex.Unlock()
+
 	// Close job queue to signal workers to finish
 	close(wpm.jobQueue)
 
-	// Wait for all workers to complete
-	wpm.wg.Wait()
+	// Wait for all workers to complete; one stuck on a read is given up on
+	// after the stall timeout, so it cannot block shutdown
+	done := make(chan struct{})
+	go func() {
+		wpm.wg.Wait()
+		close(done)
+	}()
+	if wpm.stallTimeout > 0 {
+		stallTicker := time.NewTicker(max(wpm.stallTimeout/4, time.Second))
+		defer stallTicker.Stop()
+	wait:
+		for {
+			select {
+			case <-done:
+				break wait
+			case <-stallTicker.C:
+				wpm.RestartStalled()
+			}
+		}
+	}
+	<-done
 
 	// Cancel context
 	wpm.cancel()
 
-	if wpm.logLevel == "DEBUG" || wpm.logLevel == "INFO" {
+	if wpm.logLevel.Get() == "DEBUG" || wpm.logLevel.Get() == "INFO" {
 		fmt.Println("[WorkerPool] All workers stopped")
 	}
 }
@@ -109,7 +360,7 @@ func (wpm *WorkerPoolManager) SubmitJob(job VerificationJob) bool {
 		return true
 	default:
 		// Queue is full
-		if wpm.logLevel == "WARN" || wpm.logLevel == "DEBUG" {
+		if wpm.logLevel.Get() == "WARN" || wpm.logLevel.Get() == "DEBUG" {
 			fmt.Fprintf(os.Stderr, "[WorkerPool] Queue full, dropping job for %s\n", job.FilePair.DataFile)
 		}
 		return false
@@ -117,52 +368,428 @@ func (wpm *WorkerPoolManager) SubmitJob(job VerificationJob) bool {
 }
 
 // SubmitJobBlocking submits a job and blocks until it's accepted
-func (wpm *WorkerPoolManager) SubmitJobBlocking(job VerificationJob) {
-	wpm.jobQueue <- job
+// Returns false without submitting when ctx is cancelled first
+func (wpm *WorkerPoolManager) SubmitJobBlocking(ctx context.Context, job VerificationJob) bool {
+	select {
+	case wpm.jobQueue <- job:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 // worker is the main worker goroutine that processes verification jobs
-func (wpm *WorkerPoolManager) worker(workerID int) {
-	defer wpm.wg.Done()
+// It runs until the job queue is closed or quit is closed (the pool shrank)
+func (wpm *WorkerPoolManager) worker(workerID int, quit chan struct{}) {
+	defer wpm.workerExited(workerID)
 
-	if wpm.logLevel == "DEBUG" {
+	if wpm.logLevel.Get() == "DEBUG" {
 		fmt.Printf("[Worker %d] Started\n", workerID)
 	}
 
-	for job := range wpm.jobQueue {
-		wpm.processJob(workerID, job)
+	for {
+		// A removed worker takes no further jobs, even when some are waiting
+		select {
+		case <-quit:
+			if wpm.logLevel.Get() == "DEBUG" {
+				fmt.Printf("[Worker %d] Removed\n", workerID)
+			}
+			return
+		default:
+		}
+
+		select {
+		case <-quit:
+			continue
+		case job, ok := <-wpm.jobQueue:
+			if !ok {
+				if wpm.logLevel.Get() == "DEBUG" {
+					fmt.Printf("[Worker %d] Stopped\n", workerID)
+				}
+				return
+			}
+			wpm.setCurrentFile(workerID, job.FilePair.DataFile)
+			wpm.runJob(workerID, job)
+			if !wpm.setCurrentFile(workerID, "") {
+				// The stall watchdog replaced this worker while it was stuck
+				return
+			}
+		}
 	}
+}
 
-	if wpm.logLevel == "DEBUG" {
-		fmt.Printf("[Worker %d] Stopped\n", workerID)
+// runJob processes a job, surviving a panic unless crashOnPanic is set
+// A recovered panic is logged with its stack and recorded as a failed attempt,
+// so the file is retried until its deadline like any failure, then sent to the DLQ
+func (wpm *WorkerPoolManager) runJob(workerID int, job VerificationJob) {
+	if !wpm.crashOnPanic {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Fprintf(os.Stderr, "[Worker %d] CRITICAL: panic while processing %s: %v\n%s",
+					workerID, job.FilePair.DataFile, r, debug.Stack())
+				wpm.statsTracker.IncrementPanics()
+				// The watchdog may have given up on the worker and re-queued the file already
+				if !wpm.unwatchHashing(workerID) {
+					return
+				}
+				result := unhashedResult(job, wpm.destinations.Get())
+				result.ErrorMessage = fmt.Sprintf("panic: %v", r)
+				wpm.handleFailure(workerID, result)
+			}
+		}()
 	}
+	wpm.processJob(workerID, job)
 }
 
 // processJob processes a single verification job
-func (wpm *WorkerPoolManager) processJob(workerID int, job VerificationJob) {
-	startTime := time.Now()
+// Returns the result (only Job and Folders are set when the file was not hashed) and the outcome
+func (wpm *WorkerPoolManager) processJob(workerID int, job VerificationJob) (VerificationResult, string) {
+	startTime := clockNow()
 
-	if wpm.logLevel == "DEBUG" {
+	// Destination folders may be changed at runtime, this job uses the ones current now
+	folders := wpm.destinations.Get()
+
+	// The job's root span was started at submission, end it when processing is done
+	defer trace.SpanFromContext(job.TraceContext).End()
+	recordQueueWait(job.TraceContext, job.SubmittedAt)
+
+	if wpm.logLevel.Get() == "DEBUG" {
 		fmt.Printf("[Worker %d] Processing %s\n", workerID, job.FilePair.DataFile)
 	}
 
 	// Check if files still exist (they might have been moved/deleted)
-	if !FileExists(job.FilePair.DataFilePath) || !FileExists(job.FilePair.SHA256Path) {
-		if wpm.logLevel == "DEBUG" {
+	// (a pair with the hash embedded in its name or from the database has no .sha256 file)
+	sidecarMissing := job.FilePair.EmbeddedHash == "" && !job.FilePair.ExternalHash && !job.FilePair.ArchiveCheck && !job.FilePair.EmbeddedChecksum &&
+		!FileExists(job.FilePair.SHA256Path)
+	if !FileExists(job.FilePair.DataFilePath) || sidecarMissing {
+		if wpm.logLevel.Get() == "DEBUG" {
 			fmt.Printf("[Worker %d] Files no longer exist for %s, skipping\n", workerID, job.FilePair.DataFile)
 		}
-		wpm.fileTracker.Remove(job.FilePair.DataFile)
-		return
+		wpm.fileTracker.Remove(job.FilePair.Key)
+		recordOutcome(job.TraceContext, OutcomeVanished, nil)
+		return unhashedResult(job, folders), OutcomeVanished
+	}
+
+	// Every move would fail while a destination is read-only, leave the file pending
+	if wpm.destinations.ReadOnly() != "" {
+		wpm.fileTracker.ClearInFlight(job.FilePair.Key)
+		recordOutcome(job.TraceContext, OutcomeReadOnly, nil)
+		return unhashedResult(job, folders), OutcomeReadOnly
+	}
+
+	// Skip hashing if the data file already differs from what the scanner saw
+	if wpm.handleIfChanged(workerID, job) {
+		return unhashedResult(job, folders), OutcomeChanged
+	}
+
+	// Report hashing progress to the shared registry while this job hashes
+	// Wait for a hashing slot, the CPU budget may be smaller than the worker count
+	// The slot is released once hashing is done, or by the deferred call if hashing panics
+	wpm.hashLimiter.Acquire()
+	wpm.progress.Start(job.FilePair.Key, job.FilePair.DataFile, workerID, job.FilePair.DataSize)
+	releaseHashing := sync.OnceFunc(func() {
+		wpm.progress.End(job.FilePair.Key)
+		wpm.hashLimiter.Release()
+	})
+	defer releaseHashing()
+	hashCtx := wpm.watchHashing(workerID, job, releaseHashing)
+	progress := func(bytesRead int) error {
+		wpm.progress.Add(job.FilePair.Key, int64(bytesRead))
+		return hashCtx.Err()
+	}
+
+	// The shadow algorithm is computed in the same pass, for the record only
+	var shadow hash.Hash
+	if newShadow, ok := hashAlgorithms[job.ShadowAlgorithm]; ok && job.ShadowAlgorithm != job.FilePair.hashAlgorithm() {
+		shadow = newShadow()
+	}
+
+	// A resumable upload's chunks are hashed in the same pass (nil = no chunk manifest)
+	var chunks *ChunkVerifier
+	var chunkErr error
+	if !job.FilePair.EmbeddedChecksum {
+		chunks, chunkErr = wpm.features.ChunkManifests.Load(job.FilePair.DataFilePath, job.FilePair.DataSize, job.FilePair.hashAlgorithm())
+	}
+	// The deny-list is of SHA-256 hashes of the file itself, computed in the same
+	// pass unless that is the hash verified anyway
+	var denyHasher hash.Hash
+	if wpm.features.DenyList != nil && (job.FilePair.hashAlgorithm() != AlgorithmSHA256 || len(job.Transforms) > 0) {
+		denyHasher = sha256.New()
+	}
+	tee := hashTee(shadow, denyHasher, chunks)
+
+	// Perform verification (SHA256 unless the directives select another algorithm)
+	var computedHash, expectedHash string
+	var err error
+	permanent := false
+	mispaired := false
+	untrusted := false
+	denied := false
+	badChunks := false
+	failedFolder := ""
+	linkedTo := ""
+	rememberVerdict := false // Hashed and checked against its sidecar, the verdict cache records it if it passes
+	if chunkErr != nil {
+		err = chunkErr
+	} else if directives := job.FilePair.Directives; directives != nil && directives.Error != "" {
+		err = fmt.Errorf("invalid directives: %s", directives.Error)
+	} else if job.FilePair.EmbeddedHash != "" {
+		_, hashSpan := startPhaseSpan(job.TraceContext, "hash")
+		computedHash, expectedHash, err = wpm.features.Hasher.VerifyFileAgainst(
+			job.FilePair.DataFilePath,
+			job.FilePair.EmbeddedHash,
+			job.BufferSize,
+			job.HashEncoding,
+			job.FilePair.hashAlgorithm(),
+			job.Transforms,
+			tee,
+			progress,
+		)
+		endSpan(hashSpan, err)
+
+		// The name won't change between attempts, so neither will the outcome
+		permanent = errors.Is(err, ErrHashMismatch) || errors.Is(err, ErrInvalidExpectedHash)
+	} else if job.FilePair.ExternalHash && wpm.hashProvider != nil {
+		// Lookup errors (e.g. database unreachable) are retried like any failed attempt
+		_, hashSpan := startPhaseSpan(job.TraceContext, "hash")
+		var expected string
+		expected, err = wpm.hashProvider.ExpectedHash(job.FilePair)
+		if err == nil {
+			computedHash, expectedHash, err = wpm.features.Hasher.VerifyFileAgainst(
+				job.FilePair.DataFilePath,
+				expected,
+				job.BufferSize,
+				job.HashEncoding,
+				job.FilePair.hashAlgorithm(),
+				job.Transforms,
+				tee,
+				progress,
+			)
+		} else {
+			err = fmt.Errorf("failed to read expected hash: %w", err)
+		}
+		endSpan(hashSpan, err)
+	} else if job.FilePair.EmbeddedChecksum {
+		_, hashSpan := startPhaseSpan(job.TraceContext, "hash")
+		computedHash, expectedHash, err = VerifyZipEmbeddedChecksum(
+			job.FilePair.DataFilePath,
+			job.ChecksumMember,
+			job.BufferSize,
+			job.HashEncoding,
+			job.FilePair.hashAlgorithm(),
+			progress,
+		)
+		endSpan(hashSpan, err)
+	} else if job.FilePair.ArchiveCheck {
+		// No expected hash, the hash is still computed for the record
+		_, hashSpan := startPhaseSpan(job.TraceContext, "hash")
+		err = VerifyZipArchive(job.FilePair.DataFilePath, job.BufferSize, progress)
+		if err == nil {
+			computedHash, err = wpm.features.Hasher.hashFile(
+				job.FilePair.DataFilePath,
+				job.BufferSize,
+				job.FilePair.hashAlgorithm(),
+				nil,
+				tee,
+				nil,
+			)
+		}
+		endSpan(hashSpan, err)
+	} else {
+		_, hashSpan := startPhaseSpan(job.TraceContext, "hash")
+
+		// A signed sidecar is only trusted once its signature checks out
+		var signed []byte
+		if wpm.features.Signatures != nil {
+			signed, err = wpm.features.Signatures.Verify(job.FilePair.SHA256Path)
+		}
+		if err == nil && job.CheckFilename {
+			err = CheckSidecarFilename(job.FilePair.SHA256Path, job.FilePair.DataFile)
+		}
+		cacheable := !job.AnyMatch && len(job.Transforms) == 0 && chunks == nil
+		// A hash taken without reading the file has no deny-list hash besides it
+		unread := cacheable && denyHasher == nil
+		if err == nil && wpm.knownHashes != nil && unread {
+			computedHash, expectedHash = wpm.trustKnownHash(workerID, job)
+		}
+		trusted := computedHash != ""
+
+		// A file the integrity oracle vouches for since an earlier attempt hashed it keeps that hash
+		reused := false
+		if err == nil && !trusted && unread && job.FilePair.LastHash != "" {
+			computedHash, expectedHash, reused = wpm.reuseIntactHash(workerID, job)
+			if reused && !digestsEqual(computedHash, expectedHash) {
+				err = ErrHashMismatch
+			}
+		}
+		// Another hard link of the same file may already have hashed it, or be hashing it
+		releaseLink := func(string) {}
+		claimedAlgo := job.FilePair.hashAlgorithm()
+		if err == nil && !trusted && !reused && unread && wpm.features.InodeDedup != nil {
+			computedHash, linkedTo, releaseLink, err = wpm.features.InodeDedup.Claim(job.FilePair.DataFilePath, claimedAlgo, progress)
+			defer releaseLink("")
+			if linkedTo != "" {
+				var ok bool
+				if expectedHash, ok = wpm.reuseLinkHash(workerID, job, linkedTo); ok {
+					// The shadow algorithm did not see the content this time
+					shadow = nil
+					if !digestsEqual(computedHash, expectedHash) {
+						err = ErrHashMismatch
+					}
+				} else {
+					computedHash, linkedTo = "", ""
+				}
+			}
+		}
+		hashedAt := clockNow()
+		if err == nil && !trusted && !reused && linkedTo == "" {
+			computedHash, expectedHash, err = wpm.features.Hasher.VerifyFile(
+				job.FilePair.DataFilePath,
+				job.FilePair.SHA256Path,
+				job.BufferSize,
+				job.HashEncoding,
+				job.FilePair.hashAlgorithm(),
+				job.AnyMatch,
+				job.Transforms,
+				tee,
+				progress,
+			)
+		}
+
+		// A sidecar holding another algorithm's digest is verified with that
+		// algorithm when enabled and unambiguous (directives are authoritative)
+		if errors.Is(err, ErrWrongAlgorithm) && job.DetectAlgorithm && (job.FilePair.Directives == nil || job.FilePair.Directives.Algorithm == "") {
+			if detected, ok := detectSidecarAlgorithm(job.FilePair.SHA256Path, job.HashEncoding, job.FilePair.hashAlgorithm()); ok {
+				if wpm.logLevel.Get() == "WARN" || wpm.logLevel.Get() == "DEBUG" {
+					fmt.Fprintf(os.Stderr, "[Worker %d] %s: %v, verifying with %s\n",
+						workerID, job.FilePair.DataFile, err, detected)
+				}
+				job.FilePair.SidecarAlgorithm = detected
+				// The file is read again from the start
+				for _, h := range []hash.Hash{shadow, denyHasher} {
+					if h != nil {
+						h.Reset()
+					}
+				}
+				// The chunks are hashed with the detected algorithm too
+				if chunks != nil {
+					chunks, chunkErr = wpm.features.ChunkManifests.Load(job.FilePair.DataFilePath, job.FilePair.DataSize, detected)
+					tee = hashTee(shadow, denyHasher, chunks)
+				}
+				if chunkErr != nil {
+					err = chunkErr
+				} else {
+					computedHash, expectedHash, err = wpm.features.Hasher.VerifyFile(
+						job.FilePair.DataFilePath,
+						job.FilePair.SHA256Path,
+						job.BufferSize,
+						job.HashEncoding,
+						detected,
+						job.AnyMatch,
+						job.Transforms,
+						tee,
+						progress,
+					)
+				}
+			}
+		}
+		// Links waiting on this one get the hash only if it is of the algorithm they asked for
+		if linkedTo == "" && (err == nil || errors.Is(err, ErrHashMismatch)) && job.FilePair.hashAlgorithm() == claimedAlgo {
+			releaseLink(computedHash)
+		} else {
+			releaseLink("")
+		}
+		// Content that passed earlier in this run needs no signature re-read once its full hash matched
+		knownContent := false
+		if err == nil && cacheable && !trusted {
+			knownContent = wpm.verdicts.Verified(job.FilePair.hashAlgorithm(), computedHash, job.FilePair.DataSize)
+			if knownContent && (wpm.logLevel.Get() == "DEBUG" || wpm.logLevel.Get() == "INFO") {
+				fmt.Printf("[Worker %d] %s has the content of a file verified earlier in this run, reusing its verdict\n",
+					workerID, job.FilePair.DataFile)
+			}
+			rememberVerdict = wpm.verdicts != nil && !knownContent
+		}
+
+		// The hash must have come from the content whose signature was checked
+		// (known content passed whichever sidecar named it)
+		if err == nil && signed != nil && !knownContent && !wpm.features.Signatures.Unchanged(job.FilePair.SHA256Path, signed) {
+			err = fmt.Errorf("sidecar changed during verification")
+		}
+		endSpan(hashSpan, err)
+
+		// A bad signature means the sidecar can't be trusted, not that the data is corrupt;
+		// a missing one may still be uploading and is retried until the deadline
+		if errors.Is(err, ErrInvalidSignature) || errors.Is(err, ErrMissingSignature) {
+			permanent = errors.Is(err, ErrInvalidSignature)
+			untrusted = true
+			failedFolder = folders.Quarantine
+		}
+
+		// A retry may reuse this hash if the integrity oracle vouches for the file
+		hashed := cacheable && !trusted && !reused && linkedTo == "" && computedHash != ""
+		if hashed && (err == nil || errors.Is(err, ErrHashMismatch)) {
+			wpm.fileTracker.RecordHash(job.FilePair.Key, job.FilePair.hashAlgorithm(), computedHash, hashedAt)
+		}
+
+		// A digest of another algorithm won't change on retry, the sender or the config is wrong
+		if errors.Is(err, ErrWrongAlgorithm) {
+			permanent = true
+		}
+
+		// An empty sidecar means the sender's checksum step failed, it won't fill in later
+		if errors.Is(err, ErrEmptySidecar) {
+			permanent = true
+			failedFolder = folders.EmptySidecar
+		}
+
+		// A sidecar written for another file is a mispaired upload, not a corrupt one
+		if errors.Is(err, ErrMispairedSidecar) {
+			permanent = true
+			mispaired = true
+			failedFolder = folders.Mispaired
+		}
 	}
 
-	// Perform SHA256 verification
-	computedHash, expectedHash, err := VerifyFile(
-		job.FilePair.DataFilePath,
-		job.FilePair.SHA256Path,
-		job.BufferSize,
-	)
+	// A resumable upload passes only if every chunk matches too, the failed ones are named
+	if chunks != nil && (err == nil || errors.Is(err, ErrHashMismatch)) {
+		if chunkErr := chunks.Verify(); chunkErr != nil {
+			err = chunkErr
+			badChunks = true
+		}
+	}
+	releaseHashing()
+
+	// A worker the watchdog gave up on leaves the file to the next attempt
+	if !wpm.unwatchHashing(workerID) {
+		return unhashedResult(job, folders), OutcomeStalled
+	}
+
+	// A file rewritten while we hashed it gives a meaningless result either way
+	if wpm.handleIfChanged(workerID, job) {
+		return unhashedResult(job, folders), OutcomeChanged
+	}
+
+	// Known-bad content is quarantined whatever its sidecar says
+	deniedHash := computedHash
+	if denyHasher != nil && computedHash != "" {
+		deniedHash = hex.EncodeToString(denyHasher.Sum(nil))
+	}
+	if entry, ok := wpm.features.DenyList.Match(deniedHash); ok {
+		fmt.Fprintf(os.Stderr, "[Worker %d] CRITICAL: %s matches deny-list entry %s, quarantining it\n",
+			workerID, job.FilePair.DataFile, entry)
+		err = fmt.Errorf("%w: content matches deny-list entry %s", ErrDeniedHash, entry)
+		permanent = true
+		denied = true
+		failedFolder = folders.Quarantine
+	}
+
+	// Only content that passed every check, the deny-list included, is remembered
+	// for later files with it
+	if err == nil && rememberVerdict {
+		wpm.verdicts.Add(job.FilePair.hashAlgorithm(), computedHash, job.FilePair.DataSize)
+	}
 
-	duration := time.Since(startTime)
+	duration := clockNow().Sub(startTime)
 
 	// Create verification result
 	result := VerificationResult{
@@ -170,25 +797,199 @@ func (wpm *WorkerPoolManager) processJob(workerID int, job VerificationJob) {
 		Success:      err == nil,
 		ComputedHash: computedHash,
 		ExpectedHash: expectedHash,
+		Permanent:    permanent,
+		FailedFolder: failedFolder,
+		Mispaired:    mispaired,
+		Untrusted:    untrusted,
+		Denied:       denied,
+		BadChunks:    badChunks,
+		HoleBytes:    -1,
+		LinkedTo:     linkedTo,
+		Folders:      folders,
 		Duration:     duration,
-		Timestamp:    time.Now(),
+		Timestamp:    clockNow(),
 	}
 
 	if err != nil {
 		result.ErrorMessage = err.Error()
+	} else if shadow != nil {
+		result.ShadowHash = hex.EncodeToString(shadow.Sum(nil))
 	}
 
 	// Handle result
 	if result.Success {
-		wpm.handleSuccess(workerID, result)
-	} else {
-		wpm.handleFailure(workerID, result)
+		return result, wpm.handleSuccess(workerID, result)
 	}
+	return result, wpm.handleFailure(workerID, result)
 }
 
-// handleSuccess handles a successful verification
-func (wpm *WorkerPoolManager) handleSuccess(workerID int, result VerificationResult) {
-	if wpm.logLevel == "DEBUG" || wpm.logLevel == "INFO" {
+// watchHashing registers the job a worker starts hashing with the stall watchdog
+// The returned context is cancelled if the watchdog gives up on the worker
+func (wpm *WorkerPoolManager) watchHashing(workerID int, job VerificationJob, release func()) context.Context {
+	ctx, cancel := context.WithCancel(wpm.ctx)
+
+	wpm.workersMutex.Lock()
+	defer wpm.workersMutex.Unlock()
+
+	state, exists := wpm.workers[workerID]
+	if !exists {
+		cancel()
+		return ctx
+	}
+	state.hashing = &hashingJob{job: job, cancel: cancel, release: release}
+	return ctx
+}
+
+// unwatchHashing ends the watch of a worker's hashing
+// Returns false when the watchdog already gave up on the worker
+func (wpm *WorkerPoolManager) unwatchHashing(workerID int) bool {
+	wpm.workersMutex.Lock()
+	defer wpm.workersMutex.Unlock()
+
+	state, exists := wpm.workers[workerID]
+	if !exists {
+		return false
+	}
+	if state.hashing != nil {
+		state.hashing.cancel()
+		state.hashing = nil
+	}
+	return true
+}
+
+// RestartStalled replaces the workers whose hashing made no progress for stallTimeout
+// Each stalled job is cancelled and its file re-queued as a failed attempt
+func (wpm *WorkerPoolManager) RestartStalled() {
+	for _, file := range wpm.progress.Stalled(wpm.stallTimeout) {
+		wpm.workersMutex.Lock()
+		state, exists := wpm.workers[file.Worker]
+		if !exists || state.hashing == nil || state.hashing.job.FilePair.Key != file.key {
+			wpm.workersMutex.Unlock()
+			continue
+		}
+		hashing := state.hashing
+
+		// The stuck goroutine no longer counts as a worker; it exits if its read returns
+		delete(wpm.workers, file.Worker)
+		wpm.wg.Done()
+		replaced := !state.removing && !wpm.stopped
+		if replaced {
+			wpm.startWorker()
+		}
+		wpm.workersMutex.Unlock()
+
+		hashing.cancel()
+		hashing.release()
+
+		idle := time.Since(file.LastProgress).Round(time.Second)
+		action := "given up on"
+		if replaced {
+			action = "replaced by a new worker"
+		}
+		fmt.Fprintf(os.Stderr, "[Worker %d] CRITICAL: stalled hashing %s (no progress for %s, %d of %d bytes), %s; the file is re-queued\n",
+			file.Worker, file.DataFile, idle, file.HashedBytes, file.TotalBytes, action)
+		wpm.statsTracker.IncrementStalls()
+		recordOutcome(hashing.job.TraceContext, OutcomeStalled, nil)
+		wpm.fileTracker.RecordFailure(file.key, fmt.Sprintf("worker stalled: no progress for %s", idle), nextRetryTime(hashing.job))
+	}
+}
+
+// unhashedResult is the result of a job that ended before the file was hashed
+func unhashedResult(job VerificationJob, folders DestinationFolders) VerificationResult {
+	return VerificationResult{Job: job, HoleBytes: -1, Folders: folders, Timestamp: clockNow()}
+}
+
+// trustKnownHash returns the sidecar's expected hash as both the computed and
+// expected hash when it is in the known-good list with the data file's size
+// Returns empty strings when the file has to be hashed
+func (wpm *WorkerPoolManager) trustKnownHash(workerID int, job VerificationJob) (string, string) {
+	expected, err := ReadSHA256File(job.FilePair.SHA256Path, job.HashEncoding, job.FilePair.hashAlgorithm())
+	if err != nil || !wpm.knownHashes.Trusted(expected, job.FilePair.DataSize) {
+		return "", ""
+	}
+
+	if wpm.logLevel.Get() == "DEBUG" || wpm.logLevel.Get() == "INFO" {
+		fmt.Printf("[Worker %d] %s has a known-good hash and size, trusted without hashing\n",
+			workerID, job.FilePair.DataFile)
+	}
+	return expected, expected
+}
+
+// reuseLinkHash reads the expected hash of a file whose content another hard link already hashed
+// Returns false when the sidecar can't be read, leaving it to the normal verification path
+func (wpm *WorkerPoolManager) reuseLinkHash(workerID int, job VerificationJob, linkedTo string) (string, bool) {
+	expected, err := ReadSHA256File(job.FilePair.SHA256Path, job.HashEncoding, job.FilePair.hashAlgorithm())
+	if err != nil {
+		return "", false
+	}
+
+	if wpm.logLevel.Get() == "DEBUG" || wpm.logLevel.Get() == "INFO" {
+		fmt.Printf("[Worker %d] %s is a hard link of %s, reusing its hash\n", workerID, job.FilePair.DataFile, linkedTo)
+	}
+	return expected, true
+}
+
+// reuseIntactHash returns the hash an earlier attempt computed and the expected hash
+// when the integrity oracle vouches the data file has been intact since; ok is false otherwise
+func (wpm *WorkerPoolManager) reuseIntactHash(workerID int, job VerificationJob) (string, string, bool) {
+	pair := job.FilePair
+	if pair.LastHashAlgo != pair.hashAlgorithm() {
+		return "", "", false
+	}
+
+	cleanSince, err := wpm.integrity.CleanSince(pair.DataFilePath)
+	if err != nil {
+		if wpm.logLevel.Get() == "WARN" || wpm.logLevel.Get() == "DEBUG" {
+			fmt.Fprintf(os.Stderr, "[Worker %d] Integrity oracle failed for %s, hashing it: %v\n", workerID, pair.DataFile, err)
+		}
+		return "", "", false
+	}
+	if cleanSince.IsZero() || cleanSince.After(pair.LastHashed) {
+		return "", "", false
+	}
+
+	// An unreadable sidecar is reported by the normal verification path
+	expected, err := ReadSHA256File(pair.SHA256Path, job.HashEncoding, pair.hashAlgorithm())
+	if err != nil {
+		return "", "", false
+	}
+
+	wpm.statsTracker.IncrementOracleReused()
+	if wpm.logLevel.Get() == "DEBUG" || wpm.logLevel.Get() == "INFO" {
+		fmt.Printf("[Worker %d] %s intact since %s according to the integrity oracle, reusing the hash from %s\n",
+			workerID, pair.DataFile, cleanSince.Format(time.RFC3339), pair.LastHashed.Format(time.RFC3339))
+	}
+	return pair.LastHash, expected, true
+}
+
+// handleIfChanged detects a data file whose size or modification time no longer
+// matches what the scanner recorded (e.g. truncated and re-uploaded).
+// Such a file is sent back to wait for the upload to settle instead of being
+// counted as a failed verification. Returns true if the job was handled.
+func (wpm *WorkerPoolManager) handleIfChanged(workerID int, job VerificationJob) bool {
+	info, err := os.Stat(job.FilePair.DataFilePath)
+	if err != nil {
+		// Let the normal verification path report the error
+		return false
+	}
+
+	if info.Size() == job.FilePair.DataSize && info.ModTime().Equal(job.FilePair.DataModTime) {
+		return false
+	}
+
+	if wpm.logLevel.Get() == "WARN" || wpm.logLevel.Get() == "DEBUG" {
+		fmt.Fprintf(os.Stderr, "[Worker %d] %s changed during verification (size %d -> %d), waiting for it to settle\n",
+			workerID, job.FilePair.DataFile, job.FilePair.DataSize, info.Size())
+	}
+
+	wpm.fileTracker.RecordChanged(job.FilePair.Key, info.Size(), info.ModTime())
+	recordOutcome(job.TraceContext, OutcomeChanged, nil)
+	return true
+}
+
+// handleSuccess handles a successful verification and returns the outcome
+func (wpm *WorkerPoolManager) handleSuccess(workerID int, result VerificationResult) string {
+	if wpm.logLevel.Get() == "DEBUG" || wpm.logLevel.Get() == "INFO" {
 		fmt.Printf("[Worker %d] ✓ SUCCESS: %s (%.2f KB, %.3fs)\n",
 			workerID,
 			result.Job.FilePair.DataFile,
@@ -196,79 +997,341 @@ func (wpm *WorkerPoolManager) handleSuccess(workerID int, result VerificationRes
 			result.Duration.Seconds())
 	}
 
-	// Move data file to verified folder
-	newPath, err := MoveToVerified(result.Job.FilePair.DataFilePath, wpm.verifiedFolder)
+	// Re-read the delivered file to prove it landed intact (the transformed
+	// content was hashed when transforms apply, so the file itself can't be compared)
+	var check *DeliveryCheck
+	if result.Job.ConfirmDelivery && len(result.Job.Transforms) == 0 {
+		check = &DeliveryCheck{
+			Algorithm:  result.Job.FilePair.hashAlgorithm(),
+			Hash:       result.ComputedHash,
+			BufferSize: result.Job.BufferSize,
+		}
+	}
+
+	// Note sparse files, a destination that doesn't keep holes stores them fully allocated
+	if result.Job.DetectSparse {
+		wpm.detectSparse(workerID, &result)
+	}
+
+	// Move data file to verified folder (or the subfolder chosen by its directives),
+	// renamed by the naming template; identical content gets the same name, so
+	// the collision policy decides what happens to a second copy
+	destFolder := result.Job.FilePair.Directives.destination(result.Folders.Verified)
+	destName := verifiedName(result.Job.NamingTemplate, result.Job.FilePair.DataFile, result.Job.FilePair.hashAlgorithm(), result.ComputedHash)
+	compressed := result.Job.CompressLevel > 0
+	action := ActionMoved
+	if compressed {
+		action = ActionCompressed
+		destName += compressedSuffix
+		if check != nil {
+			check.Transforms = []string{"gunzip"}
+		}
+	}
+	// Record the delivery before it starts, so a crash in the middle is finished or undone at restart
+	keepSidecar := result.Job.SidecarDeleteDelay > 0 && result.Job.FilePair.SHA256Path != ""
+	var marker *ResultFile
+	if wpm.resultFiles {
+		pending := newResultFile("", ResultVerified, result)
+		marker = &pending
+	}
+	intentID := wpm.features.IntentLog.Begin(result, filepath.Join(destFolder, destName), compressed, keepSidecar,
+		CreateCSVLogEntry(result, action, "", wpm.sourcePathBase), marker)
+
+	_, moveSpan := startPhaseSpan(result.Job.TraceContext, "move")
+	err := os.MkdirAll(destFolder, 0755)
+	var newPath string
+	if err == nil && compressed {
+		comment := result.Job.FilePair.hashAlgorithm() + ":" + result.ComputedHash
+		newPath, result.CompressedBytes, err = CompressToVerified(result.Job.FilePair.DataFilePath, destFolder, destName, wpm.onCollision, result.Job.CompressLevel, comment, check)
+	} else if err == nil {
+		newPath, err = MoveToVerified(result.Job.FilePair.DataFilePath, destFolder, destName, wpm.onCollision, check, result.Job.PreserveSparse)
+	}
+	endSpan(moveSpan, err)
+	if err != nil {
+		wpm.features.IntentLog.Abort(intentID)
+	} else {
+		wpm.features.IntentLog.Delivered(intentID, newPath)
+	}
+	if errors.Is(err, ErrCollisionSkipped) {
+		// Collision policy forbids replacing the existing file, leave source in place
+		if wpm.logLevel.Get() == "WARN" || wpm.logLevel.Get() == "DEBUG" {
+			fmt.Fprintf(os.Stderr, "[Worker %d] Skipped %s: %v\n", workerID, result.Job.FilePair.DataFile, err)
+		}
+		wpm.fileTracker.MarkSkipped(result.Job.FilePair.Key)
+		wpm.fingerprintLeftInPlace(workerID, result.Job.FilePair)
+		recordOutcome(result.Job.TraceContext, OutcomeSkipped, nil)
+		return OutcomeSkipped
+	}
+	if isReadOnlyFS(err) {
+		wpm.handleReadOnly(workerID, result, destFolder, err)
+		return OutcomeReadOnly
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[Worker %d] Failed to move %s to verified folder: %v\n",
 			workerID, result.Job.FilePair.DataFile, err)
-		wpm.statsTracker.IncrementFailure(result.Duration)
-		return
+
+		// Treat as a failed attempt so it is retried until the deadline, then sent to DLQ
+		result.Success = false
+		result.ErrorMessage = err.Error()
+		return wpm.handleFailure(workerID, result)
 	}
 
-	if wpm.logLevel == "DEBUG" {
+	if wpm.logLevel.Get() == "DEBUG" {
 		fmt.Printf("[Worker %d] Moved to: %s\n", workerID, newPath)
 	}
-
-	// Delete SHA256 file from source
-	if err := DeleteFile(result.Job.FilePair.SHA256Path); err != nil {
-		fmt.Fprintf(os.Stderr, "[Worker %d] Failed to delete SHA256 file %s: %v\n",
-			workerID, result.Job.FilePair.SHA256File, err)
-		// Continue anyway - data file was moved successfully
+	if marker != nil {
+		marker.Path = newPath
+		if err := marker.write(); err != nil {
+			fmt.Fprintf(os.Stderr, "[Worker %d] Failed to write result file: %v\n", workerID, err)
+		}
 	}
+	wpm.features.LatestLinks.Update(result.Job.FilePair.DataFile, newPath)
 
-	// Remove from tracker
-	wpm.fileTracker.Remove(result.Job.FilePair.DataFile)
+	// Keep the sidecar until the delivery has had time to prove durable, or delete it now
+	if keepSidecar {
+		wpm.fileTracker.DeferDeletion(DeferredDeletion{
+			Pair:          result.Job.FilePair,
+			DeliveredPath: newPath,
+			Transforms:    result.Job.Transforms,
+			Check:         check,
+			Compressed:    compressed,
+			Due:           clockNow().Add(result.Job.SidecarDeleteDelay),
+		})
+	} else {
+		wpm.features.deleteSourceCompanions(result.Job.FilePair, result.Job.Transforms, wpm.sourceFolder, fmt.Sprintf("[Worker %d]", workerID))
+		wpm.fileTracker.Remove(result.Job.FilePair.Key)
+	}
 
 	// Update statistics
-	wpm.statsTracker.IncrementSuccess(result.Duration)
+	wpm.statsTracker.IncrementSuccess(result.Duration, result.Job.FilePair.DataSize)
+	if wpm.batches != nil {
+		wpm.batches.RecordResult(result.Job.FilePair, true)
+	}
+	recordOutcome(result.Job.TraceContext, OutcomeVerified, nil)
 
 	// Log to CSV
-	csvEntry := CreateCSVLogEntry(result)
-	if err := wpm.csvLogger.LogVerification(csvEntry); err != nil {
+	csvEntry := CreateCSVLogEntry(result, action, newPath, wpm.sourcePathBase)
+	if err := wpm.resultLogger.LogVerification(csvEntry); err != nil {
 		fmt.Fprintf(os.Stderr, "[Worker %d] Failed to log verification: %v\n", workerID, err)
 	}
+	wpm.features.IntentLog.Done(intentID)
+	return OutcomeVerified
+}
+
+// fingerprintLeftInPlace records the change signal of a pair left in the source folder
+func (wpm *WorkerPoolManager) fingerprintLeftInPlace(workerID int, pair FilePair) {
+	if wpm.features.ChangeDetector == nil {
+		return
+	}
+	fingerprint, err := wpm.features.ChangeDetector.Fingerprint(pair.DataFilePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[Worker %d] Failed to fingerprint %s, changes to it will not be detected: %v\n", workerID, pair.DataFile, err)
+		return
+	}
+	wpm.fileTracker.SetFingerprint(pair.Key, fingerprint)
+}
+
+// detectSparse records how many bytes of a verified data file are holes
+func (wpm *WorkerPoolManager) detectSparse(workerID int, result *VerificationResult) {
+	holes, err := sparseHoleBytes(result.Job.FilePair.DataFilePath, result.Job.FilePair.DataSize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[Worker %d] Failed to check %s for holes: %v\n", workerID, result.Job.FilePair.DataFile, err)
+		return
+	}
+	result.HoleBytes = holes
+
+	if holes > 0 && (wpm.logLevel.Get() == "WARN" || wpm.logLevel.Get() == "DEBUG") {
+		note := "the delivered copy may be fully allocated"
+		if result.Job.PreserveSparse {
+			note = "holes are kept on delivery"
+		}
+		fmt.Fprintf(os.Stderr, "[Worker %d] %s is sparse: %d of %d bytes are holes, %s\n",
+			workerID, result.Job.FilePair.DataFile, holes, result.Job.FilePair.DataSize, note)
+	}
+}
+
+// deleteSourceCompanions removes what a delivered data file leaves behind in the source:
+// its sidecar (only if it is still the one we scanned), transform inputs and directives
+func (f Features) deleteSourceCompanions(pair FilePair, transforms []string, sourceFolder, logPrefix string) {
+	if pair.SHA256Path != "" {
+		err := SafeDeleteFile(pair.SHA256Path, sourceFolder, pair.SHA256Size, pair.SHA256MTime)
+		if errors.Is(err, ErrUnsafeDelete) {
+			fmt.Fprintf(os.Stderr, "%s Skipped deleting SHA256 file: %v\n", logPrefix, err)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "%s Failed to delete SHA256 file %s: %v\n", logPrefix, pair.SHA256File, err)
+			// Continue anyway - data file was moved successfully
+		}
+	}
+
+	// Transform inputs (e.g. a bsdiff patch) and the sidecar's signature have been used, remove them too
+	for _, input := range f.companionInputs(pair, transforms) {
+		err := DeleteWithinFolder(input, sourceFolder)
+		if errors.Is(err, ErrUnsafeDelete) {
+			fmt.Fprintf(os.Stderr, "%s Skipped deleting transform input: %v\n", logPrefix, err)
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "%s Failed to delete transform input %s: %v\n", logPrefix, input, err)
+		}
+	}
+
+	// Directives have been applied, remove them with the sidecar
+	if directives := pair.Directives; directives != nil {
+		err := SafeDeleteFile(directives.Path, sourceFolder, directives.Size, directives.ModTime)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s Failed to delete directives file: %v\n", logPrefix, err)
+		}
+	}
 }
 
-// handleFailure handles a failed verification
-func (wpm *WorkerPoolManager) handleFailure(workerID int, result VerificationResult) {
-	if wpm.logLevel == "DEBUG" || wpm.logLevel == "WARN" {
+// companionInputs returns the files besides the sidecar a verification read:
+// transform inputs, the sidecar's signature and the chunk manifest
+func (f Features) companionInputs(pair FilePair, transforms []string) []string {
+	inputs := transformInputs(transforms, pair.DataFilePath)
+	if f.Signatures != nil && pair.SHA256Path != "" {
+		inputs = append(inputs, f.Signatures.SignaturePath(pair.SHA256Path))
+	}
+	if f.ChunkManifests != nil {
+		inputs = append(inputs, f.ChunkManifests.ManifestPath(pair.DataFilePath))
+	}
+	return inputs
+}
+
+// handleFailure handles a failed verification and returns the outcome
+func (wpm *WorkerPoolManager) handleFailure(workerID int, result VerificationResult) string {
+	if wpm.logLevel.Get() == "DEBUG" || wpm.logLevel.Get() == "WARN" {
 		fmt.Fprintf(os.Stderr, "[Worker %d] ✗ FAILURE: %s - %s\n",
 			workerID, result.Job.FilePair.DataFile, result.ErrorMessage)
 		fmt.Fprintf(os.Stderr, "[Worker %d]   Expected: %s\n", workerID, result.ExpectedHash)
 		fmt.Fprintf(os.Stderr, "[Worker %d]   Computed: %s\n", workerID, result.ComputedHash)
 	}
 
-	// Check if retry deadline has been exceeded
-	if time.Now().After(result.Job.RetryDeadline) {
-		// Retry timeout exceeded, move to DLQ
-		if wpm.logLevel == "INFO" || wpm.logLevel == "DEBUG" {
-			fmt.Printf("[Worker %d] Retry timeout exceeded for %s, moving to DLQ\n",
-				workerID, result.Job.FilePair.DataFile)
+	// Check if retry deadline has been exceeded (or retrying cannot help)
+	if failedFolder := failureDestination(result, clockNow()); failedFolder != "" {
+		if wpm.logLevel.Get() == "INFO" || wpm.logLevel.Get() == "DEBUG" {
+			if result.Permanent {
+				fmt.Printf("[Worker %d] Non-retriable failure for %s (%s), moving to %s\n",
+					workerID, result.Job.FilePair.DataFile, result.ErrorMessage, failedFolder)
+			} else {
+				fmt.Printf("[Worker %d] Retry timeout exceeded for %s, moving to DLQ\n",
+					workerID, result.Job.FilePair.DataFile)
+			}
 		}
 
-		if err := MoveToDLQ(result.Job.FilePair.DataFilePath, result.Job.FilePair.SHA256Path, wpm.dlqFolder); err != nil {
+		_, moveSpan := startPhaseSpan(result.Job.TraceContext, "move")
+		dlqPath, err := MoveToDLQ(result.Job.FilePair.DataFilePath, result.Job.FilePair.SHA256Path, failedFolder, wpm.onCollision)
+		endSpan(moveSpan, err)
+		if isReadOnlyFS(err) {
+			wpm.handleReadOnly(workerID, result, failedFolder, err)
+			return OutcomeReadOnly
+		}
+		recordOutcome(result.Job.TraceContext, OutcomeDLQ, errors.New(result.ErrorMessage))
+		if errors.Is(err, ErrCollisionSkipped) {
+			// Collision policy forbids replacing the existing files, leave source in place
+			if wpm.logLevel.Get() == "WARN" || wpm.logLevel.Get() == "DEBUG" {
+				fmt.Fprintf(os.Stderr, "[Worker %d] Skipped DLQ move for %s: %v\n",
+					workerID, result.Job.FilePair.DataFile, err)
+			}
+			wpm.fileTracker.MarkSkipped(result.Job.FilePair.Key)
+			wpm.statsTracker.IncrementFailure(result.Duration, result.Job.FilePair.DataSize)
+			return OutcomeSkipped
+		}
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "[Worker %d] Failed to move %s to DLQ: %v\n",
 				workerID, result.Job.FilePair.DataFile, err)
 		} else {
-			if wpm.logLevel == "DEBUG" {
+			if wpm.logLevel.Get() == "DEBUG" {
 				fmt.Printf("[Worker %d] Moved to DLQ: %s\n", workerID, result.Job.FilePair.DataFile)
 			}
+
+			// Keep the directives with the data file for investigation
+			if directives := result.Job.FilePair.Directives; directives != nil {
+				if _, err := MoveToFolder(directives.Path, failedFolder, wpm.onCollision); err != nil {
+					fmt.Fprintf(os.Stderr, "[Worker %d] Failed to move directives file to DLQ: %v\n", workerID, err)
+				}
+			}
+
+			// Keep transform inputs (e.g. a bsdiff patch) and the signature with the data file too
+			for _, input := range wpm.features.companionInputs(result.Job.FilePair, result.Job.Transforms) {
+				if !FileExists(input) {
+					continue
+				}
+				if _, err := MoveToFolder(input, failedFolder, wpm.onCollision); err != nil {
+					fmt.Fprintf(os.Stderr, "[Worker %d] Failed to move %s to DLQ: %v\n", workerID, input, err)
+				}
+			}
+
+			// Tell operators re-pairing the files (or checking the signature or the content) what was wrong
+			if result.Mispaired || result.Untrusted || result.Denied || result.BadChunks {
+				if err := WriteReasonFile(failedFolder, result.Job.FilePair.DataFile, result.ErrorMessage); err != nil {
+					fmt.Fprintf(os.Stderr, "[Worker %d] Failed to write reason file: %v\n", workerID, err)
+				}
+			}
+			if wpm.resultFiles && dlqPath != "" {
+				if err := WriteResultFile(dlqPath, ResultFailed, result); err != nil {
+					fmt.Fprintf(os.Stderr, "[Worker %d] Failed to write result file: %v\n", workerID, err)
+				}
+			}
+		}
+		if result.Mispaired {
+			wpm.statsTracker.IncrementMispaired()
 		}
 
 		// Remove from tracker
-		wpm.fileTracker.Remove(result.Job.FilePair.DataFile)
+		wpm.fileTracker.Remove(result.Job.FilePair.Key)
 
 		// Update statistics
-		wpm.statsTracker.IncrementFailure(result.Duration)
-	} else {
-		// Retry deadline not exceeded yet, keep in tracker for retry
-		if wpm.logLevel == "DEBUG" {
-			timeRemaining := time.Until(result.Job.RetryDeadline)
-			fmt.Printf("[Worker %d] Will retry %s (%.0f seconds remaining)\n",
-				workerID, result.Job.FilePair.DataFile, timeRemaining.Seconds())
+		wpm.statsTracker.IncrementFailure(result.Duration, result.Job.FilePair.DataSize)
+		if wpm.batches != nil {
+			wpm.batches.RecordResult(result.Job.FilePair, false)
 		}
-		// File remains in tracker, will be picked up in next scan
+		return OutcomeDLQ
+	}
+
+	// Retry deadline not exceeded yet, keep in tracker for retry
+	if wpm.logLevel.Get() == "DEBUG" {
+		timeRemaining := time.Until(result.Job.RetryDeadline)
+		fmt.Printf("[Worker %d] Will retry %s (%.0f seconds remaining)\n",
+			workerID, result.Job.FilePair.DataFile, timeRemaining.Seconds())
+	}
+	// File remains in tracker, will be resubmitted after its next retry time
+	recordOutcome(result.Job.TraceContext, OutcomeRetry, errors.New(result.ErrorMessage))
+	wpm.fileTracker.RecordFailure(result.Job.FilePair.Key, result.ErrorMessage, nextRetryTime(result.Job))
+	return OutcomeRetry
+}
+
+// failureDestination decides where a failed result goes: "" to retry it, else the folder
+// to move it to (its failure folder or the DLQ) because retrying cannot help or time is up
+// It only looks at the result, so the decision can be checked without real files
+func failureDestination(result VerificationResult, now time.Time) string {
+	if !result.Permanent && !now.After(result.Job.RetryDeadline) {
+		return ""
+	}
+	if result.FailedFolder != "" {
+		return result.FailedFolder
+	}
+	return result.Folders.DLQ
+}
+
+// handleReadOnly handles a move that failed because the destination mount is read-only
+// The file stays in the source and pending without counting an attempt; no more
+// jobs are processed until the coordinator finds the folder writable again
+func (wpm *WorkerPoolManager) handleReadOnly(workerID int, result VerificationResult, folder string, err error) {
+	if wpm.destinations.MarkReadOnly(folder) {
+		fmt.Fprintf(os.Stderr, "[Worker %d] CRITICAL: %s is on a read-only file system, pausing verification until it is writable again: %v\n",
+			workerID, folder, err)
+	}
+
+	wpm.fileTracker.ClearInFlight(result.Job.FilePair.Key)
+	recordOutcome(result.Job.TraceContext, OutcomeReadOnly, err)
+}
+
+// nextRetryTime returns when a failed job should be resubmitted
+// Retries follow the coordinator cadence but never go past the retry deadline
+func nextRetryTime(job VerificationJob) time.Time {
+	nextRetry := clockNow().Add(coordinatorInterval)
+	if nextRetry.After(job.RetryDeadline) {
+		return job.RetryDeadline
 	}
+	return nextRetry
 }
 
 // GetQueueLength returns the current number of jobs in the queue
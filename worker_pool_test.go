@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingLogger keeps the verification entries logged by the pool
+type recordingLogger struct {
+	mutex   sync.Mutex
+	entries []CSVLogEntry
+}
+
+func (l *recordingLogger) LogVerification(entry CSVLogEntry) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func (l *recordingLogger) LogStats(entry StatsEntry) error { return nil }
+func (l *recordingLogger) Close() error                    { return nil }
+
+// testPool is a worker pool over a temporary source folder and destination folders
+type testPool struct {
+	*WorkerPoolManager
+	source  string
+	folders DestinationFolders
+	logger  *recordingLogger
+}
+
+// newTestPool creates a one-worker pool, not started (worker 1 is registered), delivering from a temporary source
+// to temporary verified, DLQ, quarantine, empty sidecar and mispaired folders
+func newTestPool(t *testing.T) *testPool {
+	t.Helper()
+	root := t.TempDir()
+	source := filepath.Join(root, "source")
+	if err := os.Mkdir(source, 0755); err != nil {
+		t.Fatal(err)
+	}
+	destination := DestinationConfig{
+		VerifiedFolder:     filepath.Join(root, "verified"),
+		DlqFolder:          filepath.Join(root, "dlq"),
+		QuarantineFolder:   filepath.Join(root, "quarantine"),
+		EmptySidecarFolder: filepath.Join(root, "empty"),
+		MispairedFolder:    filepath.Join(root, "mispaired"),
+	}
+	destinations := NewDestinations(SourceConfig{Folder: source}, destination)
+	folders := destinations.Get()
+	for _, folder := range []string{folders.Verified, folders.DLQ, folders.Quarantine, folders.EmptySidecar, folders.Mispaired} {
+		if err := os.Mkdir(folder, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger := &recordingLogger{}
+	pool := NewWorkerPoolManager(WorkerPoolOptions{
+		QueueSize:        10,
+		Workers:          1,
+		ResultLogger:     logger,
+		StatsTracker:     NewStatsTracker(),
+		FileTracker:      NewFileTracker(time.Hour, 0, []string{".sha256"}, false, false),
+		Integrity:        unknownIntegrity{},
+		Progress:         NewProgressRegistry(),
+		HashLimiter:      NewHashLimiter(0),
+		SourceFolder:     source,
+		SourcePathBase:   source,
+		Destinations:     destinations,
+		RemoveFromSource: true,
+		OnCollision:      CollisionRename,
+		LogLevel:         NewLogLevel("ERROR"),
+	})
+	// Worker 1 exists for processJob calls made directly by the tests
+	pool.workers[1] = &workerState{quit: make(chan struct{})}
+	return &testPool{WorkerPoolManager: pool, source: source, folders: folders, logger: logger}
+}
+
+// job scans the source folder and returns the job of the tracked data file name
+func (p *testPool) job(t *testing.T, name string) VerificationJob {
+	t.Helper()
+	if err := newTestScanner(p.source, p.fileTracker).scan(); err != nil {
+		t.Fatal(err)
+	}
+	pair, ok := p.fileTracker.GetFilePair(p.fileTracker.keyOf(filepath.Join(p.source, name)))
+	if !ok {
+		t.Fatalf("%s not tracked", name)
+	}
+	return VerificationJob{
+		FilePair:      *pair,
+		RetryDeadline: time.Now().Add(time.Hour),
+		BufferSize:    4096,
+		HashEncoding:  HashEncodingAuto,
+		SubmittedAt:   time.Now(),
+		TraceContext:  context.Background(),
+	}
+}
+
+func TestEmptySidecarIsAHardFailure(t *testing.T) {
+	for _, content := range []string{"", "  \n\t\n"} {
+		if _, err := ParseSHA256Content([]byte(content), HashEncodingAuto, AlgorithmSHA256); !errors.Is(err, ErrEmptySidecar) {
+			t.Errorf("sidecar %q: err = %v, want ErrEmptySidecar", content, err)
+		}
+
+		pool := newTestPool(t)
+		writeTestFile(t, pool.source, "data.zip", "data")
+		writeTestFile(t, pool.source, "data.zip.sha256", content)
+
+		// The first attempt goes to the empty sidecar folder, the deadline is an hour away
+		result, outcome := pool.processJob(1, pool.job(t, "data.zip"))
+		if outcome != OutcomeDLQ || !result.Permanent {
+			t.Fatalf("sidecar %q: outcome %s (permanent %v), want a permanent failure", content, outcome, result.Permanent)
+		}
+		if !FileExists(filepath.Join(pool.folders.EmptySidecar, "data.zip")) ||
+			!FileExists(filepath.Join(pool.folders.EmptySidecar, "data.zip.sha256")) {
+			t.Errorf("sidecar %q: pair not moved to the empty sidecar folder", content)
+		}
+		if FileExists(filepath.Join(pool.folders.DLQ, "data.zip")) {
+			t.Errorf("sidecar %q: DLQ used instead of the empty sidecar folder", content)
+		}
+		if pool.fileTracker.GetPendingCount() != 0 {
+			t.Errorf("sidecar %q: pair still tracked for a retry", content)
+		}
+	}
+}
+
+func TestWrongAlgorithmSidecar(t *testing.T) {
+	for _, detect := range []bool{false, true} {
+		pool := newTestPool(t)
+		writeTestFile(t, pool.source, "data.zip", "data")
+		writeTestFile(t, pool.source, "data.zip.sha256", dataMD5+"  data.zip\n")
+		job := pool.job(t, "data.zip")
+		job.DetectAlgorithm = detect
+
+		result, outcome := pool.processJob(1, job)
+		if detect {
+			if outcome != OutcomeVerified || result.ComputedHash != dataMD5 {
+				t.Errorf("detected: outcome %s, hash %s, want verified with MD5: %s", outcome, result.ComputedHash, result.ErrorMessage)
+			}
+			continue
+		}
+		// Retrying can't help: straight to the DLQ
+		if outcome != OutcomeDLQ || !result.Permanent || !strings.Contains(result.ErrorMessage, ErrWrongAlgorithm.Error()) {
+			t.Errorf("not detected: outcome %s (permanent %v, %s), want a permanent wrong algorithm failure", outcome, result.Permanent, result.ErrorMessage)
+		}
+		if !FileExists(filepath.Join(pool.folders.DLQ, "data.zip")) {
+			t.Error("not detected: pair not moved to the DLQ")
+		}
+	}
+}
+
+func TestFailureDestination(t *testing.T) {
+	deadline := time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC)
+	folders := DestinationFolders{DLQ: "/dlq", EmptySidecar: "/empty"}
+	tests := []struct {
+		name         string
+		permanent    bool
+		failedFolder string
+		now          time.Time
+		want         string
+	}{
+		{"retriable before the deadline", false, "", deadline.Add(-time.Second), ""},
+		{"retriable at the deadline", false, "", deadline, ""},
+		{"retriable after the deadline", false, "", deadline.Add(time.Second), "/dlq"},
+		{"retriable with a failure folder after the deadline", false, "/quarantine", deadline.Add(time.Second), "/quarantine"},
+		{"permanent before the deadline", true, "", deadline.Add(-time.Hour), "/dlq"},
+		{"permanent with a failure folder", true, "/empty", deadline.Add(-time.Hour), "/empty"},
+	}
+	for _, test := range tests {
+		result := VerificationResult{
+			Job:          VerificationJob{RetryDeadline: deadline},
+			Permanent:    test.permanent,
+			FailedFolder: test.failedFolder,
+			Folders:      folders,
+		}
+		if got := failureDestination(result, test.now); got != test.want {
+			t.Errorf("%s: destination %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNextRetryTime(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC)
+	saved := clockNow
+	t.Cleanup(func() { clockNow = saved })
+	clockNow = func() time.Time { return now }
+
+	if got := nextRetryTime(VerificationJob{RetryDeadline: now.Add(time.Hour)}); !got.Equal(now.Add(coordinatorInterval)) {
+		t.Errorf("next retry %s, want one coordinator interval after %s", got, now)
+	}
+	deadline := now.Add(coordinatorInterval / 2)
+	if got := nextRetryTime(VerificationJob{RetryDeadline: deadline}); !got.Equal(deadline) {
+		t.Errorf("next retry %s, want the deadline %s", got, deadline)
+	}
+}
+
+func TestDeliveryAction(t *testing.T) {
+	for _, test := range []struct {
+		level int
+		want  string
+	}{{0, ActionMoved}, {6, ActionCompressed}} {
+		pool := newTestPool(t)
+		writeTestPair(t, pool.source, "data.zip")
+		job := pool.job(t, "data.zip")
+		job.CompressLevel = test.level
+		if _, outcome := pool.processJob(1, job); outcome != OutcomeVerified {
+			t.Fatalf("level %d: outcome %s", test.level, outcome)
+		}
+		if len(pool.logger.entries) != 1 || pool.logger.entries[0].Action != test.want {
+			t.Errorf("level %d: logged %+v, want action %q", test.level, pool.logger.entries, test.want)
+		}
+	}
+}
+
+func TestVerdictCacheStillHashes(t *testing.T) {
+	pool := newTestPool(t)
+	pool.verdicts = NewVerdictCache(10)
+	writeTestPair(t, pool.source, "first.zip")
+	if _, outcome := pool.processJob(1, pool.job(t, "first.zip")); outcome != OutcomeVerified {
+		t.Fatalf("first file: outcome %s", outcome)
+	}
+
+	// Same size and sidecar hash as content verified earlier, but different content
+	writeTestFile(t, pool.source, "tampered.zip", "dada")
+	writeTestFile(t, pool.source, "tampered.zip.sha256", dataSHA256)
+	result, outcome := pool.processJob(1, pool.job(t, "tampered.zip"))
+	if outcome == OutcomeVerified || !strings.Contains(result.ErrorMessage, ErrHashMismatch.Error()) {
+		t.Errorf("tampered file: outcome %s (%s), want a hash mismatch", outcome, result.ErrorMessage)
+	}
+
+	// The same content passes, with its own hash
+	writeTestPair(t, pool.source, "copy.zip")
+	result, outcome = pool.processJob(1, pool.job(t, "copy.zip"))
+	if outcome != OutcomeVerified || result.ComputedHash != dataSHA256 {
+		t.Errorf("identical file: outcome %s, hash %s", outcome, result.ComputedHash)
+	}
+}
+
+// panickingProvider is an expected hash source that panics
+type panickingProvider struct{}
+
+func (panickingProvider) ExpectedHash(pair FilePair) (string, error) {
+	panic("provider bug")
+}
+
+func (panickingProvider) Close() error { return nil }
+
+func TestPanickingJobKeepsPoolWorking(t *testing.T) {
+	pool := newTestPool(t)
+	pool.hashProvider = panickingProvider{}
+	pool.hashLimiter = NewHashLimiter(1)
+	writeTestPair(t, pool.source, "panics.zip")
+	writeTestPair(t, pool.source, "good.zip")
+	panicking := pool.job(t, "panics.zip")
+	panicking.FilePair.ExternalHash = true
+
+	// The panic is recovered and leaves nothing of the job behind
+	pool.runJob(1, panicking)
+	if hashing := pool.workers[1].hashing; hashing != nil {
+		t.Error("worker still registered as hashing with the stall watchdog")
+	}
+	if inUse := pool.hashLimiter.inUse.Load(); inUse != 0 {
+		t.Errorf("%d hashing slots still in use", inUse)
+	}
+	if pair, _ := pool.fileTracker.GetFilePair(panicking.FilePair.Key); pair == nil || pair.RetryCount != 1 || !strings.Contains(pair.LastError, "provider bug") {
+		t.Errorf("pair %+v, want a failed attempt recording the panic", pair)
+	}
+
+	// Running workers go on with the next jobs
+	delete(pool.workers, 1)
+	pool.Start()
+	pool.SubmitJob(panicking)
+	pool.SubmitJob(pool.job(t, "good.zip"))
+	pool.Stop()
+
+	if !FileExists(filepath.Join(pool.folders.Verified, "good.zip")) {
+		t.Error("job after the panic not processed")
+	}
+	if panics := pool.statsTracker.GetStatistics().WorkerPanics; panics != 2 {
+		t.Errorf("%d panics counted, want 2", panics)
+	}
+}
+
+func TestVerdictCacheKeepsDenyingIdenticalFiles(t *testing.T) {
+	list := writeTestFile(t, t.TempDir(), "deny-list.txt", dataSHA256+"\n")
+	loaded, err := LoadDenyList(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := newTestPool(t)
+	pool.verdicts = NewVerdictCache(10)
+	pool.features.DenyList = loaded
+
+	// A denied file is never a verdict to reuse, its copy is denied too
+	for _, name := range []string{"first.zip", "copy.zip"} {
+		writeTestPair(t, pool.source, name)
+		result, outcome := pool.processJob(1, pool.job(t, name))
+		if outcome == OutcomeVerified || !result.Denied {
+			t.Errorf("%s: outcome %s, denied %v, want it denied", name, outcome, result.Denied)
+		}
+		if !FileExists(filepath.Join(pool.folders.Quarantine, name)) || FileExists(filepath.Join(pool.folders.Verified, name)) {
+			t.Errorf("%s not quarantined", name)
+		}
+	}
+}
+
+func TestDenyListMatchesSHA256WhateverTheAlgorithm(t *testing.T) {
+	list := writeTestFile(t, t.TempDir(), "deny-list.txt", dataSHA256[:16]+" test sample\n")
+	loaded, err := LoadDenyList(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		algorithm string
+		sidecar   string
+	}{
+		{"sha256", AlgorithmSHA256, dataSHA256},
+		{"md5", AlgorithmMD5, dataMD5},
+		{"md5 mismatch", AlgorithmMD5, strings.Repeat("0", 32)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pool := newTestPool(t)
+			pool.features.DenyList = loaded
+			writeTestFile(t, pool.source, "data.zip", "data")
+			writeTestFile(t, pool.source, "data.zip.sha256", test.sidecar)
+			job := pool.job(t, "data.zip")
+			job.FilePair.SidecarAlgorithm = test.algorithm
+
+			result, _ := pool.processJob(1, job)
+			if !result.Denied || !strings.Contains(result.ErrorMessage, ErrDeniedHash.Error()) {
+				t.Fatalf("denied %v (%s), want the content denied", result.Denied, result.ErrorMessage)
+			}
+			if !FileExists(filepath.Join(pool.folders.Quarantine, "data.zip")) {
+				t.Error("data file not quarantined")
+			}
+		})
+	}
+}